@@ -0,0 +1,202 @@
+// Package audit writes the append-only, hash-chained audit log. Handlers call
+// Record with before/after snapshots of what they changed; the AuditTrail
+// middleware records every other successful state-changing request so no
+// mutation goes unlogged.
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"peoplesoft/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// chainLockID serialises appends across backend instances (pg advisory lock)
+// so every event links to the one inserted just before it.
+const chainLockID = 7207002
+
+// recordedKey marks a request whose handler already wrote an entity event.
+const recordedKey = "audit.recorded"
+
+// immutableSQL makes audit_events append-only at the database level.
+const immutableSQL = `
+	CREATE OR REPLACE FUNCTION audit_events_immutable() RETURNS trigger AS $$
+	BEGIN
+		RAISE EXCEPTION 'audit_events is append-only';
+	END;
+	$$ LANGUAGE plpgsql;
+
+	DROP TRIGGER IF EXISTS audit_events_no_update ON audit_events;
+	CREATE TRIGGER audit_events_no_update BEFORE UPDATE OR DELETE ON audit_events
+		FOR EACH ROW EXECUTE FUNCTION audit_events_immutable();
+
+	DROP TRIGGER IF EXISTS audit_events_no_truncate ON audit_events;
+	CREATE TRIGGER audit_events_no_truncate BEFORE TRUNCATE ON audit_events
+		FOR EACH STATEMENT EXECUTE FUNCTION audit_events_immutable();`
+
+// Init installs the triggers that reject UPDATE, DELETE and TRUNCATE on the
+// audit table. Run it after AutoMigrate.
+func Init() error {
+	return config.DB.Exec(immutableSQL).Error
+}
+
+// Record appends an entity event for the current request. before and after are
+// snapshots of the entity (structs or maps; nil for create/delete); secrets are
+// redacted and the changed fields are stored alongside them. Failures are
+// logged, never surfaced: the change itself has already been committed.
+func Record(c *gin.Context, action, entityType string, entityID any, before, after any) {
+	b, a := snapshot(before), snapshot(after)
+	ev := fromRequest(c)
+	ev.Action = action
+	ev.EntityType = entityType
+	if entityID != nil {
+		ev.EntityID = fmt.Sprint(entityID)
+	}
+	ev.Before = encode(b)
+	ev.After = encode(a)
+	ev.Changes = encode(diff(b, a))
+	ev.Status = http.StatusOK
+
+	c.Set(recordedKey, true)
+	if err := appendEvent(ev); err != nil {
+		log.Printf("audit: failed to record %s %s/%s: %v", action, entityType, ev.EntityID, err)
+	}
+}
+
+// RecordRequest appends a request-level event unless the handler already
+// recorded one.
+func RecordRequest(c *gin.Context) {
+	if c.GetBool(recordedKey) {
+		return
+	}
+	ev := fromRequest(c)
+	ev.Action = "http." + strings.ToLower(c.Request.Method)
+	ev.Status = c.Writer.Status()
+	if err := appendEvent(ev); err != nil {
+		log.Printf("audit: failed to record %s %s: %v", ev.Method, ev.Path, err)
+	}
+}
+
+// fromRequest fills the actor and request fields from the gin context.
+func fromRequest(c *gin.Context) *models.AuditEvent {
+	ev := &models.AuditEvent{
+		ActorEmail:       c.GetString("email"),
+		ServiceAccountID: optionalID(c, "serviceAccountID"),
+		SCIMTokenID:      optionalID(c, "scimTokenID"),
+		RequestID:        c.GetString("requestID"),
+		Method:           c.Request.Method,
+		Path:             utils.Truncate(c.Request.URL.Path, 255),
+		IP:               c.ClientIP(),
+		UserAgent:        utils.Truncate(c.Request.UserAgent(), 255),
+	}
+	if actor := optionalID(c, "actorID"); actor != nil {
+		// Impersonating: the HR user is the actor, acting on behalf of the subject
+		ev.ActorID = actor
+		ev.OnBehalfOfID = optionalID(c, "userID")
+		ev.ActorEmail = c.GetString("actorEmail")
+	} else {
+		ev.ActorID = optionalID(c, "userID")
+	}
+	return ev
+}
+
+func optionalID(c *gin.Context, key string) *uint {
+	if id := c.GetUint(key); id != 0 {
+		return &id
+	}
+	return nil
+}
+
+// appendEvent links the event to the current head of the chain and inserts it.
+func appendEvent(ev *models.AuditEvent) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", chainLockID).Error; err != nil {
+			return err
+		}
+		var prev string
+		if err := tx.Model(&models.AuditEvent{}).Select("hash").Order("id desc").Limit(1).Scan(&prev).Error; err != nil {
+			return err
+		}
+		// Postgres keeps microseconds; hash exactly what will be read back
+		ev.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+		ev.PrevHash = prev
+		ev.Hash = Hash(ev)
+		return tx.Create(ev).Error
+	})
+}
+
+// Hash is the chain hash of an event: SHA-256 over PrevHash and every other
+// stored field in a fixed order.
+func Hash(ev *models.AuditEvent) string {
+	fields := []any{
+		ev.PrevHash,
+		ev.CreatedAt.UTC().Format(time.RFC3339Nano),
+		ev.ActorID, ev.ActorEmail, ev.OnBehalfOfID, ev.ServiceAccountID, ev.SCIMTokenID,
+		ev.Action, ev.EntityType, ev.EntityID,
+		string(ev.Before), string(ev.After), string(ev.Changes),
+		ev.RequestID, ev.Method, ev.Path, ev.Status, ev.IP, ev.UserAgent,
+	}
+	buf, _ := json.Marshal(fields)
+	sum := sha256.Sum256(buf)
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifyResult reports the outcome of walking the chain.
+type VerifyResult struct {
+	Checked  int   `json:"checked"`
+	Valid    bool  `json:"valid"`
+	BrokenAt *uint `json:"broken_at,omitempty"` // first event whose hash or link does not match
+}
+
+// Verify recomputes every hash in id order and checks each link to the
+// previous event.
+func Verify() (VerifyResult, error) {
+	v := verifier{res: VerifyResult{Valid: true}}
+	var batch []models.AuditEvent
+	err := config.DB.Order("id asc").FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for i := range batch {
+			if !v.next(&batch[i]) {
+				return errStop
+			}
+		}
+		return nil
+	}).Error
+	if err != nil && !errors.Is(err, errStop) {
+		return v.res, err
+	}
+	return v.res, nil
+}
+
+// verifier walks the chain one event at a time, in id order.
+type verifier struct {
+	res  VerifyResult
+	prev string
+}
+
+// next checks an event against its own hash and the one before it, and
+// reports whether the chain is still intact.
+func (v *verifier) next(ev *models.AuditEvent) bool {
+	v.res.Checked++
+	if ev.PrevHash != v.prev || Hash(ev) != ev.Hash {
+		id := ev.ID
+		v.res.Valid = false
+		v.res.BrokenAt = &id
+		return false
+	}
+	v.prev = ev.Hash
+	return true
+}
+
+var errStop = errors.New("stop")
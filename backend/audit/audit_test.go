@@ -0,0 +1,136 @@
+package audit
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"peoplesoft/models"
+)
+
+// chain builds n linked events the way appendEvent does.
+func chain(n int) []models.AuditEvent {
+	events := make([]models.AuditEvent, n)
+	prev := ""
+	for i := range events {
+		actor := uint(7)
+		ev := &events[i]
+		ev.ID = uint(i + 1)
+		ev.CreatedAt = time.Date(2026, 1, 1, 9, 0, i, 1000, time.UTC)
+		ev.ActorID, ev.ActorEmail = &actor, "hr@example.com"
+		ev.Action, ev.EntityType, ev.EntityID = "leave.approve", "leave", "42"
+		ev.After = models.JSONText(`{"status":"approved"}`)
+		ev.Method, ev.Path, ev.Status = "POST", "/api/leaves/42/approve", 200
+		ev.PrevHash = prev
+		ev.Hash = Hash(ev)
+		prev = ev.Hash
+	}
+	return events
+}
+
+func verify(events []models.AuditEvent) VerifyResult {
+	v := verifier{res: VerifyResult{Valid: true}}
+	for i := range events {
+		if !v.next(&events[i]) {
+			break
+		}
+	}
+	return v.res
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func([]models.AuditEvent) []models.AuditEvent
+		broken uint // 0: chain intact
+	}{
+		{"untouched", func(e []models.AuditEvent) []models.AuditEvent { return e }, 0},
+		{"edited field", func(e []models.AuditEvent) []models.AuditEvent {
+			e[1].After = models.JSONText(`{"status":"rejected"}`)
+			return e
+		}, 2},
+		{"changed actor", func(e []models.AuditEvent) []models.AuditEvent {
+			other := uint(8)
+			e[2].ActorID = &other
+			return e
+		}, 3},
+		{"backdated", func(e []models.AuditEvent) []models.AuditEvent {
+			e[0].CreatedAt = e[0].CreatedAt.Add(-time.Hour)
+			return e
+		}, 1},
+		{"edited and rehashed", func(e []models.AuditEvent) []models.AuditEvent {
+			e[1].Status = 500
+			e[1].Hash = Hash(&e[1])
+			return e
+		}, 3},
+		{"deleted event", func(e []models.AuditEvent) []models.AuditEvent {
+			return append(e[:1:1], e[2:]...)
+		}, 3},
+		{"swapped events", func(e []models.AuditEvent) []models.AuditEvent {
+			e[1], e[2] = e[2], e[1]
+			return e
+		}, 3},
+		{"truncated head", func(e []models.AuditEvent) []models.AuditEvent { return e[1:] }, 2},
+	}
+	for _, tt := range tests {
+		res := verify(tt.tamper(chain(4)))
+		switch {
+		case tt.broken == 0 && (!res.Valid || res.Checked != 4):
+			t.Errorf("%s: got %+v, want a valid chain of 4", tt.name, res)
+		case tt.broken != 0 && (res.Valid || res.BrokenAt == nil || *res.BrokenAt != tt.broken):
+			t.Errorf("%s: got %+v, want broken at %d", tt.name, res, tt.broken)
+		}
+	}
+}
+
+func TestHashCoversEveryField(t *testing.T) {
+	base := chain(1)[0]
+	onBehalf := uint(3)
+	edits := map[string]func(*models.AuditEvent){
+		"prev_hash":       func(e *models.AuditEvent) { e.PrevHash = "x" },
+		"created_at":      func(e *models.AuditEvent) { e.CreatedAt = e.CreatedAt.Add(time.Microsecond) },
+		"actor_email":     func(e *models.AuditEvent) { e.ActorEmail = "x@example.com" },
+		"on_behalf_of_id": func(e *models.AuditEvent) { e.OnBehalfOfID = &onBehalf },
+		"action":          func(e *models.AuditEvent) { e.Action = "leave.reject" },
+		"entity_id":       func(e *models.AuditEvent) { e.EntityID = "43" },
+		"before":          func(e *models.AuditEvent) { e.Before = models.JSONText(`{}`) },
+		"changes":         func(e *models.AuditEvent) { e.Changes = models.JSONText(`{}`) },
+		"path":            func(e *models.AuditEvent) { e.Path = "/api/leaves/43/approve" },
+		"ip":              func(e *models.AuditEvent) { e.IP = "10.0.0.1" },
+		"user_agent":      func(e *models.AuditEvent) { e.UserAgent = "curl" },
+	}
+	for name, edit := range edits {
+		ev := base
+		edit(&ev)
+		if Hash(&ev) == base.Hash {
+			t.Errorf("%s is not covered by the hash", name)
+		}
+	}
+}
+
+func TestSnapshotAndDiff(t *testing.T) {
+	type user struct {
+		Name         string `json:"name"`
+		PasswordHash string `json:"password_hash"`
+		MFASecret    string `json:"mfa_secret"`
+		Role         string `json:"role"`
+	}
+	before := snapshot(user{Name: "Ada", PasswordHash: "h", MFASecret: "s", Role: "employee"})
+	after := snapshot(&user{Name: "Ada", PasswordHash: "h2", Role: "hr"})
+	if want := map[string]any{"name": "Ada", "role": "employee"}; !reflect.DeepEqual(before, want) {
+		t.Errorf("snapshot = %v, want %v", before, want)
+	}
+	if got, want := diff(before, after), map[string]change{"role": {From: "employee", To: "hr"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("diff = %v, want %v", got, want)
+	}
+	if got := diff(nil, after); len(got) != 2 || got["name"].To != "Ada" {
+		t.Errorf("create diff = %v", got)
+	}
+	var none *user
+	if snapshot(none) != nil || snapshot(nil) != nil || snapshot("text") != nil {
+		t.Error("non-objects should have no snapshot")
+	}
+	if encode(diff(nil, nil)) != "" {
+		t.Error("empty diff should encode as empty text")
+	}
+}
@@ -0,0 +1,80 @@
+package audit
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"peoplesoft/models"
+)
+
+// sensitive field names are dropped from snapshots; matching is on the
+// lower-cased JSON key.
+var sensitive = []string{"password", "secret", "hash", "token", "recovery"}
+
+// snapshot turns a struct or map into a flat JSON object with secrets removed.
+// Values that are not JSON objects are returned as nil.
+func snapshot(v any) map[string]any {
+	if v == nil {
+		return nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Pointer && rv.IsNil() {
+		return nil
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var m map[string]any
+	if json.Unmarshal(buf, &m) != nil {
+		return nil
+	}
+	for k := range m {
+		lk := strings.ToLower(k)
+		for _, s := range sensitive {
+			if strings.Contains(lk, s) {
+				delete(m, k)
+				break
+			}
+		}
+	}
+	return m
+}
+
+// change is one modified field in Changes.
+type change struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// diff lists the fields whose values differ between two snapshots. A create
+// or delete (one side nil) lists every field of the other side.
+func diff(before, after map[string]any) map[string]change {
+	if before == nil && after == nil {
+		return nil
+	}
+	out := map[string]change{}
+	for k, b := range before {
+		if a, ok := after[k]; !ok || !reflect.DeepEqual(a, b) {
+			out[k] = change{From: b, To: after[k]}
+		}
+	}
+	for k, a := range after {
+		if _, ok := before[k]; !ok {
+			out[k] = change{To: a}
+		}
+	}
+	return out
+}
+
+// encode stores a value as JSON text; nil and empty values become "".
+func encode[T any](v map[string]T) models.JSONText {
+	if len(v) == 0 {
+		return ""
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return models.JSONText(buf)
+}
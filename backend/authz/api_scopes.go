@@ -0,0 +1,71 @@
+package authz
+
+// API key scopes. A scope grants a fixed set of permissions and is only honoured
+// on the routes it lists; every other route rejects API keys, so adding a
+// handler never silently widens what existing keys can reach.
+const (
+	ScopeEmployeesRead = "employees:read"
+	ScopeLeavesRead    = "leaves:read"
+)
+
+// APIScope describes one API key scope.
+type APIScope struct {
+	Key         string   `json:"key"`
+	Description string   `json:"description"`
+	Permissions []string `json:"-"`
+	Routes      []string `json:"routes"` // "METHOD /full/path" as registered with gin
+}
+
+// APIScopes is the catalog of scopes that can be granted to service account keys.
+var APIScopes = []APIScope{
+	{
+		Key:         ScopeEmployeesRead,
+		Description: "Read the employee directory and profiles",
+		Permissions: []string{EmployeeView, ScopeAll},
+		Routes: []string{
+			"GET /api/employees",
+			"GET /api/employees/:id",
+			"GET /api/managers/:managerId/team",
+		},
+	},
+	{
+		Key:         ScopeLeavesRead,
+		Description: "Read approved leaves of all employees, without reasons",
+		Permissions: []string{LeaveViewAll},
+		Routes: []string{
+			"GET /api/leaves/team",
+		},
+	},
+}
+
+// LookupAPIScope returns the scope with this key.
+func LookupAPIScope(key string) (APIScope, bool) {
+	for _, s := range APIScopes {
+		if s.Key == key {
+			return s, true
+		}
+	}
+	return APIScope{}, false
+}
+
+// APIKeyAccess returns the permissions granted by scopes and whether any of
+// them allows the route (method and gin full path).
+func APIKeyAccess(scopes []string, method, route string) (map[string]bool, bool) {
+	perms := map[string]bool{}
+	allowed := false
+	for _, key := range scopes {
+		s, ok := LookupAPIScope(key)
+		if !ok {
+			continue
+		}
+		for _, p := range s.Permissions {
+			perms[p] = true
+		}
+		for _, r := range s.Routes {
+			if r == method+" "+route {
+				allowed = true
+			}
+		}
+	}
+	return perms, allowed
+}
@@ -0,0 +1,161 @@
+// Package authz resolves what the authenticated caller is allowed to do.
+package authz
+
+import (
+	"peoplesoft/config"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Permission keys checked by the API.
+const (
+	EmployeeView      = "employee.view"
+	EmployeeCreate    = "employee.create"
+	EmployeeUpdate    = "employee.update"
+	EmployeeDelete    = "employee.delete"
+	EmployeeImport    = "employee.import" // bulk create people from CSV/XLSX
+	EmployeeExport    = "employee.export"
+	ScopeAll          = "employee.scope_all" // see/act on every employee regardless of hierarchy
+	LeaveApprove      = "leave.approve"
+	LeaveViewAll      = "leave.view_all"
+	GoalAssign        = "goal.assign"          // manager → own team
+	GoalAssignMgr     = "goal.assign_managers" // HR → managers
+	GoalApprove       = "goal.approve"
+	ReviewViewAll     = "review.view_all"
+	PerformanceEdit   = "performance.score"
+	UserDelete        = "user.delete"
+	UserRestore       = "user.restore" // list and restore soft-deleted users and employees
+	UserManageRoles   = "user.manage_roles"
+	UserSecurity      = "user.manage_security" // revoke sessions, unlock, reset MFA
+	SecurityAudit     = "security.audit"       // login attempts and lockouts
+	RBACManage        = "rbac.manage"
+	SigningKeys       = "security.signing_keys" // list and rotate token signing keys
+	SCIMManage        = "scim.manage"           // issue and revoke SCIM provisioning tokens
+	ServiceAccounts   = "service_accounts.manage"
+	Impersonate       = "user.impersonate" // act as another user, read-only
+	AuditView         = "audit.view"       // search the audit log and verify its hash chain
+	DepartmentManage  = "department.manage"
+	LifecycleManage   = "lifecycle.manage"    // status changes, onboarding/offboarding and checklist templates
+	DataPurge         = "data.purge"          // permanently erase deleted records; in no default role
+	CustomFieldManage = "custom_field.manage" // define custom profile fields, read and write HR-only values
+	DocumentManage    = "document.manage"     // employee documents of everyone in scope, expiry report
+	SkillManage       = "skill.manage"        // skills catalog and designation requirements
+	ProfileApprove    = "profile.approve"     // review self-service profile changes; see personal and bank details
+	HolidayManage     = "holiday.manage"      // holiday calendars of locations
+)
+
+// Catalog lists every permission with a description; it is seeded into the permissions table.
+var Catalog = []struct{ Key, Description string }{
+	{EmployeeView, "View the employee directory and profiles"},
+	{EmployeeCreate, "Create employee records"},
+	{EmployeeUpdate, "Edit employee records"},
+	{EmployeeDelete, "Delete employee records"},
+	{EmployeeImport, "Bulk import people and accounts from CSV or XLSX"},
+	{EmployeeExport, "Export the employee directory as CSV or XLSX"},
+	{ScopeAll, "Access every employee regardless of reporting line or department scope"},
+	{LeaveApprove, "Approve or reject leave requests"},
+	{LeaveViewAll, "View leave requests of all employees"},
+	{GoalAssign, "Assign goals to direct reports"},
+	{GoalAssignMgr, "Assign goals to managers"},
+	{GoalApprove, "Approve submitted goals and write reviews"},
+	{ReviewViewAll, "View all performance reviews"},
+	{PerformanceEdit, "Update performance scores"},
+	{UserDelete, "Delete user accounts"},
+	{UserRestore, "View and restore deleted users and employee records"},
+	{UserManageRoles, "Change user roles"},
+	{UserSecurity, "Revoke sessions, unlock accounts and reset MFA"},
+	{SecurityAudit, "View login attempts and lockouts"},
+	{RBACManage, "Manage role definitions"},
+	{SigningKeys, "View and rotate access token signing keys"},
+	{SCIMManage, "Issue and revoke SCIM provisioning tokens"},
+	{ServiceAccounts, "Manage service accounts and their API keys"},
+	{Impersonate, "View the application as another user (read-only, audited)"},
+	{AuditView, "Search the audit log of data changes"},
+	{DepartmentManage, "Create, restructure and delete departments"},
+	{LifecycleManage, "Change employee lifecycle status, run onboarding and offboarding, manage checklists"},
+	{CustomFieldManage, "Define custom employee profile fields and see HR-only values"},
+	{DocumentManage, "Access, edit and delete the documents of every employee in scope and see expiring documents"},
+	{SkillManage, "Maintain the skills catalog and the skill requirements of designations"},
+	{ProfileApprove, "Approve or reject profile change requests and view employees' personal and bank details"},
+	{HolidayManage, "Maintain holiday calendars, their locations, weekend days and holidays"},
+	{DataPurge, "Permanently erase deleted users and employee records with their history"},
+}
+
+// DefaultRoles are the system roles seeded on first start.
+var DefaultRoles = map[string][]string{
+	"employee": {EmployeeView},
+	"manager":  {EmployeeView, EmployeeUpdate, LeaveApprove, GoalAssign, GoalApprove, PerformanceEdit},
+	"hr": {
+		EmployeeView, EmployeeCreate, EmployeeUpdate, EmployeeDelete, EmployeeImport, EmployeeExport, ScopeAll,
+		LeaveApprove, LeaveViewAll, GoalAssignMgr, GoalApprove, ReviewViewAll, PerformanceEdit,
+		UserDelete, UserRestore, UserManageRoles, UserSecurity, SecurityAudit, RBACManage, SigningKeys, SCIMManage,
+		ServiceAccounts, Impersonate, AuditView, DepartmentManage, LifecycleManage,
+		CustomFieldManage, DocumentManage, SkillManage, ProfileApprove, HolidayManage,
+	},
+}
+
+// UserPermissions returns the union of permissions granted by the user's primary
+// role (users.role) and any additional roles in user_roles.
+func UserPermissions(userID uint) (map[string]bool, error) {
+	var keys []string
+	err := config.DB.Raw(`
+		SELECT DISTINCT p.key
+		FROM permissions p
+		JOIN role_permissions rp ON rp.permission_id = p.id
+		JOIN roles r ON r.id = rp.role_id
+		WHERE r.name = (SELECT role FROM users WHERE id = ?)
+		   OR r.id IN (SELECT role_id FROM user_roles WHERE user_id = ?)`, userID, userID).
+		Scan(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+	perms := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		perms[k] = true
+	}
+	return perms, nil
+}
+
+// UserRoles returns the primary role followed by any additional role names.
+func UserRoles(userID uint) ([]string, error) {
+	var names []string
+	err := config.DB.Raw(`
+		SELECT role FROM users WHERE id = ?
+		UNION
+		SELECT r.name FROM roles r JOIN user_roles ur ON ur.role_id = r.id WHERE ur.user_id = ?`, userID, userID).
+		Scan(&names).Error
+	return names, err
+}
+
+// UsersWithPermission is a subquery of the ids of (not deleted) users granted
+// perm by their primary role or an additional role.
+func UsersWithPermission(perm string) *gorm.DB {
+	return config.DB.Raw(`
+		SELECT u.id
+		FROM users u
+		JOIN roles r ON r.name = u.role OR r.id IN (SELECT role_id FROM user_roles WHERE user_id = u.id)
+		JOIN role_permissions rp ON rp.role_id = r.id
+		JOIN permissions p ON p.id = rp.permission_id
+		WHERE p.key = ? AND u.deleted_at IS NULL`, perm)
+}
+
+// HasPermission checks a permission for an arbitrary user.
+func HasPermission(userID uint, perm string) bool {
+	perms, err := UserPermissions(userID)
+	return err == nil && perms[perm]
+}
+
+// Can checks a permission for the authenticated caller. The resolved set is
+// cached on the request context so repeated checks cost one query.
+func Can(c *gin.Context, perm string) bool {
+	if v, ok := c.Get("permissions"); ok {
+		return v.(map[string]bool)[perm]
+	}
+	perms, err := UserPermissions(c.GetUint("userID"))
+	if err != nil {
+		return false
+	}
+	c.Set("permissions", perms)
+	return perms[perm]
+}
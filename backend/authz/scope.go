@@ -0,0 +1,143 @@
+package authz
+
+import (
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"peoplesoft/org"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Scope modes. View covers reading someone's data; Manage covers acting on it
+// (editing, approving). The only difference is that nobody manages themselves.
+type Mode int
+
+const (
+	View Mode = iota
+	Manage
+)
+
+// reportsSQL selects every employee id in the caller's reporting line (direct and
+// skip-level reports) plus every employee in departments granted via access_scopes.
+// UNION (not UNION ALL) in the recursive part stops on manager_id cycles.
+const reportsSQL = `
+	WITH RECURSIVE reports AS (
+		SELECT id FROM employees WHERE manager_id = @emp AND deleted_at IS NULL
+		UNION
+		SELECT e.id FROM employees e JOIN reports r ON e.manager_id = r.id
+		WHERE e.deleted_at IS NULL
+	)
+	SELECT id FROM reports
+	UNION
+	SELECT e.id FROM employees e
+	JOIN access_scopes s ON s.department_id = e.department_id
+	WHERE s.user_id = @user AND e.deleted_at IS NULL`
+
+// CallerEmployeeID returns the employees.id of the authenticated user (0 if none),
+// cached on the request context.
+func CallerEmployeeID(c *gin.Context) uint {
+	if v, ok := c.Get("employeeID"); ok {
+		return v.(uint)
+	}
+	var id uint
+	config.DB.Table("employees").Where("deleted_at IS NULL").Select("id").Where("user_id = ?", c.GetUint("userID")).Scan(&id)
+	c.Set("employeeID", id)
+	return id
+}
+
+// AccessibleEmployeeIDs lists the employee ids the caller may access through
+// their reporting line and department scopes, cached per request and mode.
+// It does not consider scope_all; check Can(c, ScopeAll) first.
+func AccessibleEmployeeIDs(c *gin.Context, mode Mode) []uint {
+	key := "scope.manage"
+	if mode == View {
+		key = "scope.view"
+	}
+	if v, ok := c.Get(key); ok {
+		return v.([]uint)
+	}
+
+	me := CallerEmployeeID(c)
+	var ids []uint
+	config.DB.Raw(reportsSQL, map[string]interface{}{"emp": me, "user": c.GetUint("userID")}).Scan(&ids)
+	if mode == View && me != 0 {
+		ids = append(ids, me)
+	}
+	c.Set(key, ids)
+	return ids
+}
+
+// CanAccessEmployee reports whether the caller may view/manage the employee with this employees.id.
+func CanAccessEmployee(c *gin.Context, employeeID uint, mode Mode) bool {
+	if employeeID == 0 {
+		return false
+	}
+	if Can(c, ScopeAll) {
+		return mode == View || employeeID != CallerEmployeeID(c)
+	}
+	for _, id := range AccessibleEmployeeIDs(c, mode) {
+		if id == employeeID {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccessUser is CanAccessEmployee keyed by users.id, for tables such as leaves
+// and goals that reference the user. A user without an employee record is only
+// accessible to themselves (view) or to scope_all holders.
+func CanAccessUser(c *gin.Context, userID uint, mode Mode) bool {
+	if userID == c.GetUint("userID") {
+		return mode == View
+	}
+	if Can(c, ScopeAll) {
+		return true
+	}
+	var employeeID uint
+	config.DB.Table("employees").Where("deleted_at IS NULL").Select("id").Where("user_id = ?", userID).Scan(&employeeID)
+	return CanAccessEmployee(c, employeeID, mode)
+}
+
+// ScopeEmployees restricts a query to rows whose employee id column (e.g. "e.id")
+// the caller may access. Queries are returned unchanged for scope_all holders.
+func ScopeEmployees(c *gin.Context, db *gorm.DB, column string, mode Mode) *gorm.DB {
+	if Can(c, ScopeAll) {
+		if mode == Manage {
+			return db.Where(column+" <> ?", CallerEmployeeID(c))
+		}
+		return db
+	}
+	ids := AccessibleEmployeeIDs(c, mode)
+	if len(ids) == 0 {
+		return db.Where("1 = 0")
+	}
+	return db.Where(column+" IN ?", ids)
+}
+
+// CanAccessDepartment reports whether the caller may see department-level data
+// (member listings, leave and goal statistics): scope_all holders, users with
+// an access scope on the department or one above it, and the head of the
+// department or one above it.
+func CanAccessDepartment(c *gin.Context, departmentID uint) bool {
+	if Can(c, ScopeAll) {
+		return true
+	}
+	ancestors, err := org.DepartmentAncestors(config.DB, departmentID)
+	if err != nil || len(ancestors) == 0 {
+		return false
+	}
+	var n int64
+	config.DB.Model(&models.AccessScope{}).
+		Where("user_id = ? AND department_id IN ?", c.GetUint("userID"), ancestors).
+		Count(&n)
+	if n > 0 {
+		return true
+	}
+	me := CallerEmployeeID(c)
+	if me == 0 {
+		return false
+	}
+	config.DB.Model(&models.Department{}).Where("id IN ? AND head_employee_id = ?", ancestors, me).Count(&n)
+	return n > 0
+}
@@ -0,0 +1,64 @@
+package authz
+
+import (
+	"errors"
+
+	"peoplesoft/config"
+	"peoplesoft/models"
+
+	"gorm.io/gorm"
+)
+
+// Seed makes sure every catalog permission and the default system roles exist.
+// A permission not yet granted to any role is treated as new and added to the
+// system roles that default to it; otherwise existing role permissions are left
+// alone so edits made through the API survive restarts.
+func Seed() error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		byKey := map[string]models.Permission{}
+		added := map[string]bool{}
+		for _, p := range Catalog {
+			perm := models.Permission{Key: p.Key}
+			res := tx.Where(models.Permission{Key: p.Key}).
+				Assign(models.Permission{Description: p.Description}).
+				FirstOrCreate(&perm)
+			if res.Error != nil {
+				return res.Error
+			}
+			byKey[p.Key] = perm
+			var n int64
+			tx.Table("role_permissions").Where("permission_id = ?", perm.ID).Count(&n)
+			added[p.Key] = n == 0
+		}
+
+		for name, keys := range DefaultRoles {
+			var role models.Role
+			err := tx.Where("name = ?", name).First(&role).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+
+			if err == nil {
+				// Existing role: only grant permissions introduced since the last start,
+				// so permissions removed through the API stay removed.
+				for _, k := range keys {
+					if added[k] {
+						if err := tx.Model(&role).Association("Permissions").Append(&models.Permission{ID: byKey[k].ID}); err != nil {
+							return err
+						}
+					}
+				}
+				continue
+			}
+
+			role = models.Role{Name: name, Description: "Default " + name + " role", IsSystem: true}
+			for _, k := range keys {
+				role.Permissions = append(role.Permissions, byKey[k])
+			}
+			if err := tx.Create(&role).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
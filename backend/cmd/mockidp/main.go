@@ -0,0 +1,230 @@
+// Command mockidp is a minimal OpenID Connect provider for local development.
+// It serves discovery, a JWKS, an authorization page that signs in whoever you
+// type in (including groups), and a token endpoint that enforces PKCE.
+//
+//	go run ./cmd/mockidp
+//
+// Backend configuration:
+//
+//	OIDC_PROVIDERS=mock
+//	OIDC_MOCK_ISSUER=http://localhost:9000
+//	OIDC_MOCK_CLIENT_ID=peoplesoft-local
+//	OIDC_MOCK_REDIRECT_URL=http://localhost:8080/api/auth/oidc/mock/callback
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"html/template"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type authCode struct {
+	clientID    string
+	redirectURI string
+	nonce       string
+	challenge   string
+	email, name string
+	groups      []string
+	expiresAt   time.Time
+}
+
+type server struct {
+	issuer   string
+	clientID string
+	key      *rsa.PrivateKey
+	kid      string
+
+	mu    sync.Mutex
+	codes map[string]authCode
+}
+
+func main() {
+	addr := envOr("MOCK_IDP_ADDR", ":9000")
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s := &server{
+		issuer:   envOr("MOCK_IDP_ISSUER", "http://localhost:9000"),
+		clientID: envOr("MOCK_IDP_CLIENT_ID", "peoplesoft-local"),
+		key:      key,
+		kid:      "mock-1",
+		codes:    map[string]authCode{},
+	}
+
+	http.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	http.HandleFunc("/jwks", s.jwks)
+	http.HandleFunc("/authorize", s.authorize)
+	http.HandleFunc("/token", s.token)
+
+	log.Printf("mock IdP %s listening on %s (client_id %s)", s.issuer, addr, s.clientID)
+	log.Fatal(http.ListenAndServe(addr, nil))
+}
+
+func (s *server) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                s.issuer,
+		"authorization_endpoint":                s.issuer + "/authorize",
+		"token_endpoint":                        s.issuer + "/token",
+		"jwks_uri":                              s.issuer + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (s *server) jwks(w http.ResponseWriter, r *http.Request) {
+	pub := s.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA", "kid": s.kid, "use": "sig", "alg": "RS256",
+		"n": base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+var loginPage = template.Must(template.New("login").Parse(`<!doctype html>
+<title>Mock IdP</title>
+<h2>Mock IdP sign-in</h2>
+<form method="post">
+  {{range $k, $v := .Query}}<input type="hidden" name="{{$k}}" value="{{index $v 0}}">{{end}}
+  <p><label>Email <input name="email" value="jane.doe@example.com"></label></p>
+  <p><label>Name <input name="name" value="Jane Doe"></label></p>
+  <p><label>Groups (comma separated) <input name="groups" value=""></label></p>
+  <button>Sign in</button>
+</form>`))
+
+func (s *server) authorize(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		loginPage.Execute(w, map[string]interface{}{"Query": r.URL.Query()})
+		return
+	}
+	r.ParseForm()
+	redirectURI := r.FormValue("redirect_uri")
+	if r.FormValue("client_id") != s.clientID || redirectURI == "" {
+		http.Error(w, "unknown client or missing redirect_uri", http.StatusBadRequest)
+		return
+	}
+	if r.FormValue("code_challenge_method") != "S256" || r.FormValue("code_challenge") == "" {
+		http.Error(w, "PKCE (S256) is required", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString()
+	s.mu.Lock()
+	s.codes[code] = authCode{
+		clientID:    s.clientID,
+		redirectURI: redirectURI,
+		nonce:       r.FormValue("nonce"),
+		challenge:   r.FormValue("code_challenge"),
+		email:       strings.TrimSpace(r.FormValue("email")),
+		name:        strings.TrimSpace(r.FormValue("name")),
+		groups:      splitList(r.FormValue("groups")),
+		expiresAt:   time.Now().Add(time.Minute),
+	}
+	s.mu.Unlock()
+
+	q := url.Values{"code": {code}, "state": {r.FormValue("state")}}
+	http.Redirect(w, r, redirectURI+"?"+q.Encode(), http.StatusFound)
+}
+
+func (s *server) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	s.mu.Lock()
+	ac, ok := s.codes[r.FormValue("code")]
+	delete(s.codes, r.FormValue("code"))
+	s.mu.Unlock()
+
+	clientID := r.FormValue("client_id")
+	if u, _, hasBasic := r.BasicAuth(); hasBasic {
+		clientID = u
+	}
+	sum := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	switch {
+	case !ok || time.Now().After(ac.expiresAt):
+		tokenError(w, "invalid_grant", "unknown or expired code")
+		return
+	case clientID != ac.clientID || r.FormValue("redirect_uri") != ac.redirectURI:
+		tokenError(w, "invalid_grant", "client or redirect_uri mismatch")
+		return
+	case base64.RawURLEncoding.EncodeToString(sum[:]) != ac.challenge:
+		tokenError(w, "invalid_grant", "PKCE verification failed")
+		return
+	}
+
+	subject := sha256.Sum256([]byte(strings.ToLower(ac.email)))
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            s.issuer,
+		"aud":            s.clientID,
+		"sub":            base64.RawURLEncoding.EncodeToString(subject[:12]),
+		"email":          ac.email,
+		"email_verified": true,
+		"name":           ac.name,
+		"groups":         ac.groups,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+	}
+	if ac.nonce != "" {
+		claims["nonce"] = ac.nonce
+	}
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	tok.Header["kid"] = s.kid
+	idToken, err := tok.SignedString(s.key)
+	if err != nil {
+		tokenError(w, "server_error", err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func tokenError(w http.ResponseWriter, code, desc string) {
+	writeJSON(w, http.StatusBadRequest, map[string]string{"error": code, "error_description": desc})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func splitList(s string) []string {
+	out := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,128 @@
+// Command mocks3 is a minimal S3-compatible object store for local
+// development. It keeps objects in memory and serves path-style PUT, GET,
+// HEAD and DELETE on /<bucket>/<key>. Requests must carry a SigV4
+// Authorization header for the configured access key, and uploads must match
+// their x-amz-content-sha256; signatures themselves are not recomputed. Use a
+// real MinIO server to test signing end to end.
+//
+//	go run ./cmd/mocks3
+//
+// Backend configuration:
+//
+//	DOCUMENT_STORAGE=s3
+//	DOCUMENT_S3_ENDPOINT=http://localhost:9100
+//	DOCUMENT_S3_BUCKET=peoplesoft-documents
+//	DOCUMENT_S3_ACCESS_KEY=mock
+//	DOCUMENT_S3_SECRET_KEY=mock-secret
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type object struct {
+	data        []byte
+	contentType string
+	modified    time.Time
+}
+
+type server struct {
+	accessKey string
+
+	mu      sync.Mutex
+	objects map[string]object
+}
+
+func main() {
+	addr := envOr("MOCK_S3_ADDR", ":9100")
+	s := &server{
+		accessKey: envOr("MOCK_S3_ACCESS_KEY", "mock"),
+		objects:   map[string]object{},
+	}
+	log.Printf("mock S3 listening on %s (access key %q)", addr, s.accessKey)
+	log.Fatal(http.ListenAndServe(addr, s))
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimPrefix(r.URL.Path, "/")
+	bucket, key, ok := strings.Cut(path, "/")
+	if !ok || bucket == "" || key == "" {
+		s.fail(w, http.StatusBadRequest, "InvalidRequest", "expected /<bucket>/<key>")
+		return
+	}
+	if !strings.Contains(r.Header.Get("Authorization"), "Credential="+s.accessKey+"/") {
+		s.fail(w, http.StatusForbidden, "InvalidAccessKeyId", "unknown access key")
+		return
+	}
+	name := bucket + "/" + key
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			s.fail(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+			return
+		}
+		sum := sha256.Sum256(data)
+		if want := r.Header.Get("X-Amz-Content-Sha256"); want != "UNSIGNED-PAYLOAD" && want != hex.EncodeToString(sum[:]) {
+			s.fail(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch", "payload hash does not match")
+			return
+		}
+		s.mu.Lock()
+		s.objects[name] = object{data: data, contentType: r.Header.Get("Content-Type"), modified: time.Now()}
+		s.mu.Unlock()
+		w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:16])+`"`)
+		w.WriteHeader(http.StatusOK)
+		log.Printf("PUT %s (%d bytes)", name, len(data))
+
+	case http.MethodGet, http.MethodHead:
+		s.mu.Lock()
+		obj, found := s.objects[name]
+		s.mu.Unlock()
+		if !found {
+			s.fail(w, http.StatusNotFound, "NoSuchKey", "the specified key does not exist")
+			return
+		}
+		if obj.contentType != "" {
+			w.Header().Set("Content-Type", obj.contentType)
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(obj.data)))
+		w.Header().Set("Last-Modified", obj.modified.UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(obj.data)
+		}
+
+	case http.MethodDelete:
+		s.mu.Lock()
+		delete(s.objects, name)
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+		log.Printf("DELETE %s", name)
+
+	default:
+		s.fail(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method+" is not supported")
+	}
+}
+
+func (s *server) fail(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, msg)
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"peoplesoft/audit"
+	"peoplesoft/config"
+	"peoplesoft/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET /api/audit/events?entity_type=&entity_id=&actor_id=&action=&request_id=&from=&to=&page=&page_size= (HR only)
+func ListAuditEvents(c *gin.Context) {
+	page, size := pageParams(c)
+
+	db := config.DB.Model(&models.AuditEvent{})
+	if v := strings.TrimSpace(c.Query("entity_type")); v != "" {
+		db = db.Where("entity_type = ?", v)
+	}
+	if v := strings.TrimSpace(c.Query("entity_id")); v != "" {
+		db = db.Where("entity_id = ?", v)
+	}
+	if v := c.Query("actor_id"); v != "" {
+		db = db.Where("actor_id = ? OR on_behalf_of_id = ?", v, v)
+	}
+	if v := strings.TrimSpace(c.Query("action")); v != "" {
+		db = db.Where("action = ?", v)
+	}
+	if v := strings.TrimSpace(c.Query("request_id")); v != "" {
+		db = db.Where("request_id = ?", v)
+	}
+	if v := c.Query("from"); v != "" {
+		from, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date, expected YYYY-MM-DD"})
+			return
+		}
+		db = db.Where("created_at >= ?", from)
+	}
+	if v := c.Query("to"); v != "" {
+		to, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date, expected YYYY-MM-DD"})
+			return
+		}
+		db = db.Where("created_at < ?", to.AddDate(0, 0, 1))
+	}
+
+	var total int64
+	db.Count(&total)
+
+	var rows []models.AuditEvent
+	if err := db.Order("id desc").
+		Offset((page - 1) * size).
+		Limit(size).
+		Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "fetch failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"page": page, "page_size": size, "total": total, "data": rows})
+}
+
+// GET /api/audit/verify (HR only)
+// Recomputes the hash chain and reports the first event that was altered or
+// whose predecessor is missing.
+func VerifyAuditLog(c *gin.Context) {
+	res, err := audit.Verify()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "verification failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": res})
+}
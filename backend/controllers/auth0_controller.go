@@ -1,20 +1,20 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
 
-	"peoplesoft/config"
-	"peoplesoft/models"
-	"peoplesoft/utils"
+	"peoplesoft/oidc"
 
 	"github.com/gin-gonic/gin"
 )
 
+// POST /api/auth/auth0-login
+// Exchanges an ID token obtained by the Auth0 SPA SDK for an application session.
+// Email and name come from the verified token; the body's email/name are ignored.
 func Auth0Login(c *gin.Context) {
 	var body struct {
-		Email string `json:"email"`
-		Name  string `json:"name"`
-		Token string `json:"token"`
+		Token string `json:"token" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&body); err != nil {
@@ -22,36 +22,58 @@ func Auth0Login(c *gin.Context) {
 		return
 	}
 
-	// Validate ID token signature (RS256)
-	_, err := utils.ValidateAuth0Token(body.Token)
+	provider, ok := oidc.Get("auth0")
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "auth0 login is not configured"})
+		return
+	}
+
+	// Validate ID token signature, issuer and audience. The SPA SDK checked its own nonce.
+	identity, err := provider.VerifyIDToken(c.Request.Context(), body.Token, "")
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token", "details": err.Error()})
 		return
 	}
 
-	// Check if user exists
-	var user models.User
-	config.DB.Where("email = ?", body.Email).First(&user)
+	user, err := ssoUser(provider, identity)
+	if errors.Is(err, errLinkRequired) {
+		recordLoginAttempt(c, identity.Email, nil, false, "sso_link_required")
+		c.JSON(http.StatusConflict, gin.H{"error": "an account with this email exists; sign in and link Auth0 to it first"})
+		return
+	}
+	if errors.Is(err, errNotProvisioned) {
+		recordLoginAttempt(c, identity.Email, nil, false, "sso_not_provisioned")
+		c.JSON(http.StatusForbidden, gin.H{"error": "no account exists for this identity, contact HR"})
+		return
+	}
+	if errors.Is(err, errDeactivated) {
+		recordLoginAttempt(c, identity.Email, nil, false, "deactivated")
+		c.JSON(http.StatusForbidden, gin.H{"error": "account is deactivated"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to resolve user"})
+		return
+	}
+	recordLoginAttempt(c, user.Email, &user.ID, true, "sso:"+identity.Provider)
 
-	if user.ID == 0 {
-		// Create if not exists
-		user = models.User{
-			Name:         body.Name,
-			Email:        body.Email,
-			Role:         "employee", // default
-			DepartmentID: 1,
-		}
-		config.DB.Create(&user)
+	// Same second factor as a password login
+	challenge, err := mfaLoginStep(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue token"})
+		return
+	}
+	if challenge != nil {
+		c.JSON(http.StatusOK, challenge)
+		return
 	}
 
-	// Create application JWT
-	appToken, _ := utils.GenerateToken(user.Email, user.Role)
+	// Create application access + refresh tokens
+	resp, err := issueSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue token"})
+		return
+	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token":  appToken,
-		"role":   user.Role,
-		"email":  user.Email,
-		"userID": user.ID,
-		"name":   user.Name,
-	})
+	c.JSON(http.StatusOK, resp)
 }
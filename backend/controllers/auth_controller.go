@@ -11,12 +11,19 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
+// dummyPasswordHash is compared against when the account does not exist.
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("peoplesoft-timing-equalizer"), 10)
+
 func Register(c *gin.Context) {
 	var body struct{ Name, Email, Password string }
 	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
 		return
 	}
+	if err := utils.GetPasswordPolicy().Validate(body.Password, body.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 	hash, _ := bcrypt.GenerateFromPassword([]byte(body.Password), 10)
 	user := models.User{Name: body.Name, Email: body.Email, PasswordHash: string(hash), Role: "employee"}
 	if tx := config.DB.Create(&user); tx.Error != nil {
@@ -32,16 +39,49 @@ func Login(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
 		return
 	}
+
+	// Per-account lockout and per-IP backoff
+	if wait := loginBlocked(body.Email, c.ClientIP()); wait > 0 {
+		recordLoginAttempt(c, normalizeEmail(body.Email), nil, false, "locked")
+		respondThrottled(c, wait)
+		return
+	}
+
 	var user models.User
 	config.DB.Where("email = ?", body.Email).First(&user)
 	if user.ID == 0 {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+		// Burn the same bcrypt time as a real check so timing does not reveal unknown accounts
+		bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(body.Password))
+		registerLoginFailure(c, body.Email, nil, "bad_credentials")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidCredentials})
 		return
 	}
 	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(body.Password)) != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "bad credentials"})
+		registerLoginFailure(c, body.Email, &user.ID, "bad_credentials")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errInvalidCredentials})
+		return
+	}
+	if !user.Active {
+		recordLoginAttempt(c, normalizeEmail(body.Email), &user.ID, false, "deactivated")
+		c.JSON(http.StatusForbidden, gin.H{"error": "account is deactivated"})
+		return
+	}
+	// Second factor: enrolled users (or roles that mandate MFA) get a challenge instead of a session
+	challenge, err := mfaLoginStep(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue token"})
+		return
+	}
+	if challenge != nil {
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+
+	resp, err := issueSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue token"})
 		return
 	}
-	token, _ := utils.GenerateToken(user.Email, user.Role)
-	c.JSON(http.StatusOK, gin.H{"token": token, "role": user.Role, "email": user.Email, "userID": user.ID, "name": user.Name})
+	registerLoginSuccess(c, body.Email, user.ID)
+	c.JSON(http.StatusOK, resp)
 }
@@ -10,6 +10,7 @@ import (
 	"strings"
 	"time"
 
+	"peoplesoft/authz"
 	"peoplesoft/config"
 	"peoplesoft/models"
 
@@ -79,8 +80,8 @@ func HandleChatbotQuery(c *gin.Context) {
 		return
 	}
 
-	// Build context from database based on user's role
-	context := buildUserContext(userID.(uint), employee.ID, role)
+	// Build context from database based on user's permissions
+	context := buildUserContext(c, userID.(uint), employee.ID, role)
 
 	// Call Groq API
 	answer, err := queryGroqAPI(req.Question, req.History, context, role)
@@ -105,8 +106,8 @@ func HandleChatbotQuery(c *gin.Context) {
 	c.JSON(200, response)
 }
 
-// buildUserContext retrieves relevant data from database based on user role
-func buildUserContext(userID uint, employeeID uint, role string) string {
+// buildUserContext retrieves the data the caller's permissions allow
+func buildUserContext(c *gin.Context, userID uint, employeeID uint, role string) string {
 	var context string
 
 	// Debug logging
@@ -297,25 +298,28 @@ func buildUserContext(userID uint, employeeID uint, role string) string {
 		}
 	}
 
-	// For managers: add comprehensive team information
-	if role == "manager" || role == "hr" {
+	// Goal assigners: the people they can assign goals to. Holders of
+	// goal.assign_managers (HR) assign to managers, holders of goal.assign to
+	// their direct reports.
+	canManagers := authz.Can(c, authz.GoalAssignMgr)
+	canTeam := authz.Can(c, authz.GoalAssign) && !canManagers
+	if canTeam || canManagers {
 		var teamMembers []models.Employee
-		if role == "manager" {
+		if canTeam {
 			// Managers see their direct reports
 			config.DB.Where("manager_id = ?", employeeID).Find(&teamMembers)
 		} else {
-			// HR sees all managers (for assignment) and potentially all employees
-			// For simplicity, let's list all Managers for HR to assign goals to
-			config.DB.Joins("JOIN users ON users.id = employees.user_id").Where("users.role = ?", "manager").Find(&teamMembers)
+			// Managers are whoever holds goal.assign through any of their roles
+			config.DB.Where("user_id IN (?)", authz.UsersWithPermission(authz.GoalAssign)).Find(&teamMembers)
 		}
 
 		if len(teamMembers) > 0 {
-			if role == "hr" {
+			if canManagers {
 				context += fmt.Sprintf("\nAssignable Managers (%d):\n", len(teamMembers))
 			} else {
 				context += fmt.Sprintf("\nTeam Members (%d):\n", len(teamMembers))
 			}
-			
+
 			for _, tm := range teamMembers {
 				var tmUser models.User
 				config.DB.First(&tmUser, tm.UserID)
@@ -324,7 +328,7 @@ func buildUserContext(userID uint, employeeID uint, role string) string {
 				context += fmt.Sprintf("  Email: %s, Phone: %s\n", tmUser.Email, tm.Phone)
 
 				// Get team member's leave balances (only for direct manager)
-				if role == "manager" {
+				if canTeam {
 					var tmBalances []models.LeaveAllocation
 					if err := config.DB.Where("user_id = ?", tm.UserID).Find(&tmBalances).Error; err == nil && len(tmBalances) > 0 {
 						context += "  Leave Balances: "
@@ -340,40 +344,35 @@ func buildUserContext(userID uint, employeeID uint, role string) string {
 				}
 			}
 		}
+	}
 
-		// Pending leave approvals (Manager only)
-		if role == "manager" {
-			var teamUserIDs []uint
-			for _, tm := range teamMembers {
-				teamUserIDs = append(teamUserIDs, tm.UserID)
-			}
-
-			if len(teamUserIDs) > 0 {
-				var pendingLeaves []models.Leave
-				config.DB.Where("user_id IN ? AND status = ?", teamUserIDs, "pending").Find(&pendingLeaves)
-
-				if len(pendingLeaves) > 0 {
-					context += fmt.Sprintf("\nPending Leave Approvals (%d):\n", len(pendingLeaves))
-					for _, pl := range pendingLeaves {
-						var plUser models.User
-						config.DB.First(&plUser, pl.UserID)
-						context += fmt.Sprintf("- %s: %s to %s (%s)\n",
-							plUser.Name,
-							pl.StartDate.Format("2006-01-02"),
-							pl.EndDate.Format("2006-01-02"),
-							pl.Type)
-					}
-				}
+	// Leave approvers: pending leaves they can act on (everyone's for
+	// leave.view_all holders, otherwise the employees in their scope)
+	if authz.Can(c, authz.LeaveApprove) && !authz.Can(c, authz.LeaveViewAll) {
+		var pendingLeaves []models.Leave
+		dashboardLeaves(c).Select("l.*").Where("l.status = ? AND l.user_id <> ?", "pending", userID).Find(&pendingLeaves)
+
+		if len(pendingLeaves) > 0 {
+			context += fmt.Sprintf("\nPending Leave Approvals (%d):\n", len(pendingLeaves))
+			for _, pl := range pendingLeaves {
+				var plUser models.User
+				config.DB.First(&plUser, pl.UserID)
+				context += fmt.Sprintf("- %s: %s to %s (%s)\n",
+					plUser.Name,
+					pl.StartDate.Format("2006-01-02"),
+					pl.EndDate.Format("2006-01-02"),
+					pl.Type)
 			}
 		}
 	}
 
-	// For HR: add company-wide stats
-	if role == "hr" {
+	// Company-wide stats for those who see everyone
+	if authz.Can(c, authz.ScopeAll) {
 		var totalEmployees int64
 		config.DB.Model(&models.Employee{}).Count(&totalEmployees)
 		context += fmt.Sprintf("\nTotal Employees: %d\n", totalEmployees)
-
+	}
+	if authz.Can(c, authz.LeaveViewAll) {
 		var pendingLeaves int64
 		config.DB.Model(&models.Leave{}).Where("status = ?", "pending").Count(&pendingLeaves)
 		context += fmt.Sprintf("Pending Leave Requests: %d\n", pendingLeaves)
@@ -585,7 +584,10 @@ func applyLeave(params map[string]interface{}, userID uint, c *gin.Context) (str
 		return "", fmt.Errorf("end date cannot be before start date")
 	}
 
-	days := workingDaysBetween(start, end)
+	days, err := workingDaysBetween(config.DB, userID, start, end)
+	if err != nil {
+		return "", fmt.Errorf("failed to load holiday calendar")
+	}
 	if days <= 0 {
 		return "", fmt.Errorf("no working days in selected range")
 	}
@@ -621,6 +623,7 @@ func applyLeave(params map[string]interface{}, userID uint, c *gin.Context) (str
 		Type:      leaveType,
 		Reason:    reason,
 		Status:    "pending",
+		Days:      days,
 	}
 
 	if err := tx.Create(&leave).Error; err != nil {
@@ -675,7 +678,6 @@ func assignGoal(params map[string]interface{}, assignerID uint, c *gin.Context)
 	}
 
 	// Verify permissions
-	assignerRole := c.GetString("role")
 	var targetUser models.User
 	if err := config.DB.First(&targetUser, targetUserID).Error; err != nil {
 		return "", fmt.Errorf("target user not found")
@@ -684,13 +686,13 @@ func assignGoal(params map[string]interface{}, assignerID uint, c *gin.Context)
 	var level string
 	var status string
 
-	if assignerRole == "hr" {
-		if targetUser.Role != "manager" {
+	if authz.Can(c, authz.GoalAssignMgr) {
+		if !authz.HasPermission(targetUserID, authz.GoalAssign) {
 			return "", fmt.Errorf("HR can only assign goals to Managers")
 		}
 		level = "hr_manager"
 		status = "hr_assigned"
-	} else if assignerRole == "manager" {
+	} else if authz.Can(c, authz.GoalAssign) {
 		// Verify target is in manager's team
 		var emp models.Employee
 		if err := config.DB.Where("user_id = ?", targetUserID).First(&emp).Error; err != nil {
@@ -0,0 +1,619 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"peoplesoft/audit"
+	"peoplesoft/authz"
+	"peoplesoft/config"
+	"peoplesoft/employment"
+	"peoplesoft/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Custom field types.
+const (
+	FieldText   = "text"
+	FieldNumber = "number"
+	FieldDate   = "date"
+	FieldEnum   = "enum"
+)
+
+var fieldTypes = []string{FieldText, FieldNumber, FieldDate, FieldEnum}
+
+// Visibility levels, lowest first: a caller sees the fields at or below their
+// level for an employee. self fields are seen by the employee, their managers
+// and HR; manager fields by managers and HR; hr fields only by holders of
+// custom_field.manage.
+const (
+	levelNone = iota
+	levelSelf
+	levelManager
+	levelHR
+)
+
+var visibilityLevels = map[string]int{"self": levelSelf, "manager": levelManager, "hr": levelHR}
+
+var fieldKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,59}$`)
+
+// maxFieldText caps text values that have no max_length of their own.
+const maxFieldText = 2000
+
+// fieldLevel is how much of the employee's custom profile the caller may see.
+func fieldLevel(c *gin.Context, employeeID uint) int {
+	switch {
+	case authz.Can(c, authz.CustomFieldManage) && authz.CanAccessEmployee(c, employeeID, authz.View):
+		return levelHR
+	case employeeID == authz.CallerEmployeeID(c):
+		return levelSelf
+	case authz.CanAccessEmployee(c, employeeID, authz.View):
+		return levelManager
+	}
+	return levelNone
+}
+
+// canWriteField reports whether the caller may set f on the employee: the
+// employee themselves for self-editable fields, and anyone managing them for
+// fields they can see.
+func canWriteField(c *gin.Context, f models.CustomField, employeeID uint) bool {
+	if employeeID == authz.CallerEmployeeID(c) {
+		return f.Visibility == "self" && f.SelfEditable
+	}
+	if !authz.CanAccessEmployee(c, employeeID, authz.Manage) {
+		return false
+	}
+	return authz.Can(c, authz.CustomFieldManage) || visibilityLevels[f.Visibility] <= levelManager
+}
+
+func loadCustomFields() ([]models.CustomField, error) {
+	var fields []models.CustomField
+	err := config.DB.Order("position asc, id asc").Find(&fields).Error
+	return fields, err
+}
+
+// customFieldValue turns a stored value back into its JSON form.
+func customFieldValue(f models.CustomField, v string) any {
+	if f.Type == FieldNumber {
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	}
+	return v
+}
+
+// attachCustomFields fills CustomFields on each row with the values the
+// caller may see. Values are always current, also for as_of listings.
+func attachCustomFields(c *gin.Context, rows []EmployeeRow) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	fields, err := loadCustomFields()
+	if err != nil || len(fields) == 0 {
+		return err
+	}
+	byID := map[uint]models.CustomField{}
+	for _, f := range fields {
+		byID[f.ID] = f
+	}
+	ids := make([]uint, len(rows))
+	for i, r := range rows {
+		ids[i] = r.ID
+	}
+	var values []models.EmployeeFieldValue
+	if err := config.DB.Where("employee_id IN ?", ids).Find(&values).Error; err != nil {
+		return err
+	}
+	byEmployee := map[uint][]models.EmployeeFieldValue{}
+	for _, v := range values {
+		byEmployee[v.EmployeeID] = append(byEmployee[v.EmployeeID], v)
+	}
+	for i := range rows {
+		level := fieldLevel(c, rows[i].ID)
+		if level == levelNone {
+			continue
+		}
+		out := map[string]any{}
+		for _, v := range byEmployee[rows[i].ID] {
+			if f, ok := byID[v.FieldID]; ok && visibilityLevels[f.Visibility] <= level {
+				out[f.Key] = customFieldValue(f, v.Value)
+			}
+		}
+		rows[i].CustomFields = out
+	}
+	return nil
+}
+
+// fieldValue is a validated custom field value; "" clears it.
+type fieldValue struct {
+	field models.CustomField
+	value string
+}
+
+// parseCustomFields validates values keyed by field key. allowed decides which
+// fields the caller may set. When creating, required fields the caller may
+// set must be present.
+func parseCustomFields(in map[string]any, allowed func(models.CustomField) bool, creating bool) ([]fieldValue, *badRequest) {
+	if len(in) == 0 && !creating {
+		return nil, nil
+	}
+	fields, err := loadCustomFields()
+	if err != nil {
+		return nil, &badRequest{"failed to load custom fields"}
+	}
+	byKey := map[string]models.CustomField{}
+	for _, f := range fields {
+		byKey[f.Key] = f
+	}
+	var out []fieldValue
+	for key, raw := range in {
+		f, ok := byKey[key]
+		if !ok {
+			return nil, &badRequest{"unknown custom field " + key}
+		}
+		if !allowed(f) {
+			return nil, &badRequest{"not allowed to set custom field " + key}
+		}
+		v, br := normalizeFieldValue(f, raw)
+		if br != nil {
+			return nil, br
+		}
+		out = append(out, fieldValue{f, v})
+	}
+	if creating {
+		for _, f := range fields {
+			if _, ok := in[f.Key]; f.Required && !ok && allowed(f) {
+				return nil, &badRequest{f.Key + " is required"}
+			}
+		}
+	}
+	return out, nil
+}
+
+// normalizeFieldValue checks raw against the field's type and rules and
+// returns it in stored form.
+func normalizeFieldValue(f models.CustomField, raw any) (string, *badRequest) {
+	var s string
+	switch v := raw.(type) {
+	case nil:
+	case string:
+		s = strings.TrimSpace(v)
+	case float64:
+		if f.Type != FieldNumber {
+			return "", &badRequest{f.Key + " must be a string"}
+		}
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return "", &badRequest{f.Key + " has an invalid value"}
+	}
+	if s == "" {
+		if f.Required {
+			return "", &badRequest{f.Key + " is required"}
+		}
+		return "", nil
+	}
+
+	switch f.Type {
+	case FieldText:
+		limit := maxFieldText
+		if f.MaxLength != nil {
+			limit = *f.MaxLength
+		}
+		if len([]rune(s)) > limit {
+			return "", &badRequest{fmt.Sprintf("%s must be at most %d characters", f.Key, limit)}
+		}
+		if f.Pattern != "" {
+			if re, err := regexp.Compile(f.Pattern); err == nil && !re.MatchString(s) {
+				return "", &badRequest{f.Key + " does not have the expected format"}
+			}
+		}
+	case FieldNumber:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return "", &badRequest{f.Key + " must be a number"}
+		}
+		if f.Min != nil && n < *f.Min {
+			return "", &badRequest{fmt.Sprintf("%s must be at least %v", f.Key, *f.Min)}
+		}
+		if f.Max != nil && n > *f.Max {
+			return "", &badRequest{fmt.Sprintf("%s must be at most %v", f.Key, *f.Max)}
+		}
+		s = strconv.FormatFloat(n, 'f', -1, 64)
+	case FieldDate:
+		if _, err := time.Parse(employment.DateLayout, s); err != nil {
+			return "", &badRequest{f.Key + " must be a date (YYYY-MM-DD)"}
+		}
+	case FieldEnum:
+		if !containsString(f.OptionList(), s) {
+			return "", &badRequest{f.Key + " must be one of " + strings.Join(f.OptionList(), ", ")}
+		}
+	}
+	return s, nil
+}
+
+// saveCustomFields writes the values of one employee, deleting cleared ones.
+func saveCustomFields(tx *gorm.DB, employeeID uint, values []fieldValue) error {
+	for _, v := range values {
+		if v.value == "" {
+			if err := tx.Where("employee_id = ? AND field_id = ?", employeeID, v.field.ID).
+				Delete(&models.EmployeeFieldValue{}).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		row := models.EmployeeFieldValue{EmployeeID: employeeID, FieldID: v.field.ID, Value: v.value}
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "employee_id"}, {Name: "field_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+		}).Create(&row).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// customFieldSnapshot returns an employee's stored values keyed by field key, for the audit log.
+func customFieldSnapshot(employeeID uint) map[string]string {
+	var rows []struct {
+		Key   string
+		Value string
+	}
+	config.DB.Table("employee_field_values v").
+		Select("f.key, v.value").
+		Joins("JOIN custom_fields f ON f.id = v.field_id").
+		Where("v.employee_id = ?", employeeID).
+		Scan(&rows)
+	out := map[string]string{}
+	for _, r := range rows {
+		out[r.Key] = r.Value
+	}
+	return out
+}
+
+// filterCustomFields applies cf.<key>=value filters (text matches a part,
+// other types the exact value) and cf.<key>.min / cf.<key>.max ranges on
+// number and date fields. Filtered results are limited to employees the
+// caller can access, and only custom_field.manage holders may filter on hr fields.
+func filterCustomFields(c *gin.Context, db *gorm.DB) (*gorm.DB, *badRequest) {
+	var keys []string
+	for k := range c.Request.URL.Query() {
+		if strings.HasPrefix(k, "cf.") {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		return db, nil
+	}
+	fields, err := loadCustomFields()
+	if err != nil {
+		return nil, &badRequest{"failed to load custom fields"}
+	}
+	byKey := map[string]models.CustomField{}
+	for _, f := range fields {
+		byKey[f.Key] = f
+	}
+
+	hr := authz.Can(c, authz.CustomFieldManage)
+	excludeSelf := false
+	for _, k := range keys {
+		name, op := strings.TrimPrefix(k, "cf."), ""
+		if i := strings.LastIndex(name, "."); i >= 0 {
+			name, op = name[:i], name[i+1:]
+		}
+		f, ok := byKey[name]
+		if !ok || (!hr && visibilityLevels[f.Visibility] > levelManager) {
+			return nil, &badRequest{"unknown custom field " + name}
+		}
+		// nobody learns their own manager-only values by filtering on them
+		excludeSelf = excludeSelf || (!hr && f.Visibility == "manager")
+		raw := c.Query(k)
+
+		cond, arg := "v.value = ?", any(raw)
+		switch {
+		case op == "" && f.Type == FieldText:
+			cond, arg = "v.value ILIKE ?", "%"+raw+"%"
+		case op == "":
+			v, br := normalizeFieldValue(models.CustomField{Key: f.Key, Type: f.Type, Options: f.Options}, raw)
+			if br != nil {
+				return nil, br
+			}
+			arg = v
+		case (op == "min" || op == "max") && f.Type == FieldNumber:
+			n, err := strconv.ParseFloat(raw, 64)
+			if err != nil {
+				return nil, &badRequest{k + " must be a number"}
+			}
+			cond, arg = "CAST(v.value AS numeric) >= ?", n
+			if op == "max" {
+				cond = "CAST(v.value AS numeric) <= ?"
+			}
+		case (op == "min" || op == "max") && f.Type == FieldDate:
+			d, err := time.Parse(employment.DateLayout, raw)
+			if err != nil {
+				return nil, &badRequest{k + " must be a date (YYYY-MM-DD)"}
+			}
+			cond, arg = "v.value >= ?", d.Format(employment.DateLayout)
+			if op == "max" {
+				cond = "v.value <= ?"
+			}
+		default:
+			return nil, &badRequest{"unsupported filter " + k}
+		}
+		db = db.Where("EXISTS (SELECT 1 FROM employee_field_values v WHERE v.employee_id = e.id AND v.field_id = ? AND "+cond+")", f.ID, arg)
+	}
+	db = authz.ScopeEmployees(c, db, "e.id", authz.View)
+	if excludeSelf {
+		db = db.Where("e.id <> ?", authz.CallerEmployeeID(c))
+	}
+	return db, nil
+}
+
+// PUT /api/employees/:id/custom-fields   body: {custom_fields: {key: value}}
+// Lets employees fill in their self-editable fields and managers the fields
+// they can see, without the general employee.update permission.
+func UpdateEmployeeCustomFields(c *gin.Context) {
+	var in struct {
+		CustomFields map[string]any `json:"custom_fields" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "custom_fields required"})
+		return
+	}
+	var emp models.Employee
+	if err := config.DB.First(&emp, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if fieldLevel(c, emp.ID) == levelNone {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to view this employee"})
+		return
+	}
+	values, br := parseCustomFields(in.CustomFields, func(f models.CustomField) bool {
+		return canWriteField(c, f, emp.ID)
+	}, false)
+	if br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
+	before := customFieldSnapshot(emp.ID)
+	if err := config.DB.Transaction(func(tx *gorm.DB) error {
+		return saveCustomFields(tx, emp.ID, values)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	audit.Record(c, "employee.custom_fields", "employee", emp.ID, before, customFieldSnapshot(emp.ID))
+
+	rows := []EmployeeRow{{ID: emp.ID}}
+	attachCustomFields(c, rows)
+	c.JSON(http.StatusOK, gin.H{"message": "updated", "custom_fields": rows[0].CustomFields})
+}
+
+// ---- field definitions ----
+
+// CustomFieldRow is a field definition with its enum options.
+type CustomFieldRow struct {
+	models.CustomField
+	Options []string `json:"options,omitempty"`
+}
+
+func customFieldRow(f models.CustomField) CustomFieldRow {
+	return CustomFieldRow{CustomField: f, Options: f.OptionList()}
+}
+
+type customFieldInput struct {
+	Key          *string   `json:"key"`
+	Label        *string   `json:"label"`
+	Description  *string   `json:"description"`
+	Type         *string   `json:"type"`
+	Options      *[]string `json:"options"`
+	Required     *bool     `json:"required"`
+	MaxLength    *int      `json:"max_length"`
+	Pattern      *string   `json:"pattern"`
+	Min          *float64  `json:"min"`
+	Max          *float64  `json:"max"`
+	Visibility   *string   `json:"visibility"`
+	SelfEditable *bool     `json:"self_editable"`
+	Position     *int      `json:"position"`
+}
+
+// apply validates the input and copies it onto f. A field that already has
+// values keeps its key and type, and enum options in use cannot be removed.
+func (in customFieldInput) apply(f *models.CustomField) *badRequest {
+	var inUse int64
+	if f.ID != 0 {
+		config.DB.Model(&models.EmployeeFieldValue{}).Where("field_id = ?", f.ID).Count(&inUse)
+	}
+	if in.Key != nil && *in.Key != f.Key {
+		key := strings.TrimSpace(*in.Key)
+		if !fieldKeyPattern.MatchString(key) {
+			return &badRequest{"key must start with a letter and use only lowercase letters, digits and _ (max 60)"}
+		}
+		if inUse > 0 {
+			return &badRequest{"the key of a field with values cannot change"}
+		}
+		var n int64
+		config.DB.Model(&models.CustomField{}).Where("key = ? AND id <> ?", key, f.ID).Count(&n)
+		if n > 0 {
+			return &badRequest{"a field with this key already exists"}
+		}
+		f.Key = key
+	}
+	if in.Label != nil {
+		label := strings.TrimSpace(*in.Label)
+		if label == "" {
+			return &badRequest{"label required"}
+		}
+		f.Label = label
+	}
+	if in.Description != nil {
+		f.Description = *in.Description
+	}
+	if in.Type != nil && *in.Type != f.Type {
+		if !containsString(fieldTypes, *in.Type) {
+			return &badRequest{"type must be one of " + strings.Join(fieldTypes, ", ")}
+		}
+		if inUse > 0 {
+			return &badRequest{"the type of a field with values cannot change"}
+		}
+		f.Type = *in.Type
+	}
+	if in.Options != nil {
+		var opts []string
+		for _, o := range *in.Options {
+			if o = strings.TrimSpace(o); o != "" && !containsString(opts, o) {
+				opts = append(opts, o)
+			}
+		}
+		if inUse > 0 {
+			var used []string
+			config.DB.Model(&models.EmployeeFieldValue{}).Where("field_id = ?", f.ID).Distinct().Pluck("value", &used)
+			for _, u := range used {
+				if !containsString(opts, u) {
+					return &badRequest{fmt.Sprintf("option %q is still in use", u)}
+				}
+			}
+		}
+		f.Options = strings.Join(opts, "\n")
+	}
+	if f.Type == FieldEnum && len(f.OptionList()) == 0 {
+		return &badRequest{"enum fields need options"}
+	}
+	if in.Required != nil {
+		f.Required = *in.Required
+	}
+	if in.MaxLength != nil {
+		if *in.MaxLength < 1 {
+			return &badRequest{"max_length must be positive"}
+		}
+		f.MaxLength = in.MaxLength
+	}
+	if in.Pattern != nil {
+		if _, err := regexp.Compile(*in.Pattern); err != nil {
+			return &badRequest{"pattern is not a valid regular expression"}
+		}
+		f.Pattern = *in.Pattern
+	}
+	if in.Min != nil {
+		f.Min = in.Min
+	}
+	if in.Max != nil {
+		f.Max = in.Max
+	}
+	if f.Min != nil && f.Max != nil && *f.Min > *f.Max {
+		return &badRequest{"min must not exceed max"}
+	}
+	if in.Visibility != nil {
+		if _, ok := visibilityLevels[*in.Visibility]; !ok {
+			return &badRequest{"visibility must be self, manager or hr"}
+		}
+		f.Visibility = *in.Visibility
+	}
+	if f.Visibility == "" {
+		f.Visibility = "hr"
+	}
+	if in.SelfEditable != nil {
+		f.SelfEditable = *in.SelfEditable
+	}
+	if f.SelfEditable && f.Visibility != "self" {
+		return &badRequest{"only fields with self visibility can be self_editable"}
+	}
+	if in.Position != nil {
+		f.Position = *in.Position
+	}
+	return nil
+}
+
+// GET /api/custom-fields
+// Everyone sees the self and manager fields (to render profile forms);
+// hr fields are listed for custom_field.manage holders only.
+func ListCustomFields(c *gin.Context) {
+	fields, err := loadCustomFields()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch custom fields"})
+		return
+	}
+	hr := authz.Can(c, authz.CustomFieldManage)
+	rows := []CustomFieldRow{}
+	for _, f := range fields {
+		if hr || visibilityLevels[f.Visibility] <= levelManager {
+			rows = append(rows, customFieldRow(f))
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
+
+// POST /api/custom-fields
+func CreateCustomField(c *gin.Context) {
+	var in customFieldInput
+	if err := c.ShouldBindJSON(&in); err != nil || in.Key == nil || in.Label == nil || in.Type == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "key, label and type required"})
+		return
+	}
+	var f models.CustomField
+	if br := in.apply(&f); br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
+	if err := config.DB.Create(&f).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create custom field"})
+		return
+	}
+	audit.Record(c, "custom_field.create", "custom_field", f.ID, nil, f)
+	c.JSON(http.StatusCreated, gin.H{"data": customFieldRow(f)})
+}
+
+// PUT /api/custom-fields/:id
+// Stored values are not re-validated against changed rules.
+func UpdateCustomField(c *gin.Context) {
+	var in customFieldInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+	var f models.CustomField
+	if err := config.DB.First(&f, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "custom field not found"})
+		return
+	}
+	before := f
+	if br := in.apply(&f); br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
+	if err := config.DB.Save(&f).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	audit.Record(c, "custom_field.update", "custom_field", f.ID, before, f)
+	c.JSON(http.StatusOK, gin.H{"data": customFieldRow(f)})
+}
+
+// DELETE /api/custom-fields/:id
+// Removes the field together with every employee's value for it.
+func DeleteCustomField(c *gin.Context) {
+	var f models.CustomField
+	if err := config.DB.First(&f, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "custom field not found"})
+		return
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("field_id = ?", f.ID).Delete(&models.EmployeeFieldValue{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&f).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		return
+	}
+	audit.Record(c, "custom_field.delete", "custom_field", f.ID, f, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
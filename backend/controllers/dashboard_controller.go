@@ -3,11 +3,16 @@ package controllers
 import (
 	"fmt"
 	"net/http"
+	"peoplesoft/authz"
 	"peoplesoft/config"
+	"peoplesoft/employment"
+	"peoplesoft/holidays"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 type DashboardStats struct {
@@ -50,16 +55,42 @@ type RecentActivity struct {
 	Time    string `json:"time"`
 }
 
+// activeGoalStatuses are goals accepted and in progress, not yet approved/completed.
+var activeGoalStatuses = []string{"accepted", "employee_accepted", "manager_accepted", "in_progress", "in-progress", "pending", "submitted"}
+
+// dashboardScope limits db to rows whose userCol is the caller or an employee in
+// their scope (direct and skip-level reports, department scopes); holders of
+// perm see every row.
+func dashboardScope(c *gin.Context, db *gorm.DB, userCol, perm string) *gorm.DB {
+	if authz.Can(c, perm) {
+		return db
+	}
+	scoped := authz.ScopeEmployees(c, config.DB.Table("employees se").Where("se.deleted_at IS NULL"), "se.id", authz.View).
+		Select("se.user_id")
+	return db.Where(userCol+" = ? OR "+userCol+" IN (?)", c.GetUint("userID"), scoped)
+}
+
+func dashboardLeaves(c *gin.Context) *gorm.DB {
+	return dashboardScope(c, config.DB.Table("leaves l").Where("l.deleted_at IS NULL"), "l.user_id", authz.LeaveViewAll)
+}
+
+func dashboardGoals(c *gin.Context) *gorm.DB {
+	return dashboardScope(c, config.DB.Table("goals g").Where("g.deleted_at IS NULL"), "g.user_id", authz.ScopeAll)
+}
+
+func dashboardPerformances(c *gin.Context) *gorm.DB {
+	return dashboardScope(c, config.DB.Table("performances p").Where("p.deleted_at IS NULL"), "p.user_id", authz.ReviewViewAll)
+}
+
 func GetDashboardStats(c *gin.Context) {
 	email := c.GetString("email")
-	role := c.GetString("role")
 
 	fmt.Printf("\n=== Dashboard Stats Request ===\n")
-	fmt.Printf("Email: %s, Role: %s\n", email, role)
+	fmt.Printf("Email: %s\n", email)
 
 	// Get user ID from users table
 	var userID uint
-	err := config.DB.Table("users").Select("id").Where("email = ?", email).Scan(&userID).Error
+	err := config.DB.Table("users").Where("deleted_at IS NULL").Select("id").Where("email = ?", email).Scan(&userID).Error
 	if err != nil {
 		fmt.Printf("Error getting user ID: %v\n", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "User not found"})
@@ -67,160 +98,42 @@ func GetDashboardStats(c *gin.Context) {
 	}
 	fmt.Printf("User ID from users table: %d\n", userID)
 
+	// Every count covers the caller and the employees in their scope: an
+	// employee sees their own data, a manager adds their team, HR the company.
 	stats := DashboardStats{}
 
-	// For HR role with peoplesoftent.hr@gmail.com, let's count ALL pending leaves
-	if role == "hr" {
-		// Count all pending leaves in the system (including HR's own)
-		config.DB.Table("leaves").Where("status = ?", "pending").Count(&stats.PendingLeaves)
-		fmt.Printf("HR - Total Pending Leaves in system (including own): %d\n", stats.PendingLeaves)
-
-		// Count all goals (for testing)
-		var totalGoals int64
-		config.DB.Table("goals").Count(&totalGoals)
-		fmt.Printf("HR - Total Goals in system: %d\n", totalGoals)
-
-		// Count all active goals (accepted and in progress, not yet approved/completed)
-		config.DB.Table("goals").
-			Where("status IN (?)", []string{"accepted", "employee_accepted", "manager_accepted", "in_progress", "in-progress", "pending", "submitted"}).
-			Count(&stats.ActiveGoals)
-		fmt.Printf("HR - Active Goals: %d\n", stats.ActiveGoals)
-
-		// Count all performances in progress + goals submitted for approval
-		var performanceReviews int64
-		config.DB.Table("performances").
-			Where("status IN (?)", []string{"in_progress", "in-progress", "pending"}).
-			Count(&performanceReviews)
-
-		var goalsSubmitted int64
-		config.DB.Table("goals").
-			Where("status = ?", "submitted").
-			Count(&goalsSubmitted)
-
-		stats.UpcomingReviews = performanceReviews + goalsSubmitted
-		fmt.Printf("HR - Upcoming Reviews (Performance: %d + Goals Submitted: %d = Total: %d)\n", performanceReviews, goalsSubmitted, stats.UpcomingReviews)
-
-		// Count all employees
-		config.DB.Table("employees").Count(&stats.TeamSize)
-		fmt.Printf("HR - Team Size: %d\n", stats.TeamSize)
-
-	} else if role == "manager" {
-		// Manager sees their team's data + their own
-
-		// Count team members' pending leaves
-		var teamLeaves int64
-		config.DB.Table("leaves l").
-			Joins("JOIN employees e ON l.user_id = e.user_id").
-			Where("e.manager_id = ? AND l.status = ?", userID, "pending").
-			Count(&teamLeaves)
-
-		// Count manager's own pending leaves
-		var ownLeaves int64
-		config.DB.Table("leaves").
-			Where("user_id = ? AND status = ?", userID, "pending").
-			Count(&ownLeaves)
-
-		// Total = team + own
-		stats.PendingLeaves = teamLeaves + ownLeaves
-		fmt.Printf("Manager - Pending Leaves (Team: %d + Own: %d = Total: %d)\n", teamLeaves, ownLeaves, stats.PendingLeaves)
-
-		// Count team's active goals
-		var teamGoals int64
-		config.DB.Table("goals g").
-			Joins("JOIN employees e ON g.user_id = e.user_id").
-			Where("e.manager_id = ? AND g.status IN (?)", userID, []string{"accepted", "employee_accepted", "manager_accepted", "in_progress", "in-progress", "pending", "submitted"}).
-			Count(&teamGoals)
-
-		// Count manager's own active goals
-		var ownGoals int64
-		config.DB.Table("goals").
-			Where("user_id = ? AND status IN (?)", userID, []string{"accepted", "employee_accepted", "manager_accepted", "in_progress", "in-progress", "pending", "submitted"}).
-			Count(&ownGoals)
-
-		// Total = team + own
-		stats.ActiveGoals = teamGoals + ownGoals
-		fmt.Printf("Manager - Active Goals (Team: %d + Own: %d = Total: %d)\n", teamGoals, ownGoals, stats.ActiveGoals)
-
-		// Count team's upcoming reviews (performances + submitted goals)
-		var teamPerformanceReviews int64
-		config.DB.Table("performances p").
-			Joins("JOIN employees e ON p.user_id = e.user_id").
-			Where("e.manager_id = ? AND p.status IN (?)", userID, []string{"in_progress", "in-progress", "pending"}).
-			Count(&teamPerformanceReviews)
-
-		var teamGoalsSubmitted int64
-		config.DB.Table("goals g").
-			Joins("JOIN employees e ON g.user_id = e.user_id").
-			Where("e.manager_id = ? AND g.status = ?", userID, "submitted").
-			Count(&teamGoalsSubmitted)
-
-		// Count manager's own upcoming reviews (performances + submitted goals)
-		var ownPerformanceReviews int64
-		config.DB.Table("performances").
-			Where("user_id = ? AND status IN (?)", userID, []string{"in_progress", "in-progress", "pending"}).
-			Count(&ownPerformanceReviews)
-
-		var ownGoalsSubmitted int64
-		config.DB.Table("goals").
-			Where("user_id = ? AND status = ?", userID, "submitted").
-			Count(&ownGoalsSubmitted)
-
-		// Total = team + own (both performances and goals)
-		stats.UpcomingReviews = teamPerformanceReviews + teamGoalsSubmitted + ownPerformanceReviews + ownGoalsSubmitted
-		fmt.Printf("Manager - Upcoming Reviews (Team Perf: %d + Team Goals: %d + Own Perf: %d + Own Goals: %d = Total: %d)\n",
-			teamPerformanceReviews, teamGoalsSubmitted, ownPerformanceReviews, ownGoalsSubmitted, stats.UpcomingReviews)
-
-		config.DB.Table("employees").Where("manager_id = ?", userID).Count(&stats.TeamSize)
-		fmt.Printf("Manager - Team Size: %d\n", stats.TeamSize)
+	dashboardLeaves(c).Where("l.status = ?", "pending").Count(&stats.PendingLeaves)
+	dashboardGoals(c).Where("g.status IN (?)", activeGoalStatuses).Count(&stats.ActiveGoals)
 
-	} else {
-		// Employee sees their own data
-
-		// Count employee's pending leaves
-		config.DB.Table("leaves").
-			Where("user_id = ? AND status = ?", userID, "pending").
-			Count(&stats.PendingLeaves)
-		fmt.Printf("Employee - Pending Leaves: %d\n", stats.PendingLeaves)
-
-		// Active goals = accepted, in_progress, submitted (NOT completed or archived)
-		config.DB.Table("goals").
-			Where("user_id = ? AND status IN (?)", userID, []string{"accepted", "employee_accepted", "manager_accepted", "in_progress", "in-progress", "pending", "submitted"}).
-			Count(&stats.ActiveGoals)
-		fmt.Printf("✅ Employee - Active Goals: %d\n", stats.ActiveGoals)
-
-		// Count employee's upcoming reviews (performances + submitted goals)
-		var performanceReviews int64
-		config.DB.Table("performances").
-			Where("user_id = ? AND status IN (?)", userID, []string{"in_progress", "in-progress", "pending"}).
-			Count(&performanceReviews)
-
-		var goalsSubmitted int64
-		config.DB.Table("goals").
-			Where("user_id = ? AND status = ?", userID, "submitted").
-			Count(&goalsSubmitted)
-
-		stats.UpcomingReviews = performanceReviews + goalsSubmitted
-		fmt.Printf("Employee - Upcoming Reviews (Performance: %d + Goals Submitted: %d = Total: %d)\n", performanceReviews, goalsSubmitted, stats.UpcomingReviews)
-
-		// Team size is 0 for employees
-		stats.TeamSize = 0
+	// Upcoming reviews = performances in progress + goals submitted for approval
+	var performanceReviews, goalsSubmitted int64
+	dashboardPerformances(c).Where("p.status IN (?)", []string{"in_progress", "in-progress", "pending"}).Count(&performanceReviews)
+	dashboardGoals(c).Where("g.status = ?", "submitted").Count(&goalsSubmitted)
+	stats.UpcomingReviews = performanceReviews + goalsSubmitted
+
+	// Team size: current employees in scope besides the caller, everyone for employee.scope_all
+	team := authz.ScopeEmployees(c, config.DB.Table("employees e").
+		Where("e.deleted_at IS NULL AND e.status <> ?", employment.LifecycleTerminated), "e.id", authz.View)
+	if !authz.Can(c, authz.ScopeAll) {
+		team = team.Where("e.user_id <> ?", userID)
 	}
+	team.Count(&stats.TeamSize)
 
 	fmt.Printf("Final Stats: %+v\n", stats)
 
 	// Quarterly Results
-	quarterlyResults := getQuarterlyResults(role, userID)
+	quarterlyResults := getQuarterlyResults(c)
 	fmt.Printf("Quarterly Results: %+v\n", quarterlyResults)
 
-	// Top Performers (for manager/HR only)
+	// Top Performers (for reviewers only)
 	var topPerformers []TopPerformer
-	if role == "manager" || role == "hr" {
-		topPerformers = getTopPerformers(role, userID)
+	if authz.Can(c, authz.ReviewViewAll) || authz.Can(c, authz.PerformanceEdit) {
+		topPerformers = getTopPerformers(userID)
 	}
 
 	// Recent activity
-	activity := getRecentActivity(role, userID)
-	upcomingEvents := getUpcomingEvents()
+	activity := getRecentActivity(c)
+	upcomingEvents := getUpcomingEvents(userID)
 
 	response := gin.H{
 		"stats":             stats,
@@ -235,7 +148,7 @@ func GetDashboardStats(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
-func getQuarterlyResults(role string, userID uint) *QuarterlyResults {
+func getQuarterlyResults(c *gin.Context) *QuarterlyResults {
 	now := time.Now()
 	quarter := (int(now.Month())-1)/3 + 1
 	quarterName := ""
@@ -256,47 +169,19 @@ func getQuarterlyResults(role string, userID uint) *QuarterlyResults {
 	}
 
 	fmt.Printf("\n=== Calculating Quarterly Results ===\n")
-	fmt.Printf("Role: %s, UserID: %d\n", role, userID)
-
-	// For HR, count ALL goals in system
-	if role == "hr" {
-		// Count goals with status 'completed', 'approved', or ('submitted' AND progress = 100)
-		err := config.DB.Table("goals").
-			Where("status IN (?) OR (status = ? AND progress = ?)", []string{"completed", "approved", "hr_approved"}, "submitted", 100).
-			Count(&results.GoalsCompleted).Error
-
-		if err != nil {
-			fmt.Printf("❌ Error counting completed goals: %v\n", err)
-		} else {
-			fmt.Printf("✅ HR - Completed goals: %d\n", results.GoalsCompleted)
-		}
-
-		// Count total goals
-		config.DB.Table("goals").Count(&results.TotalGoals)
-
-	} else if role == "manager" {
-		// Manager sees their own goals (not team goals)
-		config.DB.Table("goals").
-			Where("user_id = ? AND (status IN (?) OR (status = ? AND progress = ?))",
-				userID, []string{"completed", "approved", "hr_approved"}, "submitted", 100).
-			Count(&results.GoalsCompleted)
-
-		config.DB.Table("goals").
-			Where("user_id = ?", userID).
-			Count(&results.TotalGoals)
+	fmt.Printf("UserID: %d\n", c.GetUint("userID"))
 
-	} else {
-		// Employee sees their own goals
-		config.DB.Table("goals").
-			Where("user_id = ? AND (status IN (?) OR (status = ? AND progress = ?))",
-				userID, []string{"completed", "approved", "hr_approved"}, "submitted", 100).
-			Count(&results.GoalsCompleted)
-
-		config.DB.Table("goals").
-			Where("user_id = ?", userID).
-			Count(&results.TotalGoals)
+	// Count goals with status 'completed', 'approved', or ('submitted' AND progress = 100)
+	err := dashboardGoals(c).
+		Where("g.status IN (?) OR (g.status = ? AND g.progress = ?)", []string{"completed", "approved", "hr_approved"}, "submitted", 100).
+		Count(&results.GoalsCompleted).Error
+	if err != nil {
+		fmt.Printf("❌ Error counting completed goals: %v\n", err)
 	}
 
+	// Count total goals
+	dashboardGoals(c).Count(&results.TotalGoals)
+
 	// Calculate percentage
 	if results.TotalGoals > 0 {
 		results.GoalsCompletedPercent = int((results.GoalsCompleted * 100) / results.TotalGoals)
@@ -310,23 +195,8 @@ func getQuarterlyResults(role string, userID uint) *QuarterlyResults {
 	results.EngagementChange = 5
 
 	// Reviews
-	if role == "hr" {
-		config.DB.Table("performances").Where("status = ?", "completed").Count(&results.ReviewsCompleted)
-		config.DB.Table("performances").Where("status != ?", "completed").Count(&results.ReviewsPending)
-	} else if role == "manager" {
-		config.DB.Table("performances p").
-			Joins("JOIN employees e ON p.user_id = e.user_id").
-			Where("e.manager_id = ? AND p.status = ?", userID, "completed").
-			Count(&results.ReviewsCompleted)
-
-		config.DB.Table("performances p").
-			Joins("JOIN employees e ON p.user_id = e.user_id").
-			Where("e.manager_id = ? AND p.status != ?", userID, "completed").
-			Count(&results.ReviewsPending)
-	} else {
-		config.DB.Table("performances").Where("employee_id = ? AND status = ?", userID, "completed").Count(&results.ReviewsCompleted)
-		config.DB.Table("performances").Where("employee_id = ? AND status != ?", userID, "completed").Count(&results.ReviewsPending)
-	}
+	dashboardPerformances(c).Where("p.status = ?", "completed").Count(&results.ReviewsCompleted)
+	dashboardPerformances(c).Where("p.status != ?", "completed").Count(&results.ReviewsPending)
 
 	fmt.Printf("🎯 Final Results: Quarter=%s, Year=%d, Completed=%d, Total=%d, Percent=%d%%\n",
 		results.Quarter, results.Year, results.GoalsCompleted, results.TotalGoals, results.GoalsCompletedPercent)
@@ -335,24 +205,24 @@ func getQuarterlyResults(role string, userID uint) *QuarterlyResults {
 	return results
 }
 
-func getTopPerformers(role string, userID uint) []TopPerformer {
+func getTopPerformers(userID uint) []TopPerformer {
 	var performers []TopPerformer
 	// Mock data for now
 	return performers
 }
 
-func getRecentActivity(role string, userID uint) []RecentActivity {
+func getRecentActivity(c *gin.Context) []RecentActivity {
 	// Combined activity structure with timestamp for sorting
 	type CombinedActivity struct {
-		Type         string
-		Message      string
-		Details      string
-		Time         string
-		CreatedAt    time.Time
+		Type      string
+		Message   string
+		Details   string
+		Time      string
+		CreatedAt time.Time
 	}
 	var combinedActivities []CombinedActivity
 
-	// Query for recent leave requests
+	// Query for recent leave requests in the caller's scope
 	type LeaveActivity struct {
 		EmployeeName string
 		StartDate    time.Time
@@ -362,34 +232,12 @@ func getRecentActivity(role string, userID uint) []RecentActivity {
 	}
 	var leaves []LeaveActivity
 
-	if role == "hr" {
-		// HR sees all recent leaves
-		config.DB.Table("leaves l").
-			Select("u.name as employee_name, l.start_date, l.end_date, l.status, l.created_at").
-			Joins("JOIN users u ON l.user_id = u.id").
-			Order("l.created_at DESC").
-			Limit(10).
-			Scan(&leaves)
-	} else if role == "manager" {
-		// Manager sees team + own leaves
-		config.DB.Table("leaves l").
-			Select("u.name as employee_name, l.start_date, l.end_date, l.status, l.created_at").
-			Joins("JOIN users u ON l.user_id = u.id").
-			Joins("LEFT JOIN employees e ON l.user_id = e.user_id").
-			Where("e.manager_id = ? OR l.user_id = ?", userID, userID).
-			Order("l.created_at DESC").
-			Limit(10).
-			Scan(&leaves)
-	} else {
-		// Employee sees their own leaves
-		config.DB.Table("leaves l").
-			Select("u.name as employee_name, l.start_date, l.end_date, l.status, l.created_at").
-			Joins("JOIN users u ON l.user_id = u.id").
-			Where("l.user_id = ?", userID).
-			Order("l.created_at DESC").
-			Limit(10).
-			Scan(&leaves)
-	}
+	dashboardLeaves(c).
+		Select("u.name as employee_name, l.start_date, l.end_date, l.status, l.created_at").
+		Joins("JOIN users u ON l.user_id = u.id").
+		Order("l.created_at DESC").
+		Limit(10).
+		Scan(&leaves)
 
 	// Add leave activities to combined list
 	for _, leave := range leaves {
@@ -411,7 +259,7 @@ func getRecentActivity(role string, userID uint) []RecentActivity {
 		})
 	}
 
-	// Query for recent goal submissions
+	// Query for recent goal submissions and approvals in the caller's scope
 	type GoalActivity struct {
 		EmployeeName string
 		Title        string
@@ -420,32 +268,13 @@ func getRecentActivity(role string, userID uint) []RecentActivity {
 	}
 	var goals []GoalActivity
 
-	if role == "hr" {
-		config.DB.Table("goals g").
-			Select("u.name as employee_name, g.title, g.status, g.created_at").
-			Joins("JOIN users u ON g.user_id = u.id").
-			Where("g.status IN (?)", []string{"submitted", "approved", "hr_approved"}).
-			Order("g.created_at DESC").
-			Limit(10).
-			Scan(&goals)
-	} else if role == "manager" {
-		config.DB.Table("goals g").
-			Select("u.name as employee_name, g.title, g.status, g.created_at").
-			Joins("JOIN users u ON g.user_id = u.id").
-			Joins("LEFT JOIN employees e ON g.user_id = e.user_id").
-			Where("(e.manager_id = ? OR g.user_id = ?) AND g.status IN (?)", userID, userID, []string{"submitted", "approved", "manager_accepted"}).
-			Order("g.created_at DESC").
-			Limit(10).
-			Scan(&goals)
-	} else {
-		config.DB.Table("goals g").
-			Select("u.name as employee_name, g.title, g.status, g.created_at").
-			Joins("JOIN users u ON g.user_id = u.id").
-			Where("g.user_id = ? AND g.status IN (?)", userID, []string{"submitted", "approved", "employee_accepted"}).
-			Order("g.created_at DESC").
-			Limit(10).
-			Scan(&goals)
-	}
+	dashboardGoals(c).
+		Select("u.name as employee_name, g.title, g.status, g.created_at").
+		Joins("JOIN users u ON g.user_id = u.id").
+		Where("g.status IN (?)", []string{"submitted", "approved", "hr_approved", "manager_accepted", "employee_accepted"}).
+		Order("g.created_at DESC").
+		Limit(10).
+		Scan(&goals)
 
 	// Add goal activities to combined list
 	for _, goal := range goals {
@@ -503,17 +332,29 @@ func getTimeAgo(t time.Time) string {
 	}
 }
 
-func getUpcomingEvents() []UpcomingEvent {
-	return []UpcomingEvent{
-		{
-			Date:  "25 DEC",
-			Title: "Christmas Day",
-			Desc:  "Company Holiday - Office Closed",
-		},
-		{
-			Date:  "01 JAN",
-			Title: "New Year's Day",
-			Desc:  "Company Holiday - Office Closed",
-		},
+// getUpcomingEvents lists the next holidays of the user's calendar, skipping
+// optional holidays they have not picked.
+func getUpcomingEvents(userID uint) []UpcomingEvent {
+	events := []UpcomingEvent{}
+	today := employment.Today()
+	schedule, err := holidays.ForUser(config.DB, userID, today, today.AddDate(0, 0, 90))
+	if err != nil {
+		fmt.Printf("Error loading holidays for user %d: %v\n", userID, err)
+		return events
+	}
+	for _, d := range schedule.Days {
+		if !d.Off() {
+			continue
+		}
+		date, _ := time.Parse(employment.DateLayout, d.Date)
+		desc := "Company Holiday - Office Closed"
+		if d.Optional {
+			desc = "Optional Holiday - Day Off"
+		}
+		events = append(events, UpcomingEvent{Date: strings.ToUpper(date.Format("02 Jan")), Title: d.Name, Desc: desc})
+		if len(events) == 5 {
+			break
+		}
 	}
+	return events
 }
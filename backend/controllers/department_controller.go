@@ -0,0 +1,334 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"peoplesoft/audit"
+	"peoplesoft/authz"
+	"peoplesoft/config"
+	"peoplesoft/employment"
+	"peoplesoft/models"
+	"peoplesoft/org"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// DepartmentRow is a department with its head and direct headcount.
+type DepartmentRow struct {
+	models.Department
+	HeadName  *string `json:"head_name"`
+	Headcount int     `json:"headcount"` // employees directly in this department
+}
+
+// departmentRows selects departments with head name and headcount.
+func departmentRows() *gorm.DB {
+	return config.DB.Table("departments d").
+		Select(`d.*, hu.name AS head_name,
+			(SELECT COUNT(*) FROM employees e WHERE e.department_id = d.id AND e.deleted_at IS NULL) AS headcount`).
+		Joins("LEFT JOIN employees he ON he.id = d.head_employee_id AND he.deleted_at IS NULL").
+		Joins("LEFT JOIN users hu ON hu.id = he.user_id")
+}
+
+// GET /api/departments?q=&parent_id=
+// parent_id=0 lists top-level departments.
+func ListDepartments(c *gin.Context) {
+	db := departmentRows()
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		like := "%" + q + "%"
+		db = db.Where("d.name ILIKE ? OR d.cost_center ILIKE ?", like, like)
+	}
+	if v := c.Query("parent_id"); v != "" {
+		if v == "0" {
+			db = db.Where("d.parent_id IS NULL")
+		} else {
+			db = db.Where("d.parent_id = ?", parseUint(v))
+		}
+	}
+	var rows []DepartmentRow
+	if err := db.Order("d.name asc").Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch departments"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"count": len(rows), "data": rows})
+}
+
+// GET /api/departments/:id
+// Includes the parent chain (nearest first) and direct sub-departments.
+func GetDepartment(c *gin.Context) {
+	var row DepartmentRow
+	if err := departmentRows().Where("d.id = ?", c.Param("id")).Scan(&row).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "lookup failed"})
+		return
+	}
+	if row.ID == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "department not found"})
+		return
+	}
+
+	var children []DepartmentRow
+	departmentRows().Where("d.parent_id = ?", row.ID).Order("d.name asc").Scan(&children)
+
+	var parents []models.Department
+	if ids, err := org.DepartmentAncestors(config.DB, row.ID); err == nil && len(ids) > 1 {
+		var found []models.Department
+		config.DB.Where("id IN ?", ids[1:]).Find(&found)
+		byID := map[uint]models.Department{}
+		for _, d := range found {
+			byID[d.ID] = d
+		}
+		for _, id := range ids[1:] {
+			if d, ok := byID[id]; ok {
+				parents = append(parents, d)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": row, "parents": parents, "children": children})
+}
+
+type departmentInput struct {
+	Name           *string `json:"name"`
+	ParentID       *uint   `json:"parent_id"`
+	HeadEmployeeID *uint   `json:"head_employee_id"`
+	CostCenter     *string `json:"cost_center"`
+	Description    *string `json:"description"`
+}
+
+// apply validates the input and copies it onto dept. A parent_id or
+// head_employee_id of 0 clears the field; an empty cost_center removes it.
+func (in departmentInput) apply(dept *models.Department) *badRequest {
+	if in.Name != nil {
+		name := strings.TrimSpace(*in.Name)
+		if name == "" {
+			return &badRequest{"name required"}
+		}
+		var n int64
+		config.DB.Model(&models.Department{}).Where("LOWER(name) = LOWER(?) AND id <> ?", name, dept.ID).Count(&n)
+		if n > 0 {
+			return &badRequest{"a department with this name already exists"}
+		}
+		dept.Name = name
+	}
+	if in.ParentID != nil {
+		if *in.ParentID == 0 {
+			dept.ParentID = nil
+		} else {
+			var parent models.Department
+			if err := config.DB.First(&parent, *in.ParentID).Error; err != nil {
+				return &badRequest{"parent department not found"}
+			}
+			if dept.ID != 0 {
+				cycle, err := org.DepartmentWouldCycle(config.DB, dept.ID, parent.ID)
+				if err != nil || cycle {
+					return &badRequest{"a department cannot be placed below itself"}
+				}
+			}
+			dept.ParentID = &parent.ID
+		}
+	}
+	if in.HeadEmployeeID != nil {
+		if *in.HeadEmployeeID == 0 {
+			dept.HeadEmployeeID = nil
+		} else {
+			var head models.Employee
+			if err := config.DB.First(&head, *in.HeadEmployeeID).Error; err != nil {
+				return &badRequest{"head employee not found"}
+			}
+			dept.HeadEmployeeID = &head.ID
+		}
+	}
+	if in.CostCenter != nil {
+		code := strings.ToUpper(strings.TrimSpace(*in.CostCenter))
+		if code == "" {
+			dept.CostCenter = nil
+		} else {
+			var n int64
+			config.DB.Model(&models.Department{}).Where("cost_center = ? AND id <> ?", code, dept.ID).Count(&n)
+			if n > 0 {
+				return &badRequest{"cost center already assigned to another department"}
+			}
+			dept.CostCenter = &code
+		}
+	}
+	if in.Description != nil {
+		dept.Description = *in.Description
+	}
+	return nil
+}
+
+// POST /api/departments
+func CreateDepartment(c *gin.Context) {
+	var in departmentInput
+	if err := c.ShouldBindJSON(&in); err != nil || in.Name == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name required"})
+		return
+	}
+	var dept models.Department
+	if br := in.apply(&dept); br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
+	if err := config.DB.Create(&dept).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create department"})
+		return
+	}
+	audit.Record(c, "department.create", "department", dept.ID, nil, dept)
+	c.JSON(http.StatusCreated, gin.H{"data": dept})
+}
+
+// PUT /api/departments/:id
+// Moving a department (parent_id) carries its sub-departments along.
+func UpdateDepartment(c *gin.Context) {
+	var in departmentInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+	var dept models.Department
+	if err := config.DB.First(&dept, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "department not found"})
+		return
+	}
+	before := dept
+	if br := in.apply(&dept); br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
+	if err := config.DB.Save(&dept).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	audit.Record(c, "department.update", "department", dept.ID, before, dept)
+	c.JSON(http.StatusOK, gin.H{"data": dept})
+}
+
+// DELETE /api/departments/:id
+// Only empty departments can be deleted: move employees and sub-departments first.
+func DeleteDepartment(c *gin.Context) {
+	var dept models.Department
+	if err := config.DB.First(&dept, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "department not found"})
+		return
+	}
+	var employees, children int64
+	config.DB.Model(&models.Employee{}).Where("department_id = ?", dept.ID).Count(&employees)
+	config.DB.Model(&models.Department{}).Where("parent_id = ?", dept.ID).Count(&children)
+	if employees > 0 || children > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error":           "department still has employees or sub-departments",
+			"employees":       employees,
+			"sub_departments": children,
+		})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("department_id = ?", dept.ID).Delete(&models.AccessScope{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&dept).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		return
+	}
+	audit.Record(c, "department.delete", "department", dept.ID, dept, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// departmentScope resolves :id and, unless include_sub=false, its
+// sub-departments, after checking the caller may see the department.
+func departmentScope(c *gin.Context) ([]uint, bool) {
+	id := parseUint(c.Param("id"))
+	var dept models.Department
+	if err := config.DB.First(&dept, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "department not found"})
+		return nil, false
+	}
+	if !authz.CanAccessDepartment(c, dept.ID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to view this department"})
+		return nil, false
+	}
+	if sub, err := strconv.ParseBool(c.DefaultQuery("include_sub", "true")); err == nil && !sub {
+		return []uint{dept.ID}, true
+	}
+	ids, err := org.DepartmentSubtree(config.DB, dept.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to resolve sub-departments"})
+		return nil, false
+	}
+	return ids, true
+}
+
+// GET /api/departments/:id/employees?include_sub=
+func ListDepartmentEmployees(c *gin.Context) {
+	ids, ok := departmentScope(c)
+	if !ok {
+		return
+	}
+	var rows []EmployeeRow
+	err := config.DB.Table("employees e").Where("e.deleted_at IS NULL").
+		Select(`e.id, e.user_id, u.name, u.email, e.designation, e.department_id, e.manager_id,
+			mu.name as manager_name, e.phone, e.location, e.status`).
+		Joins("JOIN users u ON u.id = e.user_id").
+		Joins("LEFT JOIN employees me ON me.id = e.manager_id AND me.deleted_at IS NULL").
+		Joins("LEFT JOIN users mu ON mu.id = me.user_id").
+		Where("e.department_id IN ? AND e.status <> ?", ids, employment.LifecycleTerminated).
+		Order("u.name asc").
+		Scan(&rows).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch employees"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"count": len(rows), "data": rows})
+}
+
+// GET /api/departments/:id/stats?include_sub=&cycle_id=
+// Headcount, pending leave requests, people on leave today and goal completion.
+func GetDepartmentStats(c *gin.Context) {
+	ids, ok := departmentScope(c)
+	if !ok {
+		return
+	}
+	members := config.DB.Table("employees").Where("deleted_at IS NULL").Select("user_id").Where("department_id IN ?", ids)
+
+	var headcount, pending, onLeave int64
+	config.DB.Model(&models.Employee{}).
+		Where("department_id IN ? AND status <> ?", ids, employment.LifecycleTerminated).
+		Count(&headcount)
+	config.DB.Model(&models.Leave{}).Where("status = ? AND user_id IN (?)", "pending", members).Count(&pending)
+	today := time.Now().Truncate(24 * time.Hour)
+	config.DB.Model(&models.Leave{}).
+		Where("status = ? AND start_date <= ? AND end_date >= ? AND user_id IN (?)", "approved", today, today, members).
+		Count(&onLeave)
+
+	var goals struct {
+		Total     int64
+		Completed int64
+	}
+	gq := config.DB.Model(&models.Goal{}).
+		Select("COUNT(*) AS total, COALESCE(SUM(CASE WHEN status = 'approved' THEN 1 ELSE 0 END), 0) AS completed").
+		Where("user_id IN (?)", members)
+	if v := c.Query("cycle_id"); v != "" {
+		gq = gq.Where("cycle_id = ?", parseUint(v))
+	}
+	gq.Scan(&goals)
+	rate := 0.0
+	if goals.Total > 0 {
+		rate = float64(goals.Completed) / float64(goals.Total)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"department_ids":       ids,
+		"headcount":            headcount,
+		"pending_leaves":       pending,
+		"on_leave_today":       onLeave,
+		"goals_total":          goals.Total,
+		"goals_completed":      goals.Completed,
+		"goal_completion_rate": rate,
+	}})
+}
@@ -0,0 +1,393 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"peoplesoft/audit"
+	"peoplesoft/authz"
+	"peoplesoft/config"
+	"peoplesoft/employment"
+	"peoplesoft/models"
+	"peoplesoft/org"
+	"peoplesoft/storage"
+	"peoplesoft/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DocumentCategories are the accepted document categories.
+var DocumentCategories = []string{
+	"offer_letter", "contract", "id_proof", "visa", "work_permit", "policy", "certificate", "payroll", "other",
+}
+
+// DocumentRow is a document with its employee, for listings across employees.
+type DocumentRow struct {
+	models.Document
+	EmployeeName string `json:"employee_name"`
+}
+
+// canAccessDocuments reports whether the caller may see and add documents of
+// the employee: the employee, anyone above them in their reporting line, and
+// document.manage holders who can access the employee.
+func canAccessDocuments(c *gin.Context, employeeID uint) bool {
+	me := authz.CallerEmployeeID(c)
+	if me != 0 && me == employeeID {
+		return true
+	}
+	if authz.Can(c, authz.DocumentManage) && authz.CanAccessEmployee(c, employeeID, authz.View) {
+		return true
+	}
+	if me == 0 {
+		return false
+	}
+	links, _, err := org.Chain(config.DB, employeeID)
+	if err != nil || len(links) == 0 {
+		return false
+	}
+	for _, l := range links[1:] {
+		if l.ID == me {
+			return true
+		}
+	}
+	return false
+}
+
+// loadDocument loads :id when its employee still exists and the caller may see it.
+func loadDocument(c *gin.Context) (*models.Document, bool) {
+	var doc models.Document
+	if err := config.DB.First(&doc, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return nil, false
+	}
+	var n int64
+	config.DB.Model(&models.Employee{}).Where("id = ?", doc.EmployeeID).Count(&n)
+	if n == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document not found"})
+		return nil, false
+	}
+	if !canAccessDocuments(c, doc.EmployeeID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to access this employee's documents"})
+		return nil, false
+	}
+	return &doc, true
+}
+
+func documentKey(employeeID uint) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("employees/%d/%s", employeeID, hex.EncodeToString(b)), nil
+}
+
+// removeDocumentBlobs deletes stored files after their rows are gone. Failures
+// only leave orphaned objects behind, so they are logged.
+func removeDocumentBlobs(keys []string) {
+	for _, key := range keys {
+		if err := storage.Documents.Delete(context.Background(), key); err != nil {
+			log.Printf("documents: failed to delete %s: %v", key, err)
+		}
+	}
+}
+
+// GET /api/documents/categories
+func ListDocumentCategories(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": DocumentCategories})
+}
+
+// GET /api/employees/:id/documents?category=&expired=
+func ListEmployeeDocuments(c *gin.Context) {
+	id := parseUint(c.Param("id"))
+	if !canAccessDocuments(c, id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to access this employee's documents"})
+		return
+	}
+	db := config.DB.Where("employee_id = ?", id)
+	if v := c.Query("category"); v != "" {
+		db = db.Where("category = ?", v)
+	}
+	if c.Query("expired") == "true" {
+		db = db.Where("expires_on < ?", employment.Today())
+	}
+	var docs []models.Document
+	if err := db.Order("created_at desc").Find(&docs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch documents"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": docs})
+}
+
+// POST /api/employees/:id/documents   (multipart: file, category, title, expires_on, checksum_sha256)
+// checksum_sha256, when given, must match the uploaded bytes.
+func UploadEmployeeDocument(c *gin.Context) {
+	var emp models.Employee
+	if err := config.DB.First(&emp, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "employee not found"})
+		return
+	}
+	if !canAccessDocuments(c, emp.ID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to access this employee's documents"})
+		return
+	}
+
+	category := c.PostForm("category")
+	if !containsString(DocumentCategories, category) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "category must be one of " + strings.Join(DocumentCategories, ", ")})
+		return
+	}
+	var expires *time.Time
+	if v := c.PostForm("expires_on"); v != "" {
+		d, br := parseDate("expires_on", v, time.Time{})
+		if br != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+			return
+		}
+		expires = &d
+	}
+
+	maxBytes := int64(utils.IntFromEnv("DOCUMENT_MAX_SIZE_MB", 20)) << 20
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file required (multipart field \"file\")"})
+		return
+	}
+	if fh.Size > maxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file too large (max %d MB)", maxBytes>>20)})
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file"})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(f, maxBytes+1))
+	f.Close()
+	if err != nil || len(data) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file"})
+		return
+	}
+	if int64(len(data)) > maxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file too large (max %d MB)", maxBytes>>20)})
+		return
+	}
+
+	sum := sha256.Sum256(data)
+	checksum := hex.EncodeToString(sum[:])
+	if want := strings.ToLower(strings.TrimSpace(c.PostForm("checksum_sha256"))); want != "" && want != checksum {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "checksum_sha256 does not match the uploaded file"})
+		return
+	}
+
+	name := filepath.Base(strings.ReplaceAll(fh.Filename, "\\", "/"))
+	title := strings.TrimSpace(c.PostForm("title"))
+	if title == "" {
+		title = name
+	}
+	if len(title) > 200 || len(name) > 255 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title or file name too long"})
+		return
+	}
+	contentType := http.DetectContentType(data)
+	if ct, _, err := mime.ParseMediaType(fh.Header.Get("Content-Type")); err == nil && ct != "application/octet-stream" {
+		contentType = ct
+	}
+
+	key, err := documentKey(emp.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "upload failed"})
+		return
+	}
+	if err := storage.Documents.Put(c.Request.Context(), key, data, contentType); err != nil {
+		log.Printf("documents: store %s: %v", key, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "document storage unavailable"})
+		return
+	}
+	uploader := c.GetUint("userID")
+	doc := models.Document{
+		EmployeeID:   emp.ID,
+		Category:     category,
+		Title:        title,
+		FileName:     name,
+		ContentType:  contentType,
+		Size:         int64(len(data)),
+		Checksum:     checksum,
+		StorageKey:   key,
+		ExpiresOn:    expires,
+		UploadedByID: &uploader,
+	}
+	if err := config.DB.Create(&doc).Error; err != nil {
+		removeDocumentBlobs([]string{key})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "upload failed"})
+		return
+	}
+	audit.Record(c, "document.upload", "document", doc.ID, nil, doc)
+	c.JSON(http.StatusCreated, gin.H{"data": doc})
+}
+
+// GET /api/documents/:id
+func GetDocument(c *gin.Context) {
+	doc, ok := loadDocument(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": doc})
+}
+
+// GET /api/documents/:id/download
+// The stored bytes are checked against the checksum recorded at upload;
+// a mismatch is reported instead of serving a corrupted or altered file.
+func DownloadDocument(c *gin.Context) {
+	doc, ok := loadDocument(c)
+	if !ok {
+		return
+	}
+	data, err := storage.Documents.Get(c.Request.Context(), doc.StorageKey)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "document file is missing from storage"})
+		return
+	}
+	if err != nil {
+		log.Printf("documents: load %s: %v", doc.StorageKey, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "document storage unavailable"})
+		return
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != doc.Checksum {
+		log.Printf("documents: checksum mismatch for document %d (%s)", doc.ID, doc.StorageKey)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "document failed checksum verification"})
+		return
+	}
+	audit.Record(c, "document.download", "document", doc.ID, nil, nil)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": doc.FileName}))
+	c.Header("X-Checksum-SHA256", doc.Checksum)
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, doc.ContentType, data)
+}
+
+// PUT /api/documents/:id   body: {title, category, expires_on}
+// expires_on "" removes the expiry date.
+func UpdateDocument(c *gin.Context) {
+	doc, ok := loadDocument(c)
+	if !ok {
+		return
+	}
+	if !canManageDocument(c, doc) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR or the uploader can change this document"})
+		return
+	}
+	var in struct {
+		Title     *string `json:"title"`
+		Category  *string `json:"category"`
+		ExpiresOn *string `json:"expires_on"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+	before := *doc
+	updates := map[string]any{}
+	if in.Title != nil {
+		title := strings.TrimSpace(*in.Title)
+		if title == "" || len(title) > 200 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "title must be 1-200 characters"})
+			return
+		}
+		updates["title"] = title
+	}
+	if in.Category != nil {
+		if !containsString(DocumentCategories, *in.Category) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "category must be one of " + strings.Join(DocumentCategories, ", ")})
+			return
+		}
+		updates["category"] = *in.Category
+	}
+	if in.ExpiresOn != nil {
+		if *in.ExpiresOn == "" {
+			updates["expires_on"] = nil
+		} else {
+			d, br := parseDate("expires_on", *in.ExpiresOn, time.Time{})
+			if br != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+				return
+			}
+			updates["expires_on"] = d
+		}
+	}
+	if len(updates) > 0 {
+		if err := config.DB.Model(doc).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+			return
+		}
+	}
+	config.DB.First(doc, doc.ID)
+	audit.Record(c, "document.update", "document", doc.ID, before, *doc)
+	c.JSON(http.StatusOK, gin.H{"data": doc})
+}
+
+// DELETE /api/documents/:id
+// Documents are soft-deleted like other employee records; the file stays in
+// storage until the employee is purged.
+func DeleteDocument(c *gin.Context) {
+	doc, ok := loadDocument(c)
+	if !ok {
+		return
+	}
+	if !canManageDocument(c, doc) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only HR or the uploader can delete this document"})
+		return
+	}
+	if err := config.DB.Delete(doc).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		return
+	}
+	audit.Record(c, "document.delete", "document", doc.ID, *doc, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// canManageDocument: document.manage holders and whoever uploaded it.
+func canManageDocument(c *gin.Context, doc *models.Document) bool {
+	if authz.Can(c, authz.DocumentManage) {
+		return true
+	}
+	return doc.UploadedByID != nil && *doc.UploadedByID == c.GetUint("userID")
+}
+
+// GET /api/documents/expiring?within_days=30&category=
+// Documents that expire within the window or have already expired, for the
+// employees the caller can access.
+func ListExpiringDocuments(c *gin.Context) {
+	days := utils.IntFromEnv("DOCUMENT_EXPIRY_WINDOW_DAYS", 30)
+	if v := parseUint(c.Query("within_days")); v > 0 && v <= 3650 {
+		days = int(v)
+	}
+	db := config.DB.Table("documents d").
+		Select("d.*, u.name AS employee_name").
+		Joins("JOIN employees e ON e.id = d.employee_id AND e.deleted_at IS NULL").
+		Joins("JOIN users u ON u.id = e.user_id").
+		Where("d.deleted_at IS NULL AND d.expires_on IS NOT NULL AND d.expires_on <= ?",
+			employment.Today().AddDate(0, 0, days))
+	if v := c.Query("category"); v != "" {
+		db = db.Where("d.category = ?", v)
+	}
+	var rows []DocumentRow
+	err := authz.ScopeEmployees(c, db, "d.employee_id", authz.View).
+		Order("d.expires_on asc, d.id asc").
+		Scan(&rows).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch documents"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"within_days": days, "data": rows})
+}
@@ -1,14 +1,22 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
+	"peoplesoft/audit"
+	"peoplesoft/authz"
 	"peoplesoft/config"
+	"peoplesoft/employment"
+	"peoplesoft/lifecycle"
 	"peoplesoft/models"
+	"peoplesoft/search"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 // DTO to include user fields in the directory row
@@ -23,14 +31,18 @@ type EmployeeRow struct {
 	ManagerName  *string `json:"manager_name"`
 	Phone        string  `json:"phone"`
 	Location     string  `json:"location"`
+	Status       string  `json:"status"`
+
+	CustomFields map[string]any `gorm:"-" json:"custom_fields,omitempty"` // only the fields the caller may see
 }
 
-// GET /api/employees?q=&department_id=&designation=&page=&page_size=
+// GET /api/employees?q=&department_id=&designation=&location=&role=&status=&skill=&skill_level=&skill_endorsed=&page=&page_size=&as_of=&cf.<key>=
+// cf.<key>.min= and cf.<key>.max= filter number and date custom fields by range.
 func ListEmployees(c *gin.Context) {
-	q := strings.TrimSpace(c.Query("q"))
-	designation := strings.TrimSpace(c.Query("designation"))
-	dept := strings.TrimSpace(c.Query("department_id"))
-	role := strings.TrimSpace(c.Query("role"))
+	asOf, ok := parseAsOf(c)
+	if !ok {
+		return
+	}
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	size, _ := strconv.Atoi(c.DefaultQuery("page_size", "10"))
 	if page < 1 {
@@ -40,27 +52,10 @@ func ListEmployees(c *gin.Context) {
 		size = 10
 	}
 
-	db := config.DB.Table("employees e").
-		Select(`e.id, e.user_id, u.name, u.email, e.designation, e.department_id, e.manager_id,
-			mu.name as manager_name, e.phone, e.location`).
-		Joins("JOIN users u ON u.id = e.user_id").
-		Joins("LEFT JOIN employees me ON me.id = e.manager_id").
-		Joins("LEFT JOIN users mu ON mu.id = me.user_id")
-
-	if q != "" {
-		like := "%" + q + "%"
-		db = db.Where("u.name ILIKE ? OR u.email ILIKE ?", like, like)
-	}
-	if designation != "" {
-		db = db.Where("e.designation ILIKE ?", "%"+designation+"%")
-	}
-	if dept != "" {
-		if did, err := strconv.Atoi(dept); err == nil {
-			db = db.Where("e.department_id = ?", did)
-		}
-	}
-	if role != "" {
-		db = db.Where("u.role = ?", role)
+	db, br := filterCustomFields(c, filterEmployees(c, scopedDirectory(c, asOf)))
+	if br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
 	}
 
 	var total int64
@@ -75,41 +70,109 @@ func ListEmployees(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch employees"})
 		return
 	}
+	if err := attachCustomFields(c, rows); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch custom fields"})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"page": page, "page_size": size, "total": total, "data": rows,
 	})
 }
 
+// POST /api/employees
+// start_date (YYYY-MM-DD) in the future creates the employee as hired until
+// then; probation_end_date puts them on probation until that date. The
+// default onboarding checklist (or onboarding_template_id) is started.
+// custom_fields sets custom profile fields by key.
 func CreateEmployee(c *gin.Context) {
-	var emp models.Employee
-	if err := c.ShouldBindJSON(&emp); err != nil {
+	var in struct {
+		models.Employee
+		StartDate            string         `json:"start_date"`
+		ProbationEndDate     string         `json:"probation_end_date"`
+		OnboardingTemplateID uint           `json:"onboarding_template_id"`
+		CustomFields         map[string]any `json:"custom_fields"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input: " + err.Error()})
 		return
 	}
+	emp := in.Employee
+
+	joining := lifecycle.Joining{TemplateID: in.OnboardingTemplateID}
+	var br *badRequest
+	if joining.StartDate, br = parseDate("start_date", in.StartDate, employment.Today()); br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
+	if in.ProbationEndDate != "" {
+		end, br := parseDate("probation_end_date", in.ProbationEndDate, time.Time{})
+		if br != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+			return
+		}
+		if !end.After(joining.StartDate) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "probation_end_date must be after start_date"})
+			return
+		}
+		joining.ProbationEnd = &end
+	}
+	emp.Status = joining.InitialStatus()
+
+	hr := authz.Can(c, authz.CustomFieldManage)
+	fields, br := parseCustomFields(in.CustomFields, func(f models.CustomField) bool {
+		return hr || visibilityLevels[f.Visibility] <= levelManager
+	}, true)
+	if br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
 
 	// Add logging
 	fmt.Printf("Creating employee: %+v\n", emp)
 
-	if err := config.DB.Create(&emp).Error; err != nil {
+	var tasks []models.LifecycleTask
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&emp).Error; err != nil {
+			return err
+		}
+		if err := saveCustomFields(tx, emp.ID, fields); err != nil {
+			return err
+		}
+		var err error
+		tasks, err = lifecycle.Join(tx, &emp, joining, c.GetUint("userID"))
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, lifecycle.ErrTemplateNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "onboarding template not found"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create employee: " + err.Error()})
 		return
 	}
 
 	fmt.Printf("Employee created with ID: %d\n", emp.ID)
-	c.JSON(http.StatusCreated, gin.H{"data": emp})
+	audit.Record(c, "employee.create", "employee", emp.ID, nil, emp)
+	if len(fields) > 0 {
+		audit.Record(c, "employee.custom_fields", "employee", emp.ID, nil, customFieldSnapshot(emp.ID))
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": emp, "onboarding_tasks": tasks})
 }
 
-// GET /api/employees/:id
+// GET /api/employees/:id?as_of=
 func GetEmployee(c *gin.Context) {
 	id := c.Param("id")
+	if !authz.CanAccessEmployee(c, parseUint(id), authz.View) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to view this employee"})
+		return
+	}
+	asOf, ok := parseAsOf(c)
+	if !ok {
+		return
+	}
 	var row EmployeeRow
-	err := config.DB.Table("employees e").
-		Select(`e.id, e.user_id, u.name, u.email, e.designation, e.department_id, e.manager_id,
-			mu.name as manager_name, e.phone, e.location`).
-		Joins("JOIN users u ON u.id = e.user_id").
-		Joins("LEFT JOIN employees me ON me.id = e.manager_id").
-		Joins("LEFT JOIN users mu ON mu.id = me.user_id").
+	err := employeeDirectory(asOf).
 		Where("e.id = ?", id).
 		Scan(&row).Error
 	if err != nil {
@@ -120,78 +183,168 @@ func GetEmployee(c *gin.Context) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"data": row})
+	rows := []EmployeeRow{row}
+	if err := attachCustomFields(c, rows); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "lookup failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rows[0]})
 }
 
 // PUT /api/employees/:id
+// Designation, department and manager changes go into the employment history:
+// effective_date (YYYY-MM-DD, default today) may lie in the past or the future,
+// in which case the change is scheduled. change_type, reason_code and note
+// describe it. manager_id 0 removes the manager. custom_fields sets custom
+// profile fields by key (null or "" clears one).
 func UpdateEmployee(c *gin.Context) {
 	id := c.Param("id")
+	if !authz.CanAccessEmployee(c, parseUint(id), authz.Manage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to manage this employee"})
+		return
+	}
 	var in struct {
-		Designation  *string `json:"designation"`
-		DepartmentID *uint   `json:"department_id"`
-		ManagerID    *uint   `json:"manager_id"`
-		Phone        *string `json:"phone"`
-		Location     *string `json:"location"`
+		Designation   *string `json:"designation"`
+		DepartmentID  *uint   `json:"department_id"`
+		ManagerID     *uint   `json:"manager_id"`
+		Phone         *string `json:"phone"`
+		Location      *string `json:"location"`
+		EffectiveDate string  `json:"effective_date"`
+		ChangeType    string  `json:"change_type"`
+		ReasonCode    string  `json:"reason_code"`
+		Note          string  `json:"note"`
+
+		CustomFields map[string]any `json:"custom_fields"`
 	}
 	if err := c.ShouldBindJSON(&in); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
 		return
 	}
-	updates := map[string]any{}
-	if in.Designation != nil {
-		updates["designation"] = *in.Designation
+
+	var emp models.Employee
+	if err := config.DB.First(&emp, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
 	}
-	if in.DepartmentID != nil {
-		updates["department_id"] = *in.DepartmentID
+
+	change := employment.Change{
+		Type:         in.ChangeType,
+		ReasonCode:   in.ReasonCode,
+		Note:         in.Note,
+		Designation:  in.Designation,
+		DepartmentID: in.DepartmentID,
 	}
 	if in.ManagerID != nil {
-		updates["manager_id"] = in.ManagerID
+		change.SetManager = true
+		if *in.ManagerID != 0 {
+			// The new manager must also be someone the caller manages (or the caller)
+			if *in.ManagerID != authz.CallerEmployeeID(c) && !authz.CanAccessEmployee(c, *in.ManagerID, authz.Manage) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to assign this manager"})
+				return
+			}
+			change.ManagerID = in.ManagerID
+		}
 	}
+	if br := validateEmploymentChange(&emp, &change, in.EffectiveDate); br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
+
+	updates := map[string]any{}
 	if in.Phone != nil {
 		updates["phone"] = *in.Phone
 	}
 	if in.Location != nil {
 		updates["location"] = *in.Location
 	}
+	fields, br := parseCustomFields(in.CustomFields, func(f models.CustomField) bool {
+		return canWriteField(c, f, emp.ID)
+	}, false)
+	if br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
+	fieldsBefore := customFieldSnapshot(emp.ID)
 
-	tx := config.DB.Model(&models.Employee{}).Where("id = ?", id).Updates(updates)
-	if tx.Error != nil {
+	before := emp
+	var rec *models.EmploymentChange
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&emp).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if err := saveCustomFields(tx, emp.ID, fields); err != nil {
+			return err
+		}
+		if in.Designation == nil && in.DepartmentID == nil && in.ManagerID == nil {
+			return nil
+		}
+		var err error
+		rec, err = employment.Record(tx, &emp, change, c.GetUint("userID"))
+		return err
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
 		return
 	}
-	if tx.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+	config.DB.First(&emp, emp.ID)
+	audit.Record(c, "employee.update", "employee", emp.ID, before, emp)
+	if len(fields) > 0 {
+		audit.Record(c, "employee.custom_fields", "employee", emp.ID, fieldsBefore, customFieldSnapshot(emp.ID))
+	}
+
+	if rec != nil && rec.Status == employment.StatusScheduled {
+		c.JSON(http.StatusOK, gin.H{"message": "change scheduled", "change": rec})
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"message": "updated"})
+	c.JSON(http.StatusOK, gin.H{"message": "updated", "change": rec})
 }
 
 // DELETE /api/employees/:id
+// Direct reports are handed over like on an exit: to the successor of a pending
+// offboarding, else to the employee's manager.
 func DeleteEmployee(c *gin.Context) {
 	id := c.Param("id")
-	tx := config.DB.Delete(&models.Employee{}, id)
-	if tx.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+	if !authz.CanAccessEmployee(c, parseUint(id), authz.Manage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to manage this employee"})
 		return
 	}
-	if tx.RowsAffected == 0 {
+	var emp models.Employee
+	if err := config.DB.First(&emp, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
 		return
 	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Direct reports move to the successor or the next manager up, as on an exit
+		if _, err := lifecycle.ReassignReports(tx, &emp, c.GetUint("userID")); err != nil {
+			return err
+		}
+		return tx.Delete(&emp).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		return
+	}
+	audit.Record(c, "employee.delete", "employee", emp.ID, emp, nil)
 	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
 }
 
-// GET /api/managers/:managerId/team
+// GET /api/managers/:managerId/team?as_of=
 func ListTeam(c *gin.Context) {
 	managerID := c.Param("managerId")
+	if !authz.CanAccessEmployee(c, parseUint(managerID), authz.View) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to view this team"})
+		return
+	}
+	asOf, ok := parseAsOf(c)
+	if !ok {
+		return
+	}
 	var rows []EmployeeRow
-	err := config.DB.Table("employees e").
-		Select(`e.id, e.user_id, u.name, u.email, e.designation, e.department_id, e.manager_id,
-			mu.name as manager_name, e.phone, e.location`).
-		Joins("JOIN users u ON u.id = e.user_id").
-		Joins("LEFT JOIN employees me ON me.id = e.manager_id").
-		Joins("LEFT JOIN users mu ON mu.id = me.user_id").
-		Where("e.manager_id = ?", managerID).
+	db := employeeDirectory(asOf).
+		Where("e.manager_id = ?", managerID)
+	err := authz.ScopeEmployees(c, db, "e.id", authz.View).
 		Order("u.name asc").
 		Scan(&rows).Error
 	if err != nil {
@@ -201,15 +354,20 @@ func ListTeam(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"count": len(rows), "data": rows})
 }
 
-// GET /api/my-team  (manager/hr)
+// GET /api/my-team?as_of=  (manager/hr)
 // Uses email from JWT to resolve the manager's Employee.ID, then returns direct reports.
 func ListMyTeam(c *gin.Context) {
+	asOf, ok := parseAsOf(c)
+	if !ok {
+		return
+	}
+
 	emailVal, _ := c.Get("email")
 	email, _ := emailVal.(string)
 
 	// resolve manager's employee.id from email
 	var managerEmp struct{ ID uint }
-	if err := config.DB.Table("employees e").
+	if err := config.DB.Table("employees e").Where("e.deleted_at IS NULL").
 		Select("e.id").
 		Joins("JOIN users u ON u.id = e.user_id").
 		Where("u.email = ?", email).
@@ -224,12 +382,7 @@ func ListMyTeam(c *gin.Context) {
 
 	// fetch team (direct reports)
 	var rows []EmployeeRow
-	if err := config.DB.Table("employees e").
-		Select(`e.id, e.user_id, u.name, u.email, e.designation, e.department_id, e.manager_id,
-			mu.name as manager_name, e.phone, e.location`).
-		Joins("JOIN users u ON u.id = e.user_id").
-		Joins("LEFT JOIN employees me ON me.id = e.manager_id").
-		Joins("LEFT JOIN users mu ON mu.id = me.user_id").
+	if err := employeeDirectory(asOf).
 		Where("e.manager_id = ?", managerEmp.ID).
 		Order("u.name asc").
 		Scan(&rows).Error; err != nil {
@@ -239,3 +392,90 @@ func ListMyTeam(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"count": len(rows), "data": rows})
 }
+
+// employeeDirectory selects EmployeeRow columns from the live employees table
+// or, with asOf, from the employees as they stood at the end of that date.
+func employeeDirectory(asOf *time.Time) *gorm.DB {
+	db := config.DB.Table("employees e").Where("e.deleted_at IS NULL")
+	if asOf != nil {
+		db = config.DB.Table("(?) AS e", employment.AsOf(config.DB, *asOf))
+	}
+	return db.
+		Select(`e.id, e.user_id, u.name, u.email, e.designation, e.department_id, e.manager_id,
+			mu.name as manager_name, e.phone, e.location, e.status`).
+		Joins("JOIN users u ON u.id = e.user_id").
+		Joins("LEFT JOIN employees me ON me.id = e.manager_id AND me.deleted_at IS NULL").
+		Joins("LEFT JOIN users mu ON mu.id = me.user_id")
+}
+
+// scopedDirectory is employeeDirectory limited to the employees the caller may
+// view: everyone with employee.scope_all, otherwise themselves and their scope.
+func scopedDirectory(c *gin.Context, asOf *time.Time) *gorm.DB {
+	return authz.ScopeEmployees(c, employeeDirectory(asOf), "e.id", authz.View)
+}
+
+// filterEmployees applies the directory filters q, designation, department_id,
+// location, role, status and skill from the query string. q goes through the search
+// index; custom field values only match for callers who can see all of them.
+// Former employees are left out unless status=terminated or status=all.
+func filterEmployees(c *gin.Context, db *gorm.DB) *gorm.DB {
+	switch status := strings.TrimSpace(c.Query("status")); status {
+	case "all":
+	case "":
+		db = db.Where("e.status <> ?", employment.LifecycleTerminated)
+	default:
+		db = db.Where("e.status = ?", status)
+	}
+	if q := search.Parse(c.Query("q")); !q.Empty() {
+		db = search.Match(db, q, searchCustomFields(c))
+	}
+	if designation := strings.TrimSpace(c.Query("designation")); designation != "" {
+		db = db.Where("e.designation ILIKE ?", "%"+designation+"%")
+	}
+	if did, err := strconv.Atoi(strings.TrimSpace(c.Query("department_id"))); err == nil {
+		db = db.Where("e.department_id = ?", did)
+	}
+	if location := strings.TrimSpace(c.Query("location")); location != "" {
+		db = db.Where("e.location = ?", location)
+	}
+	if role := strings.TrimSpace(c.Query("role")); role != "" {
+		db = db.Where("u.role = ?", role)
+	}
+	// skill (name or id, repeatable: all required), at skill_level or above,
+	// endorsed when skill_endorsed=true
+	minLevel, _ := strconv.Atoi(c.Query("skill_level"))
+	endorsed := c.Query("skill_endorsed") == "true"
+	for _, skill := range c.QueryArray("skill") {
+		if skill = strings.TrimSpace(skill); skill == "" {
+			continue
+		}
+		db = db.Where(`EXISTS (SELECT 1 FROM employee_skills esk JOIN skills sk ON sk.id = esk.skill_id
+			WHERE esk.employee_id = e.id AND (lower(sk.name) = lower(?) OR CAST(sk.id AS text) = ?)
+			AND esk.level >= ? AND (? = false OR esk.endorsed_at IS NOT NULL))`, skill, skill, minLevel, endorsed)
+	}
+	return db
+}
+
+// parseAsOf reads the optional as_of=YYYY-MM-DD query parameter, writing a 400
+// and returning false when it is malformed.
+func parseAsOf(c *gin.Context) (*time.Time, bool) {
+	v := c.Query("as_of")
+	if v == "" {
+		return nil, true
+	}
+	d, err := time.Parse(employment.DateLayout, v)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid as_of date, expected YYYY-MM-DD"})
+		return nil, false
+	}
+	return &d, true
+}
+
+// parseUint parses a numeric path parameter, returning 0 when invalid.
+func parseUint(s string) uint {
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(v)
+}
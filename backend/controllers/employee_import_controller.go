@@ -0,0 +1,441 @@
+package controllers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"peoplesoft/audit"
+	"peoplesoft/config"
+	"peoplesoft/lifecycle"
+	"peoplesoft/models"
+	"peoplesoft/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// importColumns is the column layout of import files; export writes "id", the
+// same columns and "status", so an export can be edited and imported elsewhere.
+var importColumns = []string{"name", "email", "designation", "department", "manager_email", "phone", "location"}
+
+// importMaxColumns is the widest file accepted: the export layout.
+var importMaxColumns = len(importColumns) + 2
+
+const importMaxBytes = 5 << 20
+
+// importRow is one data row of an import file and its validation outcome.
+type importRow struct {
+	Row          int      `json:"row"` // line in the file; the header is line 1
+	Name         string   `json:"name"`
+	Email        string   `json:"email"`
+	Designation  string   `json:"designation"`
+	Department   string   `json:"department"`
+	DepartmentID uint     `json:"department_id"`
+	ManagerEmail string   `json:"manager_email"`
+	Phone        string   `json:"phone"`
+	Location     string   `json:"location"`
+	Errors       []string `json:"errors,omitempty"`
+	UserID       uint     `json:"user_id,omitempty"`
+	EmployeeID   uint     `json:"employee_id,omitempty"`
+}
+
+func (r *importRow) fail(format string, args ...any) {
+	r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+}
+
+// POST /api/employees/import?dry_run=&send_invites=   (multipart "file": .csv or .xlsx)
+// The first row names the columns: name, email, designation, department (name
+// or id), manager_email, phone, location; only name and email are required.
+// Every row is validated and reported. With dry_run=true nothing is written;
+// otherwise the whole file is imported in one transaction, or not at all when
+// any row is invalid (422). Imported accounts have no password: send_invites=true
+// mails each person a link to set one, else they can use forgot-password.
+func ImportEmployees(c *gin.Context) {
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file required (multipart field \"file\")"})
+		return
+	}
+	if fh.Size > importMaxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file too large (max %d MB)", importMaxBytes>>20)})
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file"})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(f, importMaxBytes))
+	f.Close()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file"})
+		return
+	}
+
+	maxRows := utils.IntFromEnv("IMPORT_MAX_ROWS", 2000)
+	records, err := utils.ReadSpreadsheet(utils.SpreadsheetFormat(fh.Filename), data, maxRows+1, importMaxColumns)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not parse file: " + err.Error()})
+		return
+	}
+	rows, br := parseImportRows(records)
+	if br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
+	if len(rows) > maxRows {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("too many rows (max %d)", maxRows)})
+		return
+	}
+
+	invalid := validateImportRows(rows)
+	report := gin.H{"total": len(rows), "valid": len(rows) - invalid, "invalid": invalid, "rows": rows}
+
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+	if dryRun {
+		report["dry_run"] = true
+		c.JSON(http.StatusOK, report)
+		return
+	}
+	if invalid > 0 {
+		report["error"] = "file has invalid rows; nothing was imported"
+		c.JSON(http.StatusUnprocessableEntity, report)
+		return
+	}
+
+	if err := config.DB.Transaction(func(tx *gorm.DB) error {
+		return createImportRows(tx, rows, c.GetUint("userID"))
+	}); err != nil {
+		log.Printf("employee import failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "import failed"})
+		return
+	}
+
+	employeeIDs := make([]uint, len(rows))
+	for i, r := range rows {
+		employeeIDs[i] = r.EmployeeID
+	}
+	audit.Record(c, "employee.import", "employee", nil, nil, gin.H{
+		"file": fh.Filename, "count": len(rows), "employee_ids": employeeIDs,
+	})
+
+	if send, _ := strconv.ParseBool(c.Query("send_invites")); send {
+		report["invites_sent"] = sendImportInvites(rows)
+	}
+	report["message"] = fmt.Sprintf("imported %d employee(s)", len(rows))
+	c.JSON(http.StatusCreated, report)
+}
+
+// parseImportRows maps the header onto importColumns (case-insensitive, spaces
+// and dashes count as underscores) and returns the non-blank data rows.
+func parseImportRows(records [][]string) ([]*importRow, *badRequest) {
+	if len(records) == 0 {
+		return nil, &badRequest{"file is empty"}
+	}
+	col := map[string]int{}
+	for i, h := range records[0] {
+		key := strings.ToLower(strings.TrimSpace(h))
+		key = strings.NewReplacer(" ", "_", "-", "_").Replace(key)
+		if containsString(importColumns, key) {
+			col[key] = i
+		}
+	}
+	for _, required := range []string{"name", "email"} {
+		if _, ok := col[required]; !ok {
+			return nil, &badRequest{"missing column: " + required}
+		}
+	}
+
+	cell := func(rec []string, key string) string {
+		i, ok := col[key]
+		if !ok || i >= len(rec) {
+			return ""
+		}
+		return strings.TrimSpace(rec[i])
+	}
+	var rows []*importRow
+	for n, rec := range records[1:] {
+		if strings.TrimSpace(strings.Join(rec, "")) == "" {
+			continue
+		}
+		rows = append(rows, &importRow{
+			Row:          n + 2,
+			Name:         cell(rec, "name"),
+			Email:        normalizeEmail(cell(rec, "email")),
+			Designation:  cell(rec, "designation"),
+			Department:   cell(rec, "department"),
+			ManagerEmail: normalizeEmail(cell(rec, "manager_email")),
+			Phone:        cell(rec, "phone"),
+			Location:     cell(rec, "location"),
+		})
+	}
+	if len(rows) == 0 {
+		return nil, &badRequest{"file has no data rows"}
+	}
+	return rows, nil
+}
+
+// validateImportRows checks every row against the database and the rest of
+// the file, fills DepartmentID and returns the number of invalid rows.
+func validateImportRows(rows []*importRow) int {
+	byEmail := map[string]*importRow{}
+	var emails, managerEmails []string
+	for _, r := range rows {
+		if r.Email != "" {
+			emails = append(emails, r.Email)
+		}
+		if r.ManagerEmail != "" {
+			managerEmails = append(managerEmails, r.ManagerEmail)
+		}
+	}
+
+	// deleted accounts keep their email until purged
+	var taken []string
+	if len(emails) > 0 {
+		config.DB.Unscoped().Model(&models.User{}).Where("LOWER(email) IN ?", emails).Pluck("LOWER(email)", &taken)
+	}
+	// existing managers: email → has an employee record
+	managers := map[string]bool{}
+	if len(managerEmails) > 0 {
+		var found []struct {
+			Email      string
+			EmployeeID *uint
+		}
+		config.DB.Table("users u").Where("u.deleted_at IS NULL").
+			Select("LOWER(u.email) AS email, e.id AS employee_id").
+			Joins("LEFT JOIN employees e ON e.user_id = u.id AND e.deleted_at IS NULL").
+			Where("LOWER(u.email) IN ?", uniqueStrings(managerEmails)).
+			Scan(&found)
+		for _, m := range found {
+			managers[m.Email] = managers[m.Email] || m.EmployeeID != nil
+		}
+	}
+	var departments []models.Department
+	config.DB.Find(&departments)
+
+	for _, r := range rows {
+		if r.Name == "" {
+			r.fail("name is required")
+		} else if len(r.Name) > 255 {
+			r.fail("name is too long")
+		}
+		switch {
+		case r.Email == "":
+			r.fail("email is required")
+		case !validEmail(r.Email):
+			r.fail("email %q is not a valid address", r.Email)
+		case byEmail[r.Email] != nil:
+			r.fail("email %s already appears on row %d", r.Email, byEmail[r.Email].Row)
+		case containsString(taken, r.Email):
+			r.fail("a user with email %s already exists", r.Email)
+		default:
+			byEmail[r.Email] = r
+		}
+		if len(r.Designation) > 100 {
+			r.fail("designation is too long (max 100 characters)")
+		}
+		if r.Department != "" {
+			for _, d := range departments {
+				if strings.EqualFold(d.Name, r.Department) || strconv.FormatUint(uint64(d.ID), 10) == r.Department {
+					r.DepartmentID = d.ID
+					break
+				}
+			}
+			if r.DepartmentID == 0 {
+				r.fail("department %q not found", r.Department)
+			}
+		}
+	}
+
+	for _, r := range rows {
+		if r.ManagerEmail == "" {
+			continue
+		}
+		if r.ManagerEmail == r.Email {
+			r.fail("an employee cannot be their own manager")
+			continue
+		}
+		if has, ok := managers[r.ManagerEmail]; ok {
+			if !has {
+				r.fail("manager %s has no employee record", r.ManagerEmail)
+			}
+			continue
+		}
+		if byEmail[r.ManagerEmail] == nil {
+			r.fail("manager %s is neither an existing employee nor in this file", r.ManagerEmail)
+			continue
+		}
+		// Existing employees never report to new ones, so a cycle can only run through the file.
+		seen := map[string]bool{r.Email: true}
+		for m := byEmail[r.ManagerEmail]; m != nil; m = byEmail[m.ManagerEmail] {
+			if seen[m.Email] {
+				r.fail("manager %s leads to a reporting cycle", r.ManagerEmail)
+				break
+			}
+			seen[m.Email] = true
+		}
+	}
+
+	invalid := 0
+	for _, r := range rows {
+		if len(r.Errors) > 0 {
+			invalid++
+		}
+	}
+	return invalid
+}
+
+// createImportRows creates the users and employees of validated rows, then
+// sets managers (which may be other rows) and records each hire, starting the
+// default onboarding checklist.
+func createImportRows(tx *gorm.DB, rows []*importRow, actorID uint) error {
+	emps := map[string]*models.Employee{}
+	for _, r := range rows {
+		user := models.User{Name: r.Name, Email: r.Email, Role: "employee", DepartmentID: r.DepartmentID}
+		if err := tx.Create(&user).Error; err != nil {
+			return fmt.Errorf("row %d: %w", r.Row, err)
+		}
+		emp := models.Employee{
+			UserID:       user.ID,
+			Designation:  r.Designation,
+			DepartmentID: r.DepartmentID,
+			Phone:        r.Phone,
+			Location:     r.Location,
+		}
+		if err := tx.Create(&emp).Error; err != nil {
+			return fmt.Errorf("row %d: %w", r.Row, err)
+		}
+		r.UserID, r.EmployeeID = user.ID, emp.ID
+		emps[r.Email] = &emp
+	}
+
+	for _, r := range rows {
+		emp := emps[r.Email]
+		if r.ManagerEmail != "" {
+			if m, ok := emps[r.ManagerEmail]; ok {
+				emp.ManagerID = &m.ID
+			} else {
+				var mgr struct{ ID uint }
+				if err := tx.Table("employees e").Where("e.deleted_at IS NULL").Select("e.id").
+					Joins("JOIN users u ON u.id = e.user_id").
+					Where("LOWER(u.email) = ?", r.ManagerEmail).
+					Scan(&mgr).Error; err != nil || mgr.ID == 0 {
+					return fmt.Errorf("row %d: manager %s not found", r.Row, r.ManagerEmail)
+				}
+				emp.ManagerID = &mgr.ID
+			}
+			if err := tx.Model(emp).Update("manager_id", emp.ManagerID).Error; err != nil {
+				return fmt.Errorf("row %d: %w", r.Row, err)
+			}
+		}
+		if _, err := lifecycle.Join(tx, emp, lifecycle.Joining{}, actorID); err != nil {
+			return fmt.Errorf("row %d: %w", r.Row, err)
+		}
+	}
+	return nil
+}
+
+// sendImportInvites mails every imported person a link to set their password
+// and returns how many were sent.
+func sendImportInvites(rows []*importRow) int {
+	ttl := utils.DurationFromEnv("IMPORT_INVITE_TTL", 72*time.Hour)
+	sent := 0
+	for _, r := range rows {
+		user := models.User{ID: r.UserID, Name: r.Name, Email: r.Email}
+		link, err := passwordResetLink(user, ttl)
+		if err != nil {
+			log.Printf("invite link for %s failed: %v", r.Email, err)
+			continue
+		}
+		body := fmt.Sprintf("Hi %s,\n\nAn account has been created for you in PeopleSoft. Use the link below to choose your password. It expires in %d hours and can be used once.\n\n%s",
+			r.Name, int(ttl.Hours()), link)
+		if err := utils.Mail.Send(r.Email, "Welcome to PeopleSoft", body); err != nil {
+			log.Printf("invite mail to %s failed: %v", r.Email, err)
+			continue
+		}
+		sent++
+	}
+	return sent
+}
+
+func validEmail(s string) bool {
+	addr, err := mail.ParseAddress(s)
+	return err == nil && addr.Address == s
+}
+
+// GET /api/employees/export?format=csv|xlsx&q=&department_id=&designation=&location=&role=&as_of=&cf.<key>=
+// Exports the directory with the same filters and scope as ListEmployees, unpaginated.
+func ExportEmployees(c *gin.Context) {
+	format := strings.ToLower(c.DefaultQuery("format", utils.FormatCSV))
+	if format != utils.FormatCSV && format != utils.FormatXLSX {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv or xlsx"})
+		return
+	}
+	asOf, ok := parseAsOf(c)
+	if !ok {
+		return
+	}
+
+	db, br := filterCustomFields(c, filterEmployees(c, scopedDirectory(c, asOf)))
+	if br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
+
+	var rows []struct {
+		EmployeeRow
+		DepartmentName *string
+		ManagerEmail   *string
+	}
+	err := db.
+		Select(`e.id, e.user_id, u.name, u.email, e.designation, e.department_id, e.manager_id,
+			mu.name as manager_name, e.phone, e.location, e.status, d.name AS department_name, mu.email AS manager_email`).
+		Joins("LEFT JOIN departments d ON d.id = e.department_id").
+		Order("u.name asc").
+		Scan(&rows).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch employees"})
+		return
+	}
+
+	table := [][]string{append(append([]string{"id"}, importColumns...), "status")}
+	deref := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	for _, r := range rows {
+		table = append(table, []string{
+			strconv.FormatUint(uint64(r.ID), 10), r.Name, r.Email, r.Designation,
+			deref(r.DepartmentName), deref(r.ManagerEmail), r.Phone, r.Location, r.Status,
+		})
+	}
+
+	var buf bytes.Buffer
+	if err := utils.WriteSpreadsheet(&buf, format, table); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to write export"})
+		return
+	}
+	audit.Record(c, "employee.export", "employee", nil, nil, gin.H{
+		"format": format, "count": len(rows), "filters": c.Request.URL.RawQuery,
+	})
+
+	contentType := "text/csv; charset=utf-8"
+	if format == utils.FormatXLSX {
+		contentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	name := "employees-" + time.Now().Format("20060102")
+	if asOf != nil {
+		name = "employees-as-of-" + asOf.Format("20060102")
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, name, format))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
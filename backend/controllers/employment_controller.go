@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"peoplesoft/audit"
+	"peoplesoft/authz"
+	"peoplesoft/config"
+	"peoplesoft/employment"
+	"peoplesoft/models"
+	"peoplesoft/org"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// validateEmploymentChange checks an employment change for emp and sets its
+// effective date (YYYY-MM-DD, empty for today).
+func validateEmploymentChange(emp *models.Employee, ch *employment.Change, effectiveDate string) *badRequest {
+	ch.EffectiveDate = employment.Today()
+	if effectiveDate != "" {
+		d, err := time.Parse(employment.DateLayout, effectiveDate)
+		if err != nil {
+			return &badRequest{"invalid effective_date, expected YYYY-MM-DD"}
+		}
+		ch.EffectiveDate = d
+	}
+	if ch.EffectiveDate.Before(employment.DateOf(emp.CreatedAt)) {
+		return &badRequest{"effective_date is before the employee was created"}
+	}
+	if ch.Type != "" && !containsString(employment.ChangeTypes, ch.Type) {
+		return &badRequest{"unknown change_type"}
+	}
+	if ch.ReasonCode != "" && !containsString(employment.ReasonCodes, ch.ReasonCode) {
+		return &badRequest{"unknown reason_code"}
+	}
+	if ch.DepartmentID != nil {
+		var n int64
+		config.DB.Model(&models.Department{}).Where("id = ?", *ch.DepartmentID).Count(&n)
+		if n == 0 {
+			return &badRequest{"department not found"}
+		}
+	}
+	if ch.ManagerID != nil {
+		// Nobody may end up as their own (indirect) manager
+		cycle, err := org.WouldCreateCycle(config.DB, emp.ID, *ch.ManagerID)
+		if err != nil {
+			return &badRequest{"failed to check reporting line"}
+		}
+		if cycle {
+			return &badRequest{"manager change would create a reporting cycle"}
+		}
+	}
+	return nil
+}
+
+// GET /api/employment/change-types
+func ListEmploymentChangeTypes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"change_types": employment.ChangeTypes, "reason_codes": employment.ReasonCodes})
+}
+
+// GET /api/employees/:id/history
+// Every recorded change, newest effective date first, including scheduled ones.
+func ListEmploymentHistory(c *gin.Context) {
+	id := parseUint(c.Param("id"))
+	if !authz.CanAccessEmployee(c, id, authz.View) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to view this employee"})
+		return
+	}
+	var rows []models.EmploymentChange
+	if err := config.DB.Where("employee_id = ?", id).
+		Order("effective_date desc, id desc").
+		Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "fetch failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
+
+// DELETE /api/employees/:id/history/:changeId
+// Cancels a scheduled (future-dated) change; applied changes are corrected with a new change.
+func CancelEmploymentChange(c *gin.Context) {
+	id := parseUint(c.Param("id"))
+	if !authz.CanAccessEmployee(c, id, authz.Manage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to manage this employee"})
+		return
+	}
+	var rec models.EmploymentChange
+	if err := config.DB.Where("id = ? AND employee_id = ?", c.Param("changeId"), id).First(&rec).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "change not found"})
+		return
+	}
+	if rec.EmployeeStatus != nil && (*rec.EmployeeStatus == employment.LifecycleOnNotice || *rec.EmployeeStatus == employment.LifecycleTerminated) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "exits are withdrawn with DELETE /api/employees/:id/offboarding"})
+		return
+	}
+	if err := employment.Cancel(config.DB, id, rec.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "only scheduled changes can be cancelled"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cancel failed"})
+		return
+	}
+	after := rec
+	after.Status = employment.StatusCancelled
+	audit.Record(c, "employment_change.cancel", "employment_change", rec.ID, rec, after)
+	c.JSON(http.StatusOK, gin.H{"message": "change cancelled"})
+}
@@ -0,0 +1,585 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"peoplesoft/audit"
+	"peoplesoft/authz"
+	"peoplesoft/config"
+	"peoplesoft/employment"
+	"peoplesoft/holidays"
+	"peoplesoft/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// icsMaxBytes caps iCalendar uploads.
+const icsMaxBytes = 1 << 20
+
+// HolidayCalendarRow is a calendar with its lists split out.
+type HolidayCalendarRow struct {
+	models.HolidayCalendar
+	Locations   []string `json:"locations"`
+	WeekendDays []string `json:"weekend_days"`
+}
+
+func holidayCalendarRow(cal models.HolidayCalendar) HolidayCalendarRow {
+	row := HolidayCalendarRow{HolidayCalendar: cal, Locations: cal.LocationList(), WeekendDays: cal.WeekendList()}
+	if row.Locations == nil {
+		row.Locations = []string{}
+	}
+	return row
+}
+
+type holidayCalendarInput struct {
+	Name                *string   `json:"name"`
+	Country             *string   `json:"country"`
+	Locations           *[]string `json:"locations"`
+	WeekendDays         *[]string `json:"weekend_days"`
+	IsDefault           *bool     `json:"is_default"`
+	MaxOptionalHolidays *int      `json:"max_optional_holidays"`
+}
+
+// apply validates the input onto cal. A location belongs to one calendar at
+// most; locations match employees' locations regardless of case.
+func (in holidayCalendarInput) apply(cal *models.HolidayCalendar) *badRequest {
+	if in.Name != nil {
+		name := strings.TrimSpace(*in.Name)
+		if name == "" || len(name) > 100 {
+			return &badRequest{"name must be 1-100 characters"}
+		}
+		var n int64
+		config.DB.Model(&models.HolidayCalendar{}).Where("lower(name) = lower(?) AND id <> ?", name, cal.ID).Count(&n)
+		if n > 0 {
+			return &badRequest{"a calendar named " + name + " already exists"}
+		}
+		cal.Name = name
+	}
+	if in.Country != nil {
+		if len(*in.Country) > 60 {
+			return &badRequest{"country must be at most 60 characters"}
+		}
+		cal.Country = strings.TrimSpace(*in.Country)
+	}
+	if in.Locations != nil {
+		var locations []string
+		seen := map[string]bool{}
+		for _, l := range *in.Locations {
+			l = strings.TrimSpace(l)
+			if l == "" || seen[strings.ToLower(l)] {
+				continue
+			}
+			if len(l) > 100 || strings.Contains(l, "\n") {
+				return &badRequest{"invalid location " + l}
+			}
+			seen[strings.ToLower(l)] = true
+			locations = append(locations, l)
+		}
+		var others []models.HolidayCalendar
+		config.DB.Where("id <> ?", cal.ID).Find(&others)
+		for _, o := range others {
+			for _, l := range o.LocationList() {
+				if seen[strings.ToLower(l)] {
+					return &badRequest{l + " already uses the " + o.Name + " calendar"}
+				}
+			}
+		}
+		cal.Locations = strings.Join(locations, "\n")
+	}
+	if in.WeekendDays != nil {
+		days, err := holidays.NormalizeWeekend(*in.WeekendDays)
+		if err != nil {
+			return &badRequest{err.Error()}
+		}
+		cal.WeekendDays = strings.Join(days, ",")
+	}
+	if in.IsDefault != nil {
+		cal.IsDefault = *in.IsDefault
+	}
+	if in.MaxOptionalHolidays != nil {
+		if *in.MaxOptionalHolidays < 0 || *in.MaxOptionalHolidays > 366 {
+			return &badRequest{"max_optional_holidays must be 0-366"}
+		}
+		cal.MaxOptionalHolidays = *in.MaxOptionalHolidays
+	}
+	return nil
+}
+
+// saveHolidayCalendar saves cal; a default calendar replaces the previous one.
+func saveHolidayCalendar(cal *models.HolidayCalendar) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if cal.IsDefault {
+			if err := tx.Model(&models.HolidayCalendar{}).Where("id <> ? AND is_default", cal.ID).
+				Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(cal).Error
+	})
+}
+
+// GET /api/holiday-calendars
+func ListHolidayCalendars(c *gin.Context) {
+	var cals []models.HolidayCalendar
+	if err := config.DB.Order("name").Find(&cals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch calendars"})
+		return
+	}
+	rows := make([]HolidayCalendarRow, len(cals))
+	for i, cal := range cals {
+		rows[i] = holidayCalendarRow(cal)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
+
+// POST /api/holiday-calendars
+// body: {name, country, locations, weekend_days (default saturday, sunday), is_default, max_optional_holidays}
+func CreateHolidayCalendar(c *gin.Context) {
+	var in holidayCalendarInput
+	if err := c.ShouldBindJSON(&in); err != nil || in.Name == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name required"})
+		return
+	}
+	cal := models.HolidayCalendar{WeekendDays: strings.Join(holidays.DefaultWeekend, ",")}
+	if br := in.apply(&cal); br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
+	if err := saveHolidayCalendar(&cal); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create calendar"})
+		return
+	}
+	audit.Record(c, "holiday_calendar.create", "holiday_calendar", cal.ID, nil, holidayCalendarRow(cal))
+	c.JSON(http.StatusCreated, gin.H{"data": holidayCalendarRow(cal)})
+}
+
+// PUT /api/holiday-calendars/:id
+func UpdateHolidayCalendar(c *gin.Context) {
+	var in holidayCalendarInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+	var cal models.HolidayCalendar
+	if err := config.DB.First(&cal, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "calendar not found"})
+		return
+	}
+	before := holidayCalendarRow(cal)
+	if br := in.apply(&cal); br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
+	if err := saveHolidayCalendar(&cal); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	audit.Record(c, "holiday_calendar.update", "holiday_calendar", cal.ID, before, holidayCalendarRow(cal))
+	c.JSON(http.StatusOK, gin.H{"data": holidayCalendarRow(cal)})
+}
+
+// DELETE /api/holiday-calendars/:id
+// Removes the calendar with its holidays and the optional holidays picked from it.
+func DeleteHolidayCalendar(c *gin.Context) {
+	var cal models.HolidayCalendar
+	if err := config.DB.First(&cal, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "calendar not found"})
+		return
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("holiday_id IN (?)", tx.Model(&models.Holiday{}).Select("id").Where("calendar_id = ?", cal.ID)).
+			Delete(&models.OptionalHolidayChoice{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("calendar_id = ?", cal.ID).Delete(&models.Holiday{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&cal).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		return
+	}
+	audit.Record(c, "holiday_calendar.delete", "holiday_calendar", cal.ID, holidayCalendarRow(cal), nil)
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// yearParam reads ?year=, defaulting to the current year.
+func yearParam(c *gin.Context) (int, bool) {
+	year := time.Now().Year()
+	if v := c.Query("year"); v != "" {
+		y, err := strconv.Atoi(v)
+		if err != nil || y < 1900 || y > 2999 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid year"})
+			return 0, false
+		}
+		year = y
+	}
+	return year, true
+}
+
+func yearRange(year int) (time.Time, time.Time) {
+	return time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC)
+}
+
+// GET /api/holiday-calendars/:id/holidays?year=
+func ListHolidays(c *gin.Context) {
+	year, ok := yearParam(c)
+	if !ok {
+		return
+	}
+	from, to := yearRange(year)
+	var rows []models.Holiday
+	if err := config.DB.Where("calendar_id = ? AND date BETWEEN ? AND ?", parseUint(c.Param("id")),
+		from.Format(employment.DateLayout), to.Format(employment.DateLayout)).
+		Order("date, name").Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch holidays"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"year": year, "data": rows})
+}
+
+type holidayInput struct {
+	Date     *string `json:"date"`
+	Name     *string `json:"name"`
+	Optional *bool   `json:"optional"`
+}
+
+func (in holidayInput) apply(h *models.Holiday) *badRequest {
+	if in.Date != nil {
+		d, br := parseDate("date", *in.Date, time.Time{})
+		if br != nil {
+			return br
+		}
+		h.Date = d
+	}
+	if in.Name != nil {
+		name := strings.TrimSpace(*in.Name)
+		if name == "" || len(name) > 150 {
+			return &badRequest{"name must be 1-150 characters"}
+		}
+		h.Name = name
+	}
+	if in.Optional != nil {
+		h.Optional = *in.Optional
+	}
+	var n int64
+	config.DB.Model(&models.Holiday{}).Where("calendar_id = ? AND date = ? AND lower(name) = lower(?) AND id <> ?",
+		h.CalendarID, h.Date.Format(employment.DateLayout), h.Name, h.ID).Count(&n)
+	if n > 0 {
+		return &badRequest{h.Name + " is already on " + h.Date.Format(employment.DateLayout)}
+	}
+	return nil
+}
+
+// POST /api/holiday-calendars/:id/holidays   body: {date, name, optional}
+func CreateHoliday(c *gin.Context) {
+	var cal models.HolidayCalendar
+	if err := config.DB.First(&cal, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "calendar not found"})
+		return
+	}
+	var in holidayInput
+	if err := c.ShouldBindJSON(&in); err != nil || in.Date == nil || in.Name == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date and name required"})
+		return
+	}
+	h := models.Holiday{CalendarID: cal.ID}
+	if br := in.apply(&h); br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
+	if err := config.DB.Create(&h).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create holiday"})
+		return
+	}
+	audit.Record(c, "holiday.create", "holiday", h.ID, nil, h)
+	c.JSON(http.StatusCreated, gin.H{"data": h})
+}
+
+// PUT /api/holidays/:id
+// Leaves already requested keep the days they were charged.
+func UpdateHoliday(c *gin.Context) {
+	var in holidayInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+	var h models.Holiday
+	if err := config.DB.First(&h, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "holiday not found"})
+		return
+	}
+	before := h
+	if br := in.apply(&h); br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if before.Optional && !h.Optional {
+			if err := tx.Where("holiday_id = ?", h.ID).Delete(&models.OptionalHolidayChoice{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(&h).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	audit.Record(c, "holiday.update", "holiday", h.ID, before, h)
+	c.JSON(http.StatusOK, gin.H{"data": h})
+}
+
+// DELETE /api/holidays/:id
+func DeleteHoliday(c *gin.Context) {
+	var h models.Holiday
+	if err := config.DB.First(&h, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "holiday not found"})
+		return
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("holiday_id = ?", h.ID).Delete(&models.OptionalHolidayChoice{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&h).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		return
+	}
+	audit.Record(c, "holiday.delete", "holiday", h.ID, h, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// POST /api/holiday-calendars/:id/import?optional=&dry_run=&until_year=   (multipart: file)
+// Adds the events of an iCalendar (.ics) file as holidays. Yearly recurring
+// events are repeated up to until_year (default next year); days already in
+// the calendar under the same name are skipped, so re-importing is safe.
+func ImportHolidays(c *gin.Context) {
+	var cal models.HolidayCalendar
+	if err := config.DB.First(&cal, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "calendar not found"})
+		return
+	}
+	untilYear := time.Now().Year() + 1
+	if v := c.Query("until_year"); v != "" {
+		y, err := strconv.Atoi(v)
+		if err != nil || y < 1900 || y > time.Now().Year()+10 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "until_year must be at most 10 years ahead"})
+			return
+		}
+		untilYear = y
+	}
+	fh, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file required (multipart field \"file\")"})
+		return
+	}
+	if fh.Size > icsMaxBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file too large (max %d MB)", icsMaxBytes>>20)})
+		return
+	}
+	f, err := fh.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file"})
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(f, icsMaxBytes))
+	f.Close()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not read file"})
+		return
+	}
+	events, skipped, err := holidays.ParseICS(data, untilYear)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "could not parse file: " + err.Error()})
+		return
+	}
+	optional, _ := strconv.ParseBool(c.Query("optional"))
+
+	var existing []models.Holiday
+	if err := config.DB.Where("calendar_id = ?", cal.ID).Find(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch holidays"})
+		return
+	}
+	key := func(d time.Time, name string) string {
+		return d.Format(employment.DateLayout) + "\x00" + strings.ToLower(name)
+	}
+	seen := map[string]bool{}
+	for _, h := range existing {
+		seen[key(h.Date, h.Name)] = true
+	}
+	var created []models.Holiday
+	duplicates := 0
+	for _, ev := range events {
+		name := ev.Name
+		if len(name) > 150 {
+			name = name[:150]
+		}
+		k := key(ev.Date, name)
+		if seen[k] {
+			duplicates++
+			continue
+		}
+		seen[k] = true
+		uid := ev.UID
+		if len(uid) > 255 {
+			uid = uid[:255]
+		}
+		created = append(created, models.Holiday{CalendarID: cal.ID, Date: ev.Date, Name: name, Optional: optional, UID: uid})
+	}
+
+	dryRun, _ := strconv.ParseBool(c.Query("dry_run"))
+	report := gin.H{"events": len(events), "created": len(created), "duplicates": duplicates, "skipped": skipped, "data": created}
+	if dryRun {
+		report["dry_run"] = true
+		c.JSON(http.StatusOK, report)
+		return
+	}
+	if len(created) > 0 {
+		if err := config.DB.CreateInBatches(&created, 200).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "import failed"})
+			return
+		}
+	}
+	audit.Record(c, "holiday_calendar.import", "holiday_calendar", cal.ID, nil,
+		gin.H{"file": fh.Filename, "created": len(created), "duplicates": duplicates})
+	c.JSON(http.StatusOK, report)
+}
+
+// callerEmployee loads the caller's employee record, writing a 404 without one.
+func callerEmployee(c *gin.Context) (*models.Employee, bool) {
+	var emp models.Employee
+	id := authz.CallerEmployeeID(c)
+	if id == 0 || config.DB.First(&emp, id).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "employee profile not found"})
+		return nil, false
+	}
+	return &emp, true
+}
+
+// GET /api/holidays/mine?year=
+// The caller's holidays for the year: their location's calendar, weekend
+// days, and the optional holidays they can pick and have picked.
+func ListMyHolidays(c *gin.Context) {
+	emp, ok := callerEmployee(c)
+	if !ok {
+		return
+	}
+	year, ok := yearParam(c)
+	if !ok {
+		return
+	}
+	from, to := yearRange(year)
+	schedule, err := holidays.ForEmployee(config.DB, *emp, from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load holiday calendar"})
+		return
+	}
+	resp := gin.H{"year": year, "calendar": nil, "weekend_days": holidays.DefaultWeekend, "max_optional_holidays": 0, "data": schedule.Days}
+	if cal := schedule.Calendar; cal != nil {
+		chosen := 0
+		for _, d := range schedule.Days {
+			if d.Chosen {
+				chosen++
+			}
+		}
+		resp["calendar"] = gin.H{"id": cal.ID, "name": cal.Name, "country": cal.Country}
+		resp["weekend_days"] = cal.WeekendList()
+		resp["max_optional_holidays"] = cal.MaxOptionalHolidays
+		resp["optional_chosen"] = chosen
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// loadOptionalHoliday resolves :id to an upcoming optional holiday of the
+// caller's calendar on which they have no leave booked.
+func loadOptionalHoliday(c *gin.Context) (*models.Employee, *models.Holiday, *models.HolidayCalendar, bool) {
+	emp, ok := callerEmployee(c)
+	if !ok {
+		return nil, nil, nil, false
+	}
+	var h models.Holiday
+	if err := config.DB.First(&h, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "holiday not found"})
+		return nil, nil, nil, false
+	}
+	cal, err := holidays.CalendarFor(config.DB, emp.Location)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load holiday calendar"})
+		return nil, nil, nil, false
+	}
+	if cal == nil || cal.ID != h.CalendarID || !h.Optional {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "not an optional holiday of your calendar"})
+		return nil, nil, nil, false
+	}
+	date := h.Date.Format(employment.DateLayout)
+	if date < employment.Today().Format(employment.DateLayout) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "the holiday is in the past"})
+		return nil, nil, nil, false
+	}
+	// The days of a leave were counted when it was requested
+	var onLeave int64
+	config.DB.Model(&models.Leave{}).
+		Where("user_id = ? AND status IN ? AND start_date::date <= ? AND end_date::date >= ?",
+			emp.UserID, []string{"pending", "approved"}, date, date).
+		Count(&onLeave)
+	if onLeave > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "you have leave booked on " + date + "; withdraw it first"})
+		return nil, nil, nil, false
+	}
+	return emp, &h, cal, true
+}
+
+// POST /api/holidays/:id/choose
+// Takes an optional holiday off, up to the calendar's max_optional_holidays per year.
+func ChooseOptionalHoliday(c *gin.Context) {
+	emp, h, cal, ok := loadOptionalHoliday(c)
+	if !ok {
+		return
+	}
+	from, to := yearRange(h.Date.Year())
+	var chosen int64
+	config.DB.Table("optional_holiday_choices oc").
+		Joins("JOIN holidays h ON h.id = oc.holiday_id").
+		Where("oc.employee_id = ? AND h.calendar_id = ? AND h.date BETWEEN ? AND ? AND h.id <> ?",
+			emp.ID, cal.ID, from.Format(employment.DateLayout), to.Format(employment.DateLayout), h.ID).
+		Count(&chosen)
+	if int(chosen) >= cal.MaxOptionalHolidays {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("you can pick at most %d optional holidays in %d", cal.MaxOptionalHolidays, h.Date.Year())})
+		return
+	}
+	choice := models.OptionalHolidayChoice{EmployeeID: emp.ID, HolidayID: h.ID}
+	if err := config.DB.Where(choice).FirstOrCreate(&choice).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save choice"})
+		return
+	}
+	audit.Record(c, "employee.optional_holiday_choose", "employee", emp.ID, nil, gin.H{"holiday_id": h.ID, "date": h.Date.Format(employment.DateLayout)})
+	c.JSON(http.StatusOK, gin.H{"data": choice})
+}
+
+// DELETE /api/holidays/:id/choose
+func UnchooseOptionalHoliday(c *gin.Context) {
+	emp, h, _, ok := loadOptionalHoliday(c)
+	if !ok {
+		return
+	}
+	res := config.DB.Where("employee_id = ? AND holiday_id = ?", emp.ID, h.ID).Delete(&models.OptionalHolidayChoice{})
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to remove choice"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "you have not picked this holiday"})
+		return
+	}
+	audit.Record(c, "employee.optional_holiday_unchoose", "employee", emp.ID, gin.H{"holiday_id": h.ID, "date": h.Date.Format(employment.DateLayout)}, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "removed"})
+}
@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"peoplesoft/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// impersonationDefaultTTL is used when no duration is requested
+// (IMPERSONATION_DEFAULT_TTL, default 30m, capped by IMPERSONATION_MAX_TTL).
+func impersonationDefaultTTL() time.Duration {
+	return utils.DurationFromEnv("IMPERSONATION_DEFAULT_TTL", 30*time.Minute)
+}
+
+// POST /api/impersonation/start (HR only)
+// Opens a read-only "view as" session for user_id and returns an access token
+// for it. The token carries the actor, cannot be refreshed and every request
+// made with it is recorded.
+func StartImpersonation(c *gin.Context) {
+	var in struct {
+		UserID  uint   `json:"user_id" binding:"required"`
+		Reason  string `json:"reason"`
+		Minutes int    `json:"minutes"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id required"})
+		return
+	}
+	reason := strings.TrimSpace(in.Reason)
+	if reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason required"})
+		return
+	}
+
+	ttl := impersonationDefaultTTL()
+	if in.Minutes > 0 {
+		ttl = time.Duration(in.Minutes) * time.Minute
+	}
+	if max := utils.ImpersonationMaxTTL(); ttl > max {
+		ttl = max
+	}
+
+	actorID := c.GetUint("userID")
+	if in.UserID == actorID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot impersonate yourself"})
+		return
+	}
+	var actor, subject models.User
+	if err := config.DB.First(&actor, actorID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+		return
+	}
+	if err := config.DB.First(&subject, in.UserID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if !subject.Active {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "account is deactivated"})
+		return
+	}
+
+	session := models.ImpersonationSession{
+		ActorID:   actor.ID,
+		SubjectID: subject.ID,
+		Reason:    utils.Truncate(reason, 500),
+		IP:        c.ClientIP(),
+		UserAgent: utils.Truncate(c.Request.UserAgent(), 255),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := config.DB.Create(&session).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start impersonation"})
+		return
+	}
+
+	token, err := utils.GenerateImpersonationToken(subject.Email, subject.Role, subject.SessionVersion,
+		utils.Actor{Subject: actor.Email, UserID: actor.ID}, session.ID, session.ExpiresAt)
+	if err != nil {
+		config.DB.Model(&session).Update("ended_at", time.Now())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue token"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"token":      token,
+		"expires_in": int(ttl.Seconds()),
+		"session":    session,
+		"role":       subject.Role,
+		"email":      subject.Email,
+		"userID":     subject.ID,
+		"name":       subject.Name,
+	})
+}
+
+// POST /api/impersonation/end
+// Called with the impersonation token, ends its session. Called by the actor
+// with their own token, ends session_id (or all of their open sessions).
+func EndImpersonation(c *gin.Context) {
+	db := config.DB.Model(&models.ImpersonationSession{}).Where("ended_at IS NULL")
+	if id := c.GetUint("impersonationID"); id != 0 {
+		db = db.Where("id = ?", id)
+	} else {
+		var in struct {
+			SessionID uint `json:"session_id"`
+		}
+		_ = c.ShouldBindJSON(&in)
+		db = db.Where("actor_id = ?", c.GetUint("userID"))
+		if in.SessionID != 0 {
+			db = db.Where("id = ?", in.SessionID)
+		}
+	}
+
+	res := db.Update("ended_at", time.Now())
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to end impersonation"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no active impersonation session"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "impersonation ended"})
+}
+
+// GET /api/impersonation/sessions?actor_id=&subject_id=&active=&page=&page_size= (HR only)
+func ListImpersonationSessions(c *gin.Context) {
+	page, size := pageParams(c)
+
+	db := config.DB.Model(&models.ImpersonationSession{})
+	if v := c.Query("actor_id"); v != "" {
+		db = db.Where("actor_id = ?", v)
+	}
+	if v := c.Query("subject_id"); v != "" {
+		db = db.Where("subject_id = ?", v)
+	}
+	if v := c.Query("active"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			if b {
+				db = db.Where("ended_at IS NULL AND expires_at > ?", time.Now())
+			} else {
+				db = db.Where("ended_at IS NOT NULL OR expires_at <= ?", time.Now())
+			}
+		}
+	}
+
+	var total int64
+	db.Count(&total)
+
+	var rows []models.ImpersonationSession
+	if err := db.Order("created_at desc").
+		Offset((page - 1) * size).
+		Limit(size).
+		Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "fetch failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"page": page, "page_size": size, "total": total, "data": rows})
+}
+
+// GET /api/impersonation/sessions/:id/requests?page=&page_size= (HR only)
+func ListImpersonationRequests(c *gin.Context) {
+	var session models.ImpersonationSession
+	if err := config.DB.First(&session, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+		return
+	}
+	page, size := pageParams(c)
+
+	db := config.DB.Model(&models.ImpersonationRequest{}).Where("session_id = ?", session.ID)
+	var total int64
+	db.Count(&total)
+
+	var rows []models.ImpersonationRequest
+	if err := db.Order("created_at asc").
+		Offset((page - 1) * size).
+		Limit(size).
+		Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "fetch failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"session": session, "page": page, "page_size": size, "total": total, "data": rows})
+}
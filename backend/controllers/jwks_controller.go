@@ -0,0 +1,50 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"peoplesoft/signing"
+	"peoplesoft/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET /.well-known/jwks.json
+// Public keys other services use to verify our access tokens. Empty under HS256.
+func JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": utils.PublicJWKS()})
+}
+
+// GET /api/security/signing-keys
+func ListSigningKeys(c *gin.Context) {
+	var keys []models.SigningKey
+	if err := config.DB.Where("expires_at > ?", time.Now()).Order("activates_at desc").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "fetch failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"algorithm": utils.JWTSigningAlg(),
+		"issuer":    utils.JWTIssuer(),
+		"audience":  utils.JWTAudience(),
+		"data":      keys,
+	})
+}
+
+// POST /api/security/signing-keys/rotate
+// Activates a new key immediately; the previous one keeps verifying until its tokens expire.
+func RotateSigningKey(c *gin.Context) {
+	if utils.JWTSigningAlg() == utils.AlgHS256 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "key rotation requires JWT_SIGNING_ALG=RS256 or EdDSA"})
+		return
+	}
+	key, err := signing.RotateNow()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "rotation failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "signing key rotated", "data": key})
+}
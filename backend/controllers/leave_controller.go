@@ -4,7 +4,10 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"peoplesoft/audit"
+	"peoplesoft/authz"
 	"peoplesoft/config"
+	"peoplesoft/holidays"
 	"peoplesoft/models"
 	"strings"
 	"time"
@@ -21,7 +24,7 @@ type LeaveResponse struct {
 	StartDate      time.Time `json:"start_date"`
 	EndDate        time.Time `json:"end_date"`
 	Type           string    `json:"type"`
-	Reason         string    `json:"reason"`
+	Reason         string    `json:"reason,omitempty"`
 	Status         string    `json:"status"`
 	ApprovedBy     *uint     `json:"approved_by"` // Nullable
 	ApprovedByName *string   `json:"approved_by_name"`
@@ -75,8 +78,11 @@ func CreateLeave(c *gin.Context) {
 		return
 	}
 
-	days := workingDaysBetween(start, end)
-
+	days, err := workingDaysBetween(config.DB, userID, start, end)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load holiday calendar"})
+		return
+	}
 	if days <= 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "no working days in selected range"})
 		return
@@ -122,6 +128,7 @@ func CreateLeave(c *gin.Context) {
 		Type:      leaveType,
 		Reason:    req.Reason,
 		Status:    "pending",
+		Days:      days,
 	}
 
 	if err := tx.Create(&leave).Error; err != nil {
@@ -131,6 +138,7 @@ func CreateLeave(c *gin.Context) {
 	}
 
 	tx.Commit()
+	audit.Record(c, "leave.create", "leave", leave.ID, nil, leave)
 	c.JSON(http.StatusCreated, gin.H{"data": leave})
 }
 
@@ -145,7 +153,7 @@ func ListMyLeaves(c *gin.Context) {
 	var items []LeaveResponse
 
 	err := config.DB.
-		Table("leaves l").
+		Table("leaves l").Where("l.deleted_at IS NULL").
 		Select(`
 			l.id,
 			l.user_id,
@@ -173,18 +181,24 @@ func ListMyLeaves(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": items})
 }
 
-// GET /api/leaves/team
-// - HR: all employees’ leaves
-// - Manager: employees where employees.manager_id = manager userID
-// - Employee: colleagues with same manager_id
+// GET /api/leaves/team?status=&from=&to=
+// from/to (YYYY-MM-DD) keep leaves overlapping that range.
+// - HR and leaves:read API keys: all employees’ leaves
+// - Others: own leaves, reporting line, department scopes and peers (same manager_id)
 func ListTeamLeaves(c *gin.Context) {
-	role := c.GetString("role")
 	userID := c.GetUint("userID")
 
 	var items []LeaveResponse
 
+	// API keys (leaves:read) get an absence calendar: approved leaves only,
+	// without the employee's reason
+	reason := "l.reason"
+	if userID == 0 {
+		reason = "'' AS reason"
+	}
+
 	q := config.DB.
-		Table("leaves l").
+		Table("leaves l").Where("l.deleted_at IS NULL").
 		Select(`
 			l.id,
 			l.user_id,
@@ -192,7 +206,7 @@ func ListTeamLeaves(c *gin.Context) {
 			l.start_date,
 			l.end_date,
 			l.type,
-			l.reason,
+			`+reason+`,
 			l.status,
 			l.approved_by,
 			au.name AS approved_by_name,
@@ -201,17 +215,36 @@ func ListTeamLeaves(c *gin.Context) {
 		Joins("JOIN users u ON u.id = l.user_id").
 		Joins("LEFT JOIN users au ON au.id = l.approved_by")
 
-	switch role {
-	case "hr":
-		// HR sees all
-		// no extra filter
-	case "manager":
-		q = q.Joins("JOIN employees e ON e.user_id = l.user_id").
-			Where("e.manager_id = ?", userID)
-	default:
-		// employee – colleagues with same manager (your existing logic)
-		// keep your manager lookup code here and add:
-		// q = q.Joins("JOIN employees e ON e.user_id = l.user_id").Where("e.manager_id = ?", managerID)
+	if userID == 0 {
+		q = q.Where("l.status = ?", "approved")
+	}
+	if !authz.Can(c, authz.LeaveViewAll) {
+		// own leaves, direct and skip-level reports, department scopes, and
+		// colleagues with the same manager
+		scoped := authz.ScopeEmployees(c, config.DB.Table("employees se").Where("se.deleted_at IS NULL"), "se.id", authz.View).
+			Select("se.user_id")
+		q = q.Joins("JOIN employees e ON e.user_id = l.user_id AND e.deleted_at IS NULL").
+			Where("l.user_id IN (?) OR e.manager_id = (SELECT manager_id FROM employees WHERE user_id = ? AND deleted_at IS NULL)", scoped, userID)
+	}
+
+	if status := strings.TrimSpace(c.Query("status")); status != "" {
+		q = q.Where("l.status = ?", status)
+	}
+	if from := c.Query("from"); from != "" {
+		d, err := time.Parse("2006-01-02", from)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be YYYY-MM-DD"})
+			return
+		}
+		q = q.Where("l.end_date >= ?", d)
+	}
+	if to := c.Query("to"); to != "" {
+		d, err := time.Parse("2006-01-02", to)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be YYYY-MM-DD"})
+			return
+		}
+		q = q.Where("l.start_date <= ?", d)
 	}
 
 	if err := q.Order("l.created_at DESC").Find(&items).Error; err != nil {
@@ -225,11 +258,10 @@ func ListTeamLeaves(c *gin.Context) {
 // PUT /api/leaves/:id/approve
 // Only manager can approve
 func ApproveLeave(c *gin.Context) {
-	role := c.GetString("role")
 	approverID := c.GetUint("userID")
 
-	// Only manager or HR can approve
-	if role != "manager" && role != "hr" {
+	// Only holders of leave.approve (managers, HR) can approve
+	if !authz.Can(c, authz.LeaveApprove) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "only managers or HR can approve"})
 		return
 	}
@@ -252,7 +284,14 @@ func ApproveLeave(c *gin.Context) {
 		return
 	}
 
+	// Approver must have the requester in scope (reporting line, department scope or HR-wide)
+	if !authz.CanAccessUser(c, leave.UserID, authz.Manage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "leave is not from someone you manage"})
+		return
+	}
+
 	// HR can approve anything (except own); manager can approve team leaves
+	before := leave
 	res := config.DB.Model(&leave).
 		Updates(map[string]interface{}{
 			"status":      "approved",
@@ -263,6 +302,8 @@ func ApproveLeave(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to approve leave"})
 		return
 	}
+	config.DB.First(&leave, leave.ID)
+	audit.Record(c, "leave.approve", "leave", leave.ID, before, leave)
 
 	c.JSON(http.StatusOK, gin.H{"message": "approved"})
 }
@@ -270,11 +311,10 @@ func ApproveLeave(c *gin.Context) {
 // PUT /api/leaves/:id/reject
 // Only manager can reject
 func RejectLeave(c *gin.Context) {
-	role := c.GetString("role")
 	approverID := c.GetUint("userID")
 
-	// Manager or HR can reject
-	if role != "manager" && role != "hr" {
+	// Holders of leave.approve (managers, HR) can reject
+	if !authz.Can(c, authz.LeaveApprove) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "only managers or HR can reject"})
 		return
 	}
@@ -300,8 +340,14 @@ func RejectLeave(c *gin.Context) {
 		return
 	}
 
-	// 🔁 Restore allocation (workingDaysBetween + getOrCreateAllocation)
-	days := workingDaysBetween(leave.StartDate, leave.EndDate)
+	if !authz.CanAccessUser(c, leave.UserID, authz.Manage) {
+		tx.Rollback()
+		c.JSON(http.StatusForbidden, gin.H{"error": "leave is not from someone you manage"})
+		return
+	}
+
+	// 🔁 Restore allocation (chargedDays + getOrCreateAllocation)
+	days := chargedDays(tx, leave)
 	year := leave.StartDate.Year()
 
 	alloc, err := getOrCreateAllocationTx(tx, leave.UserID, year, leave.Type)
@@ -322,6 +368,7 @@ func RejectLeave(c *gin.Context) {
 		return
 	}
 
+	before := leave
 	if err := tx.Model(&leave).Updates(map[string]interface{}{
 		"status":      "rejected",
 		"approved_by": approverID,
@@ -333,24 +380,32 @@ func RejectLeave(c *gin.Context) {
 	}
 
 	tx.Commit()
+	config.DB.First(&leave, leave.ID)
+	audit.Record(c, "leave.reject", "leave", leave.ID, before, leave)
 	c.JSON(http.StatusOK, gin.H{"message": "rejected"})
 }
 
-// business days (Mon–Fri) inclusive
-func workingDaysBetween(start, end time.Time) int {
+// working days inclusive: weekends and holidays of the user's holiday
+// calendar, and optional holidays they picked, are skipped
+func workingDaysBetween(db *gorm.DB, userID uint, start, end time.Time) (int, error) {
 	if end.Before(start) {
-		return 0
+		return 0, nil
 	}
-	d := 0
-	cur := start
-	for !cur.After(end) {
-		wd := cur.Weekday()
-		if wd != time.Saturday && wd != time.Sunday {
-			d++
-		}
-		cur = cur.AddDate(0, 0, 1)
+	schedule, err := holidays.ForUser(db, userID, start, end)
+	if err != nil {
+		return 0, err
+	}
+	return schedule.WorkingDays(start, end), nil
+}
+
+// chargedDays is what a leave took from the allocation. Leaves from before
+// Days was stored are recounted.
+func chargedDays(db *gorm.DB, leave models.Leave) int {
+	if leave.Days > 0 {
+		return leave.Days
 	}
-	return d
+	days, _ := workingDaysBetween(db, leave.UserID, leave.StartDate, leave.EndDate)
+	return days
 }
 
 // default allocations per type per year
@@ -480,7 +535,7 @@ func WithdrawLeave(c *gin.Context) {
 	}
 
 	// restore allocation
-	days := workingDaysBetween(leave.StartDate, leave.EndDate)
+	days := chargedDays(tx, leave)
 	year := leave.StartDate.Year()
 
 	alloc, err := getOrCreateAllocationTx(tx, leave.UserID, year, leave.Type)
@@ -509,5 +564,6 @@ func WithdrawLeave(c *gin.Context) {
 	}
 
 	tx.Commit()
+	audit.Record(c, "leave.withdraw", "leave", leave.ID, leave, nil)
 	c.JSON(http.StatusOK, gin.H{"message": "leave withdrawn and deleted"})
 }
@@ -0,0 +1,640 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"peoplesoft/audit"
+	"peoplesoft/authz"
+	"peoplesoft/config"
+	"peoplesoft/employment"
+	"peoplesoft/lifecycle"
+	"peoplesoft/models"
+	"peoplesoft/org"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// LifecycleTaskRow is a checklist task with the name of the employee it is for.
+type LifecycleTaskRow struct {
+	models.LifecycleTask
+	EmployeeName string `json:"employee_name"`
+}
+
+func lifecycleTaskRows() *gorm.DB {
+	return config.DB.Table("lifecycle_tasks t").
+		Select("t.*, u.name AS employee_name").
+		Joins("JOIN employees e ON e.id = t.employee_id AND e.deleted_at IS NULL").
+		Joins("JOIN users u ON u.id = e.user_id")
+}
+
+// parseDate reads an optional YYYY-MM-DD field; def is returned when v is empty.
+func parseDate(field, v string, def time.Time) (time.Time, *badRequest) {
+	if v == "" {
+		return def, nil
+	}
+	d, err := time.Parse(employment.DateLayout, v)
+	if err != nil {
+		return time.Time{}, &badRequest{"invalid " + field + ", expected YYYY-MM-DD"}
+	}
+	return d, nil
+}
+
+// loadManagedEmployee loads :id after checking the caller may manage them.
+func loadManagedEmployee(c *gin.Context) (*models.Employee, bool) {
+	id := parseUint(c.Param("id"))
+	if !authz.CanAccessEmployee(c, id, authz.Manage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to manage this employee"})
+		return nil, false
+	}
+	var emp models.Employee
+	if err := config.DB.First(&emp, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "employee not found"})
+		return nil, false
+	}
+	return &emp, true
+}
+
+// GET /api/employees/:id/lifecycle
+// Current status, status history (including scheduled changes), the latest
+// offboarding and every checklist task.
+func GetEmployeeLifecycle(c *gin.Context) {
+	id := parseUint(c.Param("id"))
+	if !authz.CanAccessEmployee(c, id, authz.View) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to view this employee"})
+		return
+	}
+	var emp models.Employee
+	if err := config.DB.First(&emp, id).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "employee not found"})
+		return
+	}
+
+	var history []models.EmploymentChange
+	config.DB.Where("employee_id = ? AND employee_status IS NOT NULL", emp.ID).
+		Order("effective_date asc, id asc").Find(&history)
+
+	var offboarding *models.Offboarding
+	var off models.Offboarding
+	if err := config.DB.Where("employee_id = ?", emp.ID).Order("id desc").First(&off).Error; err == nil {
+		offboarding = &off
+	}
+
+	var tasks []LifecycleTaskRow
+	lifecycleTaskRows().Where("t.employee_id = ?", emp.ID).
+		Order("t.kind asc, t.due_date asc, t.id asc").Scan(&tasks)
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"employee_id": emp.ID,
+		"status":      emp.Status,
+		"history":     history,
+		"offboarding": offboarding,
+		"tasks":       tasks,
+	}})
+}
+
+// POST /api/employees/:id/status
+// Moves an employee to probation or active (e.g. a hire starting early or
+// probation ending), effective today or on effective_date. Notice and
+// termination go through offboarding.
+func ChangeEmployeeStatus(c *gin.Context) {
+	var in struct {
+		Status        string `json:"status" binding:"required"`
+		EffectiveDate string `json:"effective_date"`
+		ReasonCode    string `json:"reason_code"`
+		Note          string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status required"})
+		return
+	}
+	if in.Status == employment.LifecycleOnNotice || in.Status == employment.LifecycleTerminated {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "use POST /api/employees/:id/offboarding to record an exit"})
+		return
+	}
+	if in.Status != employment.LifecycleProbation && in.Status != employment.LifecycleActive {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be probation or active"})
+		return
+	}
+	emp, ok := loadManagedEmployee(c)
+	if !ok {
+		return
+	}
+
+	change := employment.Change{ReasonCode: in.ReasonCode, Note: in.Note, Status: &in.Status}
+	if br := validateEmploymentChange(emp, &change, in.EffectiveDate); br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
+	cur, err := employment.StateAsOf(config.DB, emp.ID, change.EffectiveDate)
+	if err != nil || cur == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to resolve current status"})
+		return
+	}
+	if !employment.CanTransition(cur.Status, in.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot move from " + cur.Status + " to " + in.Status})
+		return
+	}
+
+	before := *emp
+	var rec *models.EmploymentChange
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		rec, err = employment.Record(tx, emp, change, c.GetUint("userID"))
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "status change failed"})
+		return
+	}
+	audit.Record(c, "employee.status_change", "employee", emp.ID, before, emp)
+	if rec != nil && rec.Status == employment.StatusScheduled {
+		c.JSON(http.StatusOK, gin.H{"message": "change scheduled", "change": rec})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "status updated", "change": rec})
+}
+
+// POST /api/employees/:id/onboarding
+// Starts an onboarding checklist (template_id, default: the default
+// onboarding template) with tasks due relative to start_date (default today).
+func StartOnboarding(c *gin.Context) {
+	var in struct {
+		TemplateID uint   `json:"template_id"`
+		StartDate  string `json:"start_date"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+	start, br := parseDate("start_date", in.StartDate, employment.Today())
+	if br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
+	emp, ok := loadManagedEmployee(c)
+	if !ok {
+		return
+	}
+	tasks, err := lifecycle.StartChecklist(config.DB, emp, lifecycle.KindOnboarding, in.TemplateID, start, nil)
+	if err != nil {
+		if errors.Is(err, lifecycle.ErrTemplateNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "onboarding template not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start onboarding"})
+		return
+	}
+	if tasks == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "no default onboarding template; pass template_id"})
+		return
+	}
+	audit.Record(c, "employee.onboarding_start", "employee", emp.ID, nil, gin.H{"tasks": len(tasks), "start_date": start})
+	c.JSON(http.StatusCreated, gin.H{"data": tasks})
+}
+
+// POST /api/employees/:id/offboarding
+// Records an exit. The employee is on notice from notice_date (default today)
+// and terminated the day after last_working_day: direct reports then move to
+// successor_id (default: the leaver's manager) and the account is deactivated.
+func StartOffboarding(c *gin.Context) {
+	var in struct {
+		LastWorkingDay string `json:"last_working_day" binding:"required"`
+		NoticeDate     string `json:"notice_date"`
+		ReasonCode     string `json:"reason_code"`
+		Note           string `json:"note"`
+		SuccessorID    *uint  `json:"successor_id"`
+		TemplateID     uint   `json:"template_id"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "last_working_day required"})
+		return
+	}
+	emp, ok := loadManagedEmployee(c)
+	if !ok {
+		return
+	}
+	x, br := validateExit(emp, in.NoticeDate, in.LastWorkingDay, in.ReasonCode, in.SuccessorID)
+	if br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
+	x.Note = in.Note
+	x.TemplateID = in.TemplateID
+
+	before := *emp
+	var off *models.Offboarding
+	var tasks []models.LifecycleTask
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		off, tasks, err = lifecycle.Offboard(tx, emp, x, c.GetUint("userID"))
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, lifecycle.ErrTemplateNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "offboarding template not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start offboarding"})
+		return
+	}
+	audit.Record(c, "employee.offboarding_start", "employee", emp.ID, before, emp)
+	c.JSON(http.StatusCreated, gin.H{"data": off, "tasks": tasks, "status": emp.Status})
+}
+
+// validateExit checks offboarding input for emp and returns the exit to record.
+func validateExit(emp *models.Employee, noticeDate, lastWorkingDay, reasonCode string, successorID *uint) (lifecycle.Exit, *badRequest) {
+	var x lifecycle.Exit
+	if emp.Status == employment.LifecycleTerminated {
+		return x, &badRequest{"employee has already left"}
+	}
+	var n int64
+	config.DB.Model(&models.Offboarding{}).
+		Where("employee_id = ? AND status = ?", emp.ID, lifecycle.OffboardingPending).Count(&n)
+	if n > 0 {
+		return x, &badRequest{"an offboarding is already in progress"}
+	}
+
+	var br *badRequest
+	if x.NoticeDate, br = parseDate("notice_date", noticeDate, employment.Today()); br != nil {
+		return x, br
+	}
+	if x.LastWorkingDay, br = parseDate("last_working_day", lastWorkingDay, time.Time{}); br != nil {
+		return x, br
+	}
+	if x.NoticeDate.Before(employment.DateOf(emp.CreatedAt)) {
+		return x, &badRequest{"notice_date is before the employee was created"}
+	}
+	if x.LastWorkingDay.Before(x.NoticeDate) {
+		return x, &badRequest{"last_working_day must not be before notice_date"}
+	}
+	if reasonCode != "" && !containsString(employment.ReasonCodes, reasonCode) {
+		return x, &badRequest{"unknown reason_code"}
+	}
+	x.ReasonCode = reasonCode
+
+	if successorID != nil {
+		var successor models.Employee
+		if err := config.DB.First(&successor, *successorID).Error; err != nil {
+			return x, &badRequest{"successor not found"}
+		}
+		if successor.ID == emp.ID || successor.Status == employment.LifecycleTerminated {
+			return x, &badRequest{"successor must be another current employee"}
+		}
+		// A direct report may take over (their own manager becomes the leaver's
+		// manager); anyone further down would end up managing their own manager.
+		links, _, err := org.Chain(config.DB, successor.ID)
+		if err != nil {
+			return x, &badRequest{"failed to check reporting line"}
+		}
+		for _, l := range links {
+			if l.ID == emp.ID && l.Level > 1 {
+				return x, &badRequest{"successor reports to the leaver indirectly"}
+			}
+		}
+		x.SuccessorID = &successor.ID
+	}
+	return x, nil
+}
+
+// DELETE /api/employees/:id/offboarding
+// Withdraws a pending offboarding before the termination takes effect.
+func CancelOffboarding(c *gin.Context) {
+	emp, ok := loadManagedEmployee(c)
+	if !ok {
+		return
+	}
+	var off models.Offboarding
+	if err := config.DB.Where("employee_id = ? AND status = ?", emp.ID, lifecycle.OffboardingPending).
+		Order("id desc").First(&off).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no offboarding in progress"})
+		return
+	}
+	before := off
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		return lifecycle.CancelOffboarding(tx, emp, &off, c.GetUint("userID"))
+	})
+	if err != nil {
+		if errors.Is(err, lifecycle.ErrAlreadyLeft) {
+			c.JSON(http.StatusConflict, gin.H{"error": "employee has already left"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "cancel failed"})
+		return
+	}
+	audit.Record(c, "offboarding.cancel", "offboarding", off.ID, before, off)
+	c.JSON(http.StatusOK, gin.H{"message": "offboarding cancelled", "status": emp.Status})
+}
+
+// ---- checklist templates ----
+
+type checklistTaskInput struct {
+	Title          string `json:"title"`
+	Description    string `json:"description"`
+	Assignee       string `json:"assignee"`
+	AssigneeUserID *uint  `json:"assignee_user_id"`
+	DueOffsetDays  int    `json:"due_offset_days"`
+}
+
+type checklistTemplateInput struct {
+	Name        *string               `json:"name"`
+	Kind        *string               `json:"kind"`
+	Description *string               `json:"description"`
+	IsDefault   *bool                 `json:"is_default"`
+	Tasks       *[]checklistTaskInput `json:"tasks"` // replaces every task when present
+}
+
+// apply validates the input and copies it onto tpl.
+func (in checklistTemplateInput) apply(tpl *models.ChecklistTemplate) *badRequest {
+	if in.Name != nil {
+		name := strings.TrimSpace(*in.Name)
+		if name == "" {
+			return &badRequest{"name required"}
+		}
+		var n int64
+		config.DB.Model(&models.ChecklistTemplate{}).Where("LOWER(name) = LOWER(?) AND id <> ?", name, tpl.ID).Count(&n)
+		if n > 0 {
+			return &badRequest{"a template with this name already exists"}
+		}
+		tpl.Name = name
+	}
+	if in.Kind != nil {
+		if *in.Kind != lifecycle.KindOnboarding && *in.Kind != lifecycle.KindOffboarding {
+			return &badRequest{"kind must be onboarding or offboarding"}
+		}
+		tpl.Kind = *in.Kind
+	}
+	if in.Description != nil {
+		tpl.Description = *in.Description
+	}
+	if in.IsDefault != nil {
+		tpl.IsDefault = *in.IsDefault
+	}
+	if in.Tasks != nil {
+		tpl.Tasks = nil
+		for i, t := range *in.Tasks {
+			title := strings.TrimSpace(t.Title)
+			if title == "" {
+				return &badRequest{"every task needs a title"}
+			}
+			if !containsString(lifecycle.Assignees, t.Assignee) {
+				return &badRequest{"task assignee must be one of " + strings.Join(lifecycle.Assignees, ", ")}
+			}
+			if t.AssigneeUserID != nil {
+				var n int64
+				config.DB.Model(&models.User{}).Where("id = ?", *t.AssigneeUserID).Count(&n)
+				if n == 0 {
+					return &badRequest{"task assignee user not found"}
+				}
+			}
+			tpl.Tasks = append(tpl.Tasks, models.ChecklistTemplateTask{
+				Position:       i,
+				Title:          title,
+				Description:    t.Description,
+				Assignee:       t.Assignee,
+				AssigneeUserID: t.AssigneeUserID,
+				DueOffsetDays:  t.DueOffsetDays,
+			})
+		}
+	}
+	return nil
+}
+
+// saveChecklistTemplate writes tpl, replacing its tasks when replaceTasks is
+// set and keeping at most one default template per kind.
+func saveChecklistTemplate(tpl *models.ChecklistTemplate, replaceTasks bool) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if tpl.IsDefault {
+			if err := tx.Model(&models.ChecklistTemplate{}).
+				Where("kind = ? AND id <> ?", tpl.Kind, tpl.ID).
+				Update("is_default", false).Error; err != nil {
+				return err
+			}
+		}
+		tasks := tpl.Tasks
+		tpl.Tasks = nil
+		if err := tx.Save(tpl).Error; err != nil {
+			return err
+		}
+		if replaceTasks {
+			if err := tx.Where("template_id = ?", tpl.ID).Delete(&models.ChecklistTemplateTask{}).Error; err != nil {
+				return err
+			}
+			for i := range tasks {
+				tasks[i].ID = 0
+				tasks[i].TemplateID = tpl.ID
+			}
+			if len(tasks) > 0 {
+				if err := tx.Create(&tasks).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return tx.Preload("Tasks", func(db *gorm.DB) *gorm.DB { return db.Order("position asc, id asc") }).
+			First(tpl, tpl.ID).Error
+	})
+}
+
+// GET /api/lifecycle/templates?kind=
+func ListChecklistTemplates(c *gin.Context) {
+	db := config.DB.Preload("Tasks", func(db *gorm.DB) *gorm.DB { return db.Order("position asc, id asc") })
+	if kind := c.Query("kind"); kind != "" {
+		db = db.Where("kind = ?", kind)
+	}
+	var rows []models.ChecklistTemplate
+	if err := db.Order("kind asc, name asc").Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch templates"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"count": len(rows), "data": rows})
+}
+
+// POST /api/lifecycle/templates
+func CreateChecklistTemplate(c *gin.Context) {
+	var in checklistTemplateInput
+	if err := c.ShouldBindJSON(&in); err != nil || in.Name == nil || in.Kind == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name and kind required"})
+		return
+	}
+	var tpl models.ChecklistTemplate
+	if br := in.apply(&tpl); br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
+	if err := saveChecklistTemplate(&tpl, true); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create template"})
+		return
+	}
+	audit.Record(c, "checklist_template.create", "checklist_template", tpl.ID, nil, tpl)
+	c.JSON(http.StatusCreated, gin.H{"data": tpl})
+}
+
+// PUT /api/lifecycle/templates/:id
+// Tasks already started for employees are not affected.
+func UpdateChecklistTemplate(c *gin.Context) {
+	var in checklistTemplateInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+	var tpl models.ChecklistTemplate
+	if err := config.DB.Preload("Tasks").First(&tpl, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+	before := tpl
+	if br := in.apply(&tpl); br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
+	if err := saveChecklistTemplate(&tpl, in.Tasks != nil); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	audit.Record(c, "checklist_template.update", "checklist_template", tpl.ID, before, tpl)
+	c.JSON(http.StatusOK, gin.H{"data": tpl})
+}
+
+// DELETE /api/lifecycle/templates/:id
+func DeleteChecklistTemplate(c *gin.Context) {
+	var tpl models.ChecklistTemplate
+	if err := config.DB.First(&tpl, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template not found"})
+		return
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("template_id = ?", tpl.ID).Delete(&models.ChecklistTemplateTask{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&tpl).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		return
+	}
+	audit.Record(c, "checklist_template.delete", "checklist_template", tpl.ID, tpl, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// ---- checklist tasks ----
+
+// GET /api/lifecycle/tasks?employee_id=&kind=&assignee=&assignee_user_id=&status=&unassigned=&page=&page_size=
+func ListLifecycleTasks(c *gin.Context) {
+	page, size := pageParams(c)
+	db := lifecycleTaskRows()
+	if v := c.Query("employee_id"); v != "" {
+		db = db.Where("t.employee_id = ?", parseUint(v))
+	}
+	if v := c.Query("kind"); v != "" {
+		db = db.Where("t.kind = ?", v)
+	}
+	if v := c.Query("assignee"); v != "" {
+		db = db.Where("t.assignee = ?", v)
+	}
+	if v := c.Query("assignee_user_id"); v != "" {
+		db = db.Where("t.assignee_user_id = ?", parseUint(v))
+	}
+	if c.Query("unassigned") == "true" {
+		db = db.Where("t.assignee_user_id IS NULL")
+	}
+	if v := c.Query("status"); v != "" {
+		db = db.Where("t.status = ?", v)
+	}
+
+	var total int64
+	db.Count(&total)
+	var rows []LifecycleTaskRow
+	if err := db.Order("t.due_date asc, t.id asc").Offset((page - 1) * size).Limit(size).Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch tasks"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"page": page, "page_size": size, "total": total, "data": rows})
+}
+
+// GET /api/lifecycle/my-tasks?status=   (default open)
+func ListMyLifecycleTasks(c *gin.Context) {
+	var rows []LifecycleTaskRow
+	err := lifecycleTaskRows().
+		Where("t.assignee_user_id = ? AND t.status = ?", c.GetUint("userID"), c.DefaultQuery("status", lifecycle.TaskOpen)).
+		Order("t.due_date asc, t.id asc").
+		Scan(&rows).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch tasks"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"count": len(rows), "data": rows})
+}
+
+// PUT /api/lifecycle/tasks/:id
+// The assignee may set status (open, done, skipped) and note; holders of
+// lifecycle.manage may also reassign with assignee_user_id (0 returns it to the pool).
+func UpdateLifecycleTask(c *gin.Context) {
+	var in struct {
+		Status         *string `json:"status"`
+		Note           *string `json:"note"`
+		AssigneeUserID *uint   `json:"assignee_user_id"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+	var task models.LifecycleTask
+	if err := config.DB.First(&task, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+		return
+	}
+	userID := c.GetUint("userID")
+	manager := authz.Can(c, authz.LifecycleManage)
+	if !manager && (task.AssigneeUserID == nil || *task.AssigneeUserID != userID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "task is not assigned to you"})
+		return
+	}
+
+	before := task
+	if in.Status != nil {
+		switch *in.Status {
+		case lifecycle.TaskDone, lifecycle.TaskSkipped:
+			if task.Status == lifecycle.TaskOpen {
+				now := time.Now()
+				task.CompletedAt = &now
+				task.CompletedByID = &userID
+			}
+		case lifecycle.TaskOpen:
+			task.CompletedAt = nil
+			task.CompletedByID = nil
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be open, done or skipped"})
+			return
+		}
+		task.Status = *in.Status
+	}
+	if in.Note != nil {
+		task.Note = *in.Note
+	}
+	if in.AssigneeUserID != nil {
+		if !manager {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only lifecycle managers can reassign tasks"})
+			return
+		}
+		if *in.AssigneeUserID == 0 {
+			task.AssigneeUserID = nil
+		} else {
+			var n int64
+			config.DB.Model(&models.User{}).Where("id = ?", *in.AssigneeUserID).Count(&n)
+			if n == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "assignee user not found"})
+				return
+			}
+			task.AssigneeUserID = in.AssigneeUserID
+		}
+	}
+	if err := config.DB.Save(&task).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	audit.Record(c, "lifecycle_task.update", "lifecycle_task", task.ID, before, task)
+	c.JSON(http.StatusOK, gin.H{"data": task})
+}
@@ -0,0 +1,242 @@
+package controllers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"peoplesoft/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Uniform message for every credential failure so responses never reveal whether an account exists.
+const errInvalidCredentials = "invalid email or password"
+
+// Login throttling settings (env overridable):
+//   - LOGIN_LOCKOUT_THRESHOLD: consecutive failures per account before lockout (default 5)
+//   - LOGIN_LOCKOUT_BASE: first lockout duration, doubled on each further failure (default 1m)
+//   - LOGIN_LOCKOUT_MAX: cap on a single lockout (default 1h)
+//   - LOGIN_IP_MAX_FAILURES: failures per IP within LOGIN_IP_WINDOW before backoff (default 20)
+//   - LOGIN_IP_WINDOW: sliding window for per-IP counting (default 15m)
+type loginGuardConfig struct {
+	threshold     int
+	base, max     time.Duration
+	ipMaxFailures int
+	ipWindow      time.Duration
+}
+
+func loadLoginGuardConfig() loginGuardConfig {
+	return loginGuardConfig{
+		threshold:     utils.IntFromEnv("LOGIN_LOCKOUT_THRESHOLD", 5),
+		base:          utils.DurationFromEnv("LOGIN_LOCKOUT_BASE", time.Minute),
+		max:           utils.DurationFromEnv("LOGIN_LOCKOUT_MAX", time.Hour),
+		ipMaxFailures: utils.IntFromEnv("LOGIN_IP_MAX_FAILURES", 20),
+		ipWindow:      utils.DurationFromEnv("LOGIN_IP_WINDOW", 15*time.Minute),
+	}
+}
+
+// backoff returns base * 2^n capped at max.
+func (g loginGuardConfig) backoff(n int) time.Duration {
+	if n < 0 {
+		n = 0
+	}
+	d := time.Duration(float64(g.base) * math.Pow(2, float64(n)))
+	if d > g.max || d <= 0 {
+		return g.max
+	}
+	return d
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// loginBlocked reports how long the caller must wait before another attempt for this
+// email/IP pair. Zero means the attempt may proceed.
+func loginBlocked(email, ip string) time.Duration {
+	g := loadLoginGuardConfig()
+	now := time.Now()
+
+	var lock models.AccountLockout
+	if err := config.DB.Where("email = ?", normalizeEmail(email)).First(&lock).Error; err == nil {
+		if lock.LockedUntil != nil && lock.LockedUntil.After(now) {
+			return lock.LockedUntil.Sub(now)
+		}
+	}
+
+	ipFailures := config.DB.Model(&models.LoginAttempt{}).
+		Where("ip = ? AND success = ? AND reason <> ?", ip, false, reasonResetRequested)
+	return g.throttled(ipFailures, g.ipMaxFailures, now)
+}
+
+// throttled applies the backoff to the login attempts matched by q within the
+// IP window: once max is reached, each further attempt waits base * 2^n after
+// the last one.
+func (g loginGuardConfig) throttled(q *gorm.DB, max int, now time.Time) time.Duration {
+	var recent struct {
+		Count int64
+		Last  *time.Time
+	}
+	q.Select("COUNT(*) AS count, MAX(created_at) AS last").
+		Where("created_at > ?", now.Add(-g.ipWindow)).
+		Scan(&recent)
+	if int(recent.Count) >= max && recent.Last != nil {
+		wait := g.backoff(int(recent.Count) - max)
+		if until := recent.Last.Add(wait); until.After(now) {
+			return until.Sub(now)
+		}
+	}
+	return 0
+}
+
+// reasonResetRequested marks forgot-password requests in login_attempts; they
+// are throttled on their own and do not count as login failures.
+const reasonResetRequested = "reset_requested"
+
+// passwordResetBlocked reports how long forgot-password requests for this
+// email/IP pair must wait: PASSWORD_RESET_MAX_PER_EMAIL (default 3) per address
+// and LOGIN_IP_MAX_FAILURES per IP within LOGIN_IP_WINDOW, with the login backoff.
+func passwordResetBlocked(email, ip string) time.Duration {
+	g := loadLoginGuardConfig()
+	now := time.Now()
+	requests := config.DB.Model(&models.LoginAttempt{}).Where("reason = ?", reasonResetRequested)
+	if wait := g.throttled(requests.Session(&gorm.Session{}).Where("email = ?", normalizeEmail(email)),
+		utils.IntFromEnv("PASSWORD_RESET_MAX_PER_EMAIL", 3), now); wait > 0 {
+		return wait
+	}
+	return g.throttled(requests.Where("ip = ?", ip), g.ipMaxFailures, now)
+}
+
+// registerLoginFailure bumps the per-account counter and locks the account with
+// exponential backoff once the threshold is reached.
+func registerLoginFailure(c *gin.Context, email string, userID *uint, reason string) {
+	g := loadLoginGuardConfig()
+	now := time.Now()
+	key := normalizeEmail(email)
+
+	recordLoginAttempt(c, key, userID, false, reason)
+
+	tx := config.DB.Begin()
+	var lock models.AccountLockout
+	tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("email = ?", key).First(&lock)
+	lock.Email = key
+	lock.FailedCount++
+	lock.LastFailureAt = &now
+	if lock.FailedCount >= g.threshold {
+		until := now.Add(g.backoff(lock.FailedCount - g.threshold))
+		lock.LockedUntil = &until
+	}
+	if err := tx.Save(&lock).Error; err != nil {
+		tx.Rollback()
+		return
+	}
+	tx.Commit()
+}
+
+// registerLoginSuccess clears the account's failure counter.
+func registerLoginSuccess(c *gin.Context, email string, userID uint) {
+	key := normalizeEmail(email)
+	recordLoginAttempt(c, key, &userID, true, "ok")
+	config.DB.Where("email = ?", key).Delete(&models.AccountLockout{})
+}
+
+func recordLoginAttempt(c *gin.Context, email string, userID *uint, success bool, reason string) {
+	config.DB.Create(&models.LoginAttempt{
+		Email:     email,
+		UserID:    userID,
+		IP:        c.ClientIP(),
+		UserAgent: utils.Truncate(c.Request.UserAgent(), 255),
+		Success:   success,
+		Reason:    reason,
+	})
+}
+
+// respondThrottled answers 429 with Retry-After.
+func respondThrottled(c *gin.Context, wait time.Duration) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed attempts, try again later"})
+}
+
+// POST /api/users/:id/unlock (HR only)
+func UnlockUser(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err := config.DB.Where("email = ?", normalizeEmail(user.Email)).Delete(&models.AccountLockout{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "unlock failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "account unlocked"})
+}
+
+// GET /api/security/lockouts (HR only)
+// Lists identifiers currently locked out.
+func ListLockouts(c *gin.Context) {
+	var rows []models.AccountLockout
+	if err := config.DB.Where("locked_until > ?", time.Now()).
+		Order("locked_until desc").
+		Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "fetch failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
+
+// GET /api/security/login-attempts?email=&user_id=&ip=&success=&from=&to=&page=&page_size= (HR only)
+func ListLoginAttempts(c *gin.Context) {
+	page, size := pageParams(c)
+
+	db := config.DB.Model(&models.LoginAttempt{})
+	if v := strings.TrimSpace(c.Query("email")); v != "" {
+		db = db.Where("email = ?", normalizeEmail(v))
+	}
+	if v := c.Query("user_id"); v != "" {
+		db = db.Where("user_id = ?", v)
+	}
+	if v := strings.TrimSpace(c.Query("ip")); v != "" {
+		db = db.Where("ip = ?", v)
+	}
+	if v := c.Query("success"); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			db = db.Where("success = ?", b)
+		}
+	}
+	if v := c.Query("from"); v != "" {
+		from, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date, expected YYYY-MM-DD"})
+			return
+		}
+		db = db.Where("created_at >= ?", from)
+	}
+	if v := c.Query("to"); v != "" {
+		to, err := time.Parse("2006-01-02", v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date, expected YYYY-MM-DD"})
+			return
+		}
+		db = db.Where("created_at < ?", to.AddDate(0, 0, 1))
+	}
+
+	var total int64
+	db.Count(&total)
+
+	var rows []models.LoginAttempt
+	if err := db.Order("created_at desc").
+		Offset((page - 1) * size).
+		Limit(size).
+		Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "fetch failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"page": page, "page_size": size, "total": total, "data": rows})
+}
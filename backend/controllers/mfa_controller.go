@@ -0,0 +1,417 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"peoplesoft/authz"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"peoplesoft/utils"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const recoveryCodeCount = 10
+
+// mfaRequired reports whether MFA_REQUIRED_ROLES lists the user's primary role
+// or any of their additional roles.
+func mfaRequired(user models.User) (bool, error) {
+	roles, err := authz.UserRoles(user.ID)
+	if err != nil {
+		return false, err
+	}
+	for _, role := range roles {
+		if utils.MFARequiredForRole(role) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// mfaLoginStep decides whether a user who passed the first factor (password or
+// SSO) needs a second step. It returns the response to send instead of a
+// session, or nil when no MFA applies.
+func mfaLoginStep(user models.User) (gin.H, error) {
+	if user.MFAEnabled {
+		token, err := utils.GenerateMFAChallenge(user.ID, utils.MFAPurposeVerify)
+		if err != nil {
+			return nil, err
+		}
+		return gin.H{"mfa_required": true, "mfa_token": token}, nil
+	}
+	required, err := mfaRequired(user)
+	if err != nil || !required {
+		return nil, err
+	}
+	token, err := utils.GenerateMFAChallenge(user.ID, utils.MFAPurposeEnroll)
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{"mfa_enrollment_required": true, "mfa_token": token}, nil
+}
+
+// mfaUser resolves the user an MFA management call applies to: the logged-in user
+// when called behind AuthRequired, otherwise the holder of an enrollment challenge.
+func mfaUser(c *gin.Context, mfaToken string) (user models.User, viaChallenge bool, err error) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		claims, err := utils.ValidateMFAChallenge(mfaToken, utils.MFAPurposeEnroll)
+		if err != nil {
+			return user, false, err
+		}
+		userID, viaChallenge = claims.UserID, true
+	}
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return user, false, errors.New("user not found")
+	}
+	return user, viaChallenge, nil
+}
+
+// checkTOTP validates a code against the user's active secret and records the
+// accepted step so the same code cannot be replayed.
+func checkTOTP(user *models.User, code string) bool {
+	secret, err := utils.DecryptSecret(user.MFASecret)
+	if err != nil {
+		return false
+	}
+	step, ok := utils.ValidateTOTP(secret, code, time.Now())
+	if !ok || step <= user.MFALastStep {
+		return false
+	}
+	res := config.DB.Model(&models.User{}).
+		Where("id = ? AND mfa_last_step < ?", user.ID, step).
+		Update("mfa_last_step", step)
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false
+	}
+	user.MFALastStep = step
+	return true
+}
+
+// useRecoveryCode consumes a matching unused recovery code.
+func useRecoveryCode(userID uint, code string) bool {
+	res := config.DB.Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, utils.HashToken(utils.NormalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	return res.Error == nil && res.RowsAffected > 0
+}
+
+// replaceRecoveryCodes drops existing codes and stores a fresh set, returning the plain codes once.
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	rows := make([]models.MFARecoveryCode, len(codes))
+	for i, code := range codes {
+		rows[i] = models.MFARecoveryCode{UserID: userID, CodeHash: utils.HashToken(code)}
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// POST /api/auth/mfa/verify
+// Second login step: exchanges an MFA challenge plus a TOTP or recovery code for a session.
+func MFAVerify(c *gin.Context) {
+	var in struct {
+		MFAToken     string `json:"mfa_token" binding:"required"`
+		Code         string `json:"code"`
+		RecoveryCode string `json:"recovery_code"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil || (in.Code == "" && in.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa_token and code or recovery_code required"})
+		return
+	}
+
+	claims, err := utils.ValidateMFAChallenge(in.MFAToken, utils.MFAPurposeVerify)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, claims.UserID).Error; err != nil || !user.MFAEnabled {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired mfa token"})
+		return
+	}
+
+	// Code guessing counts against the same lockout as passwords
+	if wait := loginBlocked(user.Email, c.ClientIP()); wait > 0 {
+		recordLoginAttempt(c, normalizeEmail(user.Email), &user.ID, false, "locked")
+		respondThrottled(c, wait)
+		return
+	}
+
+	ok := false
+	if in.Code != "" {
+		ok = checkTOTP(&user, in.Code)
+	} else {
+		ok = useRecoveryCode(user.ID, in.RecoveryCode)
+	}
+	if !ok {
+		registerLoginFailure(c, user.Email, &user.ID, "bad_mfa_code")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid verification code"})
+		return
+	}
+
+	resp, err := issueSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue token"})
+		return
+	}
+	registerLoginSuccess(c, user.Email, user.ID)
+	c.JSON(http.StatusOK, resp)
+}
+
+// GET /api/mfa/status
+func MFAStatus(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, c.GetUint("userID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	required, err := mfaRequired(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load mfa status"})
+		return
+	}
+	var remaining int64
+	config.DB.Model(&models.MFARecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", user.ID).
+		Count(&remaining)
+
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":                  user.MFAEnabled,
+		"required":                 required,
+		"enrolled_at":              user.MFAEnrolledAt,
+		"recovery_codes_remaining": remaining,
+	})
+}
+
+// POST /api/mfa/setup, POST /api/auth/mfa/setup (with enrollment mfa_token)
+// Starts enrollment: generates a secret and returns it with the otpauth:// URI for the QR code.
+func MFASetup(c *gin.Context) {
+	var in struct {
+		MFAToken string `json:"mfa_token"`
+	}
+	_ = c.ShouldBindJSON(&in)
+
+	user, _, err := mfaUser(c, in.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if user.MFAEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "mfa already enabled"})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate secret"})
+		return
+	}
+	sealed, err := utils.EncryptSecret(secret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store secret"})
+		return
+	}
+	if err := config.DB.Model(&user).Update("mfa_pending_secret", sealed).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store secret"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret":           secret,
+		"provisioning_uri": utils.TOTPProvisioningURI(secret, user.Email),
+	})
+}
+
+// POST /api/mfa/activate, POST /api/auth/mfa/activate (with enrollment mfa_token)
+// Confirms enrollment with a first code and returns recovery codes. When enrolling
+// from the login challenge, a session is issued as well.
+func MFAActivate(c *gin.Context) {
+	var in struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code required"})
+		return
+	}
+
+	user, viaChallenge, err := mfaUser(c, in.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if user.MFAEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "mfa already enabled"})
+		return
+	}
+	if user.MFAPendingSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "call setup first"})
+		return
+	}
+
+	secret, err := utils.DecryptSecret(user.MFAPendingSecret)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read secret"})
+		return
+	}
+	step, ok := utils.ValidateTOTP(secret, in.Code, time.Now())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid verification code"})
+		return
+	}
+
+	var codes []string
+	now := time.Now()
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"mfa_enabled":        true,
+			"mfa_secret":         user.MFAPendingSecret,
+			"mfa_pending_secret": "",
+			"mfa_last_step":      step,
+			"mfa_enrolled_at":    now,
+		}).Error; err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enable mfa"})
+		return
+	}
+
+	resp := gin.H{"message": "mfa enabled", "recovery_codes": codes}
+	if viaChallenge {
+		session, err := issueSession(c, user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue token"})
+			return
+		}
+		registerLoginSuccess(c, user.Email, user.ID)
+		for k, v := range session {
+			resp[k] = v
+		}
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// POST /api/mfa/disable
+// Requires the password and a current code. Not allowed when any of the user's
+// roles mandates MFA.
+func MFADisable(c *gin.Context) {
+	var in struct {
+		Password string `json:"password" binding:"required"`
+		Code     string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password and code required"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, c.GetUint("userID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if !user.MFAEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mfa is not enabled"})
+		return
+	}
+	if required, err := mfaRequired(user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to disable mfa"})
+		return
+	} else if required {
+		c.JSON(http.StatusForbidden, gin.H{"error": "mfa is mandatory for your role"})
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(in.Password)) != nil || !checkTOTP(&user, in.Code) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid password or code"})
+		return
+	}
+
+	if err := clearMFA(config.DB, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to disable mfa"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "mfa disabled"})
+}
+
+// POST /api/mfa/recovery-codes
+// Regenerates recovery codes after verifying a current TOTP code.
+func MFARegenerateRecoveryCodes(c *gin.Context) {
+	var in struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code required"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, c.GetUint("userID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if !user.MFAEnabled || !checkTOTP(&user, in.Code) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid verification code"})
+		return
+	}
+
+	var codes []string
+	err := config.DB.Transaction(func(tx *gorm.DB) (err error) {
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to regenerate codes"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// POST /api/users/:id/mfa/reset (HR only)
+// Clears MFA for a user who lost their device; they re-enroll on next login if required.
+func ResetUserMFA(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := clearMFA(tx, user.ID); err != nil {
+			return err
+		}
+		return revokeAllSessions(tx, user.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset mfa"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "mfa reset, sessions revoked"})
+}
+
+func clearMFA(db *gorm.DB, userID uint) error {
+	if err := db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"mfa_enabled":        false,
+		"mfa_secret":         "",
+		"mfa_pending_secret": "",
+		"mfa_enrolled_at":    nil,
+	}).Error; err != nil {
+		return err
+	}
+	return db.Where("user_id = ?", userID).Delete(&models.MFARecoveryCode{}).Error
+}
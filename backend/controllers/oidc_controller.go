@@ -0,0 +1,306 @@
+package controllers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"peoplesoft/audit"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"peoplesoft/oidc"
+	"peoplesoft/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	oidcLoginTTL   = 10 * time.Minute // time allowed at the IdP
+	oidcHandoffTTL = time.Minute      // time for the frontend to redeem the handoff code
+)
+
+// oidcBindingCookie holds a random value set when a login starts; the callback
+// is only accepted from the browser that carries it, so a callback URL started
+// by someone else cannot log a victim into the attacker's account.
+const (
+	oidcBindingCookie = "oidc_binding"
+	oidcCookiePath    = "/api/auth/oidc"
+)
+
+func setOIDCBinding(c *gin.Context, value string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcBindingCookie, value, maxAge, oidcCookiePath, "", true, true)
+}
+
+// bindingMatches compares the request's binding cookie with the stored hash.
+func bindingMatches(c *gin.Context, hash string) bool {
+	value, err := c.Cookie(oidcBindingCookie)
+	if err != nil || value == "" || hash == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(utils.HashToken(value)), []byte(hash)) == 1
+}
+
+// GET /api/auth/oidc/providers
+// Lists identity providers that support the server-side login flow.
+func ListOIDCProviders(c *gin.Context) {
+	out := []gin.H{}
+	for _, p := range oidc.List() {
+		if !p.SupportsCodeFlow() {
+			continue
+		}
+		out = append(out, gin.H{"id": p.ID, "name": p.DisplayName, "login_url": "/api/auth/oidc/" + p.ID + "/login"})
+	}
+	c.JSON(http.StatusOK, gin.H{"data": out})
+}
+
+// GET /api/auth/oidc/:provider/login
+// Starts the authorization-code flow with PKCE and redirects to the IdP.
+// ?format=json returns the URL instead of redirecting; the request must then
+// be sent with credentials so the browser keeps the binding cookie.
+func OIDCLoginStart(c *gin.Context) {
+	authURL, ok := startOIDCLogin(c, nil)
+	if !ok {
+		return
+	}
+	if c.Query("format") == "json" {
+		c.JSON(http.StatusOK, gin.H{"url": authURL})
+		return
+	}
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// POST /api/auth/oidc/:provider/link
+// Lets a signed-in user link an account at the provider to their own, which is
+// how existing accounts the provider did not provision get SSO. Returns the
+// IdP URL to open (send with credentials for the binding cookie); the callback
+// links the identity and redirects to the frontend with ?sso_linked=provider.
+func OIDCLinkStart(c *gin.Context) {
+	userID := c.GetUint("userID")
+	if userID == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only users can link an identity provider"})
+		return
+	}
+	if authURL, ok := startOIDCLogin(c, &userID); ok {
+		c.JSON(http.StatusOK, gin.H{"url": authURL})
+	}
+}
+
+// startOIDCLogin records a login attempt, sets the binding cookie and returns
+// the IdP authorization URL. It writes the error response itself.
+func startOIDCLogin(c *gin.Context, linkUserID *uint) (string, bool) {
+	p, ok := oidc.Get(c.Param("provider"))
+	if !ok || !p.SupportsCodeFlow() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown identity provider"})
+		return "", false
+	}
+
+	state, err1 := oidc.RandomString()
+	nonce, err2 := oidc.RandomString()
+	verifier, err3 := oidc.RandomString()
+	binding, err4 := oidc.RandomString()
+	if err := errors.Join(err1, err2, err3, err4); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start login"})
+		return "", false
+	}
+
+	authURL, err := p.AuthCodeURL(c.Request.Context(), state, nonce, verifier)
+	if err != nil {
+		log.Printf("oidc %s: %v", p.ID, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "identity provider unavailable"})
+		return "", false
+	}
+
+	if err := config.DB.Create(&models.OIDCLogin{
+		StateHash:    utils.HashToken(state),
+		Provider:     p.ID,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		BindingHash:  utils.HashToken(binding),
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().Add(oidcLoginTTL),
+	}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start login"})
+		return "", false
+	}
+	setOIDCBinding(c, binding, int(oidcLoginTTL/time.Second))
+	return authURL, true
+}
+
+// GET /api/auth/oidc/:provider/callback
+// Validates state and the browser binding cookie, redeems the code with the
+// PKCE verifier, verifies the ID token (nonce included) and redirects to the
+// frontend with a single-use handoff code.
+func OIDCCallback(c *gin.Context) {
+	providerID := c.Param("provider")
+	p, ok := oidc.Get(providerID)
+	if !ok || !p.SupportsCodeFlow() {
+		c.JSON(http.StatusNotFound, gin.H{"error": "unknown identity provider"})
+		return
+	}
+	// The binding is single-use whatever the outcome; the request keeps its copy
+	setOIDCBinding(c, "", -1)
+	if idpErr := c.Query("error"); idpErr != "" {
+		redirectSSOError(c, idpErr)
+		return
+	}
+	state, code := c.Query("state"), c.Query("code")
+	if state == "" || code == "" {
+		redirectSSOError(c, "invalid_request")
+		return
+	}
+
+	// Consume the state exactly once
+	var login models.OIDCLogin
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("state_hash = ? AND provider = ?", utils.HashToken(state), providerID).
+			First(&login).Error; err != nil {
+			return err
+		}
+		if login.CallbackAt != nil || time.Now().After(login.ExpiresAt) {
+			return errors.New("state expired or already used")
+		}
+		if !bindingMatches(c, login.BindingHash) {
+			return errors.New("login was started in another browser")
+		}
+		return tx.Model(&login).Update("callback_at", time.Now()).Error
+	})
+	if err != nil {
+		log.Printf("oidc %s: callback rejected: %v", providerID, err)
+		redirectSSOError(c, "invalid_state")
+		return
+	}
+
+	idToken, err := p.Exchange(c.Request.Context(), code, login.CodeVerifier)
+	if err != nil {
+		log.Printf("oidc %s: %v", p.ID, err)
+		redirectSSOError(c, "exchange_failed")
+		return
+	}
+	identity, err := p.VerifyIDToken(c.Request.Context(), idToken, login.Nonce)
+	if err != nil {
+		log.Printf("oidc %s: %v", p.ID, err)
+		redirectSSOError(c, "invalid_token")
+		return
+	}
+
+	if login.LinkUserID != nil {
+		finishOIDCLink(c, *login.LinkUserID, identity)
+		return
+	}
+
+	user, err := ssoUser(p, identity)
+	if errors.Is(err, errLinkRequired) {
+		recordLoginAttempt(c, identity.Email, nil, false, "sso_link_required")
+		redirectSSOError(c, "link_required")
+		return
+	}
+	if errors.Is(err, errNotProvisioned) {
+		recordLoginAttempt(c, identity.Email, nil, false, "sso_not_provisioned")
+		redirectSSOError(c, "not_provisioned")
+		return
+	}
+	if errors.Is(err, errDeactivated) {
+		recordLoginAttempt(c, identity.Email, nil, false, "deactivated")
+		redirectSSOError(c, "deactivated")
+		return
+	}
+	if err != nil {
+		redirectSSOError(c, "user_error")
+		return
+	}
+
+	raw, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		redirectSSOError(c, "server_error")
+		return
+	}
+	if err := config.DB.Model(&login).Updates(map[string]interface{}{
+		"user_id":      user.ID,
+		"handoff_hash": hash,
+		"expires_at":   time.Now().Add(oidcHandoffTTL),
+	}).Error; err != nil {
+		redirectSSOError(c, "server_error")
+		return
+	}
+	recordLoginAttempt(c, user.Email, &user.ID, true, "sso:"+identity.Provider)
+
+	c.Redirect(http.StatusFound, frontendURL("/sso/callback?code="+url.QueryEscape(raw)))
+}
+
+// POST /api/auth/oidc/exchange
+// Trades the handoff code from the callback redirect for access + refresh tokens.
+func OIDCExchange(c *gin.Context) {
+	var body struct {
+		Code string `json:"code" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "code required"})
+		return
+	}
+
+	var login models.OIDCLogin
+	res := config.DB.Model(&login).
+		Clauses(clause.Returning{}).
+		Where("handoff_hash = ? AND exchanged_at IS NULL AND expires_at > ?", utils.HashToken(body.Code), time.Now()).
+		Update("exchanged_at", time.Now())
+	if res.Error != nil || res.RowsAffected == 0 || login.UserID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired code"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, *login.UserID).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+		return
+	}
+	// Same second factor as a password login
+	challenge, err := mfaLoginStep(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue token"})
+		return
+	}
+	if challenge != nil {
+		c.JSON(http.StatusOK, challenge)
+		return
+	}
+	resp, err := issueSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue token"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// finishOIDCLink links the verified identity to the user who started the link.
+func finishOIDCLink(c *gin.Context, userID uint, identity *oidc.Identity) {
+	err := linkIdentity(userID, identity)
+	if errors.Is(err, errIdentityTaken) {
+		redirectSSOError(c, "identity_taken")
+		return
+	}
+	if err != nil {
+		log.Printf("oidc %s: link for user %d failed: %v", identity.Provider, userID, err)
+		redirectSSOError(c, "server_error")
+		return
+	}
+	audit.Record(c, "user.identity_link", "user", userID, nil, gin.H{"provider": identity.Provider, "subject": identity.Subject})
+	c.Redirect(http.StatusFound, frontendURL("/?sso_linked="+url.QueryEscape(identity.Provider)))
+}
+
+func redirectSSOError(c *gin.Context, code string) {
+	c.Redirect(http.StatusFound, frontendURL("/login?sso_error="+url.QueryEscape(code)))
+}
+
+func frontendURL(path string) string {
+	return fmt.Sprintf("%s%s", strings.TrimRight(os.Getenv("FRONTEND_URL"), "/"), path)
+}
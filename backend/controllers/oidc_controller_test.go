@@ -0,0 +1,83 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"peoplesoft/oidc"
+	"peoplesoft/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestBindingMatches(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	hash := utils.HashToken("binding-1")
+	tests := []struct {
+		name   string
+		cookie string // "" sends no cookie
+		hash   string
+		want   bool
+	}{
+		{"same browser", "binding-1", hash, true},
+		{"other browser", "binding-2", hash, false},
+		{"no cookie", "", hash, false},
+		{"login without binding", "binding-1", "", false},
+	}
+	for _, tt := range tests {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/auth/oidc/test/callback", nil)
+		if tt.cookie != "" {
+			c.Request.AddCookie(&http.Cookie{Name: oidcBindingCookie, Value: tt.cookie})
+		}
+		if got := bindingMatches(c, tt.hash); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// The callback checks below return before the state lookup, so they run
+// without a database.
+func TestOIDCCallbackRejectsBeforeState(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("FRONTEND_URL", "https://hr.example.com")
+	oidc.Register(&oidc.Provider{ID: "cbtest", Issuer: "https://idp.example.com", ClientID: "peoplesoft", RedirectURL: "https://hr.example.com/cb"})
+
+	r := gin.New()
+	r.GET("/api/auth/oidc/:provider/callback", OIDCCallback)
+	tests := []struct {
+		name, path string
+		status     int
+		location   string
+	}{
+		{"unknown provider", "/api/auth/oidc/nope/callback?state=s&code=c", http.StatusNotFound, ""},
+		{"missing state", "/api/auth/oidc/cbtest/callback?code=c", http.StatusFound, "https://hr.example.com/login?sso_error=invalid_request"},
+		{"missing code", "/api/auth/oidc/cbtest/callback?state=s", http.StatusFound, "https://hr.example.com/login?sso_error=invalid_request"},
+		{"idp error", "/api/auth/oidc/cbtest/callback?error=access_denied&state=s", http.StatusFound, "https://hr.example.com/login?sso_error=access_denied"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		req.AddCookie(&http.Cookie{Name: oidcBindingCookie, Value: "binding-1"})
+		r.ServeHTTP(w, req)
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.status)
+			continue
+		}
+		if got := w.Header().Get("Location"); got != tt.location {
+			t.Errorf("%s: Location %q, want %q", tt.name, got, tt.location)
+		}
+		if tt.status != http.StatusFound {
+			continue
+		}
+		// The binding cookie is single use whatever the outcome
+		cookie := w.Header().Get("Set-Cookie")
+		for _, want := range []string{oidcBindingCookie + "=;", "Path=" + oidcCookiePath, "Max-Age=0", "HttpOnly", "Secure", "SameSite=Lax"} {
+			if !strings.Contains(cookie, want) {
+				t.Errorf("%s: Set-Cookie %q lacks %q", tt.name, cookie, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"peoplesoft/authz"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"peoplesoft/org"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GET /api/org-chart?root_id=&depth=
+// Returns the reporting tree from the top of the organization (scope_all
+// holders; others get their own subtree), or the subtree under root_id when
+// the caller may view that employee. depth (default 3, max org.MaxDepth) limits how many levels of
+// children are included; direct_reports and headcount always cover the whole subtree.
+// Nodes carry directory fields only, the same ones ListEmployees shows everyone.
+func GetOrgChart(c *gin.Context) {
+	depth := 3
+	if v := c.Query("depth"); v != "" {
+		d, err := strconv.Atoi(v)
+		if err != nil || d < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "depth must be a non-negative integer"})
+			return
+		}
+		depth = d
+	}
+	if depth > org.MaxDepth {
+		depth = org.MaxDepth
+	}
+
+	var roots []uint
+	if v := c.Query("root_id"); v != "" {
+		var emp models.Employee
+		if err := config.DB.First(&emp, parseUint(v)).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "employee not found"})
+			return
+		}
+		if !authz.CanAccessEmployee(c, emp.ID, authz.View) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to view this employee"})
+			return
+		}
+		roots = []uint{emp.ID}
+	} else if !authz.Can(c, authz.ScopeAll) {
+		me := authz.CallerEmployeeID(c)
+		if me == 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "no employee record to start the org chart from; pass root_id"})
+			return
+		}
+		roots = []uint{me}
+	} else {
+		var err error
+		if roots, err = org.Roots(config.DB); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load org chart"})
+			return
+		}
+	}
+
+	tree, err := org.Tree(config.DB, roots, depth)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load org chart"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"depth": depth, "data": tree})
+}
+
+// GET /api/employees/:id/chain
+// Chain of command from the employee up to the top of the organization, for
+// employees the caller may view.
+// "cycle" is true when the reporting line loops back on itself (bad data).
+func GetChainOfCommand(c *gin.Context) {
+	id := parseUint(c.Param("id"))
+	if !authz.CanAccessEmployee(c, id, authz.View) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to view this employee"})
+		return
+	}
+	links, cycle, err := org.Chain(config.DB, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load chain of command"})
+		return
+	}
+	if len(links) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "employee not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": links, "cycle": cycle})
+}
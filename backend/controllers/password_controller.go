@@ -0,0 +1,220 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"peoplesoft/utils"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const passwordResetTTL = 30 * time.Minute
+
+// errResetTokenUsed is returned when another request claimed the reset token first.
+var errResetTokenUsed = errors.New("reset token already used")
+
+// POST /api/auth/change-password
+// Requires the current password. All existing sessions are revoked and a fresh
+// session is returned to the caller.
+func ChangePassword(c *gin.Context) {
+	var in struct {
+		CurrentPassword string `json:"current_password" binding:"required"`
+		NewPassword     string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "current_password and new_password required"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, c.GetUint("userID")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(in.CurrentPassword)) != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "current password is incorrect"})
+		return
+	}
+	if in.CurrentPassword == in.NewPassword {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "new password must differ from the current one"})
+		return
+	}
+	if err := utils.GetPasswordPolicy().Validate(in.NewPassword, user.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := setPassword(&user, in.NewPassword); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change password"})
+		return
+	}
+
+	resp, err := issueSession(c, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue token"})
+		return
+	}
+	resp["message"] = "password changed"
+	c.JSON(http.StatusOK, resp)
+}
+
+// POST /api/auth/forgot-password
+// Always answers 200 so the endpoint cannot be used to discover accounts.
+func ForgotPassword(c *gin.Context) {
+	var in struct {
+		Email string `json:"email" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "email required"})
+		return
+	}
+
+	const msg = "if the account exists, a reset link has been sent"
+
+	// Throttled per address and IP whether or not the account exists
+	if wait := passwordResetBlocked(in.Email, c.ClientIP()); wait > 0 {
+		respondThrottled(c, wait)
+		return
+	}
+
+	var user models.User
+	err := config.DB.Where("email = ?", strings.TrimSpace(in.Email)).First(&user).Error
+	var userID *uint
+	if err == nil {
+		userID = &user.ID
+	}
+	recordLoginAttempt(c, normalizeEmail(in.Email), userID, false, reasonResetRequested)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"message": msg})
+		return
+	}
+
+	link, err := passwordResetLink(user, passwordResetTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create reset token"})
+		return
+	}
+
+	body := fmt.Sprintf("Hi %s,\n\nUse the link below to reset your PeopleSoft password. It expires in %d minutes and can be used once.\n\n%s\n\nIf you did not request this, you can ignore this email.",
+		user.Name, int(passwordResetTTL.Minutes()), link)
+	if err := utils.Mail.Send(user.Email, "Reset your PeopleSoft password", body); err != nil {
+		log.Printf("reset mail to %s failed: %v", user.Email, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": msg})
+}
+
+// passwordResetLink issues a single-use reset token for user, invalidating
+// any earlier unused ones, and returns the frontend link that consumes it.
+func passwordResetLink(user models.User, ttl time.Duration) (string, error) {
+	raw, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// Only the most recent link is valid
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", user.ID).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordResetToken{
+			UserID:    user.ID,
+			TokenHash: hash,
+			ExpiresAt: time.Now().Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/reset-password?token=%s", strings.TrimRight(os.Getenv("FRONTEND_URL"), "/"), raw), nil
+}
+
+// POST /api/auth/reset-password
+// Consumes a reset token, sets the new password and revokes every session.
+func ResetPassword(c *gin.Context) {
+	var in struct {
+		Token       string `json:"token" binding:"required"`
+		NewPassword string `json:"new_password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token and new_password required"})
+		return
+	}
+
+	var reset models.PasswordResetToken
+	if err := config.DB.Where("token_hash = ? AND used_at IS NULL", utils.HashToken(in.Token)).
+		First(&reset).Error; err != nil || time.Now().After(reset.ExpiresAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reset link is invalid or expired"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, reset.UserID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reset link is invalid or expired"})
+		return
+	}
+	if err := utils.GetPasswordPolicy().Validate(in.NewPassword, user.Email); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Claiming the token, storing the password and revoking sessions commit
+	// together, so a failed write leaves the link usable. The conditional
+	// update makes the token single-use under concurrency.
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", reset.ID).
+			Update("used_at", time.Now())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errResetTokenUsed
+		}
+		return setPasswordTx(tx, &user, in.NewPassword)
+	})
+	if errors.Is(err, errResetTokenUsed) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reset link is invalid or expired"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "password reset, please log in"})
+}
+
+// setPassword stores a new bcrypt hash and revokes all sessions of the user.
+// user.SessionVersion is refreshed so a new session can be issued afterwards.
+func setPassword(user *models.User, password string) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		return setPasswordTx(tx, user, password)
+	})
+}
+
+// setPasswordTx is setPassword within the caller's transaction.
+func setPasswordTx(tx *gorm.DB, user *models.User, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), 10)
+	if err != nil {
+		return err
+	}
+	if err := tx.Model(user).Update("password_hash", string(hash)).Error; err != nil {
+		return err
+	}
+	if err := revokeAllSessions(tx, user.ID); err != nil {
+		return err
+	}
+	return tx.First(user, user.ID).Error
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"net/http"
+	"peoplesoft/authz"
 	"peoplesoft/config"
 	"peoplesoft/models"
 	_ "peoplesoft/utils"
@@ -88,9 +89,8 @@ func AddPerformanceComment(c *gin.Context) {
 // UpdatePerformanceScore - Manager/HR only
 func UpdatePerformanceScore(c *gin.Context) {
 	id := c.Param("id")
-	role := c.GetString("role")
 
-	if role != "manager" && role != "hr" {
+	if !authz.Can(c, authz.PerformanceEdit) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized"})
 		return
 	}
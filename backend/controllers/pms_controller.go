@@ -4,10 +4,13 @@ import (
 	"net/http"
 	"time"
 
+	"peoplesoft/audit"
+	"peoplesoft/authz"
 	"peoplesoft/config"
 	"peoplesoft/models"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func mustUser(c *gin.Context) (email, role string, userID uint) {
@@ -25,8 +28,8 @@ func mustUser(c *gin.Context) (email, role string, userID uint) {
 // POST /api/pms/hr/assign-goals (HR only)
 // HR assigns goals to managers
 func HRAssignGoalsToManager(c *gin.Context) {
-	_, role, userID := mustUser(c)
-	if role != "hr" {
+	_, _, userID := mustUser(c)
+	if !authz.Can(c, authz.GoalAssignMgr) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "HR access only"})
 		return
 	}
@@ -43,10 +46,8 @@ func HRAssignGoalsToManager(c *gin.Context) {
 		return
 	}
 
-	// Verify manager role
-	var managerRole string
-	config.DB.Table("users").Select("role").Where("id = ?", in.ManagerID).Scan(&managerRole)
-	if managerRole != "manager" {
+	// Verify target is a manager, i.e. can assign goals to a team
+	if !authz.HasPermission(in.ManagerID, authz.GoalAssign) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "target user is not a manager"})
 		return
 	}
@@ -71,8 +72,8 @@ func HRAssignGoalsToManager(c *gin.Context) {
 // POST /api/pms/manager/assign-goals (Manager only)
 // Manager assigns goals to employees
 func ManagerAssignGoalsToEmployee(c *gin.Context) {
-	_, role, userID := mustUser(c)
-	if role != "manager" {
+	_, _, userID := mustUser(c)
+	if !authz.Can(c, authz.GoalAssign) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Manager access only"})
 		return
 	}
@@ -94,9 +95,9 @@ func ManagerAssignGoalsToEmployee(c *gin.Context) {
 		ManagerID uint
 	}
 
-	if err := config.DB.Table("employees e").
+	if err := config.DB.Table("employees e").Where("e.deleted_at IS NULL").
 		Select("e.manager_id").
-		Joins("JOIN employees m ON m.id = e.manager_id").
+		Joins("JOIN employees m ON m.id = e.manager_id AND m.deleted_at IS NULL").
 		Where("e.user_id = ? AND m.user_id = ?", in.EmployeeID, userID).
 		Scan(&emp).Error; err != nil || emp.ManagerID == 0 {
 		c.JSON(http.StatusForbidden, gin.H{"error": "employee not in your team"})
@@ -126,7 +127,7 @@ func GetMyAssignedGoals(c *gin.Context) {
 	_, role, userID := mustUser(c)
 	cycleID := c.Query("cycle_id")
 
-	db := config.DB.Table("goals").Where("user_id = ?", userID)
+	db := config.DB.Table("goals").Where("deleted_at IS NULL").Where("user_id = ?", userID)
 	
 	// Filter by assignment status based on role
 	if role == "manager" {
@@ -174,10 +175,13 @@ func AcceptGoal(c *gin.Context) {
 			"accepted_at": now,
 		}
 		
+		before := goal
 		if err := config.DB.Model(&goal).Updates(updates).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
 			return
 		}
+		config.DB.First(&goal, goal.ID)
+		audit.Record(c, "goal.accept", "goal", goal.ID, before, goal)
 		c.JSON(http.StatusOK, gin.H{"message": "goal accepted", "status": "accepted"})
 	} else {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot accept goal in current state"})
@@ -223,17 +227,20 @@ func SubmitGoalForApproval(c *gin.Context) {
 		updates["description"] = goal.Description + "\n\n--- Submission Comments ---\n" + in.Comments
 	}
 
+	before := goal
 	if err := config.DB.Model(&goal).Updates(updates).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
 		return
 	}
+	config.DB.First(&goal, goal.ID)
+	audit.Record(c, "goal.submit", "goal", goal.ID, before, goal)
 	c.JSON(http.StatusOK, gin.H{"message": "goal submitted for approval", "status": "submitted"})
 }
 
 // GET /api/pms/pending-approvals
 // Get goals pending approval (for Manager/HR)
 func GetPendingApprovals(c *gin.Context) {
-	_, role, userID := mustUser(c)
+	_, _, userID := mustUser(c)
 
 	var rows []struct {
 		models.Goal
@@ -241,23 +248,31 @@ func GetPendingApprovals(c *gin.Context) {
 		EmployeeEmail string `json:"employee_email"`
 	}
 
-	db := config.DB.Table("goals g").
+	db := config.DB.Table("goals g").Where("g.deleted_at IS NULL").
 		Select("g.*, u.name as employee_name, u.email as employee_email").
 		Joins("JOIN users u ON u.id = g.user_id").
+		Joins("LEFT JOIN employees e ON e.user_id = g.user_id AND e.deleted_at IS NULL").
 		Where("g.status = ?", "submitted")
 
-	if role == "manager" {
+	canTeam := authz.Can(c, authz.GoalAssign)
+	canManagers := authz.Can(c, authz.GoalAssignMgr)
+
+	switch {
+	case canTeam && canManagers:
+		var managerEmpID uint
+		config.DB.Table("employees").Where("deleted_at IS NULL").Select("id").Where("user_id = ?", userID).Scan(&managerEmpID)
+		db = db.Where("(e.manager_id = ? AND g.level = ?) OR g.level = ?", managerEmpID, "manager_employee", "hr_manager")
+	case canTeam:
 		// Get manager's employee ID first
 		var managerEmpID uint
-		config.DB.Table("employees").Select("id").Where("user_id = ?", userID).Scan(&managerEmpID)
-		
+		config.DB.Table("employees").Where("deleted_at IS NULL").Select("id").Where("user_id = ?", userID).Scan(&managerEmpID)
+
 		// Manager sees employee submissions from their team
-		db = db.Joins("JOIN employees e ON e.user_id = g.user_id").
-			Where("e.manager_id = ? AND g.level = ?", managerEmpID, "manager_employee")
-	} else if role == "hr" {
+		db = db.Where("e.manager_id = ? AND g.level = ?", managerEmpID, "manager_employee")
+	case canManagers:
 		// HR sees manager submissions
 		db = db.Where("g.level = ?", "hr_manager")
-	} else {
+	default:
 		c.JSON(http.StatusForbidden, gin.H{"error": "insufficient privileges"})
 		return
 	}
@@ -272,7 +287,7 @@ func GetPendingApprovals(c *gin.Context) {
 // POST /api/pms/reviews/:goal_id/approve
 // Manager approves employee goal or HR approves manager goal
 func ApproveGoalAndReview(c *gin.Context) {
-	_, role, userID := mustUser(c)
+	_, _, userID := mustUser(c)
 	goalID := c.Param("goal_id")
 
 	var in struct {
@@ -297,13 +312,21 @@ func ApproveGoalAndReview(c *gin.Context) {
 		return
 	}
 
-	// Verify correct approver
-	if role == "manager" && goal.Level != "manager_employee" {
+	// Verify correct approver: whoever may assign goals at a level approves that level
+	if goal.Level == "manager_employee" && !authz.Can(c, authz.GoalAssign) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only approve manager goals"})
+		return
+	}
+	if goal.Level == "hr_manager" && !authz.Can(c, authz.GoalAssignMgr) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "you can only approve employee goals"})
 		return
 	}
-	if role == "hr" && goal.Level != "hr_manager" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "you can only approve manager goals"})
+	if goal.Level != "manager_employee" && goal.Level != "hr_manager" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "goal does not require approval"})
+		return
+	}
+	if !authz.CanAccessUser(c, goal.UserID, authz.Manage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "goal owner is not in your scope"})
 		return
 	}
 
@@ -382,7 +405,7 @@ func ReviewsGiven(c *gin.Context) {
 		EmployeeName string `json:"employee_name"`
 	}
 
-	db := config.DB.Table("manager_reviews mr").
+	db := config.DB.Table("manager_reviews mr").Where("mr.deleted_at IS NULL").
 		Select("mr.*, u.name as employee_name").
 		Joins("JOIN users u ON u.id = mr.employee_id").
 		Where("mr.reviewer_id = ?", userID).
@@ -397,9 +420,7 @@ func ReviewsGiven(c *gin.Context) {
 
 // GET /api/pms/all-reviews (for HR to see all reviews)
 func AllReviews(c *gin.Context) {
-	_, role, _ := mustUser(c)
-	
-	if role != "hr" && role != "admin" {
+	if !authz.Can(c, authz.ReviewViewAll) {
 		c.JSON(http.StatusForbidden, gin.H{"error": "HR access only"})
 		return
 	}
@@ -412,11 +433,11 @@ func AllReviews(c *gin.Context) {
 		GoalTitle      string `json:"goal_title"`
 	}
 
-	db := config.DB.Table("manager_reviews mr").
+	db := config.DB.Table("manager_reviews mr").Where("mr.deleted_at IS NULL").
 		Select("mr.*, u1.name as employee_name, u2.name as reviewer_name, e.designation as job_title, (SELECT STRING_AGG(g.title, ', ') FROM goals g WHERE g.user_id = mr.employee_id AND g.cycle_id = mr.cycle_id) as goal_title").
 		Joins("JOIN users u1 ON u1.id = mr.employee_id").
 		Joins("JOIN users u2 ON u2.id = mr.reviewer_id").
-		Joins("LEFT JOIN employees e ON e.user_id = mr.employee_id").
+		Joins("LEFT JOIN employees e ON e.user_id = mr.employee_id AND e.deleted_at IS NULL").
 		Order("mr.reviewed_at desc")
 
 	if err := db.Scan(&rows).Error; err != nil {
@@ -433,7 +454,7 @@ func ListMyGoals(c *gin.Context) {
 	_, _, userID := mustUser(c)
 	cycleID := c.Query("cycle_id")
 
-	db := config.DB.Table("goals").Where("user_id = ? AND (level = ? OR level IS NULL)", userID, "self")
+	db := config.DB.Table("goals").Where("deleted_at IS NULL").Where("user_id = ? AND (level = ? OR level IS NULL)", userID, "self")
 	if cycleID != "" {
 		db = db.Where("cycle_id = ?", cycleID)
 	}
@@ -474,6 +495,7 @@ func CreateGoal(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "create failed"})
 		return
 	}
+	audit.Record(c, "goal.create", "goal", g.ID, nil, g)
 	c.JSON(http.StatusCreated, gin.H{"data": g})
 }
 
@@ -511,18 +533,18 @@ func UpdateGoal(c *gin.Context) {
 		updates["status"] = *in.Status
 	}
 
-	tx := config.DB.Model(&models.Goal{}).
-		Where("id = ? AND user_id = ?", id, userID).
-		Updates(updates)
-
-	if tx.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+	var goal models.Goal
+	if err := config.DB.Where("id = ? AND user_id = ?", id, userID).First(&goal).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "goal not found"})
 		return
 	}
-	if tx.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "goal not found"})
+	before := goal
+	if err := config.DB.Model(&goal).Updates(updates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
 		return
 	}
+	config.DB.First(&goal, goal.ID)
+	audit.Record(c, "goal.update", "goal", goal.ID, before, goal)
 	c.JSON(http.StatusOK, gin.H{"message": "updated"})
 }
 
@@ -535,7 +557,11 @@ func ManagerListEmployeeGoals(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "employee_id required"})
 		return
 	}
-	db := config.DB.Table("goals").Where("user_id = ?", emp)
+	if !authz.CanAccessUser(c, parseUint(emp), authz.View) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "employee not in your scope"})
+		return
+	}
+	db := config.DB.Table("goals").Where("deleted_at IS NULL").Where("user_id = ?", emp)
 	if cycle != "" {
 		db = db.Where("cycle_id = ?", cycle)
 	}
@@ -550,9 +576,40 @@ func ManagerListEmployeeGoals(c *gin.Context) {
 
 /* ========== REPORTS ========== */
 
+// reviewedUsers returns a subquery of the user ids whose reviews the caller may
+// read besides their own, or nil for none. Only review.view_all and
+// performance.edit holders see anyone else's, limited to the employees they
+// may view; teamOnly narrows that to their reporting line and department
+// scopes even when they hold scope_all.
+func reviewedUsers(c *gin.Context, teamOnly bool) *gorm.DB {
+	if !authz.Can(c, authz.ReviewViewAll) && !authz.Can(c, authz.PerformanceEdit) {
+		return nil
+	}
+	db := config.DB.Table("employees se").Where("se.deleted_at IS NULL").Select("se.user_id")
+	if teamOnly {
+		ids := authz.AccessibleEmployeeIDs(c, authz.View)
+		if len(ids) == 0 {
+			return nil
+		}
+		return db.Where("se.id IN ?", ids)
+	}
+	return authz.ScopeEmployees(c, db, "se.id", authz.View)
+}
+
+// scopeReviews limits a manager_reviews query (alias mr) to the caller's own
+// reviews plus those of reviewedUsers.
+func scopeReviews(c *gin.Context, db *gorm.DB, userID uint, teamOnly bool) *gorm.DB {
+	if others := reviewedUsers(c, teamOnly); others != nil {
+		return db.Where("mr.employee_id = ? OR mr.employee_id IN (?)", userID, others)
+	}
+	return db.Where("mr.employee_id = ?", userID)
+}
+
 // GET /api/pms/reports/performance
+// Own performance data, plus that of the employees in scope for review.view_all
+// and performance.edit holders (everyone for HR).
 func PerformanceReports(c *gin.Context) {
-	_, role, userID := mustUser(c)
+	_, _, userID := mustUser(c)
 
 	var reports []struct {
 		EmployeeID     uint    `json:"employee_id"`
@@ -566,7 +623,7 @@ func PerformanceReports(c *gin.Context) {
 		GoalTitles     string  `json:"goal_titles"`
 	}
 
-	db := config.DB.Table("manager_reviews mr").
+	db := config.DB.Table("manager_reviews mr").Where("mr.deleted_at IS NULL").
 		Select(`
 			mr.employee_id,
 			u.name as employee_name,
@@ -579,25 +636,11 @@ func PerformanceReports(c *gin.Context) {
 			STRING_AGG(DISTINCT g.title, ', ') as goal_titles
 		`).
 		Joins("JOIN users u ON u.id = mr.employee_id").
-		Joins("LEFT JOIN employees e ON e.user_id = u.id").
+		Joins("LEFT JOIN employees e ON e.user_id = u.id AND e.deleted_at IS NULL").
 		Joins("LEFT JOIN departments d ON d.id = e.department_id").
 		Joins("LEFT JOIN goals g ON g.user_id = mr.employee_id AND g.cycle_id = mr.cycle_id")
 
-	if role == "employee" {
-		// Employees see only their OWN performance data
-		db = db.Where("mr.employee_id = ?", userID)
-	} else if role == "manager" {
-		// Managers see BOTH their own data AND their team's data
-		var managerEmpID uint
-		config.DB.Table("employees").Select("id").Where("user_id = ?", userID).Scan(&managerEmpID)
-
-		// Get both: own data (mr.employee_id = userID) OR team data (emp.manager_id = managerEmpID)
-		db = db.Joins("LEFT JOIN employees emp ON emp.user_id = mr.employee_id").
-			Where("mr.employee_id = ? OR emp.manager_id = ?", userID, managerEmpID)
-	}
-	// HR sees all performance data (no filter)
-
-	db = db.Group("mr.employee_id, u.name, d.name, mr.cycle_id").
+	db = scopeReviews(c, db, userID, false).Group("mr.employee_id, u.name, d.name, mr.cycle_id").
 		Order("mr.employee_id ASC, mr.cycle_id ASC")
 
 	if err := db.Scan(&reports).Error; err != nil {
@@ -608,8 +651,9 @@ func PerformanceReports(c *gin.Context) {
 }
 
 // GET /api/pms/my-reviews
+// The caller's own reviews and, for reviewers, those of their team.
 func MyReviews(c *gin.Context) {
-	_, role, userID := mustUser(c)
+	_, _, userID := mustUser(c)
 
 	type ReviewWithEmployee struct {
 		models.ManagerReview
@@ -619,46 +663,13 @@ func MyReviews(c *gin.Context) {
 	}
 
 	var rows []ReviewWithEmployee
-
-	if role == "employee" {
-		// Employees see only their own reviews
-		if err := config.DB.Table("manager_reviews mr").
-			Select("mr.*, u.name as employee_name, e.designation as job_title, (SELECT STRING_AGG(g.title, ', ') FROM goals g WHERE g.user_id = mr.employee_id AND g.cycle_id = mr.cycle_id) as goal_title").
-			Joins("JOIN users u ON u.id = mr.employee_id").
-			Joins("LEFT JOIN employees e ON e.user_id = mr.employee_id").
-			Where("mr.employee_id = ?", userID).
-			Order("mr.reviewed_at desc").
-			Scan(&rows).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "fetch failed"})
-			return
-		}
-	} else if role == "manager" {
-		// Managers see both their own reviews AND their team's reviews
-		var managerEmpID uint
-		config.DB.Table("employees").Select("id").Where("user_id = ?", userID).Scan(&managerEmpID)
-
-		if err := config.DB.Table("manager_reviews mr").
-			Select("mr.*, u.name as employee_name, e.designation as job_title, (SELECT STRING_AGG(g.title, ', ') FROM goals g WHERE g.user_id = mr.employee_id AND g.cycle_id = mr.cycle_id) as goal_title").
-			Joins("JOIN users u ON u.id = mr.employee_id").
-			Joins("LEFT JOIN employees e ON e.user_id = mr.employee_id").
-			Where("mr.employee_id = ? OR e.manager_id = ?", userID, managerEmpID).
-			Order("mr.reviewed_at desc").
-			Scan(&rows).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "fetch failed"})
-			return
-		}
-	} else {
-		// HR or other roles (for backward compatibility)
-		if err := config.DB.Table("manager_reviews mr").
-			Select("mr.*, u.name as employee_name, e.designation as job_title, (SELECT STRING_AGG(g.title, ', ') FROM goals g WHERE g.user_id = mr.employee_id AND g.cycle_id = mr.cycle_id) as goal_title").
-			Joins("JOIN users u ON u.id = mr.employee_id").
-			Joins("LEFT JOIN employees e ON e.user_id = mr.employee_id").
-			Where("mr.employee_id = ?", userID).
-			Order("mr.reviewed_at desc").
-			Scan(&rows).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "fetch failed"})
-			return
-		}
+	db := config.DB.Table("manager_reviews mr").Where("mr.deleted_at IS NULL").
+		Select("mr.*, u.name as employee_name, e.designation as job_title, (SELECT STRING_AGG(g.title, ', ') FROM goals g WHERE g.user_id = mr.employee_id AND g.cycle_id = mr.cycle_id) as goal_title").
+		Joins("JOIN users u ON u.id = mr.employee_id").
+		Joins("LEFT JOIN employees e ON e.user_id = mr.employee_id AND e.deleted_at IS NULL")
+	if err := scopeReviews(c, db, userID, true).Order("mr.reviewed_at desc").Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "fetch failed"})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{"data": rows})
@@ -0,0 +1,604 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"peoplesoft/audit"
+	"peoplesoft/authz"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"peoplesoft/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Profile change request statuses.
+const (
+	ProfileChangePending   = "pending"
+	ProfileChangeApplied   = "applied"  // needed no approval
+	ProfileChangeApproved  = "approved" // applied after HR approval, or entered by HR
+	ProfileChangeRejected  = "rejected"
+	ProfileChangeCancelled = "cancelled"
+)
+
+var (
+	phonePattern   = regexp.MustCompile(`^\+?[0-9 ()-]{6,30}$`)
+	accountPattern = regexp.MustCompile(`^[A-Za-z0-9 ]{4,34}$`)
+	routingPattern = regexp.MustCompile(`^[A-Za-z0-9]{4,15}$`)
+)
+
+// profileField is a self-service profile field. Key is the employee_profiles
+// column, except phone which is kept on employees.
+type profileField struct {
+	Key      string
+	Label    string
+	MaxLen   int
+	Pattern  *regexp.Regexp
+	Approval bool // default when PROFILE_APPROVAL_FIELDS is unset
+	Secret   bool // sealed at rest and masked in change requests
+}
+
+var profileFields = []profileField{
+	{Key: "phone", Label: "Phone", MaxLen: 30, Pattern: phonePattern},
+	{Key: "address", Label: "Address", MaxLen: 500},
+	{Key: "emergency_contact_name", Label: "Emergency contact name", MaxLen: 100},
+	{Key: "emergency_contact_phone", Label: "Emergency contact phone", MaxLen: 30, Pattern: phonePattern},
+	{Key: "emergency_contact_relation", Label: "Emergency contact relation", MaxLen: 50},
+	{Key: "bank_account_holder", Label: "Bank account holder", MaxLen: 100, Approval: true},
+	{Key: "bank_account_number", Label: "Bank account number", MaxLen: 34, Pattern: accountPattern, Approval: true, Secret: true},
+	{Key: "bank_routing_code", Label: "Bank routing code (IFSC, sort code, ...)", MaxLen: 15, Pattern: routingPattern, Approval: true, Secret: true},
+}
+
+func findProfileField(key string) (profileField, bool) {
+	for _, f := range profileFields {
+		if f.Key == key {
+			return f, true
+		}
+	}
+	return profileField{}, false
+}
+
+// profileNeedsApproval reports whether an employee's change to key goes to HR.
+// PROFILE_APPROVAL_FIELDS (comma separated keys) overrides the defaults.
+func profileNeedsApproval(f profileField) bool {
+	if v, ok := os.LookupEnv("PROFILE_APPROVAL_FIELDS"); ok {
+		for _, k := range strings.Split(v, ",") {
+			if strings.TrimSpace(k) == f.Key {
+				return true
+			}
+		}
+		return false
+	}
+	return f.Approval
+}
+
+// canViewProfile: the employee and profile.approve holders who can see them.
+func canViewProfile(c *gin.Context, employeeID uint) bool {
+	me := authz.CallerEmployeeID(c)
+	if me != 0 && me == employeeID {
+		return true
+	}
+	return authz.Can(c, authz.ProfileApprove) && authz.CanAccessEmployee(c, employeeID, authz.View)
+}
+
+func isSecretProfileField(key string) bool {
+	f, ok := findProfileField(key)
+	return ok && f.Secret
+}
+
+// sealProfileValue encrypts a secret field's value for storage, like the MFA
+// secrets; other fields and empty values are stored as they are.
+func sealProfileValue(key, v string) (string, error) {
+	if v == "" || !isSecretProfileField(key) {
+		return v, nil
+	}
+	return utils.EncryptSecret(v)
+}
+
+// openProfileValue reverses sealProfileValue. Values written before bank
+// details were sealed do not open and are returned unchanged.
+func openProfileValue(key, v string) string {
+	if v == "" || !isSecretProfileField(key) {
+		return v
+	}
+	if plain, err := utils.DecryptSecret(v); err == nil {
+		return plain
+	}
+	return v
+}
+
+// maskProfileValue keeps the last four characters of a secret field.
+func maskProfileValue(key, v string) string {
+	if v == "" || !isSecretProfileField(key) {
+		return v
+	}
+	if n := len(v); n > 4 {
+		return strings.Repeat("*", n-4) + v[n-4:]
+	}
+	return strings.Repeat("*", len(v))
+}
+
+// profileValues returns the current value of every profile field.
+func profileValues(db *gorm.DB, emp models.Employee) (map[string]string, error) {
+	var p models.EmployeeProfile
+	if err := db.Where("employee_id = ?", emp.ID).Limit(1).Find(&p).Error; err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"phone":                      emp.Phone,
+		"address":                    p.Address,
+		"emergency_contact_name":     p.EmergencyContactName,
+		"emergency_contact_phone":    p.EmergencyContactPhone,
+		"emergency_contact_relation": p.EmergencyContactRelation,
+		"bank_account_holder":        p.BankAccountHolder,
+		"bank_account_number":        openProfileValue("bank_account_number", p.BankAccountNumber),
+		"bank_routing_code":          openProfileValue("bank_routing_code", p.BankRoutingCode),
+	}, nil
+}
+
+// applyProfileChanges writes values and returns the values they replaced.
+func applyProfileChanges(tx *gorm.DB, employeeID uint, values map[string]string) (map[string]string, error) {
+	var emp models.Employee
+	if err := tx.First(&emp, employeeID).Error; err != nil {
+		return nil, err
+	}
+	current, err := profileValues(tx, emp)
+	if err != nil {
+		return nil, err
+	}
+	previous := map[string]string{}
+	updates := map[string]any{}
+	for k, v := range values {
+		previous[k] = current[k]
+		if k != "phone" {
+			sealed, err := sealProfileValue(k, v)
+			if err != nil {
+				return nil, err
+			}
+			updates[k] = sealed
+		}
+	}
+	if v, ok := values["phone"]; ok {
+		if err := tx.Model(&emp).Update("phone", v).Error; err != nil {
+			return nil, err
+		}
+	}
+	if len(updates) > 0 {
+		p := models.EmployeeProfile{EmployeeID: employeeID}
+		if err := tx.FirstOrCreate(&p, models.EmployeeProfile{EmployeeID: employeeID}).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Model(&p).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+	}
+	return previous, nil
+}
+
+// encodeProfileValues stores values for a change request, secret ones sealed.
+func encodeProfileValues(values map[string]string) (models.JSONText, error) {
+	sealed := make(map[string]string, len(values))
+	for k, v := range values {
+		s, err := sealProfileValue(k, v)
+		if err != nil {
+			return "", err
+		}
+		sealed[k] = s
+	}
+	b, err := json.Marshal(sealed)
+	return models.JSONText(b), err
+}
+
+func decodeProfileValues(j models.JSONText) map[string]string {
+	values := map[string]string{}
+	_ = json.Unmarshal([]byte(j), &values)
+	for k, v := range values {
+		values[k] = openProfileValue(k, v)
+	}
+	return values
+}
+
+// maskProfileChange prepares a request for a response: secret values keep
+// only their last characters.
+func maskProfileChange(req *models.ProfileChangeRequest) {
+	for _, j := range []*models.JSONText{&req.Changes, &req.Previous} {
+		if *j == "" {
+			continue
+		}
+		values := decodeProfileValues(*j)
+		for k, v := range values {
+			values[k] = maskProfileValue(k, v)
+		}
+		b, _ := json.Marshal(values)
+		*j = models.JSONText(b)
+	}
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// GET /api/profile-fields
+func ListProfileFields(c *gin.Context) {
+	rows := make([]gin.H, len(profileFields))
+	for i, f := range profileFields {
+		rows[i] = gin.H{"key": f.Key, "label": f.Label, "max_length": f.MaxLen, "requires_approval": profileNeedsApproval(f)}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
+
+// GET /api/employees/:id/profile
+// Current personal details with the requests still waiting for approval.
+func GetEmployeeProfile(c *gin.Context) {
+	var emp models.Employee
+	if err := config.DB.First(&emp, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if !canViewProfile(c, emp.ID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to view this profile"})
+		return
+	}
+	values, err := profileValues(config.DB, emp)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch profile"})
+		return
+	}
+	var pending []models.ProfileChangeRequest
+	config.DB.Where("employee_id = ? AND status = ?", emp.ID, ProfileChangePending).Order("created_at").Find(&pending)
+	for i := range pending {
+		maskProfileChange(&pending[i])
+	}
+	c.JSON(http.StatusOK, gin.H{"data": values, "pending": pending})
+}
+
+// POST /api/employees/:id/profile-changes   body: {changes: {field: value}, reason}
+// Employees change their own profile: fields that need no approval are applied
+// at once, the rest become a pending request for HR. profile.approve holders
+// changing someone else's profile apply everything directly.
+func SubmitProfileChange(c *gin.Context) {
+	var in struct {
+		Changes map[string]string `json:"changes" binding:"required"`
+		Reason  string            `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "changes required"})
+		return
+	}
+	var emp models.Employee
+	if err := config.DB.First(&emp, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	me := authz.CallerEmployeeID(c)
+	self := me != 0 && me == emp.ID
+	if !self && !(authz.Can(c, authz.ProfileApprove) && authz.CanAccessEmployee(c, emp.ID, authz.Manage)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you can only change your own profile"})
+		return
+	}
+	reason := strings.TrimSpace(in.Reason)
+	if len(reason) > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "reason must be at most 500 characters"})
+		return
+	}
+
+	current, err := profileValues(config.DB, emp)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch profile"})
+		return
+	}
+	direct, review := map[string]string{}, map[string]string{}
+	for k, v := range in.Changes {
+		f, ok := findProfileField(k)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown profile field " + k})
+			return
+		}
+		v = strings.TrimSpace(v)
+		if len(v) > f.MaxLen {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s must be at most %d characters", k, f.MaxLen)})
+			return
+		}
+		if v != "" && f.Pattern != nil && !f.Pattern.MatchString(v) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + k})
+			return
+		}
+		if v == current[k] {
+			continue
+		}
+		if self && profileNeedsApproval(f) {
+			review[k] = v
+		} else {
+			direct[k] = v
+		}
+	}
+	if len(direct) == 0 && len(review) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "nothing to change"})
+		return
+	}
+	if len(review) > 0 {
+		var pending []models.ProfileChangeRequest
+		config.DB.Where("employee_id = ? AND status = ?", emp.ID, ProfileChangePending).Find(&pending)
+		for _, p := range pending {
+			for k := range decodeProfileValues(p.Changes) {
+				if _, ok := review[k]; ok {
+					c.JSON(http.StatusConflict, gin.H{"error": "a change to " + k + " is already waiting for approval", "request_id": p.ID})
+					return
+				}
+			}
+		}
+	}
+
+	userID := c.GetUint("userID")
+	var applied, pending *models.ProfileChangeRequest
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if len(direct) > 0 {
+			previous, err := applyProfileChanges(tx, emp.ID, direct)
+			if err != nil {
+				return err
+			}
+			changes, err := encodeProfileValues(direct)
+			if err != nil {
+				return err
+			}
+			prev, err := encodeProfileValues(previous)
+			if err != nil {
+				return err
+			}
+			applied = &models.ProfileChangeRequest{
+				EmployeeID: emp.ID, RequestedByID: userID, Reason: reason,
+				Changes: changes, Previous: prev, Status: ProfileChangeApplied,
+			}
+			if !self {
+				now := time.Now()
+				applied.Status, applied.ReviewedByID, applied.ReviewedAt = ProfileChangeApproved, &userID, &now
+			}
+			if err := tx.Create(applied).Error; err != nil {
+				return err
+			}
+		}
+		if len(review) > 0 {
+			changes, err := encodeProfileValues(review)
+			if err != nil {
+				return err
+			}
+			pending = &models.ProfileChangeRequest{
+				EmployeeID: emp.ID, RequestedByID: userID, Reason: reason,
+				Changes: changes, Status: ProfileChangePending,
+			}
+			return tx.Create(pending).Error
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save profile change"})
+		return
+	}
+	// Values stay out of the audit log; the requests themselves are the history
+	if applied != nil {
+		audit.Record(c, "employee.profile_update", "employee", emp.ID, nil, gin.H{"request_id": applied.ID, "fields": sortedKeys(direct)})
+	}
+	if pending != nil {
+		audit.Record(c, "employee.profile_request", "employee", emp.ID, nil, gin.H{"request_id": pending.ID, "fields": sortedKeys(review)})
+		maskProfileChange(pending)
+	}
+	if applied != nil {
+		maskProfileChange(applied)
+	}
+	c.JSON(http.StatusCreated, gin.H{"applied": applied, "pending": pending})
+}
+
+// GET /api/employees/:id/profile-changes?status=
+// The employee's requests, newest first; status=history lists only applied
+// and approved ones.
+func ListEmployeeProfileChanges(c *gin.Context) {
+	id := parseUint(c.Param("id"))
+	if !canViewProfile(c, id) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to view this profile"})
+		return
+	}
+	db := config.DB.Where("employee_id = ?", id)
+	switch status := c.Query("status"); status {
+	case "":
+	case "history":
+		db = db.Where("status IN ?", []string{ProfileChangeApplied, ProfileChangeApproved})
+	default:
+		db = db.Where("status = ?", status)
+	}
+	var rows []models.ProfileChangeRequest
+	if err := db.Order("created_at desc, id desc").Find(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch profile changes"})
+		return
+	}
+	for i := range rows {
+		maskProfileChange(&rows[i])
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
+
+// ProfileChangeRow is a request with the employee's name, for the review queue.
+type ProfileChangeRow struct {
+	models.ProfileChangeRequest
+	EmployeeName string `json:"employee_name"`
+}
+
+// GET /api/profile-changes?status=pending&page=&page_size=
+func ListProfileChangeRequests(c *gin.Context) {
+	page, size := pageParams(c)
+	status := c.DefaultQuery("status", ProfileChangePending)
+	db := config.DB.Table("profile_change_requests r").
+		Select("r.*, u.name AS employee_name").
+		Joins("JOIN employees e ON e.id = r.employee_id AND e.deleted_at IS NULL").
+		Joins("JOIN users u ON u.id = e.user_id")
+	if status != "all" {
+		db = db.Where("r.status = ?", status)
+	}
+	db = authz.ScopeEmployees(c, db, "e.id", authz.View).Session(&gorm.Session{})
+
+	var total int64
+	db.Count(&total)
+	var rows []ProfileChangeRow
+	if err := db.Order("r.created_at").Offset((page - 1) * size).Limit(size).Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch profile changes"})
+		return
+	}
+	for i := range rows {
+		maskProfileChange(&rows[i].ProfileChangeRequest)
+	}
+	c.JSON(http.StatusOK, gin.H{"page": page, "page_size": size, "total": total, "data": rows})
+}
+
+// loadPendingProfileChange loads :id for a reviewer, who must be able to
+// manage the employee (so nobody approves their own request).
+func loadPendingProfileChange(c *gin.Context) (*models.ProfileChangeRequest, bool) {
+	var req models.ProfileChangeRequest
+	if err := config.DB.First(&req, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "request not found"})
+		return nil, false
+	}
+	if !authz.CanAccessEmployee(c, req.EmployeeID, authz.Manage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to review this request"})
+		return nil, false
+	}
+	if req.Status != ProfileChangePending {
+		c.JSON(http.StatusConflict, gin.H{"error": "request is already " + req.Status})
+		return nil, false
+	}
+	return &req, true
+}
+
+// errNotPending means another reviewer decided the request first.
+var errNotPending = errors.New("request is no longer pending")
+
+// decideProfileChange moves a pending request to status, applying its values
+// when approved.
+func decideProfileChange(c *gin.Context, req *models.ProfileChangeRequest, status, note string) error {
+	reviewer, now := c.GetUint("userID"), time.Now()
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		updates := map[string]any{"status": status, "reviewed_by_id": reviewer, "reviewed_at": now, "review_note": note}
+		if status == ProfileChangeApproved {
+			previous, err := applyProfileChanges(tx, req.EmployeeID, decodeProfileValues(req.Changes))
+			if err != nil {
+				return err
+			}
+			if updates["previous"], err = encodeProfileValues(previous); err != nil {
+				return err
+			}
+		}
+		res := tx.Model(&models.ProfileChangeRequest{}).
+			Where("id = ? AND status = ?", req.ID, ProfileChangePending).Updates(updates)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return errNotPending
+		}
+		return tx.First(req, req.ID).Error
+	})
+}
+
+// notifyProfileDecision mails the employee about the outcome of their request.
+func notifyProfileDecision(req *models.ProfileChangeRequest) {
+	var user models.User
+	if err := config.DB.Joins("JOIN employees e ON e.user_id = users.id").
+		Where("e.id = ?", req.EmployeeID).First(&user).Error; err != nil {
+		return
+	}
+	fields := strings.Join(sortedKeys(decodeProfileValues(req.Changes)), ", ")
+	body := fmt.Sprintf("Hi %s,\n\nYour profile change request #%d (%s) was %s.", user.Name, req.ID, fields, req.Status)
+	if req.ReviewNote != "" {
+		body += "\n\nNote from the reviewer: " + req.ReviewNote
+	}
+	if err := utils.Mail.Send(user.Email, "Your profile change request was "+req.Status, body); err != nil {
+		log.Printf("profile change mail to %s failed: %v", user.Email, err)
+	}
+}
+
+// POST /api/profile-changes/:id/approve   body: {note}
+func ApproveProfileChange(c *gin.Context) {
+	req, ok := loadPendingProfileChange(c)
+	if !ok {
+		return
+	}
+	var in struct {
+		Note string `json:"note"`
+	}
+	_ = c.ShouldBindJSON(&in)
+	if err := decideProfileChange(c, req, ProfileChangeApproved, strings.TrimSpace(in.Note)); err != nil {
+		if errors.Is(err, errNotPending) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to approve request"})
+		return
+	}
+	audit.Record(c, "employee.profile_approve", "employee", req.EmployeeID, nil,
+		gin.H{"request_id": req.ID, "fields": sortedKeys(decodeProfileValues(req.Changes))})
+	notifyProfileDecision(req)
+	maskProfileChange(req)
+	c.JSON(http.StatusOK, gin.H{"data": req})
+}
+
+// POST /api/profile-changes/:id/reject   body: {note} (required)
+func RejectProfileChange(c *gin.Context) {
+	req, ok := loadPendingProfileChange(c)
+	if !ok {
+		return
+	}
+	var in struct {
+		Note string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil || strings.TrimSpace(in.Note) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "note required"})
+		return
+	}
+	if err := decideProfileChange(c, req, ProfileChangeRejected, strings.TrimSpace(in.Note)); err != nil {
+		if errors.Is(err, errNotPending) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reject request"})
+		return
+	}
+	audit.Record(c, "employee.profile_reject", "employee", req.EmployeeID, nil,
+		gin.H{"request_id": req.ID, "fields": sortedKeys(decodeProfileValues(req.Changes))})
+	notifyProfileDecision(req)
+	maskProfileChange(req)
+	c.JSON(http.StatusOK, gin.H{"data": req})
+}
+
+// DELETE /api/profile-changes/:id
+// Withdraws a pending request; only whoever submitted it can.
+func CancelProfileChange(c *gin.Context) {
+	var req models.ProfileChangeRequest
+	if err := config.DB.First(&req, c.Param("id")).Error; err != nil || req.RequestedByID != c.GetUint("userID") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "request not found"})
+		return
+	}
+	res := config.DB.Model(&req).Where("status = ?", ProfileChangePending).Update("status", ProfileChangeCancelled)
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel request"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "request is already " + req.Status})
+		return
+	}
+	audit.Record(c, "employee.profile_cancel", "employee", req.EmployeeID, nil, gin.H{"request_id": req.ID})
+	c.JSON(http.StatusOK, gin.H{"message": "cancelled"})
+}
@@ -0,0 +1,341 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+
+	"peoplesoft/audit"
+	"peoplesoft/authz"
+	"peoplesoft/config"
+	"peoplesoft/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GET /api/rbac/permissions
+func ListPermissions(c *gin.Context) {
+	var perms []models.Permission
+	if err := config.DB.Order("key asc").Find(&perms).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "fetch failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": perms})
+}
+
+// GET /api/rbac/roles
+func ListRoles(c *gin.Context) {
+	var roles []models.Role
+	if err := config.DB.Preload("Permissions").Order("name asc").Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "fetch failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": roles})
+}
+
+type roleInput struct {
+	Name        string   `json:"name"`
+	Description *string  `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+// loadPermissions resolves permission keys, reporting the first unknown key.
+func loadPermissions(keys []string) ([]models.Permission, string) {
+	var perms []models.Permission
+	if len(keys) == 0 {
+		return perms, ""
+	}
+	config.DB.Where("key IN ?", keys).Find(&perms)
+	found := map[string]bool{}
+	for _, p := range perms {
+		found[p.Key] = true
+	}
+	for _, k := range keys {
+		if !found[k] {
+			return nil, k
+		}
+	}
+	return perms, ""
+}
+
+// POST /api/rbac/roles
+func CreateRole(c *gin.Context) {
+	var in roleInput
+	if err := c.ShouldBindJSON(&in); err != nil || strings.TrimSpace(in.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name required"})
+		return
+	}
+	perms, unknown := loadPermissions(in.Permissions)
+	if unknown != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown permission: " + unknown})
+		return
+	}
+
+	role := models.Role{Name: strings.ToLower(strings.TrimSpace(in.Name)), Permissions: perms}
+	if in.Description != nil {
+		role.Description = *in.Description
+	}
+	if err := config.DB.Create(&role).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role already exists or db error"})
+		return
+	}
+	audit.Record(c, "role.create", "role", role.ID, nil, role)
+	c.JSON(http.StatusCreated, gin.H{"data": role})
+}
+
+// PUT /api/rbac/roles/:id
+// Updates the description and, when "permissions" is present, replaces the permission set.
+func UpdateRole(c *gin.Context) {
+	var in roleInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+
+	var role models.Role
+	if err := config.DB.Preload("Permissions").First(&role, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "role not found"})
+		return
+	}
+	before := role
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if in.Description != nil {
+			if err := tx.Model(&role).Update("description", *in.Description).Error; err != nil {
+				return err
+			}
+		}
+		if in.Permissions != nil {
+			perms, unknown := loadPermissions(in.Permissions)
+			if unknown != "" {
+				return &badRequest{"unknown permission: " + unknown}
+			}
+			// Never let HR lock itself out of role management
+			if role.Name == "hr" && !containsString(in.Permissions, authz.RBACManage) {
+				return &badRequest{"the hr role must keep " + authz.RBACManage}
+			}
+			if err := tx.Model(&role).Association("Permissions").Replace(perms); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if br, ok := err.(*badRequest); ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+
+	role = models.Role{}
+	config.DB.Preload("Permissions").First(&role, before.ID)
+	audit.Record(c, "role.update", "role", role.ID, before, role)
+	c.JSON(http.StatusOK, gin.H{"data": role})
+}
+
+// DELETE /api/rbac/roles/:id
+func DeleteRole(c *gin.Context) {
+	var role models.Role
+	if err := config.DB.First(&role, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "role not found"})
+		return
+	}
+	if role.IsSystem {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "system roles cannot be deleted"})
+		return
+	}
+	var primaryUsers int64
+	config.DB.Model(&models.User{}).Where("role = ?", role.Name).Count(&primaryUsers)
+	if primaryUsers > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "role is the primary role of existing users"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.UserRole{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		return
+	}
+	audit.Record(c, "role.delete", "role", role.ID, role, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// GET /api/users/:id/roles
+func GetUserRoles(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	roles, _ := authz.UserRoles(user.ID)
+	perms, _ := authz.UserPermissions(user.ID)
+	keys := make([]string, 0, len(perms))
+	for k := range perms {
+		keys = append(keys, k)
+	}
+	c.JSON(http.StatusOK, gin.H{"primary_role": user.Role, "roles": roles, "permissions": keys})
+}
+
+// PUT /api/users/:id/roles
+// Replaces the additional roles of a user. The primary role is changed via /users/:id/role.
+func SetUserRoles(c *gin.Context) {
+	var in struct {
+		Roles []string `json:"roles"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "roles required"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	var roles []models.Role
+	if len(in.Roles) > 0 {
+		config.DB.Where("name IN ?", in.Roles).Find(&roles)
+		if len(roles) != len(uniqueStrings(in.Roles)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown role in list"})
+			return
+		}
+	}
+
+	previous, _ := authz.UserRoles(user.ID)
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.UserRole{}).Error; err != nil {
+			return err
+		}
+		for _, r := range roles {
+			if r.Name == user.Role {
+				continue
+			}
+			if err := tx.Create(&models.UserRole{UserID: user.ID, RoleID: r.ID}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+
+	names, _ := authz.UserRoles(user.ID)
+	audit.Record(c, "user.roles_set", "user", user.ID, gin.H{"roles": previous}, gin.H{"roles": names})
+	c.JSON(http.StatusOK, gin.H{"message": "roles updated", "roles": names})
+}
+
+// GET /api/users/:id/scopes
+// Lists the departments a user can access in addition to their reporting line.
+func GetUserScopes(c *gin.Context) {
+	var scopes []models.AccessScope
+	if err := config.DB.Where("user_id = ?", c.Param("id")).Order("department_id").Find(&scopes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "fetch failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": scopes})
+}
+
+// PUT /api/users/:id/scopes
+// Replaces the department scopes of a user, e.g. an HR partner covering two departments.
+func SetUserScopes(c *gin.Context) {
+	var in struct {
+		DepartmentIDs []uint `json:"department_ids"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "department_ids required"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if len(in.DepartmentIDs) > 0 {
+		var n int64
+		config.DB.Model(&models.Department{}).Where("id IN ?", in.DepartmentIDs).Count(&n)
+		if int(n) != len(uniqueUints(in.DepartmentIDs)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown department in list"})
+			return
+		}
+	}
+
+	var previous []uint
+	config.DB.Model(&models.AccessScope{}).Where("user_id = ?", user.ID).Order("department_id").Pluck("department_id", &previous)
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.AccessScope{}).Error; err != nil {
+			return err
+		}
+		for _, d := range uniqueUints(in.DepartmentIDs) {
+			if err := tx.Create(&models.AccessScope{UserID: user.ID, DepartmentID: d}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	audit.Record(c, "user.scopes_set", "user", user.ID,
+		gin.H{"department_ids": previous}, gin.H{"department_ids": uniqueUints(in.DepartmentIDs)})
+	c.JSON(http.StatusOK, gin.H{"message": "scopes updated", "department_ids": uniqueUints(in.DepartmentIDs)})
+}
+
+// roleExists reports whether a role with this name is defined.
+func roleExists(name string) bool {
+	var n int64
+	config.DB.Model(&models.Role{}).Where("name = ?", name).Count(&n)
+	return n > 0
+}
+
+type badRequest struct{ msg string }
+
+func (e *badRequest) Error() string { return e.msg }
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func uniqueStrings(list []string) []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, v := range list {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func uniqueUints(list []uint) []uint {
+	seen := map[uint]bool{}
+	var out []uint
+	for _, v := range list {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
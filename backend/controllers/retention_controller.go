@@ -0,0 +1,322 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"peoplesoft/audit"
+	"peoplesoft/config"
+	"peoplesoft/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// userRecords are the soft-deletable tables that belong to a user, with the
+// column holding the user id. They are deleted, restored and purged together
+// with the account.
+var userRecords = []struct {
+	model  interface{}
+	column string
+}{
+	{&models.Employee{}, "user_id"},
+	{&models.Leave{}, "user_id"},
+	{&models.Goal{}, "user_id"},
+	{&models.SelfAssessment{}, "user_id"},
+	{&models.ManagerReview{}, "employee_id"},
+	{&models.Performance{}, "user_id"},
+}
+
+// DeletedUserRow is a soft-deleted account in the restore listing.
+type DeletedUserRow struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Role      string    `json:"role"`
+	DeletedAt time.Time `json:"deleted_at"`
+}
+
+// DeletedEmployeeRow is a soft-deleted employee record in the restore listing.
+type DeletedEmployeeRow struct {
+	ID           uint      `json:"id"`
+	UserID       uint      `json:"user_id"`
+	Name         string    `json:"name"`
+	Email        string    `json:"email"`
+	Designation  string    `json:"designation"`
+	DepartmentID uint      `json:"department_id"`
+	UserDeleted  bool      `json:"user_deleted"` // deleted with the account; restore the user instead
+	DeletedAt    time.Time `json:"deleted_at"`
+}
+
+// GET /api/users/deleted?q=&page=&page_size=
+func ListDeletedUsers(c *gin.Context) {
+	page, size := pageParams(c)
+	db := config.DB.Unscoped().Model(&models.User{}).Where("deleted_at IS NOT NULL")
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		like := "%" + q + "%"
+		db = db.Where("name ILIKE ? OR email ILIKE ?", like, like)
+	}
+
+	var total int64
+	db.Count(&total)
+	var rows []DeletedUserRow
+	if err := db.Select("id, name, email, role, deleted_at").
+		Order("deleted_at desc").Offset((page - 1) * size).Limit(size).
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch deleted users"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"page": page, "page_size": size, "total": total, "data": rows})
+}
+
+// POST /api/users/:id/restore
+// Brings back the account and every record deleted with it. Reporting lines
+// cleared by the deletion are not restored.
+func RestoreUser(c *gin.Context) {
+	var user models.User
+	if err := config.DB.Unscoped().Where("deleted_at IS NOT NULL").First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "deleted user not found"})
+		return
+	}
+	var taken int64
+	config.DB.Model(&models.User{}).Where("LOWER(email) = ?", normalizeEmail(user.Email)).Count(&taken)
+	if taken > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "another account now uses " + user.Email})
+		return
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		for _, r := range userRecords {
+			if err := tx.Unscoped().Model(r.model).
+				Where(r.column+" = ? AND deleted_at = ?", user.ID, user.DeletedAt.Time).
+				Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Unscoped().Model(&user).Update("deleted_at", nil).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "restore failed"})
+		return
+	}
+	audit.Record(c, "user.restore", "user", user.ID, nil, user)
+	c.JSON(http.StatusOK, gin.H{"message": "restored", "id": user.ID})
+}
+
+// DELETE /api/users/:id/purge   body: {confirm_email}
+// Permanently erases a deleted account with all its records. Only users that
+// were deleted first can be purged, and the email must be repeated.
+func PurgeUser(c *gin.Context) {
+	var in struct {
+		ConfirmEmail string `json:"confirm_email" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "confirm_email required"})
+		return
+	}
+	var user models.User
+	if err := config.DB.Unscoped().First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if !user.DeletedAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "only deleted users can be purged; delete the user first"})
+		return
+	}
+	if normalizeEmail(in.ConfirmEmail) != normalizeEmail(user.Email) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "confirm_email does not match the user"})
+		return
+	}
+
+	var blobs []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		tx = tx.Unscoped()
+		var empIDs []uint
+		if err := tx.Model(&models.Employee{}).Where("user_id = ?", user.ID).Pluck("id", &empIDs).Error; err != nil {
+			return err
+		}
+		for _, id := range empIDs {
+			keys, err := purgeEmployee(tx, id)
+			if err != nil {
+				return err
+			}
+			blobs = append(blobs, keys...)
+		}
+		for _, r := range userRecords {
+			if err := tx.Where(r.column+" = ?", user.ID).Delete(r.model).Error; err != nil {
+				return err
+			}
+		}
+		for _, m := range []interface{}{
+			&models.LeaveAllocation{}, &models.RefreshToken{}, &models.PasswordResetToken{},
+			&models.MFARecoveryCode{}, &models.UserIdentity{}, &models.OIDCLogin{},
+			&models.UserRole{}, &models.AccessScope{},
+		} {
+			if err := tx.Where("user_id = ?", user.ID).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("link_user_id = ?", user.ID).Delete(&models.OIDCLogin{}).Error; err != nil {
+			return err
+		}
+		// Impersonation of the user showed their data; those sessions go too
+		for _, m := range []interface{}{&models.ImpersonationRequest{}, &models.ImpersonationSession{}} {
+			if err := tx.Where("subject_id = ?", user.ID).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		// References from other people's records lose the name but survive.
+		// Non-null columns fall back to 0 (no user). Audit events keep their
+		// actor id: the log is append-only and hash-chained.
+		for _, ref := range []struct {
+			model  interface{}
+			column string
+			clear  interface{}
+		}{
+			{&models.Leave{}, "approved_by", nil},
+			{&models.Goal{}, "assigned_by_id", nil},
+			{&models.LifecycleTask{}, "assignee_user_id", nil},
+			{&models.LifecycleTask{}, "completed_by_id", nil},
+			{&models.ChecklistTemplateTask{}, "assignee_user_id", nil},
+			{&models.LoginAttempt{}, "user_id", nil},
+			{&models.Offboarding{}, "created_by_id", nil},
+			{&models.EmploymentChange{}, "created_by_id", nil},
+			{&models.Document{}, "uploaded_by_id", nil},
+			{&models.EmployeeSkill{}, "endorsed_by_id", nil},
+			{&models.ProfileChangeRequest{}, "reviewed_by_id", nil},
+			{&models.ProfileChangeRequest{}, "requested_by_id", 0},
+			{&models.ManagerReview{}, "reviewer_id", 0},
+			{&models.Performance{}, "reviewer_id", 0},
+			{&models.ImpersonationSession{}, "actor_id", 0},
+			{&models.ImpersonationRequest{}, "actor_id", 0},
+			{&models.SCIMToken{}, "created_by_id", 0},
+			{&models.ServiceAccount{}, "created_by_id", 0},
+			{&models.APIKey{}, "created_by_id", 0},
+		} {
+			if err := tx.Model(ref.model).Where(ref.column+" = ?", user.ID).Update(ref.column, ref.clear).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&user).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "purge failed"})
+		return
+	}
+	removeDocumentBlobs(blobs)
+	// The audit trail keeps that a purge happened, not what was purged
+	audit.Record(c, "user.purge", "user", user.ID, nil, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "purged"})
+}
+
+// GET /api/employees/deleted?page=&page_size=
+func ListDeletedEmployees(c *gin.Context) {
+	page, size := pageParams(c)
+	db := config.DB.Table("employees e").
+		Joins("JOIN users u ON u.id = e.user_id").
+		Where("e.deleted_at IS NOT NULL")
+
+	var total int64
+	db.Count(&total)
+	var rows []DeletedEmployeeRow
+	if err := db.Select(`e.id, e.user_id, u.name, u.email, e.designation, e.department_id,
+			u.deleted_at IS NOT NULL AS user_deleted, e.deleted_at`).
+		Order("e.deleted_at desc").Offset((page - 1) * size).Limit(size).
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch deleted employees"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"page": page, "page_size": size, "total": total, "data": rows})
+}
+
+// POST /api/employees/:id/restore
+func RestoreEmployee(c *gin.Context) {
+	var emp models.Employee
+	if err := config.DB.Unscoped().Where("deleted_at IS NOT NULL").First(&emp, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "deleted employee not found"})
+		return
+	}
+	var user models.User
+	if err := config.DB.First(&user, emp.UserID).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "the user account is deleted; restore the user instead"})
+		return
+	}
+	var live int64
+	config.DB.Model(&models.Employee{}).Where("user_id = ?", emp.UserID).Count(&live)
+	if live > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "user already has an employee record"})
+		return
+	}
+	if err := config.DB.Unscoped().Model(&emp).Update("deleted_at", nil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "restore failed"})
+		return
+	}
+	audit.Record(c, "employee.restore", "employee", emp.ID, nil, emp)
+	c.JSON(http.StatusOK, gin.H{"message": "restored", "id": emp.ID})
+}
+
+// DELETE /api/employees/:id/purge
+// Permanently erases a deleted employee record with its employment history,
+// lifecycle tasks and documents. The user account and its leave and PMS records stay.
+func PurgeEmployee(c *gin.Context) {
+	var emp models.Employee
+	if err := config.DB.Unscoped().First(&emp, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	if !emp.DeletedAt.Valid {
+		c.JSON(http.StatusConflict, gin.H{"error": "only deleted employees can be purged; delete the employee first"})
+		return
+	}
+	var blobs []string
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		blobs, err = purgeEmployee(tx.Unscoped(), emp.ID)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "purge failed"})
+		return
+	}
+	removeDocumentBlobs(blobs)
+	audit.Record(c, "employee.purge", "employee", emp.ID, nil, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "purged"})
+}
+
+// purgeEmployee hard-deletes an employee row with its history, checklist,
+// custom fields, documents, skills and personal details, clearing references
+// to it from other employees and departments. It returns the storage keys of
+// the documents, to be removed once the transaction has committed.
+func purgeEmployee(tx *gorm.DB, id uint) ([]string, error) {
+	var blobs []string
+	if err := tx.Model(&models.Document{}).Where("employee_id = ?", id).Pluck("storage_key", &blobs).Error; err != nil {
+		return nil, err
+	}
+	for _, m := range []interface{}{
+		&models.EmploymentChange{}, &models.LifecycleTask{}, &models.Offboarding{}, &models.EmployeeFieldValue{},
+		&models.Document{}, &models.EmployeeSkill{}, &models.EmployeeProfile{}, &models.ProfileChangeRequest{},
+		&models.OptionalHolidayChoice{},
+	} {
+		if err := tx.Where("employee_id = ?", id).Delete(m).Error; err != nil {
+			return nil, err
+		}
+	}
+	for _, ref := range []struct {
+		model  interface{}
+		column string
+	}{
+		{&models.Employee{}, "manager_id"},
+		{&models.Department{}, "head_employee_id"},
+		{&models.Offboarding{}, "successor_id"},
+	} {
+		if err := tx.Model(ref.model).Where(ref.column+" = ?", id).Update(ref.column, nil).Error; err != nil {
+			return nil, err
+		}
+	}
+	res := tx.Delete(&models.Employee{}, id)
+	if res.Error == nil && res.RowsAffected == 0 {
+		return nil, errors.New("employee not found")
+	}
+	return blobs, res.Error
+}
@@ -0,0 +1,480 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"peoplesoft/oidc"
+	"peoplesoft/scim"
+	"peoplesoft/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SCIM Groups are backed by roles. Members are the users holding the role as
+// an additional role (user_roles); a user's primary role is managed by HR or
+// the SSO mapping and is never changed through SCIM. Every group can be read,
+// but only the roles listed in SCIM_GROUP_ROLES can gain or lose members, be
+// renamed or be deleted, so an IdP token cannot hand out HR access.
+
+// scimManagedRole reports whether SCIM_GROUP_ROLES (comma separated role
+// names) lists the role.
+func scimManagedRole(name string) bool {
+	for _, r := range strings.Split(os.Getenv("SCIM_GROUP_ROLES"), ",") {
+		if strings.EqualFold(strings.TrimSpace(r), name) && name != "" {
+			return true
+		}
+	}
+	return false
+}
+
+var errSCIMUnmanagedGroup = &scimError{http.StatusForbidden, "", "group is not managed through SCIM (SCIM_GROUP_ROLES)"}
+
+type scimGroupInput struct {
+	DisplayName string `json:"displayName"`
+	Members     []struct {
+		Value string `json:"value"`
+	} `json:"members"`
+}
+
+var scimGroupAttrs = map[string]scim.Attr{
+	"id":            {Column: "r.id", Type: scim.Int},
+	"displayname":   {Column: "r.name"},
+	"members":       {Build: scimMemberFilter},
+	"members.value": {Build: scimMemberFilter},
+}
+
+func scimMemberFilter(op string, value interface{}) (string, []interface{}, error) {
+	if op != "eq" {
+		return "", nil, fmt.Errorf("members only supports eq")
+	}
+	return "r.id IN (SELECT role_id FROM user_roles WHERE user_id = ?)", []interface{}{fmt.Sprint(value)}, nil
+}
+
+// GET /scim/v2/Groups
+func SCIMListGroups(c *gin.Context) {
+	q := config.DB.Table("roles r")
+	if f := c.Query("filter"); f != "" {
+		parsed, err := scim.ParseFilter(f)
+		if err != nil {
+			scim.Error(c, http.StatusBadRequest, "invalidFilter", err.Error())
+			return
+		}
+		where, args, err := scim.ToSQL(parsed, scimGroupAttrs)
+		if err != nil {
+			scim.Error(c, http.StatusBadRequest, "invalidFilter", err.Error())
+			return
+		}
+		q = q.Where(where, args...)
+	}
+	q = q.Session(&gorm.Session{})
+
+	var total int64
+	if err := q.Count(&total).Error; err != nil {
+		scim.Error(c, http.StatusInternalServerError, "", "query failed")
+		return
+	}
+	start, count := scim.Page(c)
+	var roles []models.Role
+	if count > 0 {
+		q.Select("r.*").Order("r.id").Offset(start - 1).Limit(count).Find(&roles)
+	}
+
+	// Okta and Entra ID ask for excludedAttributes=members on large lists
+	withMembers := !strings.Contains(strings.ToLower(c.Query("excludedAttributes")), "members")
+	resources := make([]interface{}, 0, len(roles))
+	for _, r := range roles {
+		res, err := renderSCIMGroup(r, withMembers)
+		if err != nil {
+			scim.Error(c, http.StatusInternalServerError, "", "query failed")
+			return
+		}
+		resources = append(resources, res)
+	}
+	scim.List(c, total, start, resources)
+}
+
+// GET /scim/v2/Groups/:id
+func SCIMGetGroup(c *gin.Context) {
+	var role models.Role
+	if err := config.DB.First(&role, c.Param("id")).Error; err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	res, err := renderSCIMGroup(role, true)
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	scim.JSON(c, http.StatusOK, res)
+}
+
+// POST /scim/v2/Groups
+// Creates a role without permissions; HR grants them through /api/rbac/roles.
+func SCIMCreateGroup(c *gin.Context) {
+	var in scimGroupInput
+	if err := json.NewDecoder(c.Request.Body).Decode(&in); err != nil {
+		scim.Error(c, http.StatusBadRequest, "invalidSyntax", "invalid JSON body")
+		return
+	}
+	name := strings.ToLower(strings.TrimSpace(in.DisplayName))
+	if name == "" {
+		scim.Error(c, http.StatusBadRequest, "invalidValue", "displayName required")
+		return
+	}
+
+	role := models.Role{Name: name, Description: "Provisioned via SCIM"}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if roleExists(name) {
+			return &scimError{http.StatusConflict, "uniqueness", "group already exists"}
+		}
+		if err := tx.Create(&role).Error; err != nil {
+			return err
+		}
+		return setSCIMMembers(tx, role, in)
+	})
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	res, err := renderSCIMGroup(role, true)
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	c.Header("Location", scimLocation(c, "Groups", role.ID))
+	scim.JSON(c, http.StatusCreated, res)
+}
+
+// PUT /scim/v2/Groups/:id
+func SCIMReplaceGroup(c *gin.Context) {
+	var in scimGroupInput
+	if err := json.NewDecoder(c.Request.Body).Decode(&in); err != nil {
+		scim.Error(c, http.StatusBadRequest, "invalidSyntax", "invalid JSON body")
+		return
+	}
+	updateSCIMGroup(c, in)
+}
+
+// PATCH /scim/v2/Groups/:id
+func SCIMPatchGroup(c *gin.Context) {
+	var req scim.PatchRequest
+	if err := json.NewDecoder(c.Request.Body).Decode(&req); err != nil {
+		scim.Error(c, http.StatusBadRequest, "invalidSyntax", "invalid JSON body")
+		return
+	}
+	var role models.Role
+	if err := config.DB.First(&role, c.Param("id")).Error; err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	current, err := renderSCIMGroup(role, true)
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	doc, err := toDocument(current)
+	if err != nil {
+		scim.Error(c, http.StatusInternalServerError, "", "internal error")
+		return
+	}
+	if err := scim.ApplyPatch(doc, req.Operations); err != nil {
+		scim.Error(c, http.StatusBadRequest, "invalidPath", err.Error())
+		return
+	}
+
+	var in scimGroupInput
+	raw, _ := json.Marshal(doc)
+	if err := json.Unmarshal(raw, &in); err != nil {
+		scim.Error(c, http.StatusBadRequest, "invalidValue", "patched resource is invalid")
+		return
+	}
+	updateSCIMGroup(c, in)
+}
+
+// DELETE /scim/v2/Groups/:id
+func SCIMDeleteGroup(c *gin.Context) {
+	var role models.Role
+	if err := config.DB.First(&role, c.Param("id")).Error; err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	if role.IsSystem {
+		scim.Error(c, http.StatusBadRequest, "mutability", "system roles cannot be deleted")
+		return
+	}
+	if !scimManagedRole(role.Name) {
+		respondSCIMError(c, errSCIMUnmanagedGroup)
+		return
+	}
+	var primaryUsers int64
+	config.DB.Model(&models.User{}).Where("role = ?", role.Name).Count(&primaryUsers)
+	if primaryUsers > 0 {
+		scim.Error(c, http.StatusConflict, "", "role is the primary role of existing users")
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("role_id = ?", role.ID).Delete(&models.UserRole{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(&role).Error
+	})
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func updateSCIMGroup(c *gin.Context, in scimGroupInput) {
+	var role models.Role
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&role, c.Param("id")).Error; err != nil {
+			return err
+		}
+		name := strings.ToLower(strings.TrimSpace(in.DisplayName))
+		if name != "" && name != role.Name {
+			// users.role stores role names, so only unused custom roles can be renamed
+			var primaryUsers int64
+			tx.Model(&models.User{}).Where("role = ?", role.Name).Count(&primaryUsers)
+			if role.IsSystem || primaryUsers > 0 {
+				return &scimError{http.StatusBadRequest, "mutability", "this group cannot be renamed"}
+			}
+			if !scimManagedRole(role.Name) || !scimManagedRole(name) {
+				return errSCIMUnmanagedGroup
+			}
+			if roleExists(name) {
+				return &scimError{http.StatusConflict, "uniqueness", "group already exists"}
+			}
+			if err := tx.Model(&role).Update("name", name).Error; err != nil {
+				return err
+			}
+		}
+		return setSCIMMembers(tx, role, in)
+	})
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	res, err := renderSCIMGroup(role, true)
+	if err != nil {
+		respondSCIMError(c, err)
+		return
+	}
+	scim.JSON(c, http.StatusOK, res)
+}
+
+// setSCIMMembers makes the role's additional-role holders exactly the members
+// listed. Only roles in SCIM_GROUP_ROLES may change.
+func setSCIMMembers(tx *gorm.DB, role models.Role, in scimGroupInput) error {
+	want := map[uint]bool{}
+	for _, m := range in.Members {
+		id, err := strconv.ParseUint(m.Value, 10, 64)
+		if err != nil {
+			return &scimError{http.StatusBadRequest, "invalidValue", "member value must be a user id"}
+		}
+		want[uint(id)] = true
+	}
+
+	var users []models.User
+	if len(want) > 0 {
+		ids := make([]uint, 0, len(want))
+		for id := range want {
+			ids = append(ids, id)
+		}
+		tx.Where("id IN ?", ids).Find(&users)
+		if len(users) != len(ids) {
+			return &scimError{http.StatusBadRequest, "invalidValue", "unknown member"}
+		}
+	}
+
+	if !scimManagedRole(role.Name) {
+		var current []uint
+		if err := tx.Model(&models.UserRole{}).Where("role_id = ?", role.ID).Pluck("user_id", &current).Error; err != nil {
+			return err
+		}
+		unchanged := len(current) == len(want)
+		for _, id := range current {
+			unchanged = unchanged && want[id]
+		}
+		if !unchanged {
+			return errSCIMUnmanagedGroup
+		}
+		return nil
+	}
+
+	if err := tx.Where("role_id = ?", role.ID).Delete(&models.UserRole{}).Error; err != nil {
+		return err
+	}
+	for _, u := range users {
+		if u.Role == role.Name {
+			continue // already the primary role
+		}
+		if err := tx.Create(&models.UserRole{UserID: u.ID, RoleID: role.ID}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renderSCIMGroup(role models.Role, withMembers bool) (gin.H, error) {
+	res := gin.H{
+		"schemas":     []string{scim.GroupSchema},
+		"id":          fmt.Sprint(role.ID),
+		"displayName": role.Name,
+		"meta": gin.H{
+			"resourceType": "Group",
+			"created":      role.CreatedAt,
+			"lastModified": role.CreatedAt,
+			"location":     "/scim/v2/Groups/" + fmt.Sprint(role.ID),
+		},
+	}
+	if !withMembers {
+		return res, nil
+	}
+
+	var members []struct {
+		ID   uint
+		Name string
+	}
+	err := config.DB.Table("user_roles ur").
+		Select("u.id, u.name").
+		Joins("JOIN users u ON u.id = ur.user_id").
+		Where("ur.role_id = ?", role.ID).
+		Order("u.id").
+		Scan(&members).Error
+	list := make([]gin.H, 0, len(members))
+	for _, m := range members {
+		list = append(list, gin.H{
+			"value":   fmt.Sprint(m.ID),
+			"display": m.Name,
+			"$ref":    "/scim/v2/Users/" + fmt.Sprint(m.ID),
+		})
+	}
+	res["members"] = list
+	return res, err
+}
+
+// ----------------------------
+// DISCOVERY
+// ----------------------------
+
+// GET /scim/v2/ServiceProviderConfig
+func SCIMServiceProviderConfig(c *gin.Context) {
+	scim.JSON(c, http.StatusOK, gin.H{
+		"schemas":        []string{"urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"},
+		"patch":          gin.H{"supported": true},
+		"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         gin.H{"supported": true, "maxResults": scim.MaxPageSize},
+		"changePassword": gin.H{"supported": false},
+		"sort":           gin.H{"supported": false},
+		"etag":           gin.H{"supported": false},
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "Token issued by HR via /api/scim/tokens",
+			"primary":     true,
+		}},
+	})
+}
+
+// GET /scim/v2/ResourceTypes
+func SCIMResourceTypes(c *gin.Context) {
+	types := []interface{}{
+		gin.H{
+			"schemas":  []string{"urn:ietf:params:scim:schemas:core:2.0:ResourceType"},
+			"id":       "User",
+			"name":     "User",
+			"endpoint": "/Users",
+			"schema":   scim.UserSchema,
+			"schemaExtensions": []gin.H{{
+				"schema":   scim.EnterpriseUserSchema,
+				"required": false,
+			}},
+		},
+		gin.H{
+			"schemas":  []string{"urn:ietf:params:scim:schemas:core:2.0:ResourceType"},
+			"id":       "Group",
+			"name":     "Group",
+			"endpoint": "/Groups",
+			"schema":   scim.GroupSchema,
+		},
+	}
+	scim.List(c, int64(len(types)), 1, types)
+}
+
+// ----------------------------
+// TOKENS (HR, under /api)
+// ----------------------------
+
+// GET /api/scim/tokens
+func ListSCIMTokens(c *gin.Context) {
+	var tokens []models.SCIMToken
+	if err := config.DB.Order("created_at desc").Find(&tokens).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "fetch failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": tokens})
+}
+
+// POST /api/scim/tokens
+// Returns the raw token once; only its hash is stored.
+func CreateSCIMToken(c *gin.Context) {
+	var in struct {
+		Name          string `json:"name" binding:"required"`
+		Provider      string `json:"provider"`
+		ExpiresInDays int    `json:"expires_in_days"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name required"})
+		return
+	}
+	if _, ok := oidc.Get(in.Provider); in.Provider != "" && !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown identity provider"})
+		return
+	}
+
+	raw, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create token"})
+		return
+	}
+	token := models.SCIMToken{Name: in.Name, Provider: in.Provider, TokenHash: hash, CreatedByID: c.GetUint("userID")}
+	if in.ExpiresInDays > 0 {
+		exp := time.Now().AddDate(0, 0, in.ExpiresInDays)
+		token.ExpiresAt = &exp
+	}
+	if err := config.DB.Create(&token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create token"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": token, "token": raw})
+}
+
+// DELETE /api/scim/tokens/:id
+func RevokeSCIMToken(c *gin.Context) {
+	res := config.DB.Model(&models.SCIMToken{}).
+		Where("id = ? AND revoked_at IS NULL", c.Param("id")).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "revoke failed"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "token not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "token revoked"})
+}
@@ -0,0 +1,135 @@
+package controllers
+
+import (
+	"net/http"
+
+	"peoplesoft/authz"
+	"peoplesoft/config"
+	"peoplesoft/employment"
+	"peoplesoft/search"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// facetLimit caps the values returned per facet.
+const facetLimit = 25
+
+// FacetCount is one value of a facet with the number of matching employees.
+type FacetCount struct {
+	Value string `json:"value"`
+	Label string `json:"label,omitempty"`
+	Count int64  `json:"count"`
+}
+
+// EmployeeSuggestion is a typeahead entry.
+type EmployeeSuggestion struct {
+	ID             uint   `json:"id"`
+	Name           string `json:"name"`
+	Email          string `json:"email"`
+	Designation    string `json:"designation"`
+	DepartmentName string `json:"department_name"`
+}
+
+// searchCustomFields reports whether the caller's searches may match custom
+// field values: only holders of custom_field.manage (who see every field) with
+// access to every employee.
+func searchCustomFields(c *gin.Context) bool {
+	return authz.Can(c, authz.CustomFieldManage) && authz.Can(c, authz.ScopeAll)
+}
+
+// GET /api/search/employees?q=&department_id=&location=&role=&designation=&status=&page=&page_size=&cf.<key>=
+// Ranked directory search with department, location and role counts for the
+// whole result set. Without q results are sorted by name.
+func SearchEmployees(c *gin.Context) {
+	page, size := pageParams(c)
+	db, br := filterCustomFields(c, filterEmployees(c, scopedDirectory(c, nil)))
+	if br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
+	db = db.Session(&gorm.Session{}) // count, facets and page query must not share state
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "search failed"})
+		return
+	}
+
+	list := db.Order("u.name")
+	if q := search.Parse(c.Query("q")); !q.Empty() {
+		list = search.OrderByRank(db, q, searchCustomFields(c))
+	}
+	var rows []EmployeeRow
+	if err := list.Offset((page - 1) * size).Limit(size).Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "search failed"})
+		return
+	}
+	if err := attachCustomFields(c, rows); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch custom fields"})
+		return
+	}
+
+	facets, err := employeeFacets(db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "search failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"page": page, "page_size": size, "total": total, "data": rows, "facets": facets,
+	})
+}
+
+// employeeFacets counts the employees of db per department, location and role,
+// most common first.
+func employeeFacets(db *gorm.DB) (map[string][]FacetCount, error) {
+	facets := map[string][]FacetCount{}
+	var departments, locations, roles []FacetCount
+	if err := db.Select("CAST(e.department_id AS text) AS value, COALESCE(fd.name, '') AS label, COUNT(*) AS count").
+		Joins("LEFT JOIN departments fd ON fd.id = e.department_id").
+		Group("e.department_id, fd.name").Order("COUNT(*) DESC, fd.name").Limit(facetLimit).
+		Scan(&departments).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Select("e.location AS value, COUNT(*) AS count").Where("e.location <> ''").
+		Group("e.location").Order("COUNT(*) DESC, e.location").Limit(facetLimit).
+		Scan(&locations).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Select("u.role AS value, COUNT(*) AS count").
+		Group("u.role").Order("COUNT(*) DESC, u.role").Limit(facetLimit).
+		Scan(&roles).Error; err != nil {
+		return nil, err
+	}
+	facets["department"], facets["location"], facets["role"] = departments, locations, roles
+	return facets, nil
+}
+
+// GET /api/search/suggest?q=&limit=8
+// Typeahead for current employees the caller may view: every typed word must
+// start a word of the name, email, designation, department or location, or the
+// text must be close to them; best matches first.
+func SuggestEmployees(c *gin.Context) {
+	rows := []EmployeeSuggestion{}
+	q := search.Parse(c.Query("q"))
+	if q.Empty() {
+		c.JSON(http.StatusOK, gin.H{"data": rows})
+		return
+	}
+	limit := 8
+	if v := parseUint(c.Query("limit")); v > 0 && v <= 20 {
+		limit = int(v)
+	}
+	db := config.DB.Table("employees e").
+		Select("e.id, u.name, u.email, e.designation, COALESCE(d.name, '') AS department_name").
+		Joins("JOIN users u ON u.id = e.user_id").
+		Joins("LEFT JOIN departments d ON d.id = e.department_id").
+		Where("e.deleted_at IS NULL AND e.status <> ?", employment.LifecycleTerminated)
+	db = authz.ScopeEmployees(c, db, "e.id", authz.View)
+	db = search.OrderByRank(search.Match(db, q, false), q, false)
+	if err := db.Limit(limit).Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "search failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
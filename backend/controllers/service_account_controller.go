@@ -0,0 +1,290 @@
+package controllers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"peoplesoft/authz"
+	"peoplesoft/config"
+	"peoplesoft/middleware"
+	"peoplesoft/models"
+	"peoplesoft/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// API key lifetimes: API_KEY_DEFAULT_TTL when none is requested, never more than
+// API_KEY_MAX_TTL. On rotation the old key keeps working for API_KEY_ROTATION_GRACE
+// (or grace_hours) so the caller can deploy the new one.
+func apiKeyDefaultTTL() time.Duration {
+	return utils.DurationFromEnv("API_KEY_DEFAULT_TTL", 90*24*time.Hour)
+}
+func apiKeyMaxTTL() time.Duration {
+	return utils.DurationFromEnv("API_KEY_MAX_TTL", 365*24*time.Hour)
+}
+func apiKeyRotationGrace() time.Duration {
+	return utils.DurationFromEnv("API_KEY_ROTATION_GRACE", 24*time.Hour)
+}
+
+// GET /api/service-accounts/scopes
+func ListAPIScopes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"data": authz.APIScopes})
+}
+
+// GET /api/service-accounts
+func ListServiceAccounts(c *gin.Context) {
+	var accounts []models.ServiceAccount
+	if err := config.DB.Order("name asc").Find(&accounts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "fetch failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": accounts})
+}
+
+// POST /api/service-accounts
+func CreateServiceAccount(c *gin.Context) {
+	var in struct {
+		Name        string `json:"name"`
+		Description string `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil || strings.TrimSpace(in.Name) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name required"})
+		return
+	}
+	account := models.ServiceAccount{
+		Name:        strings.TrimSpace(in.Name),
+		Description: in.Description,
+		Active:      true,
+		CreatedByID: c.GetUint("userID"),
+	}
+	if err := config.DB.Create(&account).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "service account already exists or db error"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": account})
+}
+
+// PUT /api/service-accounts/:id
+// Setting active=false blocks every key of the account until it is re-enabled.
+func UpdateServiceAccount(c *gin.Context) {
+	var in struct {
+		Description *string `json:"description"`
+		Active      *bool   `json:"active"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+	var account models.ServiceAccount
+	if err := config.DB.First(&account, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "service account not found"})
+		return
+	}
+	updates := map[string]interface{}{}
+	if in.Description != nil {
+		updates["description"] = *in.Description
+	}
+	if in.Active != nil {
+		updates["active"] = *in.Active
+	}
+	if len(updates) > 0 {
+		if err := config.DB.Model(&account).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+			return
+		}
+		config.DB.First(&account, account.ID)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": account})
+}
+
+// DELETE /api/service-accounts/:id
+// Disables the account and revokes all of its keys; the row is kept for key history.
+func DeleteServiceAccount(c *gin.Context) {
+	var account models.ServiceAccount
+	if err := config.DB.First(&account, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "service account not found"})
+		return
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&account).Update("active", false).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.APIKey{}).
+			Where("service_account_id = ? AND revoked_at IS NULL", account.ID).
+			Update("revoked_at", time.Now()).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to disable service account"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "service account disabled and keys revoked"})
+}
+
+// GET /api/service-accounts/:id/keys
+func ListAPIKeys(c *gin.Context) {
+	var keys []models.APIKey
+	if err := config.DB.Where("service_account_id = ?", c.Param("id")).Order("created_at desc").Find(&keys).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "fetch failed"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": keys})
+}
+
+type apiKeyInput struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+// POST /api/service-accounts/:id/keys
+// The raw key is returned once; only its hash is stored.
+func CreateAPIKey(c *gin.Context) {
+	var in apiKeyInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+	scopes := uniqueStrings(in.Scopes)
+	if len(scopes) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one scope required"})
+		return
+	}
+	for _, s := range scopes {
+		if _, ok := authz.LookupAPIScope(s); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown scope: " + s})
+			return
+		}
+	}
+	ttl, ok := apiKeyTTL(in.ExpiresInDays)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_days exceeds API_KEY_MAX_TTL"})
+		return
+	}
+
+	var account models.ServiceAccount
+	if err := config.DB.First(&account, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "service account not found"})
+		return
+	}
+	if !account.Active {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "service account is disabled"})
+		return
+	}
+
+	key := models.APIKey{
+		ServiceAccountID: account.ID,
+		Name:             in.Name,
+		Scopes:           strings.Join(scopes, " "),
+		ExpiresAt:        time.Now().Add(ttl),
+		CreatedByID:      c.GetUint("userID"),
+	}
+	raw, err := issueAPIKey(config.DB, &key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create key"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": key, "key": raw})
+}
+
+// POST /api/service-accounts/:id/keys/:keyId/rotate
+// Issues a replacement with the same scopes and shortens the old key's expiry
+// to the grace period.
+func RotateAPIKey(c *gin.Context) {
+	var in struct {
+		ExpiresInDays int  `json:"expires_in_days"`
+		GraceHours    *int `json:"grace_hours"`
+	}
+	_ = c.ShouldBindJSON(&in)
+	ttl, ok := apiKeyTTL(in.ExpiresInDays)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_days exceeds API_KEY_MAX_TTL"})
+		return
+	}
+	grace := apiKeyRotationGrace()
+	if in.GraceHours != nil && *in.GraceHours >= 0 {
+		grace = time.Duration(*in.GraceHours) * time.Hour
+	}
+
+	var (
+		next models.APIKey
+		raw  string
+	)
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var old models.APIKey
+		if err := tx.Where("id = ? AND service_account_id = ?", c.Param("keyId"), c.Param("id")).First(&old).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		if old.RevokedAt != nil || now.After(old.ExpiresAt) {
+			return &badRequest{"key is revoked or expired; create a new one"}
+		}
+
+		next = models.APIKey{
+			ServiceAccountID: old.ServiceAccountID,
+			Name:             old.Name,
+			Scopes:           old.Scopes,
+			ExpiresAt:        now.Add(ttl),
+			RotatedFromID:    &old.ID,
+			CreatedByID:      c.GetUint("userID"),
+		}
+		var err error
+		if raw, err = issueAPIKey(tx, &next); err != nil {
+			return err
+		}
+		if cutoff := now.Add(grace); cutoff.Before(old.ExpiresAt) {
+			return tx.Model(&old).Update("expires_at", cutoff).Error
+		}
+		return nil
+	})
+	if err != nil {
+		if br, ok := err.(*badRequest); ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+			return
+		}
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "key not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "rotation failed"})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"data": next, "key": raw})
+}
+
+// DELETE /api/service-accounts/:id/keys/:keyId
+func RevokeAPIKey(c *gin.Context) {
+	res := config.DB.Model(&models.APIKey{}).
+		Where("id = ? AND service_account_id = ? AND revoked_at IS NULL", c.Param("keyId"), c.Param("id")).
+		Update("revoked_at", time.Now())
+	if res.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "revoke failed"})
+		return
+	}
+	if res.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "key not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "key revoked"})
+}
+
+func apiKeyTTL(days int) (time.Duration, bool) {
+	if days <= 0 {
+		return apiKeyDefaultTTL(), true
+	}
+	ttl := time.Duration(days) * 24 * time.Hour
+	return ttl, ttl <= apiKeyMaxTTL()
+}
+
+// issueAPIKey generates the secret, fills Prefix/KeyHash and inserts the key.
+func issueAPIKey(db *gorm.DB, key *models.APIKey) (string, error) {
+	secret, _, err := utils.NewOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+	raw := middleware.APIKeyPrefix + secret
+	key.Prefix = raw[:12]
+	key.KeyHash = utils.HashToken(raw)
+	return raw, db.Create(key).Error
+}
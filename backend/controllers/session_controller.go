@@ -0,0 +1,206 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"peoplesoft/utils"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// issueSession creates an access token plus a new refresh token family for the user.
+func issueSession(c *gin.Context, user models.User) (gin.H, error) {
+	resp, _, err := issueSessionInFamily(config.DB, c, user, "")
+	return resp, err
+}
+
+// issueSessionInFamily mints an access token and a refresh token. An empty
+// familyID starts a new family (fresh login); rotation passes the old one on.
+func issueSessionInFamily(db *gorm.DB, c *gin.Context, user models.User, familyID string) (gin.H, *models.RefreshToken, error) {
+	if !user.Active {
+		return nil, nil, errors.New("account is deactivated")
+	}
+	access, err := utils.GenerateToken(user.Email, user.Role, user.SessionVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	raw, hash, err := utils.NewOpaqueToken()
+	if err != nil {
+		return nil, nil, err
+	}
+	if familyID == "" {
+		if _, familyID, err = utils.NewOpaqueToken(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	rt := models.RefreshToken{
+		UserID:    user.ID,
+		TokenHash: hash,
+		FamilyID:  familyID,
+		ExpiresAt: time.Now().Add(utils.RefreshTokenTTL()),
+		UserAgent: utils.Truncate(c.Request.UserAgent(), 255),
+		IP:        c.ClientIP(),
+	}
+	if err := db.Create(&rt).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return gin.H{
+		"token":         access,
+		"refresh_token": raw,
+		"expires_in":    int(utils.AccessTokenTTL().Seconds()),
+		"role":          user.Role,
+		"email":         user.Email,
+		"userID":        user.ID,
+		"name":          user.Name,
+	}, &rt, nil
+}
+
+// POST /api/auth/refresh
+// Exchanges a refresh token for a new access/refresh pair. The presented token is
+// revoked (rotation); presenting an already-rotated token revokes the whole family.
+func Refresh(c *gin.Context) {
+	var body struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token required"})
+		return
+	}
+
+	tx := config.DB.Begin()
+
+	var rt models.RefreshToken
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ?", utils.HashToken(body.RefreshToken)).
+		First(&rt).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
+		return
+	}
+
+	if rt.RevokedAt != nil {
+		// Reuse of a rotated token: assume it was stolen and kill the family.
+		if err := revokeFamily(tx, rt.FamilyID); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+			return
+		}
+		if err := tx.Commit().Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token reuse detected, session revoked"})
+		return
+	}
+	if time.Now().After(rt.ExpiresAt) {
+		tx.Rollback()
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token expired"})
+		return
+	}
+
+	var user models.User
+	if err := tx.First(&user, rt.UserID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "user not found"})
+		return
+	}
+	if !user.Active {
+		tx.Rollback()
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "account is deactivated"})
+		return
+	}
+
+	resp, next, err := issueSessionInFamily(tx, c, user, rt.FamilyID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue token"})
+		return
+	}
+
+	if err := tx.Model(&rt).Updates(map[string]interface{}{
+		"revoked_at":     time.Now(),
+		"replaced_by_id": next.ID,
+	}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rotate token"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rotate token"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// POST /api/auth/logout
+// Revokes the refresh token family the presented token belongs to.
+func Logout(c *gin.Context) {
+	var body struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "refresh_token required"})
+		return
+	}
+
+	var rt models.RefreshToken
+	if err := config.DB.Where("token_hash = ?", utils.HashToken(body.RefreshToken)).First(&rt).Error; err == nil {
+		if err := revokeFamily(config.DB, rt.FamilyID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to log out"})
+			return
+		}
+	}
+
+	// Always succeed so logout does not reveal whether a token was valid.
+	c.JSON(http.StatusOK, gin.H{"message": "logged out"})
+}
+
+// POST /api/users/:id/revoke-sessions (HR only)
+func RevokeUserSessions(c *gin.Context) {
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+	if err := revokeAllSessions(config.DB, user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "all sessions revoked"})
+}
+
+// revokeAllSessions revokes every refresh token of a user and bumps the session
+// version so outstanding access tokens stop working immediately.
+func revokeAllSessions(db *gorm.DB, userID uint) error {
+	if err := db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+	res := db.Model(&models.User{}).Where("id = ?", userID).
+		Update("session_version", gorm.Expr("session_version + 1"))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
+// revokeFamily revokes every live refresh token of a rotation family.
+func revokeFamily(db *gorm.DB, familyID string) error {
+	return db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
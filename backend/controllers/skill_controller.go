@@ -0,0 +1,560 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"peoplesoft/audit"
+	"peoplesoft/authz"
+	"peoplesoft/config"
+	"peoplesoft/employment"
+	"peoplesoft/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// SkillLevels names the proficiency levels; level n is SkillLevels[n-1].
+var SkillLevels = []string{"novice", "beginner", "competent", "proficient", "expert"}
+
+func validSkillLevel(l int) bool { return l >= 1 && l <= len(SkillLevels) }
+
+// SkillRow is a catalog entry with the number of current employees who have it.
+type SkillRow struct {
+	models.Skill
+	Holders int64 `json:"holders"`
+}
+
+// EmployeeSkillRow is an employee's skill with its name and endorser.
+type EmployeeSkillRow struct {
+	models.EmployeeSkill
+	SkillName      string  `json:"skill_name"`
+	Category       string  `json:"category"`
+	EndorsedByName *string `json:"endorsed_by_name"`
+}
+
+// SkillRequirementRow is a designation requirement with the skill name.
+type SkillRequirementRow struct {
+	models.SkillRequirement
+	SkillName string `json:"skill_name"`
+}
+
+// canEditSkills: the employee themselves and whoever can manage them.
+func canEditSkills(c *gin.Context, employeeID uint) bool {
+	me := authz.CallerEmployeeID(c)
+	return (me != 0 && me == employeeID) || authz.CanAccessEmployee(c, employeeID, authz.Manage)
+}
+
+// GET /api/skills/levels
+func ListSkillLevels(c *gin.Context) {
+	levels := make([]gin.H, len(SkillLevels))
+	for i, name := range SkillLevels {
+		levels[i] = gin.H{"level": i + 1, "name": name}
+	}
+	c.JSON(http.StatusOK, gin.H{"data": levels})
+}
+
+// GET /api/skills?q=&category=
+func ListSkills(c *gin.Context) {
+	db := config.DB.Table("skills s").
+		Select(`s.*, (SELECT COUNT(*) FROM employee_skills es
+			JOIN employees e ON e.id = es.employee_id AND e.deleted_at IS NULL
+			WHERE es.skill_id = s.id) AS holders`)
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		db = db.Where("s.name ILIKE ?", "%"+q+"%")
+	}
+	if v := strings.TrimSpace(c.Query("category")); v != "" {
+		db = db.Where("s.category = ?", v)
+	}
+	var rows []SkillRow
+	if err := db.Order("s.name").Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch skills"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
+
+type skillInput struct {
+	Name        *string `json:"name"`
+	Category    *string `json:"category"`
+	Description *string `json:"description"`
+}
+
+// apply validates the input onto s; names are unique regardless of case.
+func (in skillInput) apply(s *models.Skill) *badRequest {
+	if in.Name != nil {
+		name := strings.TrimSpace(*in.Name)
+		if name == "" || len(name) > 100 {
+			return &badRequest{"name must be 1-100 characters"}
+		}
+		var n int64
+		config.DB.Model(&models.Skill{}).Where("lower(name) = lower(?) AND id <> ?", name, s.ID).Count(&n)
+		if n > 0 {
+			return &badRequest{"a skill named " + name + " already exists"}
+		}
+		s.Name = name
+	}
+	if in.Category != nil {
+		if len(*in.Category) > 60 {
+			return &badRequest{"category must be at most 60 characters"}
+		}
+		s.Category = strings.TrimSpace(*in.Category)
+	}
+	if in.Description != nil {
+		if len(*in.Description) > 500 {
+			return &badRequest{"description must be at most 500 characters"}
+		}
+		s.Description = strings.TrimSpace(*in.Description)
+	}
+	return nil
+}
+
+// POST /api/skills
+func CreateSkill(c *gin.Context) {
+	var in skillInput
+	if err := c.ShouldBindJSON(&in); err != nil || in.Name == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name required"})
+		return
+	}
+	var s models.Skill
+	if br := in.apply(&s); br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
+	if err := config.DB.Create(&s).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create skill"})
+		return
+	}
+	audit.Record(c, "skill.create", "skill", s.ID, nil, s)
+	c.JSON(http.StatusCreated, gin.H{"data": s})
+}
+
+// PUT /api/skills/:id
+func UpdateSkill(c *gin.Context) {
+	var in skillInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+	var s models.Skill
+	if err := config.DB.First(&s, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "skill not found"})
+		return
+	}
+	before := s
+	if br := in.apply(&s); br != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": br.msg})
+		return
+	}
+	if err := config.DB.Save(&s).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	audit.Record(c, "skill.update", "skill", s.ID, before, s)
+	c.JSON(http.StatusOK, gin.H{"data": s})
+}
+
+// DELETE /api/skills/:id
+// Removes the skill from every employee and designation requirement.
+func DeleteSkill(c *gin.Context) {
+	var s models.Skill
+	if err := config.DB.First(&s, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "skill not found"})
+		return
+	}
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		for _, m := range []interface{}{&models.EmployeeSkill{}, &models.SkillRequirement{}} {
+			if err := tx.Where("skill_id = ?", s.ID).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&s).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		return
+	}
+	audit.Record(c, "skill.delete", "skill", s.ID, s, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+func employeeSkillRows(db *gorm.DB) *gorm.DB {
+	return db.Table("employee_skills es").
+		Select("es.*, s.name AS skill_name, s.category, eu.name AS endorsed_by_name").
+		Joins("JOIN skills s ON s.id = es.skill_id").
+		Joins("LEFT JOIN users eu ON eu.id = es.endorsed_by_id")
+}
+
+// GET /api/employees/:id/skills
+func ListEmployeeSkills(c *gin.Context) {
+	var emp models.Employee
+	if err := config.DB.First(&emp, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return
+	}
+	var rows []EmployeeSkillRow
+	if err := employeeSkillRows(config.DB).Where("es.employee_id = ?", emp.ID).
+		Order("es.level DESC, s.name").Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch skills"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
+
+// loadEmployeeSkillTarget resolves :id and :skillId, writing the error response
+// when either is missing or the caller may not change the employee's skills.
+func loadEmployeeSkillTarget(c *gin.Context) (*models.Employee, *models.Skill, bool) {
+	var emp models.Employee
+	if err := config.DB.First(&emp, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+		return nil, nil, false
+	}
+	if !canEditSkills(c, emp.ID) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to change this employee's skills"})
+		return nil, nil, false
+	}
+	var s models.Skill
+	if err := config.DB.First(&s, c.Param("skillId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "skill not found"})
+		return nil, nil, false
+	}
+	return &emp, &s, true
+}
+
+// PUT /api/employees/:id/skills/:skillId   body: {level, note}
+// Adds or updates a skill of the employee (themselves or whoever manages
+// them). A changed level needs a new endorsement.
+func SetEmployeeSkill(c *gin.Context) {
+	emp, skill, ok := loadEmployeeSkillTarget(c)
+	if !ok {
+		return
+	}
+	var in struct {
+		Level int     `json:"level"`
+		Note  *string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil || !validSkillLevel(in.Level) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "level must be 1-5"})
+		return
+	}
+	if in.Note != nil && len(*in.Note) > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "note must be at most 500 characters"})
+		return
+	}
+
+	var es models.EmployeeSkill
+	err := config.DB.Where("employee_id = ? AND skill_id = ?", emp.ID, skill.ID).First(&es).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch skill"})
+		return
+	}
+	before := es
+	if es.ID == 0 {
+		es = models.EmployeeSkill{EmployeeID: emp.ID, SkillID: skill.ID}
+	}
+	if es.Level != in.Level {
+		es.Level = in.Level
+		es.EndorsedByID, es.EndorsedAt = nil, nil
+	}
+	if in.Note != nil {
+		es.Note = strings.TrimSpace(*in.Note)
+	}
+	if err := config.DB.Save(&es).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save skill"})
+		return
+	}
+	if before.ID == 0 {
+		audit.Record(c, "employee.skill_add", "employee", emp.ID, nil, es)
+	} else {
+		audit.Record(c, "employee.skill_update", "employee", emp.ID, before, es)
+	}
+	c.JSON(http.StatusOK, gin.H{"data": es})
+}
+
+// DELETE /api/employees/:id/skills/:skillId
+func RemoveEmployeeSkill(c *gin.Context) {
+	emp, skill, ok := loadEmployeeSkillTarget(c)
+	if !ok {
+		return
+	}
+	var es models.EmployeeSkill
+	if err := config.DB.Where("employee_id = ? AND skill_id = ?", emp.ID, skill.ID).First(&es).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "the employee does not have this skill"})
+		return
+	}
+	if err := config.DB.Delete(&es).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		return
+	}
+	audit.Record(c, "employee.skill_remove", "employee", emp.ID, es, nil)
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// POST /api/employees/:id/skills/:skillId/endorse   body: {level} (optional)
+// A manager or HR confirms the employee's level, or corrects it to level and
+// endorses that. Nobody endorses their own skills.
+func EndorseEmployeeSkill(c *gin.Context) {
+	emp, skill, ok := loadEmployeeSkillTarget(c)
+	if !ok {
+		return
+	}
+	if emp.ID == authz.CallerEmployeeID(c) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "you cannot endorse your own skills"})
+		return
+	}
+	var in struct {
+		Level int `json:"level"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&in); err != nil || (in.Level != 0 && !validSkillLevel(in.Level)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "level must be 1-5"})
+			return
+		}
+	}
+	var es models.EmployeeSkill
+	if err := config.DB.Where("employee_id = ? AND skill_id = ?", emp.ID, skill.ID).First(&es).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "the employee does not have this skill"})
+		return
+	}
+	before := es
+	if in.Level != 0 {
+		es.Level = in.Level
+	}
+	by, now := c.GetUint("userID"), time.Now()
+	es.EndorsedByID, es.EndorsedAt = &by, &now
+	if err := config.DB.Save(&es).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to endorse skill"})
+		return
+	}
+	audit.Record(c, "employee.skill_endorse", "employee", emp.ID, before, es)
+	c.JSON(http.StatusOK, gin.H{"data": es})
+}
+
+// GET /api/skills/requirements?designation=
+func ListSkillRequirements(c *gin.Context) {
+	db := config.DB.Table("skill_requirements r").
+		Select("r.*, s.name AS skill_name").
+		Joins("JOIN skills s ON s.id = r.skill_id")
+	if v := strings.TrimSpace(c.Query("designation")); v != "" {
+		db = db.Where("lower(r.designation) = lower(?)", v)
+	}
+	var rows []SkillRequirementRow
+	if err := db.Order("r.designation, s.name").Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch requirements"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": rows})
+}
+
+// PUT /api/skills/requirements   body: {designation, requirements: [{skill_id, min_level}]}
+// Replaces the requirements of a designation; an empty list removes them.
+// Designations match employees' designations regardless of case.
+func SetSkillRequirements(c *gin.Context) {
+	var in struct {
+		Designation  string `json:"designation"`
+		Requirements []struct {
+			SkillID  uint `json:"skill_id"`
+			MinLevel int  `json:"min_level"`
+		} `json:"requirements"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid input"})
+		return
+	}
+	designation := strings.TrimSpace(in.Designation)
+	if designation == "" || len(designation) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "designation must be 1-100 characters"})
+		return
+	}
+	seen := map[uint]bool{}
+	reqs := make([]models.SkillRequirement, 0, len(in.Requirements))
+	for _, r := range in.Requirements {
+		if !validSkillLevel(r.MinLevel) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "min_level must be 1-5"})
+			return
+		}
+		if seen[r.SkillID] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "each skill may only be listed once"})
+			return
+		}
+		seen[r.SkillID] = true
+		reqs = append(reqs, models.SkillRequirement{Designation: designation, SkillID: r.SkillID, MinLevel: r.MinLevel})
+	}
+	if len(seen) > 0 {
+		var n int64
+		config.DB.Model(&models.Skill{}).Where("id IN ?", keysOf(seen)).Count(&n)
+		if int(n) != len(seen) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown skill_id"})
+			return
+		}
+	}
+
+	var before []models.SkillRequirement
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("lower(designation) = lower(?)", designation).Find(&before).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("lower(designation) = lower(?)", designation).Delete(&models.SkillRequirement{}).Error; err != nil {
+			return err
+		}
+		if len(reqs) == 0 {
+			return nil
+		}
+		return tx.Create(&reqs).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save requirements"})
+		return
+	}
+	audit.Record(c, "skill.requirements", "designation", designation, gin.H{"requirements": before}, gin.H{"requirements": reqs})
+	c.JSON(http.StatusOK, gin.H{"data": reqs})
+}
+
+func keysOf(m map[uint]bool) []uint {
+	ids := make([]uint, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// SkillGap is a required skill an employee lacks or has below the required level.
+type SkillGap struct {
+	SkillID  uint   `json:"skill_id"`
+	Skill    string `json:"skill"`
+	Required int    `json:"required_level"`
+	Level    int    `json:"level"` // 0 when the employee does not have the skill
+	Endorsed bool   `json:"endorsed"`
+}
+
+// EmployeeSkillGaps compares one employee with their designation's requirements.
+type EmployeeSkillGaps struct {
+	EmployeeID   uint       `json:"employee_id"`
+	Name         string     `json:"name"`
+	Designation  string     `json:"designation"`
+	Requirements int        `json:"requirements"`
+	Met          int        `json:"met"`
+	Gaps         []SkillGap `json:"gaps"`
+}
+
+// TeamSkillGap sums up one skill across the team.
+type TeamSkillGap struct {
+	SkillID    uint   `json:"skill_id"`
+	Skill      string `json:"skill"`
+	RequiredBy int    `json:"required_by"` // employees whose designation requires it
+	Missing    int    `json:"missing"`     // of those, below the required level
+}
+
+// GET /api/skills/gaps?manager_id=&department_id=&endorsed_only=
+// Compares a team's skills with the requirements of each member's designation:
+// the direct reports of manager_id (default: the caller) or the members of
+// department_id. endorsed_only=true only counts endorsed levels as met.
+func ListSkillGaps(c *gin.Context) {
+	db := employeeDirectory(nil).Where("e.status <> ?", employment.LifecycleTerminated)
+	if v := c.Query("department_id"); v != "" {
+		deptID := parseUint(v)
+		if !authz.CanAccessDepartment(c, deptID) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to view this department"})
+			return
+		}
+		db = db.Where("e.department_id = ?", deptID)
+	} else {
+		managerID := authz.CallerEmployeeID(c)
+		if v := c.Query("manager_id"); v != "" {
+			managerID = parseUint(v)
+		}
+		if managerID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "manager_id or department_id required"})
+			return
+		}
+		if managerID != authz.CallerEmployeeID(c) && !authz.CanAccessEmployee(c, managerID, authz.View) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to view this team"})
+			return
+		}
+		db = db.Where("e.manager_id = ?", managerID)
+	}
+	var members []EmployeeRow
+	if err := authz.ScopeEmployees(c, db, "e.id", authz.View).Order("u.name").Scan(&members).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch team"})
+		return
+	}
+	endorsedOnly := c.Query("endorsed_only") == "true"
+
+	ids := make([]uint, len(members))
+	designations := []string{}
+	for i, m := range members {
+		ids[i] = m.ID
+		designations = append(designations, strings.ToLower(m.Designation))
+	}
+	var reqs []SkillRequirementRow
+	var held []models.EmployeeSkill
+	if len(members) > 0 {
+		if err := config.DB.Table("skill_requirements r").
+			Select("r.*, s.name AS skill_name").
+			Joins("JOIN skills s ON s.id = r.skill_id").
+			Where("lower(r.designation) IN ?", uniqueStrings(designations)).
+			Order("s.name").Scan(&reqs).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch requirements"})
+			return
+		}
+		if err := config.DB.Where("employee_id IN ?", ids).Find(&held).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch skills"})
+			return
+		}
+	}
+	byDesignation := map[string][]SkillRequirementRow{}
+	for _, r := range reqs {
+		d := strings.ToLower(r.Designation)
+		byDesignation[d] = append(byDesignation[d], r)
+	}
+	type key struct{ emp, skill uint }
+	levels := map[key]models.EmployeeSkill{}
+	for _, es := range held {
+		levels[key{es.EmployeeID, es.SkillID}] = es
+	}
+
+	rows := make([]EmployeeSkillGaps, 0, len(members))
+	summary := map[uint]*TeamSkillGap{}
+	for _, m := range members {
+		row := EmployeeSkillGaps{EmployeeID: m.ID, Name: m.Name, Designation: m.Designation, Gaps: []SkillGap{}}
+		for _, r := range byDesignation[strings.ToLower(m.Designation)] {
+			s := summary[r.SkillID]
+			if s == nil {
+				s = &TeamSkillGap{SkillID: r.SkillID, Skill: r.SkillName}
+				summary[r.SkillID] = s
+			}
+			s.RequiredBy++
+			row.Requirements++
+
+			es, has := levels[key{m.ID, r.SkillID}]
+			endorsed := has && es.EndorsedAt != nil
+			level := es.Level
+			if endorsedOnly && !endorsed {
+				level = 0
+			}
+			if level >= r.MinLevel {
+				row.Met++
+				continue
+			}
+			s.Missing++
+			row.Gaps = append(row.Gaps, SkillGap{
+				SkillID: r.SkillID, Skill: r.SkillName, Required: r.MinLevel, Level: es.Level, Endorsed: endorsed,
+			})
+		}
+		rows = append(rows, row)
+	}
+	skills := make([]TeamSkillGap, 0, len(summary))
+	for _, s := range summary {
+		skills = append(skills, *s)
+	}
+	sort.Slice(skills, func(i, j int) bool {
+		if skills[i].Missing != skills[j].Missing {
+			return skills[i].Missing > skills[j].Missing
+		}
+		return skills[i].Skill < skills[j].Skill
+	})
+	c.JSON(http.StatusOK, gin.H{"data": rows, "skills": skills})
+}
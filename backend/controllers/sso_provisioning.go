@@ -0,0 +1,228 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"peoplesoft/config"
+	"peoplesoft/employment"
+	"peoplesoft/models"
+	"peoplesoft/oidc"
+
+	"gorm.io/gorm"
+)
+
+// errNotProvisioned is returned for unknown users when JIT provisioning is off.
+var errNotProvisioned = errors.New("user is not provisioned")
+
+// errDeactivated is returned when the matched account has been deactivated.
+var errDeactivated = errors.New("account is deactivated")
+
+// errLinkRequired is returned when an account with the identity's email exists
+// but the provider may not claim it; its owner has to link the provider first.
+var errLinkRequired = errors.New("account must be linked to this identity provider first")
+
+// ssoUser resolves the local user for a verified identity: an existing link on
+// (provider, subject) wins, otherwise the account with the same email is linked
+// if this provider provisioned it and it has no other identity there, otherwise
+// a new account is created (unless the provider disables JIT).
+// On every login the name, and the role/department when the provider's mapping
+// manages them, are updated from the token, and the Employee row is ensured.
+func ssoUser(p *oidc.Provider, id *oidc.Identity) (models.User, error) {
+	var user models.User
+	now := time.Now()
+	m := p.Mapping
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var link models.UserIdentity
+		linked := false
+		err := tx.Where("provider = ? AND subject = ?", id.Provider, id.Subject).First(&link).Error
+		switch {
+		case err == nil:
+			if err := tx.First(&user, link.UserID).Error; err != nil {
+				return err
+			}
+			linked = true
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			err = tx.Where("LOWER(email) = ?", id.Email).First(&user).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				if !m.JIT {
+					return errNotProvisioned
+				}
+			} else if err != nil {
+				return err
+			} else if claimed, err := claimableBy(tx, user, id.Provider); err != nil {
+				return err
+			} else if !claimed {
+				return errLinkRequired
+			}
+		default:
+			return err
+		}
+
+		if user.ID != 0 && !user.Active {
+			return errDeactivated
+		}
+
+		role := resolveMappedRole(tx, m, id)
+		deptID, deptOK := resolveMappedDepartment(tx, m, id)
+
+		if user.ID == 0 {
+			user = models.User{
+				Name:          id.Name,
+				Email:         id.Email,
+				Role:          role,
+				DepartmentID:  deptID,
+				ProvisionedBy: id.Provider,
+			}
+			if err := tx.Create(&user).Error; err != nil {
+				return err
+			}
+			log.Printf("sso: provisioned %s via %s as %s", user.Email, id.Provider, user.Role)
+		} else if err := applyJITUpdates(tx, &user, m, id, role, deptID, deptOK); err != nil {
+			return err
+		}
+
+		if linked {
+			if err := tx.Model(&link).Updates(map[string]interface{}{"email": id.Email, "last_login_at": now}).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Create(&models.UserIdentity{
+			UserID:      user.ID,
+			Provider:    id.Provider,
+			Subject:     id.Subject,
+			Email:       id.Email,
+			LastLoginAt: &now,
+		}).Error; err != nil {
+			return err
+		}
+
+		return ensureEmployeeRow(tx, user, deptOK)
+	})
+	return user, err
+}
+
+// claimableBy reports whether provider may link user by email: the provider
+// provisioned the account and no other subject of it is linked yet.
+func claimableBy(tx *gorm.DB, user models.User, provider string) (bool, error) {
+	if user.ProvisionedBy != provider {
+		return false, nil
+	}
+	var n int64
+	err := tx.Model(&models.UserIdentity{}).Where("user_id = ? AND provider = ?", user.ID, provider).Count(&n).Error
+	return n == 0, err
+}
+
+// linkIdentity links a verified identity to a signed-in user who asked for it.
+func linkIdentity(userID uint, id *oidc.Identity) error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		var existing models.UserIdentity
+		err := tx.Where("provider = ? AND subject = ?", id.Provider, id.Subject).First(&existing).Error
+		switch {
+		case err == nil && existing.UserID == userID:
+			return nil
+		case err == nil:
+			return errIdentityTaken
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+		now := time.Now()
+		return tx.Create(&models.UserIdentity{
+			UserID:      userID,
+			Provider:    id.Provider,
+			Subject:     id.Subject,
+			Email:       id.Email,
+			LastLoginAt: &now,
+		}).Error
+	})
+}
+
+// errIdentityTaken means the IdP account is already linked to another user.
+var errIdentityTaken = errors.New("identity is linked to another account")
+
+// applyJITUpdates refreshes an existing user from the token. A role change
+// revokes the user's other sessions, like a change made by HR.
+func applyJITUpdates(tx *gorm.DB, user *models.User, m oidc.Mapping, id *oidc.Identity, role string, deptID uint, deptOK bool) error {
+	updates := map[string]interface{}{}
+	if id.Name != "" && id.Name != id.Email && id.Name != user.Name {
+		updates["name"] = id.Name
+	}
+	roleChanged := m.ManagesRole() && role != user.Role
+	if roleChanged {
+		updates["role"] = role
+	}
+	if deptOK && deptID != user.DepartmentID {
+		updates["department_id"] = deptID
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := tx.Model(user).Updates(updates).Error; err != nil {
+		return err
+	}
+	if roleChanged {
+		log.Printf("sso: role of %s changed to %s by %s mapping", user.Email, role, id.Provider)
+		if err := revokeAllSessions(tx, user.ID); err != nil {
+			return err
+		}
+	}
+	return tx.First(user, user.ID).Error
+}
+
+// ensureEmployeeRow creates the Employee record for SSO users and keeps its
+// department in sync when the IdP manages departments.
+func ensureEmployeeRow(tx *gorm.DB, user models.User, syncDepartment bool) error {
+	var emp models.Employee
+	err := tx.Where("user_id = ?", user.ID).First(&emp).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		emp = models.Employee{UserID: user.ID, DepartmentID: user.DepartmentID}
+		if err := tx.Create(&emp).Error; err != nil {
+			return err
+		}
+		return employment.Hire(tx, &emp, 0)
+	}
+	if err != nil {
+		return err
+	}
+	if syncDepartment && emp.DepartmentID != user.DepartmentID {
+		_, err := employment.Record(tx, &emp, employment.Change{
+			Type:         employment.TypeProvisioning,
+			DepartmentID: &user.DepartmentID,
+		}, 0)
+		return err
+	}
+	return nil
+}
+
+// resolveMappedRole returns the first mapped role that exists, falling back to "employee".
+func resolveMappedRole(tx *gorm.DB, m oidc.Mapping, id *oidc.Identity) string {
+	for _, name := range m.RoleCandidates(id.Claims) {
+		name = strings.ToLower(name)
+		var n int64
+		tx.Model(&models.Role{}).Where("name = ?", name).Count(&n)
+		if n > 0 {
+			return name
+		}
+		log.Printf("sso: %s mapping refers to unknown role %q", id.Provider, name)
+	}
+	return "employee"
+}
+
+// resolveMappedDepartment returns the first mapped department that exists, by id or name.
+func resolveMappedDepartment(tx *gorm.DB, m oidc.Mapping, id *oidc.Identity) (uint, bool) {
+	for _, ref := range m.DepartmentCandidates(id.Claims) {
+		var dept models.Department
+		if n, err := strconv.ParseUint(ref, 10, 64); err == nil {
+			if tx.First(&dept, n).Error == nil {
+				return dept.ID, true
+			}
+		} else if tx.Where("LOWER(name) = LOWER(?)", ref).First(&dept).Error == nil {
+			return dept.ID, true
+		}
+		log.Printf("sso: %s mapping refers to unknown department %q", id.Provider, ref)
+	}
+	return 0, false
+}
@@ -2,10 +2,15 @@ package controllers
 
 import (
 	"net/http"
+	"peoplesoft/audit"
+	"peoplesoft/authz"
 	"peoplesoft/config"
+	"peoplesoft/lifecycle"
 	"peoplesoft/models"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func GetUserByEmail(c *gin.Context) {
@@ -21,30 +26,85 @@ func GetUserByEmail(c *gin.Context) {
 func DeleteUser(c *gin.Context) {
 	id := c.Param("id")
 
-	// First, get all employees managed by this user and set their manager_id to NULL
 	var user models.User
 	if err := config.DB.First(&user, id).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 		return
 	}
+	if !authz.CanAccessUser(c, user.ID, authz.Manage) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "not allowed to manage this user"})
+		return
+	}
 
-	// Find employee record for this user
-	var emp models.Employee
-	config.DB.Where("user_id = ?", id).First(&emp)
+	// Soft delete: the account and its records are hidden but kept for
+	// compliance until restored or purged. One timestamp marks the whole set.
+	now := time.Now()
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		// Kill outstanding sessions before the account disappears
+		if err := revokeAllSessions(tx, user.ID); err != nil {
+			return err
+		}
+		// Team members of a deleted manager move on as on an exit
+		var emp models.Employee
+		if err := tx.Where("user_id = ?", user.ID).Limit(1).Find(&emp).Error; err != nil {
+			return err
+		}
+		if emp.ID != 0 {
+			if _, err := lifecycle.ReassignReports(tx, &emp, c.GetUint("userID")); err != nil {
+				return err
+			}
+		}
+		for _, r := range userRecords {
+			if err := tx.Model(r.model).Where(r.column+" = ?", user.ID).Update("deleted_at", now).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&user).Update("deleted_at", now).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+		return
+	}
+	audit.Record(c, "user.delete", "user", user.ID, user, nil)
 
-	// Update team members' manager_id to NULL if this person was a manager
-	if emp.ID != 0 {
-		config.DB.Model(&models.Employee{}).Where("manager_id = ?", emp.ID).Update("manager_id", nil)
+	c.JSON(http.StatusOK, gin.H{"message": "deleted"})
+}
+
+// PUT /api/users/:id/role (HR only)
+// Changing a role revokes the user's sessions so the new role takes effect on next login.
+func UpdateUserRole(c *gin.Context) {
+	var in struct {
+		Role string `json:"role" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role required"})
+		return
+	}
+	if !roleExists(in.Role) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown role"})
+		return
 	}
 
-	// Delete employee record (will cascade to leaves, goals, reviews via foreign keys)
-	config.DB.Where("user_id = ?", id).Delete(&models.Employee{})
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
 
-	// Delete user (will cascade to all user-related records)
-	if err := config.DB.Delete(&models.User{}, id).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "delete failed"})
+	oldRole := user.Role
+	tx := config.DB.Begin()
+	if err := tx.Model(&user).Update("role", in.Role).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "update failed"})
+		return
+	}
+	if err := revokeAllSessions(tx, user.ID); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
 		return
 	}
+	tx.Commit()
+	audit.Record(c, "user.role_change", "user", user.ID, gin.H{"role": oldRole}, gin.H{"role": in.Role})
 
-	c.JSON(http.StatusOK, gin.H{"message": "deleted with cascading"})
+	c.JSON(http.StatusOK, gin.H{"message": "role updated, sessions revoked", "role": in.Role})
 }
@@ -0,0 +1,454 @@
+// Package employment keeps the effective-dated history of each employee's
+// designation, department, manager and lifecycle status. Changes dated today or earlier are
+// applied to the employees row at once; future-dated ones are scheduled and
+// applied by Start's background job on their effective date. AsOf rebuilds
+// the employees table as it stood on any date.
+package employment
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"peoplesoft/org"
+	"peoplesoft/utils"
+
+	"gorm.io/gorm"
+)
+
+// Change statuses.
+const (
+	StatusScheduled = "scheduled"
+	StatusApplied   = "applied"
+	StatusCancelled = "cancelled"
+	StatusFailed    = "failed"
+)
+
+// Change types. Baseline records the state an employee had before their first
+// tracked change; provisioning covers SCIM and SSO updates.
+const (
+	TypeBaseline      = "baseline"
+	TypeHire          = "hire"
+	TypePromotion     = "promotion"
+	TypeTransfer      = "transfer"
+	TypeManagerChange = "manager_change"
+	TypeJobChange     = "job_change"
+	TypeCorrection    = "correction"
+	TypeProvisioning  = "provisioning"
+	TypeStatusChange  = "status_change"
+	TypeTermination   = "termination"
+)
+
+// Lifecycle statuses of an employee.
+const (
+	LifecycleHired      = "hired" // signed but not started yet
+	LifecycleProbation  = "probation"
+	LifecycleActive     = "active"
+	LifecycleOnNotice   = "on_notice"
+	LifecycleTerminated = "terminated"
+)
+
+// LifecycleStatuses lists every lifecycle status.
+var LifecycleStatuses = []string{LifecycleHired, LifecycleProbation, LifecycleActive, LifecycleOnNotice, LifecycleTerminated}
+
+// transitions lists the statuses each status may move to. Notice can only be
+// withdrawn by cancelling the offboarding, and nobody comes back from terminated.
+var transitions = map[string][]string{
+	LifecycleHired:     {LifecycleProbation, LifecycleActive, LifecycleTerminated},
+	LifecycleProbation: {LifecycleActive, LifecycleOnNotice, LifecycleTerminated},
+	LifecycleActive:    {LifecycleOnNotice, LifecycleTerminated},
+	LifecycleOnNotice:  {LifecycleTerminated},
+}
+
+// CanTransition reports whether an employee may move from one lifecycle status to another.
+func CanTransition(from, to string) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ExitHook, when set, runs inside the same transaction whenever an employee's
+// status becomes terminated (package lifecycle uses it to hand over reports
+// and revoke access).
+var ExitHook func(tx *gorm.DB, emp *models.Employee) error
+
+// ChangeTypes can be chosen by callers of the API.
+var ChangeTypes = []string{TypePromotion, TypeTransfer, TypeManagerChange, TypeJobChange, TypeCorrection}
+
+// ReasonCodes are the accepted reason codes for a change.
+var ReasonCodes = []string{
+	"promotion", "lateral_move", "reorganization", "performance", "relocation",
+	"employee_request", "manager_departure", "correction", "probation_completed",
+	"resignation", "dismissal", "end_of_contract", "retirement", "notice_withdrawn", "other",
+}
+
+// DateLayout is how effective dates are written in the API.
+const DateLayout = "2006-01-02"
+
+// lockID serialises the apply job between backend instances (pg advisory lock).
+const lockID = 7207003
+
+// asOfSQL selects every (not deleted) employee that existed on @d with the designation,
+// department, manager and lifecycle status in force that day. Employees without history keep
+// their current values.
+const asOfSQL = `
+	SELECT e.id, e.user_id, e.phone, e.location, e.created_at,
+		CASE WHEN hd.found THEN hd.designation ELSE e.designation END AS designation,
+		CASE WHEN hp.found THEN hp.department_id ELSE e.department_id END AS department_id,
+		CASE WHEN hm.found THEN hm.manager_id ELSE e.manager_id END AS manager_id,
+		CASE WHEN hs.found THEN hs.employee_status ELSE e.status END AS status
+	FROM employees e
+	LEFT JOIN LATERAL (
+		SELECT h.designation, true AS found FROM employment_changes h
+		WHERE h.employee_id = e.id AND h.designation IS NOT NULL
+			AND h.status IN ('applied', 'scheduled') AND h.effective_date <= CAST(@d AS date)
+		ORDER BY h.effective_date DESC, h.id DESC LIMIT 1
+	) hd ON true
+	LEFT JOIN LATERAL (
+		SELECT h.department_id, true AS found FROM employment_changes h
+		WHERE h.employee_id = e.id AND h.department_id IS NOT NULL
+			AND h.status IN ('applied', 'scheduled') AND h.effective_date <= CAST(@d AS date)
+		ORDER BY h.effective_date DESC, h.id DESC LIMIT 1
+	) hp ON true
+	LEFT JOIN LATERAL (
+		SELECT h.manager_id, true AS found FROM employment_changes h
+		WHERE h.employee_id = e.id AND h.set_manager
+			AND h.status IN ('applied', 'scheduled') AND h.effective_date <= CAST(@d AS date)
+		ORDER BY h.effective_date DESC, h.id DESC LIMIT 1
+	) hm ON true
+	LEFT JOIN LATERAL (
+		SELECT h.employee_status, true AS found FROM employment_changes h
+		WHERE h.employee_id = e.id AND h.employee_status IS NOT NULL
+			AND h.status IN ('applied', 'scheduled') AND h.effective_date <= CAST(@d AS date)
+		ORDER BY h.effective_date DESC, h.id DESC LIMIT 1
+	) hs ON true
+	WHERE e.created_at < CAST(@d AS date) + 1 AND e.deleted_at IS NULL`
+
+// Change is a requested change; nil fields stay as they are.
+type Change struct {
+	Type          string
+	ReasonCode    string
+	Note          string
+	EffectiveDate time.Time
+	Designation   *string
+	DepartmentID  *uint
+	SetManager    bool
+	ManagerID     *uint
+	Status        *string // lifecycle status
+}
+
+// State is an employee's tracked attributes on a given date.
+type State struct {
+	ID           uint
+	Designation  string
+	DepartmentID uint
+	ManagerID    *uint
+	Status       string
+}
+
+// Today is the current local date as a UTC midnight, the form dates are stored in.
+func Today() time.Time {
+	return DateOf(time.Now())
+}
+
+// DateOf drops the time of day, keeping the local calendar date.
+func DateOf(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// AsOf returns a subquery shaped like the employees table as it stood at the
+// end of date. Use it as a table: db.Table("(?) AS e", employment.AsOf(db, d)).
+func AsOf(db *gorm.DB, date time.Time) *gorm.DB {
+	return db.Raw(asOfSQL, map[string]interface{}{"d": date.Format(DateLayout)})
+}
+
+// StateAsOf returns one employee's attributes on date (nil if they did not exist yet).
+func StateAsOf(db *gorm.DB, employeeID uint, date time.Time) (*State, error) {
+	var s State
+	err := db.Table("(?) AS e", AsOf(db, date)).
+		Select("e.id, e.designation, e.department_id, e.manager_id, e.status").
+		Where("e.id = ?", employeeID).
+		Scan(&s).Error
+	if err != nil || s.ID == 0 {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Hire writes the first history record of a newly created employee.
+func Hire(tx *gorm.DB, emp *models.Employee, actorID uint) error {
+	if emp.Status == "" {
+		emp.Status = LifecycleActive
+	}
+	rec := models.EmploymentChange{
+		EmployeeID:     emp.ID,
+		EffectiveDate:  DateOf(emp.CreatedAt),
+		ChangeType:     TypeHire,
+		Designation:    &emp.Designation,
+		DepartmentID:   &emp.DepartmentID,
+		SetManager:     true,
+		ManagerID:      emp.ManagerID,
+		EmployeeStatus: &emp.Status,
+		Status:         StatusApplied,
+		CreatedByID:    optional(actorID),
+	}
+	now := time.Now()
+	rec.AppliedAt = &now
+	return tx.Create(&rec).Error
+}
+
+// Record stores a change for emp. Fields that already hold the requested
+// value on the effective date are dropped; when nothing is left it returns
+// (nil, nil). Changes effective today or earlier are applied to the employees
+// row (and emp) immediately, later ones are scheduled.
+func Record(tx *gorm.DB, emp *models.Employee, ch Change, actorID uint) (*models.EmploymentChange, error) {
+	date := DateOf(ch.EffectiveDate)
+	if ch.EffectiveDate.IsZero() {
+		date = Today()
+	}
+	if date.Before(DateOf(emp.CreatedAt)) {
+		return nil, errors.New("effective date is before the employee was created")
+	}
+	if err := ensureBaseline(tx, emp); err != nil {
+		return nil, err
+	}
+
+	cur, err := StateAsOf(tx, emp.ID, date)
+	if err != nil {
+		return nil, err
+	}
+	if cur != nil {
+		if ch.Designation != nil && *ch.Designation == cur.Designation {
+			ch.Designation = nil
+		}
+		if ch.DepartmentID != nil && *ch.DepartmentID == cur.DepartmentID {
+			ch.DepartmentID = nil
+		}
+		if ch.SetManager && sameID(ch.ManagerID, cur.ManagerID) {
+			ch.SetManager = false
+		}
+		if ch.Status != nil && *ch.Status == cur.Status {
+			ch.Status = nil
+		}
+	}
+	if ch.Designation == nil && ch.DepartmentID == nil && !ch.SetManager && ch.Status == nil {
+		return nil, nil
+	}
+
+	rec := models.EmploymentChange{
+		EmployeeID:     emp.ID,
+		EffectiveDate:  date,
+		ChangeType:     ch.Type,
+		ReasonCode:     ch.ReasonCode,
+		Note:           ch.Note,
+		Designation:    ch.Designation,
+		DepartmentID:   ch.DepartmentID,
+		SetManager:     ch.SetManager,
+		ManagerID:      ch.ManagerID,
+		EmployeeStatus: ch.Status,
+		Status:         StatusScheduled,
+		CreatedByID:    optional(actorID),
+	}
+	if rec.ChangeType == "" {
+		rec.ChangeType = inferType(ch)
+	}
+	if !date.After(Today()) {
+		now := time.Now()
+		rec.Status = StatusApplied
+		rec.AppliedAt = &now
+	}
+	if err := tx.Create(&rec).Error; err != nil {
+		return nil, err
+	}
+	if rec.Status == StatusApplied {
+		if err := Sync(tx, emp); err != nil {
+			return nil, err
+		}
+	}
+	return &rec, nil
+}
+
+// Cancel withdraws a scheduled change before it takes effect.
+func Cancel(tx *gorm.DB, employeeID, changeID uint) error {
+	res := tx.Model(&models.EmploymentChange{}).
+		Where("id = ? AND employee_id = ? AND status = ?", changeID, employeeID, StatusScheduled).
+		Update("status", StatusCancelled)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Sync writes the state as of today into the employees row and emp, running
+// ExitHook when the employee has just become terminated.
+func Sync(tx *gorm.DB, emp *models.Employee) error {
+	s, err := StateAsOf(tx, emp.ID, Today())
+	if err != nil || s == nil {
+		return err
+	}
+	var prev string
+	if err := tx.Model(&models.Employee{}).Select("status").Where("id = ?", emp.ID).Scan(&prev).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.Employee{}).Where("id = ?", emp.ID).Updates(map[string]interface{}{
+		"designation":   s.Designation,
+		"department_id": s.DepartmentID,
+		"manager_id":    s.ManagerID,
+		"status":        s.Status,
+	}).Error; err != nil {
+		return err
+	}
+	emp.Designation = s.Designation
+	emp.DepartmentID = s.DepartmentID
+	emp.ManagerID = s.ManagerID
+	emp.Status = s.Status
+	if s.Status == LifecycleTerminated && prev != LifecycleTerminated && ExitHook != nil {
+		return ExitHook(tx, emp)
+	}
+	return nil
+}
+
+// ensureBaseline records the employee's current attributes, dated when the
+// employee was created, before their first tracked change, so as-of queries
+// for earlier dates still see the old values. Employees whose history predates
+// lifecycle statuses get a status-only baseline.
+func ensureBaseline(tx *gorm.DB, emp *models.Employee) error {
+	var counts struct{ Total, WithStatus int64 }
+	if err := tx.Model(&models.EmploymentChange{}).
+		Select("COUNT(*) AS total, COUNT(employee_status) AS with_status").
+		Where("employee_id = ?", emp.ID).
+		Scan(&counts).Error; err != nil || counts.WithStatus > 0 {
+		return err
+	}
+	status := emp.Status
+	if status == "" {
+		status = LifecycleActive
+	}
+	rec := models.EmploymentChange{
+		EmployeeID:     emp.ID,
+		EffectiveDate:  DateOf(emp.CreatedAt),
+		ChangeType:     TypeBaseline,
+		EmployeeStatus: &status,
+		Status:         StatusApplied,
+		AppliedAt:      &emp.CreatedAt,
+	}
+	if counts.Total == 0 {
+		rec.Designation = &emp.Designation
+		rec.DepartmentID = &emp.DepartmentID
+		rec.SetManager = true
+		rec.ManagerID = emp.ManagerID
+	}
+	return tx.Create(&rec).Error
+}
+
+// Start applies due changes now and then every EMPLOYMENT_APPLY_INTERVAL (default 1h).
+func Start() {
+	interval := utils.DurationFromEnv("EMPLOYMENT_APPLY_INTERVAL", time.Hour)
+	go func() {
+		for {
+			if n, err := ApplyDue(); err != nil {
+				log.Printf("employment: applying scheduled changes failed: %v", err)
+			} else if n > 0 {
+				log.Printf("employment: applied %d scheduled change(s)", n)
+			}
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// ApplyDue applies every scheduled change whose effective date has arrived.
+// Each change is applied in its own savepoint: one that fails (a manager
+// change that would now create a reporting cycle, or an error from Sync or
+// ExitHook) is marked failed with the reason and the rest still apply.
+func ApplyDue() (int, error) {
+	applied := 0
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", lockID).Error; err != nil {
+			return err
+		}
+		var due []models.EmploymentChange
+		if err := tx.Where("status = ? AND effective_date <= ?", StatusScheduled, Today().Format(DateLayout)).
+			Order("effective_date asc, id asc").
+			Find(&due).Error; err != nil {
+			return err
+		}
+		for _, rec := range due {
+			err := tx.Transaction(func(sp *gorm.DB) error { return applyScheduled(sp, rec) })
+			if err == nil {
+				applied++
+				continue
+			}
+			log.Printf("employment: scheduled change %d failed: %v", rec.ID, err)
+			if err := tx.Model(&rec).Updates(map[string]interface{}{
+				"status": StatusFailed,
+				"error":  utils.Truncate(err.Error(), 255),
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// applyScheduled marks one due change applied and syncs its employee.
+func applyScheduled(tx *gorm.DB, rec models.EmploymentChange) error {
+	if rec.SetManager && rec.ManagerID != nil {
+		cycle, err := org.WouldCreateCycle(tx, rec.EmployeeID, *rec.ManagerID)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return errors.New("manager change would create a reporting cycle")
+		}
+	}
+	if err := tx.Model(&rec).Updates(map[string]interface{}{
+		"status":     StatusApplied,
+		"applied_at": time.Now(),
+	}).Error; err != nil {
+		return err
+	}
+	var emp models.Employee
+	if err := tx.First(&emp, rec.EmployeeID).Error; err != nil {
+		return nil // employee deleted meanwhile
+	}
+	return Sync(tx, &emp)
+}
+
+func inferType(ch Change) string {
+	switch {
+	case ch.Status != nil && *ch.Status == LifecycleTerminated:
+		return TypeTermination
+	case ch.Status != nil:
+		return TypeStatusChange
+	case ch.DepartmentID != nil:
+		return TypeTransfer
+	case ch.Designation != nil:
+		return TypeJobChange
+	default:
+		return TypeManagerChange
+	}
+}
+
+func sameID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+func optional(id uint) *uint {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
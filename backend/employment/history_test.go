@@ -0,0 +1,113 @@
+package employment
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// dryRun is a Postgres session that only renders SQL.
+func dryRun(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestStateAsOfQuery(t *testing.T) {
+	db := dryRun(t)
+	date := time.Date(2026, 3, 31, 23, 59, 0, 0, time.UTC)
+	sql := db.ToSQL(func(tx *gorm.DB) *gorm.DB {
+		var s State
+		return tx.Table("(?) AS e", AsOf(tx, date)).Select("e.id, e.status").Where("e.id = ?", 12).Scan(&s)
+	})
+	for _, want := range []string{
+		"CAST('2026-03-31' AS date)",                  // effective dates compare on the calendar day
+		"created_at < CAST('2026-03-31' AS date) + 1", // hired by the end of that day
+		"h.status IN ('applied', 'scheduled')",        // scheduled changes count once effective
+		"e.deleted_at IS NULL",
+		"e.id = 12",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("query lacks %q:\n%s", want, sql)
+		}
+	}
+	if strings.Contains(sql, "@d") {
+		t.Errorf("unbound date parameter:\n%s", sql)
+	}
+}
+
+func TestDateOf(t *testing.T) {
+	ist := time.FixedZone("IST", 5*3600+1800)
+	tests := []struct {
+		in   time.Time
+		want string
+	}{
+		{time.Date(2026, 4, 1, 0, 30, 0, 0, ist), "2026-04-01"}, // still 31 March in UTC
+		{time.Date(2026, 3, 31, 23, 59, 59, 0, time.UTC), "2026-03-31"},
+		{time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC), "2024-02-29"},
+	}
+	for _, tt := range tests {
+		got := DateOf(tt.in)
+		if got.Format(DateLayout) != tt.want || got.Location() != time.UTC || got.Hour() != 0 {
+			t.Errorf("DateOf(%s) = %s, want %s at UTC midnight", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	allowed := map[[2]string]bool{
+		{LifecycleHired, LifecycleProbation}:      true,
+		{LifecycleHired, LifecycleActive}:         true,
+		{LifecycleHired, LifecycleTerminated}:     true,
+		{LifecycleProbation, LifecycleActive}:     true,
+		{LifecycleProbation, LifecycleOnNotice}:   true,
+		{LifecycleProbation, LifecycleTerminated}: true,
+		{LifecycleActive, LifecycleOnNotice}:      true,
+		{LifecycleActive, LifecycleTerminated}:    true,
+		{LifecycleOnNotice, LifecycleTerminated}:  true,
+	}
+	for _, from := range append(LifecycleStatuses, "unknown") {
+		for _, to := range LifecycleStatuses {
+			if got := CanTransition(from, to); got != allowed[[2]string{from, to}] {
+				t.Errorf("CanTransition(%s, %s) = %v", from, to, got)
+			}
+		}
+	}
+}
+
+func TestInferType(t *testing.T) {
+	str := func(s string) *string { return &s }
+	dept := uint(3)
+	tests := []struct {
+		ch   Change
+		want string
+	}{
+		{Change{Status: str(LifecycleTerminated), DepartmentID: &dept}, TypeTermination},
+		{Change{Status: str(LifecycleOnNotice)}, TypeStatusChange},
+		{Change{DepartmentID: &dept, Designation: str("Lead")}, TypeTransfer},
+		{Change{Designation: str("Lead")}, TypeJobChange},
+		{Change{SetManager: true}, TypeManagerChange},
+	}
+	for _, tt := range tests {
+		if got := inferType(tt.ch); got != tt.want {
+			t.Errorf("inferType(%+v) = %s, want %s", tt.ch, got, tt.want)
+		}
+	}
+}
+
+func TestSameID(t *testing.T) {
+	one, alsoOne, two := uint(1), uint(1), uint(2)
+	if !sameID(nil, nil) || !sameID(&one, &alsoOne) || sameID(&one, &two) || sameID(&one, nil) || sameID(nil, &two) {
+		t.Error("sameID is wrong")
+	}
+	if optional(0) != nil || *optional(4) != 4 {
+		t.Error("optional is wrong")
+	}
+}
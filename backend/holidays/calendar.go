@@ -0,0 +1,164 @@
+// Package holidays resolves which days are working days for an employee:
+// the weekend days and holidays of the calendar for their location, plus the
+// optional holidays they picked. Leave day counting and the dashboard's
+// upcoming events are driven from it.
+package holidays
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"peoplesoft/employment"
+	"peoplesoft/models"
+
+	"gorm.io/gorm"
+)
+
+// DefaultWeekend applies when no calendar matches an employee.
+var DefaultWeekend = []string{"saturday", "sunday"}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "monday": time.Monday, "tuesday": time.Tuesday, "wednesday": time.Wednesday,
+	"thursday": time.Thursday, "friday": time.Friday, "saturday": time.Saturday,
+}
+
+// NormalizeWeekend lower-cases and checks weekday names, dropping duplicates
+// and keeping week order (Sunday first).
+func NormalizeWeekend(names []string) ([]string, error) {
+	set := map[time.Weekday]bool{}
+	for _, n := range names {
+		d, ok := weekdays[strings.ToLower(strings.TrimSpace(n))]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday %q", n)
+		}
+		set[d] = true
+	}
+	if len(set) == 7 {
+		return nil, errors.New("at least one weekday must be a working day")
+	}
+	out := []string{}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		if set[d] {
+			out = append(out, strings.ToLower(d.String()))
+		}
+	}
+	return out, nil
+}
+
+// Day is a holiday as it applies to one employee.
+type Day struct {
+	HolidayID uint   `json:"holiday_id"`
+	Date      string `json:"date"` // YYYY-MM-DD
+	Name      string `json:"name"`
+	Optional  bool   `json:"optional"`
+	Chosen    bool   `json:"chosen"` // optional holiday the employee takes off
+}
+
+// Off reports whether the employee does not work on the holiday.
+func (d Day) Off() bool { return !d.Optional || d.Chosen }
+
+// Schedule is the working calendar of one employee over a date range.
+type Schedule struct {
+	Calendar *models.HolidayCalendar // nil when no calendar applies
+	Weekend  map[time.Weekday]bool
+	Days     []Day // holidays in the range, by date
+	off      map[string]bool
+}
+
+// CalendarFor returns the calendar listing location (ignoring case and
+// surrounding spaces), else the default calendar, else nil.
+func CalendarFor(db *gorm.DB, location string) (*models.HolidayCalendar, error) {
+	var cals []models.HolidayCalendar
+	if err := db.Order("id").Find(&cals).Error; err != nil {
+		return nil, err
+	}
+	location = strings.ToLower(strings.TrimSpace(location))
+	var fallback *models.HolidayCalendar
+	for i := range cals {
+		if location != "" {
+			for _, l := range cals[i].LocationList() {
+				if strings.ToLower(l) == location {
+					return &cals[i], nil
+				}
+			}
+		}
+		if cals[i].IsDefault && fallback == nil {
+			fallback = &cals[i]
+		}
+	}
+	return fallback, nil
+}
+
+// ForEmployee builds the schedule of an employee from from to to (inclusive).
+func ForEmployee(db *gorm.DB, emp models.Employee, from, to time.Time) (*Schedule, error) {
+	cal, err := CalendarFor(db, emp.Location)
+	if err != nil {
+		return nil, err
+	}
+	s := &Schedule{Calendar: cal, Weekend: map[time.Weekday]bool{}, Days: []Day{}, off: map[string]bool{}}
+	weekend := DefaultWeekend
+	if cal != nil {
+		weekend = cal.WeekendList()
+	}
+	for _, n := range weekend {
+		s.Weekend[weekdays[n]] = true
+	}
+	if cal == nil {
+		return s, nil
+	}
+
+	var rows []models.Holiday
+	if err := db.Where("calendar_id = ? AND date BETWEEN ? AND ?", cal.ID,
+		from.Format(employment.DateLayout), to.Format(employment.DateLayout)).
+		Order("date, name").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	chosen := map[uint]bool{}
+	if emp.ID != 0 {
+		var ids []uint
+		if err := db.Model(&models.OptionalHolidayChoice{}).Where("employee_id = ?", emp.ID).
+			Pluck("holiday_id", &ids).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			chosen[id] = true
+		}
+	}
+	for _, h := range rows {
+		d := Day{HolidayID: h.ID, Date: h.Date.Format(employment.DateLayout), Name: h.Name, Optional: h.Optional, Chosen: h.Optional && chosen[h.ID]}
+		s.Days = append(s.Days, d)
+		if d.Off() {
+			s.off[d.Date] = true
+		}
+	}
+	return s, nil
+}
+
+// ForUser is ForEmployee for the employee record of a user account. Users
+// without one get the default calendar.
+func ForUser(db *gorm.DB, userID uint, from, to time.Time) (*Schedule, error) {
+	var emp models.Employee
+	if err := db.Where("user_id = ?", userID).Limit(1).Find(&emp).Error; err != nil {
+		return nil, err
+	}
+	return ForEmployee(db, emp, from, to)
+}
+
+// WorkingDay reports whether the employee works on d.
+func (s *Schedule) WorkingDay(d time.Time) bool {
+	return !s.Weekend[d.Weekday()] && !s.off[d.Format(employment.DateLayout)]
+}
+
+// WorkingDays counts the working days from start to end inclusive. Dates
+// outside the range the schedule was built for only skip weekends.
+func (s *Schedule) WorkingDays(start, end time.Time) int {
+	n := 0
+	for cur := start; !cur.After(end); cur = cur.AddDate(0, 0, 1) {
+		if s.WorkingDay(cur) {
+			n++
+		}
+	}
+	return n
+}
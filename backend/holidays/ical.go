@@ -0,0 +1,208 @@
+package holidays
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxEventDays caps how many days one multi-day event expands to.
+const maxEventDays = 31
+
+// Event is one holiday day read from an iCalendar file.
+type Event struct {
+	UID  string
+	Date time.Time
+	Name string
+}
+
+// ParseICS reads the VEVENTs of an iCalendar (RFC 5545) file, one Event per
+// day: all-day events spanning several days (DTEND is exclusive) yield a day
+// each, and yearly recurring events (RRULE:FREQ=YEARLY) are expanded up to
+// and including lastYear. Events with other recurrence rules, without a date
+// or cancelled are returned in skipped with a reason.
+func ParseICS(data []byte, lastYear int) (events []Event, skipped []string, err error) {
+	lines := unfold(string(data))
+	if len(lines) == 0 || !strings.EqualFold(strings.TrimSpace(lines[0]), "BEGIN:VCALENDAR") {
+		return nil, nil, errors.New("not an iCalendar file")
+	}
+
+	var ev map[string]icsProp
+	depth := 0 // components nested in the current VEVENT (VALARM, ...)
+	for _, line := range lines {
+		name, prop := parseICSLine(line)
+		switch {
+		case name == "BEGIN" && strings.EqualFold(prop.value, "VEVENT") && ev == nil:
+			ev = map[string]icsProp{}
+		case ev == nil:
+		case name == "BEGIN":
+			depth++
+		case name == "END" && depth > 0:
+			depth--
+		case name == "END" && strings.EqualFold(prop.value, "VEVENT"):
+			days, reason := expandEvent(ev, lastYear)
+			if reason != "" {
+				skipped = append(skipped, reason)
+			}
+			events = append(events, days...)
+			ev = nil
+		case depth == 0:
+			if _, seen := ev[name]; !seen {
+				ev[name] = prop
+			}
+		}
+	}
+	return events, skipped, nil
+}
+
+type icsProp struct {
+	params map[string]string
+	value  string
+}
+
+// unfold joins continuation lines (starting with a space or tab) to the line
+// before them.
+func unfold(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	var out []string
+	for _, l := range strings.Split(s, "\n") {
+		if (strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")) && len(out) > 0 {
+			out[len(out)-1] += l[1:]
+			continue
+		}
+		if strings.TrimSpace(l) != "" {
+			out = append(out, strings.TrimRight(l, "\r"))
+		}
+	}
+	return out
+}
+
+// parseICSLine splits NAME;PARAM=x:VALUE, minding quoted parameter values.
+func parseICSLine(line string) (string, icsProp) {
+	inQuote, colon := false, -1
+	for i, ch := range line {
+		if ch == '"' {
+			inQuote = !inQuote
+		} else if ch == ':' && !inQuote {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		return strings.ToUpper(line), icsProp{}
+	}
+	parts := strings.Split(line[:colon], ";")
+	prop := icsProp{params: map[string]string{}, value: line[colon+1:]}
+	for _, p := range parts[1:] {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			prop.params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+		}
+	}
+	return strings.ToUpper(parts[0]), prop
+}
+
+func unescapeICS(s string) string {
+	r := strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, " ", `\N`, " ")
+	return strings.TrimSpace(r.Replace(s))
+}
+
+// icsDate reads the date part of a DATE or DATE-TIME value.
+func icsDate(v string) (time.Time, bool) {
+	if len(v) < 8 {
+		return time.Time{}, false
+	}
+	d, err := time.Parse("20060102", v[:8])
+	return d, err == nil
+}
+
+func expandEvent(ev map[string]icsProp, lastYear int) ([]Event, string) {
+	name := unescapeICS(ev["SUMMARY"].value)
+	if name == "" {
+		name = "Holiday"
+	}
+	if strings.EqualFold(ev["STATUS"].value, "CANCELLED") {
+		return nil, name + ": cancelled"
+	}
+	start, ok := icsDate(ev["DTSTART"].value)
+	if !ok {
+		return nil, name + ": no valid DTSTART"
+	}
+	span := 1
+	if end, ok := icsDate(ev["DTEND"].value); ok && len(ev["DTEND"].value) == 8 && end.After(start) {
+		span = int(end.Sub(start).Hours()/24 + 0.5) // DTEND of all-day events is exclusive
+	}
+	if span > maxEventDays {
+		span = maxEventDays
+	}
+
+	starts := []time.Time{start}
+	if rule := ev["RRULE"].value; rule != "" {
+		var reason string
+		if starts, reason = expandYearly(start, rule, lastYear); reason != "" {
+			return nil, name + ": " + reason
+		}
+	}
+	uid := strings.TrimSpace(ev["UID"].value)
+	var out []Event
+	for _, s := range starts {
+		for i := 0; i < span; i++ {
+			out = append(out, Event{UID: uid, Date: s.AddDate(0, 0, i), Name: name})
+		}
+	}
+	return out, ""
+}
+
+// expandYearly lists the occurrences of a FREQ=YEARLY rule on the same month
+// and day as start, honouring COUNT and UNTIL.
+func expandYearly(start time.Time, rule string, lastYear int) ([]time.Time, string) {
+	parts := map[string]string{}
+	for _, p := range strings.Split(rule, ";") {
+		if k, v, ok := strings.Cut(p, "="); ok {
+			parts[strings.ToUpper(k)] = v
+		}
+	}
+	if !strings.EqualFold(parts["FREQ"], "YEARLY") {
+		return nil, "unsupported recurrence " + rule
+	}
+	for k := range parts {
+		switch k {
+		case "FREQ", "COUNT", "UNTIL", "INTERVAL", "WKST":
+		case "BYMONTH":
+			if parts[k] != strconv.Itoa(int(start.Month())) {
+				return nil, "unsupported recurrence " + rule
+			}
+		case "BYMONTHDAY":
+			if parts[k] != strconv.Itoa(start.Day()) {
+				return nil, "unsupported recurrence " + rule
+			}
+		default:
+			return nil, "unsupported recurrence " + rule
+		}
+	}
+	interval := 1
+	if v, err := strconv.Atoi(parts["INTERVAL"]); err == nil && v > 0 {
+		interval = v
+	}
+	count := -1
+	if v, err := strconv.Atoi(parts["COUNT"]); err == nil && v > 0 {
+		count = v
+	}
+	until, hasUntil := icsDate(parts["UNTIL"])
+
+	var out []time.Time
+	for y := start.Year(); y <= lastYear && count != 0; y += interval {
+		d := time.Date(y, start.Month(), start.Day(), 0, 0, 0, 0, time.UTC)
+		if hasUntil && d.After(until) {
+			break
+		}
+		if d.Month() != start.Month() { // 29 February in a common year; not counted (RFC 5545)
+			continue
+		}
+		if count > 0 {
+			count--
+		}
+		out = append(out, d)
+	}
+	return out, ""
+}
@@ -0,0 +1,161 @@
+package holidays
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ics(events ...string) []byte {
+	return []byte("BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" + strings.Join(events, "") + "END:VCALENDAR\r\n")
+}
+
+func dates(events []Event) []string {
+	out := []string{}
+	for _, e := range events {
+		out = append(out, e.Date.Format("2006-01-02"))
+	}
+	return out
+}
+
+func TestParseICS(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   string
+		dates   []string
+		skipped int
+	}{
+		{
+			name:  "single day",
+			event: "BEGIN:VEVENT\r\nUID:a\r\nDTSTART;VALUE=DATE:20260126\r\nSUMMARY:Republic Day\r\nEND:VEVENT\r\n",
+			dates: []string{"2026-01-26"},
+		},
+		{
+			name:  "date-time start keeps the date",
+			event: "BEGIN:VEVENT\r\nDTSTART:20261225T000000Z\r\nSUMMARY:Christmas\r\nEND:VEVENT\r\n",
+			dates: []string{"2026-12-25"},
+		},
+		{
+			name:  "multi-day with exclusive end",
+			event: "BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20261224\r\nDTEND;VALUE=DATE:20261227\r\nSUMMARY:Break\r\nEND:VEVENT\r\n",
+			dates: []string{"2026-12-24", "2026-12-25", "2026-12-26"},
+		},
+		{
+			name:  "yearly up to last year",
+			event: "BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20250101\r\nRRULE:FREQ=YEARLY\r\nSUMMARY:New Year\r\nEND:VEVENT\r\n",
+			dates: []string{"2025-01-01", "2026-01-01", "2027-01-01"},
+		},
+		{
+			name:  "yearly with count and interval",
+			event: "BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20200501\r\nRRULE:FREQ=YEARLY;INTERVAL=2;COUNT=3\r\nSUMMARY:May Day\r\nEND:VEVENT\r\n",
+			dates: []string{"2020-05-01", "2022-05-01", "2024-05-01"},
+		},
+		{
+			name:  "yearly until",
+			event: "BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20240815\r\nRRULE:FREQ=YEARLY;UNTIL=20250815\r\nSUMMARY:Independence Day\r\nEND:VEVENT\r\n",
+			dates: []string{"2024-08-15", "2025-08-15"},
+		},
+		{
+			name:  "29 February skips common years without counting them",
+			event: "BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20160229\r\nRRULE:FREQ=YEARLY;COUNT=3\r\nSUMMARY:Leap Day\r\nEND:VEVENT\r\n",
+			dates: []string{"2016-02-29", "2020-02-29", "2024-02-29"},
+		},
+		{
+			name:    "unsupported recurrence",
+			event:   "BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20260105\r\nRRULE:FREQ=WEEKLY\r\nSUMMARY:Standup\r\nEND:VEVENT\r\n",
+			dates:   []string{},
+			skipped: 1,
+		},
+		{
+			name:    "cancelled",
+			event:   "BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20260105\r\nSTATUS:CANCELLED\r\nSUMMARY:Off\r\nEND:VEVENT\r\n",
+			dates:   []string{},
+			skipped: 1,
+		},
+		{
+			name:    "missing start",
+			event:   "BEGIN:VEVENT\r\nSUMMARY:Someday\r\nEND:VEVENT\r\n",
+			dates:   []string{},
+			skipped: 1,
+		},
+	}
+	for _, tt := range tests {
+		events, skipped, err := ParseICS(ics(tt.event), 2027)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if got := dates(events); !reflect.DeepEqual(got, tt.dates) {
+			t.Errorf("%s: dates %v, want %v", tt.name, got, tt.dates)
+		}
+		if len(skipped) != tt.skipped {
+			t.Errorf("%s: skipped %q, want %d", tt.name, skipped, tt.skipped)
+		}
+	}
+}
+
+func TestParseICSLines(t *testing.T) {
+	data := ics("BEGIN:VEVENT\r\n" +
+		"UID:holiday-1@example.com\r\n" +
+		"DTSTART;VALUE=DATE:20261002\r\n" +
+		"SUMMARY;LANGUAGE=en:Gandhi\r\n  Jayanti\\, national holiday\r\n" +
+		"BEGIN:VALARM\r\nSUMMARY:Reminder\r\nDTSTART:20261001T090000Z\r\nEND:VALARM\r\n" +
+		"END:VEVENT\r\n")
+	events, _, err := ParseICS(data, 2026)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Event{{UID: "holiday-1@example.com", Date: time.Date(2026, 10, 2, 0, 0, 0, 0, time.UTC), Name: "Gandhi Jayanti, national holiday"}}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("got %+v, want %+v", events, want)
+	}
+}
+
+func TestParseICSRejectsOtherFiles(t *testing.T) {
+	for _, data := range []string{"", "name,date\nNew Year,2026-01-01\n", "BEGIN:VCARD\r\nEND:VCARD\r\n"} {
+		if _, _, err := ParseICS([]byte(data), 2026); err == nil {
+			t.Errorf("%q: expected an error", data)
+		}
+	}
+}
+
+func TestParseICSCapsLongEvents(t *testing.T) {
+	data := ics("BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20260101\r\nDTEND;VALUE=DATE:20270101\r\nSUMMARY:Sabbatical\r\nEND:VEVENT\r\n")
+	events, _, err := ParseICS(data, 2026)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != maxEventDays {
+		t.Errorf("got %d days, want %d", len(events), maxEventDays)
+	}
+}
+
+func TestNormalizeWeekend(t *testing.T) {
+	got, err := NormalizeWeekend([]string{" Sunday", "friday", "SUNDAY"})
+	if err != nil || !reflect.DeepEqual(got, []string{"sunday", "friday"}) {
+		t.Errorf("got %v, %v", got, err)
+	}
+	if _, err := NormalizeWeekend([]string{"funday"}); err == nil {
+		t.Error("unknown weekday accepted")
+	}
+	all := []string{"monday", "tuesday", "wednesday", "thursday", "friday", "saturday", "sunday"}
+	if _, err := NormalizeWeekend(all); err == nil {
+		t.Error("a week without working days accepted")
+	}
+}
+
+func TestScheduleWorkingDays(t *testing.T) {
+	s := &Schedule{
+		Weekend: map[time.Weekday]bool{time.Friday: true, time.Saturday: true},
+		off:     map[string]bool{"2026-03-31": true},
+	}
+	// Sun 29 March to Sat 4 April 2026: Friday and Saturday off, Tuesday a holiday
+	got := s.WorkingDays(time.Date(2026, 3, 29, 0, 0, 0, 0, time.UTC), time.Date(2026, 4, 4, 0, 0, 0, 0, time.UTC))
+	if got != 4 {
+		t.Errorf("got %d working days, want 4", got)
+	}
+	if (Day{Optional: true}).Off() || !(Day{Optional: true, Chosen: true}).Off() || !(Day{}).Off() {
+		t.Error("Day.Off is wrong")
+	}
+}
@@ -0,0 +1,185 @@
+// Package lifecycle runs employees through their lifecycle: hiring (with an
+// optional start date and probation), onboarding and offboarding checklists,
+// and the exit itself. Statuses are stored as effective-dated employment
+// changes, so scheduled moves (end of probation, termination) are applied by
+// the employment package's background job; Init hooks the exit work into it.
+package lifecycle
+
+import (
+	"errors"
+	"time"
+
+	"peoplesoft/employment"
+	"peoplesoft/models"
+
+	"gorm.io/gorm"
+)
+
+// Checklist kinds.
+const (
+	KindOnboarding  = "onboarding"
+	KindOffboarding = "offboarding"
+)
+
+// Task assignees. Manager and employee tasks go to that person; hr and it
+// tasks go to the template's default person, if any, or stay in the pool.
+const (
+	AssigneeHR       = "hr"
+	AssigneeManager  = "manager"
+	AssigneeIT       = "it"
+	AssigneeEmployee = "employee"
+)
+
+// Assignees lists the accepted task assignees.
+var Assignees = []string{AssigneeHR, AssigneeManager, AssigneeIT, AssigneeEmployee}
+
+// Task statuses.
+const (
+	TaskOpen    = "open"
+	TaskDone    = "done"
+	TaskSkipped = "skipped"
+)
+
+// Offboarding statuses.
+const (
+	OffboardingPending   = "pending"
+	OffboardingCompleted = "completed"
+	OffboardingCancelled = "cancelled"
+)
+
+// ErrTemplateNotFound is returned when a requested checklist template does not
+// exist or is of the wrong kind.
+var ErrTemplateNotFound = errors.New("checklist template not found")
+
+// Init installs the exit hook that hands over reports and revokes access when
+// an employee's termination takes effect.
+func Init() {
+	employment.ExitHook = finalizeExit
+}
+
+// Joining describes how a new employee starts.
+type Joining struct {
+	StartDate    time.Time  // zero or past: today
+	ProbationEnd *time.Time // on probation from the start date until this date
+	TemplateID   uint       // onboarding checklist; 0 uses the default one
+}
+
+// InitialStatus is the status a new employee is created with.
+func (j Joining) InitialStatus() string {
+	today := employment.Today()
+	switch {
+	case j.StartDate.After(today):
+		return employment.LifecycleHired
+	case j.ProbationEnd != nil && j.ProbationEnd.After(today):
+		return employment.LifecycleProbation
+	default:
+		return employment.LifecycleActive
+	}
+}
+
+// Join records the hire of a just-created employee (created with
+// j.InitialStatus()), schedules the moves to probation and active, and starts
+// the onboarding checklist.
+func Join(tx *gorm.DB, emp *models.Employee, j Joining, actorID uint) ([]models.LifecycleTask, error) {
+	if err := employment.Hire(tx, emp, actorID); err != nil {
+		return nil, err
+	}
+	today := employment.Today()
+	start := employment.DateOf(j.StartDate)
+	if start.Before(today) {
+		start = today
+	}
+	if start.After(today) {
+		next := employment.LifecycleActive
+		if j.ProbationEnd != nil {
+			next = employment.LifecycleProbation
+		}
+		if _, err := employment.Record(tx, emp, employment.Change{
+			Type:          employment.TypeStatusChange,
+			EffectiveDate: start,
+			Status:        &next,
+		}, actorID); err != nil {
+			return nil, err
+		}
+	}
+	if j.ProbationEnd != nil && j.ProbationEnd.After(today) {
+		active := employment.LifecycleActive
+		if _, err := employment.Record(tx, emp, employment.Change{
+			Type:          employment.TypeStatusChange,
+			ReasonCode:    "probation_completed",
+			EffectiveDate: *j.ProbationEnd,
+			Status:        &active,
+		}, actorID); err != nil {
+			return nil, err
+		}
+	}
+	return StartChecklist(tx, emp, KindOnboarding, j.TemplateID, start, nil)
+}
+
+// StartChecklist copies a template's tasks onto emp, due relative to base.
+// templateID 0 uses the default template of the kind; without one nothing is
+// created.
+func StartChecklist(tx *gorm.DB, emp *models.Employee, kind string, templateID uint, base time.Time, offboardingID *uint) ([]models.LifecycleTask, error) {
+	var tpl models.ChecklistTemplate
+	q := tx.Preload("Tasks", func(db *gorm.DB) *gorm.DB { return db.Order("position asc, id asc") }).
+		Where("kind = ?", kind)
+	if templateID != 0 {
+		q = q.Where("id = ?", templateID)
+	} else {
+		q = q.Where("is_default")
+	}
+	if err := q.First(&tpl).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if templateID != 0 {
+				return nil, ErrTemplateNotFound
+			}
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var managerUserID *uint
+	if emp.ManagerID != nil {
+		var mgr models.Employee
+		if err := tx.Select("user_id").First(&mgr, *emp.ManagerID).Error; err == nil {
+			managerUserID = &mgr.UserID
+		}
+	}
+
+	tasks := make([]models.LifecycleTask, 0, len(tpl.Tasks))
+	for _, t := range tpl.Tasks {
+		due := employment.DateOf(base).AddDate(0, 0, t.DueOffsetDays)
+		task := models.LifecycleTask{
+			EmployeeID:     emp.ID,
+			Kind:           kind,
+			TemplateID:     &tpl.ID,
+			OffboardingID:  offboardingID,
+			Title:          t.Title,
+			Description:    t.Description,
+			Assignee:       t.Assignee,
+			AssigneeUserID: t.AssigneeUserID,
+			DueDate:        &due,
+			Status:         TaskOpen,
+		}
+		switch t.Assignee {
+		case AssigneeManager:
+			task.AssigneeUserID = managerUserID
+		case AssigneeEmployee:
+			task.AssigneeUserID = &emp.UserID
+		}
+		tasks = append(tasks, task)
+	}
+	if len(tasks) > 0 {
+		if err := tx.Create(&tasks).Error; err != nil {
+			return nil, err
+		}
+	}
+	return tasks, nil
+}
+
+func optional(id uint) *uint {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
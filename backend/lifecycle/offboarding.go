@@ -0,0 +1,267 @@
+package lifecycle
+
+import (
+	"errors"
+	"time"
+
+	"peoplesoft/employment"
+	"peoplesoft/models"
+	"peoplesoft/org"
+
+	"gorm.io/gorm"
+)
+
+// ErrAlreadyLeft is returned when an offboarding can no longer be cancelled.
+var ErrAlreadyLeft = errors.New("employee has already left")
+
+// Exit describes an employee's departure.
+type Exit struct {
+	NoticeDate     time.Time // zero: today
+	LastWorkingDay time.Time
+	ReasonCode     string
+	Note           string
+	SuccessorID    *uint // takes over direct reports; nil: the employee's manager
+	TemplateID     uint  // offboarding checklist; 0 uses the default one
+}
+
+// Offboard starts emp's exit: it records the offboarding, moves the employee
+// to on_notice on the notice date and to terminated the day after their last
+// working day, and starts the offboarding checklist. A last working day in the
+// past completes the exit at once.
+func Offboard(tx *gorm.DB, emp *models.Employee, x Exit, actorID uint) (*models.Offboarding, []models.LifecycleTask, error) {
+	notice := employment.DateOf(x.NoticeDate)
+	if x.NoticeDate.IsZero() {
+		notice = employment.Today()
+	}
+	off := models.Offboarding{
+		EmployeeID:     emp.ID,
+		NoticeDate:     notice,
+		LastWorkingDay: employment.DateOf(x.LastWorkingDay),
+		ReasonCode:     x.ReasonCode,
+		Note:           x.Note,
+		SuccessorID:    x.SuccessorID,
+		Status:         OffboardingPending,
+		CreatedByID:    optional(actorID),
+	}
+	if err := tx.Create(&off).Error; err != nil {
+		return nil, nil, err
+	}
+	tasks, err := StartChecklist(tx, emp, KindOffboarding, x.TemplateID, off.LastWorkingDay, &off.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	onNotice := employment.LifecycleOnNotice
+	noticeRec, err := employment.Record(tx, emp, employment.Change{
+		Type:          employment.TypeStatusChange,
+		ReasonCode:    x.ReasonCode,
+		Note:          x.Note,
+		EffectiveDate: notice,
+		Status:        &onNotice,
+	}, actorID)
+	if err != nil {
+		return nil, nil, err
+	}
+	// Recording the termination may complete the exit (and update off) right away.
+	terminated := employment.LifecycleTerminated
+	exitRec, err := employment.Record(tx, emp, employment.Change{
+		Type:          employment.TypeTermination,
+		ReasonCode:    x.ReasonCode,
+		Note:          x.Note,
+		EffectiveDate: off.LastWorkingDay.AddDate(0, 0, 1),
+		Status:        &terminated,
+	}, actorID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	links := map[string]interface{}{}
+	if noticeRec != nil {
+		links["notice_change_id"] = noticeRec.ID
+	}
+	if exitRec != nil {
+		links["exit_change_id"] = exitRec.ID
+	}
+	if len(links) > 0 {
+		if err := tx.Model(&off).Updates(links).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := tx.First(&off, off.ID).Error; err != nil {
+		return nil, nil, err
+	}
+	return &off, tasks, nil
+}
+
+// CancelOffboarding withdraws a pending exit: scheduled status changes are
+// cancelled, a notice already in effect is reverted to the previous status and
+// the remaining checklist tasks are skipped.
+func CancelOffboarding(tx *gorm.DB, emp *models.Employee, off *models.Offboarding, actorID uint) error {
+	if off.Status != OffboardingPending || emp.Status == employment.LifecycleTerminated {
+		return ErrAlreadyLeft
+	}
+	if off.ExitChangeID != nil {
+		if err := employment.Cancel(tx, emp.ID, *off.ExitChangeID); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+	if off.NoticeChangeID != nil {
+		var rec models.EmploymentChange
+		if err := tx.First(&rec, *off.NoticeChangeID).Error; err != nil {
+			return err
+		}
+		switch rec.Status {
+		case employment.StatusScheduled:
+			if err := employment.Cancel(tx, emp.ID, rec.ID); err != nil {
+				return err
+			}
+		case employment.StatusApplied:
+			prev := employment.LifecycleActive
+			if s, err := employment.StateAsOf(tx, emp.ID, rec.EffectiveDate.AddDate(0, 0, -1)); err != nil {
+				return err
+			} else if s != nil && s.Status != employment.LifecycleOnNotice {
+				prev = s.Status
+			}
+			if _, err := employment.Record(tx, emp, employment.Change{
+				Type:       employment.TypeStatusChange,
+				ReasonCode: "notice_withdrawn",
+				Status:     &prev,
+			}, actorID); err != nil {
+				return err
+			}
+		}
+	}
+	if err := tx.Model(&models.LifecycleTask{}).
+		Where("offboarding_id = ? AND status = ?", off.ID, TaskOpen).
+		Update("status", TaskSkipped).Error; err != nil {
+		return err
+	}
+	off.Status = OffboardingCancelled
+	return tx.Model(off).Update("status", OffboardingCancelled).Error
+}
+
+// ReassignReports moves emp's current direct reports to the successor of emp's
+// pending offboarding, or else to emp's own manager, as when an exit takes
+// effect, and returns how many moved. It is for employees removed without
+// going through an exit.
+func ReassignReports(tx *gorm.DB, emp *models.Employee, actorID uint) (int, error) {
+	successor := emp.ManagerID
+	var off models.Offboarding
+	err := tx.Where("employee_id = ? AND status = ?", emp.ID, OffboardingPending).
+		Order("id desc").First(&off).Error
+	switch {
+	case err == nil && off.SuccessorID != nil:
+		successor = off.SuccessorID
+	case err != nil && !errors.Is(err, gorm.ErrRecordNotFound):
+		return 0, err
+	}
+	return handOverReports(tx, emp, successor, actorID)
+}
+
+// handOverReports records a manager change for each current direct report of
+// emp to successor. A report that is the successor, or would end up managing
+// themselves through it, goes to emp's manager instead. It returns the number
+// of reports.
+func handOverReports(tx *gorm.DB, emp *models.Employee, successor *uint, actorID uint) (int, error) {
+	var reports []models.Employee
+	if err := tx.Where("manager_id = ? AND status <> ?", emp.ID, employment.LifecycleTerminated).
+		Find(&reports).Error; err != nil {
+		return 0, err
+	}
+	for i := range reports {
+		r := &reports[i]
+		to := successor
+		if to != nil && *to == r.ID {
+			to = emp.ManagerID
+		}
+		if to != nil {
+			cycle, err := org.WouldCreateCycle(tx, r.ID, *to)
+			if err != nil {
+				return 0, err
+			}
+			if cycle {
+				to = emp.ManagerID
+			}
+		}
+		if _, err := employment.Record(tx, r, employment.Change{
+			Type:       employment.TypeManagerChange,
+			ReasonCode: "manager_departure",
+			SetManager: true,
+			ManagerID:  to,
+		}, actorID); err != nil {
+			return 0, err
+		}
+	}
+	return len(reports), nil
+}
+
+// finalizeExit runs when emp's termination takes effect. Direct reports move
+// to the successor (or the leaver's manager), which also routes their pending
+// leave and goal approvals there; department headships, open goals the leaver
+// assigned and their open checklist tasks pass to the successor; the account
+// is deactivated and every session revoked.
+func finalizeExit(tx *gorm.DB, emp *models.Employee) error {
+	var off models.Offboarding
+	err := tx.Where("employee_id = ? AND status = ?", emp.ID, OffboardingPending).
+		Order("id desc").First(&off).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	successor := emp.ManagerID
+	if off.SuccessorID != nil {
+		successor = off.SuccessorID
+	}
+	var actorID uint
+	if off.CreatedByID != nil {
+		actorID = *off.CreatedByID
+	}
+
+	reassigned, err := handOverReports(tx, emp, successor, actorID)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Model(&models.Department{}).Where("head_employee_id = ?", emp.ID).
+		Update("head_employee_id", successor).Error; err != nil {
+		return err
+	}
+	var successorUserID *uint
+	if successor != nil {
+		var s models.Employee
+		if err := tx.Select("user_id").First(&s, *successor).Error; err == nil {
+			successorUserID = &s.UserID
+		}
+	}
+	if err := tx.Model(&models.Goal{}).
+		Where("assigned_by_id = ? AND status NOT IN ?", emp.UserID, []string{"approved", "archived"}).
+		Update("assigned_by_id", successorUserID).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.LifecycleTask{}).
+		Where("assignee_user_id = ? AND status = ? AND employee_id <> ?", emp.UserID, TaskOpen, emp.ID).
+		Update("assignee_user_id", successorUserID).Error; err != nil {
+		return err
+	}
+
+	// Access ends with the employment
+	if err := tx.Model(&models.User{}).Where("id = ?", emp.UserID).Updates(map[string]interface{}{
+		"active":          false,
+		"session_version": gorm.Expr("session_version + 1"),
+	}).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", emp.UserID).
+		Update("revoked_at", time.Now()).Error; err != nil {
+		return err
+	}
+
+	if off.ID == 0 {
+		return nil
+	}
+	return tx.Model(&off).Updates(map[string]interface{}{
+		"status":             OffboardingCompleted,
+		"completed_at":       time.Now(),
+		"reassigned_reports": reassigned,
+	}).Error
+}
@@ -6,11 +6,19 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 
+	"peoplesoft/audit"
+	"peoplesoft/authz"
 	"peoplesoft/config"
 	"peoplesoft/controllers"
+	"peoplesoft/employment"
+	"peoplesoft/lifecycle"
 	"peoplesoft/middleware"
 	"peoplesoft/models"
+	"peoplesoft/oidc"
 	"peoplesoft/routes"
+	"peoplesoft/search"
+	"peoplesoft/signing"
+	"peoplesoft/storage"
 	"peoplesoft/utils"
 )
 
@@ -18,8 +26,20 @@ func main() {
 	// Load environment variables
 	_ = godotenv.Load()
 
-	// Initialize Auth0
-	utils.InitAuth0()
+	// Register OIDC identity providers (OIDC_PROVIDERS, plus Auth0 when AUTH0_DOMAIN is set)
+	if err := oidc.Init(); err != nil {
+		log.Fatalf("OIDC init failed: %v", err)
+	}
+
+	// Initialize mail sender (password reset emails)
+	if err := utils.InitMailer(); err != nil {
+		log.Fatalf("Mailer init failed: %v", err)
+	}
+
+	// Employee document store (local directory or S3-compatible bucket)
+	if err := storage.Init(); err != nil {
+		log.Fatalf("Document storage init failed: %v", err)
+	}
 
 	// Connect to database
 	if err := config.ConnectDatabase(); err != nil {
@@ -29,7 +49,41 @@ func main() {
 	// Auto migrate ALL models (including PMS models)
 	if err := config.DB.AutoMigrate(
 		&models.User{},
+		&models.RefreshToken{},
+		&models.SigningKey{},
+		&models.UserIdentity{},
+		&models.OIDCLogin{},
+		&models.SCIMToken{},
+		&models.ServiceAccount{},
+		&models.APIKey{},
+		&models.PasswordResetToken{},
+		&models.MFARecoveryCode{},
+		&models.LoginAttempt{},
+		&models.AccountLockout{},
+		&models.ImpersonationSession{},
+		&models.ImpersonationRequest{},
+		&models.AuditEvent{},
+		&models.Permission{},
+		&models.Role{},
+		&models.UserRole{},
+		&models.AccessScope{},
 		&models.Employee{},
+		&models.EmploymentChange{},
+		&models.ChecklistTemplate{},
+		&models.ChecklistTemplateTask{},
+		&models.LifecycleTask{},
+		&models.Offboarding{},
+		&models.CustomField{},
+		&models.EmployeeFieldValue{},
+		&models.Document{},
+		&models.Skill{},
+		&models.EmployeeSkill{},
+		&models.SkillRequirement{},
+		&models.EmployeeProfile{},
+		&models.ProfileChangeRequest{},
+		&models.HolidayCalendar{},
+		&models.Holiday{},
+		&models.OptionalHolidayChoice{},
 		&models.Department{},
 		&models.Leave{},
 		&models.LeaveAllocation{},
@@ -42,11 +96,47 @@ func main() {
 		log.Fatalf("AutoMigrate failed: %v", err)
 	}
 	
+	// Emails used to be unique across deleted accounts too; the partial index
+	// idx_users_email_live replaces that constraint
+	if err := config.DB.Exec("ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key, DROP CONSTRAINT IF EXISTS uni_users_email").Error; err != nil {
+		log.Fatalf("Migrating users email index failed: %v", err)
+	}
+
 	log.Println("✅ Database migrations completed successfully")
 
+	// Make the audit log append-only (rejects UPDATE/DELETE/TRUNCATE)
+	if err := audit.Init(); err != nil {
+		log.Fatalf("Audit init failed: %v", err)
+	}
+
+	// Directory search index (tsvector + trigram), kept current by triggers
+	if err := search.Init(); err != nil {
+		log.Fatalf("Search index init failed: %v", err)
+	}
+
+	// Seed default permissions and the hr/manager/employee roles
+	if err := authz.Seed(); err != nil {
+		log.Fatalf("RBAC seed failed: %v", err)
+	}
+
+	// Load (or create) asymmetric token signing keys and rotate them on schedule
+	if err := signing.Init(); err != nil {
+		log.Fatalf("Signing key init failed: %v", err)
+	}
+	signing.Start()
+
+	// Apply future-dated job, department, manager and status changes when they
+	// come due; terminations hand over reports and revoke access
+	lifecycle.Init()
+	employment.Start()
+
 	// Initialize Gin router
 	r := gin.Default()
 	r.Use(config.CorsMiddleware())
+	r.Use(middleware.AuditTrail())
+
+	// Public keys for verifying our access tokens
+	r.GET("/.well-known/jwks.json", controllers.JWKS)
 
 	// ========================================
 	// PUBLIC ROUTES (No Authentication)
@@ -56,6 +146,23 @@ func main() {
 		auth.POST("/register", controllers.Register)
 		auth.POST("/login", controllers.Login)
 		auth.POST("/auth0-login", controllers.Auth0Login)
+
+		// Generic OIDC: authorization code + PKCE, then a single-use handoff code
+		auth.GET("/oidc/providers", controllers.ListOIDCProviders)
+		auth.GET("/oidc/:provider/login", controllers.OIDCLoginStart)
+		auth.GET("/oidc/:provider/callback", controllers.OIDCCallback)
+		auth.POST("/oidc/exchange", controllers.OIDCExchange)
+		auth.POST("/oidc/:provider/link", middleware.AuthRequired(), controllers.OIDCLinkStart)
+		auth.POST("/refresh", controllers.Refresh)
+		auth.POST("/logout", controllers.Logout)
+		auth.POST("/forgot-password", controllers.ForgotPassword)
+		auth.POST("/reset-password", controllers.ResetPassword)
+		auth.POST("/change-password", middleware.AuthRequired(), controllers.ChangePassword)
+
+		// MFA second step and enrollment during login (mfa_token from /login)
+		auth.POST("/mfa/verify", controllers.MFAVerify)
+		auth.POST("/mfa/setup", controllers.MFASetup)
+		auth.POST("/mfa/activate", controllers.MFAActivate)
 	}
 
 	// ========================================
@@ -102,23 +209,23 @@ func main() {
 		// ========== HR FUNCTIONS ==========
 		
 		// HR assigns goals to managers
-		pms.POST("/hr/assign-goals", middleware.RoleMiddleware("hr"), controllers.HRAssignGoalsToManager)
+		pms.POST("/hr/assign-goals", middleware.RequirePermission(authz.GoalAssignMgr), controllers.HRAssignGoalsToManager)
 		
 		// ========== MANAGER FUNCTIONS ==========
 		
 		// Manager assigns goals to employees
-		pms.POST("/manager/assign-goals", middleware.RoleMiddleware("manager"), controllers.ManagerAssignGoalsToEmployee)
+		pms.POST("/manager/assign-goals", middleware.RequirePermission(authz.GoalAssign), controllers.ManagerAssignGoalsToEmployee)
 		
 		// View employee goals (manager/hr only)
-		pms.GET("/manager/goals", middleware.RoleMiddleware("manager", "hr"), controllers.ManagerListEmployeeGoals)
+		pms.GET("/manager/goals", middleware.RequirePermission(authz.GoalApprove), controllers.ManagerListEmployeeGoals)
 		
 		// ========== APPROVALS & REVIEWS ==========
 		
 		// Get pending approvals (manager/hr only)
-		pms.GET("/pending-approvals", middleware.RoleMiddleware("manager", "hr"), controllers.GetPendingApprovals)
+		pms.GET("/pending-approvals", middleware.RequirePermission(authz.GoalApprove), controllers.GetPendingApprovals)
 		
 		// Approve goal and create review
-		pms.POST("/reviews/:goal_id/approve", middleware.RoleMiddleware("manager", "hr"), controllers.ApproveGoalAndReview)
+		pms.POST("/reviews/:goal_id/approve", middleware.RequirePermission(authz.GoalApprove), controllers.ApproveGoalAndReview)
 		
 		// View my reviews
 		pms.GET("/my-reviews", controllers.MyReviews)
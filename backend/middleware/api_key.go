@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"peoplesoft/authz"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"peoplesoft/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyPrefix marks service account keys so AuthRequired can tell them from JWTs.
+const APIKeyPrefix = "psk_"
+
+// lastUsedResolution limits last_used_at writes to one per key per minute.
+const lastUsedResolution = time.Minute
+
+// authenticateAPIKey validates a service account key and fills the context the
+// same way a user login does, except userID is 0 and permissions come from the
+// key's scopes. It writes the error response itself and returns false on failure.
+func authenticateAPIKey(c *gin.Context, raw string) bool {
+	var key models.APIKey
+	err := config.DB.Where("key_hash = ? AND revoked_at IS NULL", utils.HashToken(raw)).First(&key).Error
+	now := time.Now()
+	if err != nil || now.After(key.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired API key"})
+		c.Abort()
+		return false
+	}
+
+	var account models.ServiceAccount
+	if err := config.DB.First(&account, key.ServiceAccountID).Error; err != nil || !account.Active {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "service account is disabled"})
+		c.Abort()
+		return false
+	}
+
+	perms, allowed := authz.APIKeyAccess(key.ScopeList(), c.Request.Method, c.FullPath())
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "API key scopes do not allow this endpoint"})
+		c.Abort()
+		return false
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > lastUsedResolution {
+		config.DB.Model(&key).Updates(map[string]interface{}{"last_used_at": now, "last_used_ip": c.ClientIP()})
+	}
+
+	c.Set("userID", uint(0))
+	c.Set("role", "")
+	c.Set("email", "")
+	c.Set("permissions", perms)
+	c.Set("serviceAccountID", account.ID)
+	c.Set("apiKeyID", key.ID)
+	return true
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"regexp"
+
+	"peoplesoft/audit"
+	"peoplesoft/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the request id in and out; a well-formed incoming
+// value (e.g. from a load balancer) is kept, otherwise one is generated.
+const RequestIDHeader = "X-Request-ID"
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{8,64}$`)
+
+// unauditedRoutes exchange credentials for sessions; they are already recorded
+// in login_attempts and refresh_tokens.
+var unauditedRoutes = map[string]bool{
+	"/api/auth/login":         true,
+	"/api/auth/auth0-login":   true,
+	"/api/auth/refresh":       true,
+	"/api/auth/logout":        true,
+	"/api/auth/mfa/verify":    true,
+	"/api/auth/oidc/exchange": true,
+}
+
+// AuditTrail tags every request with a request id and, once the handler has
+// run, records each successful POST/PUT/PATCH/DELETE in the audit log unless
+// the handler recorded a more detailed entity event itself.
+func AuditTrail() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id, _, _ = utils.NewOpaqueToken()
+		}
+		c.Set("requestID", id)
+		c.Header(RequestIDHeader, id)
+
+		c.Next()
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			return
+		}
+		if c.Writer.Status() >= http.StatusBadRequest || unauditedRoutes[c.FullPath()] {
+			return
+		}
+		audit.RecordRequest(c)
+	}
+}
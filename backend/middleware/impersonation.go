@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"peoplesoft/authz"
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"peoplesoft/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EndImpersonationRoute is the only non-read route an impersonation token may call.
+const EndImpersonationRoute = "/api/impersonation/end"
+
+// runImpersonated serves a request made with an impersonation token. The
+// handlers see the impersonated user; "actorID", "actorEmail" and
+// "impersonationID" name the real caller. Anything other than GET/HEAD/OPTIONS
+// is refused, and every request, allowed or not, is recorded against the session.
+func runImpersonated(c *gin.Context, claims *utils.Claims, subject models.User) {
+	var session models.ImpersonationSession
+	err := config.DB.Where("id = ? AND actor_id = ? AND subject_id = ? AND ended_at IS NULL",
+		claims.ImpersonationID, claims.Actor.UserID, subject.ID).First(&session).Error
+	if err != nil || time.Now().After(session.ExpiresAt) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "impersonation session has ended"})
+		c.Abort()
+		return
+	}
+
+	// The actor must still be allowed to impersonate right now
+	var actor models.User
+	if err := config.DB.First(&actor, session.ActorID).Error; err != nil || !actor.Active ||
+		!authz.HasPermission(actor.ID, authz.Impersonate) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "impersonation no longer permitted"})
+		c.Abort()
+		return
+	}
+
+	c.Set("actorID", actor.ID)
+	c.Set("actorEmail", actor.Email)
+	c.Set("impersonationID", session.ID)
+	c.Header("X-Impersonated-By", actor.Email)
+
+	blocked := false
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		blocked = c.FullPath() != EndImpersonationRoute
+	}
+	if blocked {
+		c.JSON(http.StatusForbidden, gin.H{"error": "changes are not allowed while impersonating"})
+		c.Abort()
+	} else {
+		c.Next()
+	}
+
+	config.DB.Create(&models.ImpersonationRequest{
+		SessionID: session.ID,
+		ActorID:   actor.ID,
+		SubjectID: subject.ID,
+		Method:    c.Request.Method,
+		Path:      utils.Truncate(c.Request.URL.Path, 255),
+		Query:     utils.Truncate(c.Request.URL.RawQuery, 500),
+		Status:    c.Writer.Status(),
+		Blocked:   blocked,
+		IP:        c.ClientIP(),
+	})
+}
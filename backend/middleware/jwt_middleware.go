@@ -1,28 +1,19 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
-	"os"
 	"peoplesoft/config"
 	"peoplesoft/models"
+	"peoplesoft/utils"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-type Claims struct {
-	Email string `json:"email"`
-	Role  string `json:"role"`
-	jwt.RegisteredClaims
-}
-
-// AuthRequired validates JWT token and sets user context
+// AuthRequired validates a JWT access token or a service account API key and sets the caller context
 func AuthRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		auth := c.GetHeader("Authorization")
-		fmt.Println("auth is ", auth)
 
 		if auth == "" || !strings.HasPrefix(auth, "Bearer ") {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "missing token"})
@@ -32,13 +23,16 @@ func AuthRequired() gin.HandlerFunc {
 
 		tokenStr := strings.TrimPrefix(auth, "Bearer ")
 
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
+		if strings.HasPrefix(tokenStr, APIKeyPrefix) {
+			if authenticateAPIKey(c, tokenStr) {
+				c.Next()
+			}
+			return
+		}
 
-		if err != nil || !token.Valid {
-			fmt.Println("Token error:", err)
+		// Algorithm, signature, issuer, audience and expiry are all checked here
+		claims, err := utils.ValidateToken(tokenStr)
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 			c.Abort()
 			return
@@ -52,16 +46,36 @@ func AuthRequired() gin.HandlerFunc {
 			return
 		}
 
+		if !user.Active {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "account is deactivated"})
+			c.Abort()
+			return
+		}
+
+		// Sessions revoked (logout everywhere, role change, deletion) bump the version
+		if claims.SessionVersion != user.SessionVersion {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "session revoked"})
+			c.Abort()
+			return
+		}
+
 		// Put into context
 		c.Set("email", claims.Email)
 		c.Set("role", claims.Role)
 		c.Set("userID", user.ID)
 
+		if claims.Actor != nil {
+			runImpersonated(c, claims, user)
+			return
+		}
+
 		c.Next()
 	}
 }
 
 // RoleMiddleware checks if user has one of the allowed roles
+//
+// Deprecated: routes should use RequirePermission so roles stay configurable.
 func RoleMiddleware(allowedRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userRole := c.GetString("role")
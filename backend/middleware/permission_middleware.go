@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"peoplesoft/authz"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission allows the request when the caller holds at least one of the permissions.
+func RequirePermission(perms ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, p := range perms {
+			if authz.Can(c, p) {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "Access denied. Required permission: " + strings.Join(perms, " or "),
+		})
+		c.Abort()
+	}
+}
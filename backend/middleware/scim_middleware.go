@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"peoplesoft/scim"
+	"peoplesoft/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SCIMAuth accepts only SCIM bearer tokens (not user access tokens) and puts
+// the token id in the context as "scimTokenID" and the provider it provisions
+// for as "scimProvider".
+func SCIMAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		auth := c.GetHeader("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") {
+			c.Header("WWW-Authenticate", `Bearer realm="scim"`)
+			scim.Error(c, http.StatusUnauthorized, "", "missing bearer token")
+			return
+		}
+
+		var token models.SCIMToken
+		err := config.DB.Where("token_hash = ? AND revoked_at IS NULL", utils.HashToken(strings.TrimPrefix(auth, "Bearer "))).
+			First(&token).Error
+		if err != nil || (token.ExpiresAt != nil && time.Now().After(*token.ExpiresAt)) {
+			c.Header("WWW-Authenticate", `Bearer realm="scim", error="invalid_token"`)
+			scim.Error(c, http.StatusUnauthorized, "", "invalid or expired token")
+			return
+		}
+
+		config.DB.Model(&token).Update("last_used_at", time.Now())
+		c.Set("scimTokenID", token.ID)
+		c.Set("scimProvider", token.Provider)
+		c.Next()
+	}
+}
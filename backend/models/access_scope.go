@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// AccessScope grants a user access to every employee in a department, on top of
+// their own reporting line. Used for department-scoped HR partners; users holding
+// employee.scope_all do not need scopes.
+type AccessScope struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	UserID       uint      `gorm:"not null;uniqueIndex:idx_access_scope;constraint:OnDelete:CASCADE" json:"user_id"`
+	DepartmentID uint      `gorm:"not null;uniqueIndex:idx_access_scope" json:"department_id"`
+	CreatedAt    time.Time `json:"created_at"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEvent is one entry of the append-only audit log. Rows are never
+// updated or deleted (a database trigger rejects it) and each row's Hash
+// covers its content plus PrevHash, so any edit or removal breaks the chain.
+type AuditEvent struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+
+	// Who: the human behind the request, the user they acted as while
+	// impersonating, or the machine credential used.
+	ActorID          *uint  `gorm:"index" json:"actor_id"`
+	ActorEmail       string `gorm:"size:255" json:"actor_email"`
+	OnBehalfOfID     *uint  `json:"on_behalf_of_id"`
+	ServiceAccountID *uint  `json:"service_account_id"`
+	SCIMTokenID      *uint  `json:"scim_token_id"`
+
+	// What: Action is "<entity>.<verb>" (e.g. leave.approve) or "http.<method>"
+	// for requests no handler described in detail.
+	Action     string   `gorm:"size:80;index;not null" json:"action"`
+	EntityType string   `gorm:"size:40;index:idx_audit_entity" json:"entity_type"`
+	EntityID   string   `gorm:"size:64;index:idx_audit_entity" json:"entity_id"`
+	Before     JSONText `gorm:"type:text" json:"before"`
+	After      JSONText `gorm:"type:text" json:"after"`
+	Changes    JSONText `gorm:"type:text" json:"changes"`
+
+	// Where from
+	RequestID string `gorm:"size:64;index" json:"request_id"`
+	Method    string `gorm:"size:10" json:"method"`
+	Path      string `gorm:"size:255" json:"path"`
+	Status    int    `json:"status"`
+	IP        string `gorm:"size:64" json:"ip"`
+	UserAgent string `gorm:"size:255" json:"user_agent"`
+
+	PrevHash string `gorm:"size:64;not null" json:"prev_hash"`
+	Hash     string `gorm:"size:64;uniqueIndex;not null" json:"hash"`
+}
+
+// JSONText is a JSON document stored verbatim in a text column (so its bytes,
+// and therefore its hash, survive the round trip) and emitted as raw JSON.
+type JSONText string
+
+func (j JSONText) MarshalJSON() ([]byte, error) {
+	if j == "" || !json.Valid([]byte(j)) {
+		return []byte("null"), nil
+	}
+	return []byte(j), nil
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// CustomField is an HR-defined employee attribute (T-shirt size, employee code,
+// emergency contact, ...). Values are stored per employee in EmployeeFieldValue.
+type CustomField struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	Key          string    `gorm:"size:60;not null;uniqueIndex" json:"key"`
+	Label        string    `gorm:"size:120;not null" json:"label"`
+	Description  string    `json:"description"`
+	Type         string    `gorm:"size:10;not null" json:"type"` // text, number, date, enum
+	Options      string    `gorm:"type:text" json:"-"`           // enum values, one per line
+	Required     bool      `json:"required"`
+	MaxLength    *int      `json:"max_length"`                                    // text
+	Pattern      string    `gorm:"size:255" json:"pattern"`                       // text, regular expression
+	Min          *float64  `json:"min"`                                           // number
+	Max          *float64  `json:"max"`                                           // number
+	Visibility   string    `gorm:"size:10;not null;default:hr" json:"visibility"` // self, manager, hr
+	SelfEditable bool      `json:"self_editable"`                                 // the employee may set it (self visibility only)
+	Position     int       `json:"position"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// OptionList splits Options.
+func (f CustomField) OptionList() []string {
+	if f.Options == "" {
+		return nil
+	}
+	return strings.Split(f.Options, "\n")
+}
+
+// EmployeeFieldValue holds one employee's value for a custom field, normalised
+// to text (numbers in plain decimal, dates as YYYY-MM-DD).
+type EmployeeFieldValue struct {
+	ID         uint   `gorm:"primaryKey"`
+	EmployeeID uint   `gorm:"not null;uniqueIndex:idx_employee_field;constraint:OnDelete:CASCADE"`
+	FieldID    uint   `gorm:"not null;uniqueIndex:idx_employee_field;index"`
+	Value      string `gorm:"type:text;not null"`
+	UpdatedAt  time.Time
+}
@@ -1,6 +1,17 @@
 package models
 
+import "time"
+
+// Department is an organizational unit. Departments form a tree through
+// ParentID; HeadEmployeeID names the employee who leads it.
 type Department struct {
-	ID   uint   `gorm:"primaryKey"`
-	Name string `gorm:"size:100;not null"`
+	ID             uint    `gorm:"primaryKey" json:"id"`
+	Name           string  `gorm:"size:100;not null" json:"name"`
+	ParentID       *uint   `gorm:"index" json:"parent_id"`
+	HeadEmployeeID *uint   `gorm:"index" json:"head_employee_id"`
+	CostCenter     *string `gorm:"size:40;uniqueIndex" json:"cost_center"`
+	Description    string  `gorm:"size:500" json:"description"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
 }
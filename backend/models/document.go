@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Document is a file kept against an employee (offer letter, ID proof, visa,
+// signed policy, ...). The bytes live in the document store under StorageKey;
+// Checksum is the SHA-256 of the uploaded content and is verified on download.
+type Document struct {
+	ID           uint           `gorm:"primaryKey" json:"id"`
+	EmployeeID   uint           `gorm:"not null;index;constraint:OnDelete:CASCADE" json:"employee_id"`
+	Category     string         `gorm:"size:30;not null;index" json:"category"`
+	Title        string         `gorm:"size:200;not null" json:"title"`
+	FileName     string         `gorm:"size:255;not null" json:"file_name"`
+	ContentType  string         `gorm:"size:100" json:"content_type"`
+	Size         int64          `json:"size"`
+	Checksum     string         `gorm:"size:64;not null" json:"checksum_sha256"`
+	StorageKey   string         `gorm:"size:255;not null;uniqueIndex" json:"-"`
+	ExpiresOn    *time.Time     `gorm:"type:date;index" json:"expires_on"` // visas, permits, certificates
+	UploadedByID *uint          `json:"uploaded_by_id"`
+	CreatedAt    time.Time      `json:"created_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
+}
@@ -1,16 +1,22 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Employee struct {
-	ID           uint      `gorm:"primaryKey" json:"id"`
-	UserID       uint      `gorm:"not null" json:"user_id"`
-	Designation  string    `gorm:"size:100" json:"designation"`
-	DepartmentID uint      `json:"department_id"`
-	ManagerID    *uint     `json:"manager_id"`
-	Phone        string    `json:"phone"`
-	Location     string    `json:"location"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID           uint           `gorm:"primaryKey" json:"id"`
+	UserID       uint           `gorm:"not null" json:"user_id"`
+	Designation  string         `gorm:"size:100" json:"designation"`
+	DepartmentID uint           `json:"department_id"`
+	ManagerID    *uint          `json:"manager_id"`
+	Phone        string         `json:"phone"`
+	Location     string         `json:"location"`
+	Status       string         `gorm:"size:20;not null;default:active;index" json:"status"` // hired, probation, active, on_notice, terminated
+	CreatedAt    time.Time      `json:"created_at"`
+	DeletedAt    gorm.DeletedAt `gorm:"index" json:"-"`
 
 	User User `gorm:"constraint:OnDelete:CASCADE;" json:"-"`
 }
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// EmploymentChange is one effective-dated change to an employee's job,
+// department, manager or lifecycle status. Nil fields (and SetManager=false) leave that
+// attribute as it was; the employees row always holds the state as of today.
+type EmploymentChange struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	EmployeeID    uint      `gorm:"not null;index:idx_employment_emp_date;constraint:OnDelete:CASCADE" json:"employee_id"`
+	EffectiveDate time.Time `gorm:"type:date;not null;index:idx_employment_emp_date" json:"effective_date"`
+	ChangeType    string    `gorm:"size:30;not null" json:"change_type"` // baseline, hire, promotion, transfer, manager_change, ...
+	ReasonCode    string    `gorm:"size:40" json:"reason_code"`
+	Note          string    `gorm:"size:500" json:"note"`
+
+	Designation    *string `gorm:"size:100" json:"designation"`
+	DepartmentID   *uint   `json:"department_id"`
+	SetManager     bool    `gorm:"not null;default:false" json:"set_manager"`
+	ManagerID      *uint   `json:"manager_id"`                     // only meaningful with SetManager; nil removes the manager
+	EmployeeStatus *string `gorm:"size:20" json:"employee_status"` // lifecycle status: hired, probation, active, on_notice, terminated
+
+	// scheduled (future dated), applied, cancelled or failed (could not be applied)
+	Status      string     `gorm:"size:20;not null;index" json:"status"`
+	AppliedAt   *time.Time `json:"applied_at"`
+	Error       string     `gorm:"size:255" json:"error,omitempty"`
+	CreatedByID *uint      `json:"created_by_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
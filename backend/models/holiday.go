@@ -0,0 +1,55 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// HolidayCalendar is the set of public holidays and weekend days of one or more
+// locations. Employees follow the calendar listing their location, or the
+// default calendar when none does.
+type HolidayCalendar struct {
+	ID                  uint      `gorm:"primaryKey" json:"id"`
+	Name                string    `gorm:"size:100;not null;uniqueIndex" json:"name"`
+	Country             string    `gorm:"size:60" json:"country"`
+	Locations           string    `gorm:"type:text" json:"-"`                                // employee locations, one per line
+	WeekendDays         string    `gorm:"size:80;not null;default:saturday,sunday" json:"-"` // comma separated weekday names
+	IsDefault           bool      `json:"is_default"`
+	MaxOptionalHolidays int       `json:"max_optional_holidays"` // optional holidays an employee may pick per year
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+// LocationList splits Locations.
+func (c HolidayCalendar) LocationList() []string {
+	if c.Locations == "" {
+		return nil
+	}
+	return strings.Split(c.Locations, "\n")
+}
+
+// WeekendList splits WeekendDays.
+func (c HolidayCalendar) WeekendList() []string {
+	if c.WeekendDays == "" {
+		return nil
+	}
+	return strings.Split(c.WeekendDays, ",")
+}
+
+// Holiday is a day off in a calendar. Optional holidays only count as days off
+// for employees who picked them (OptionalHolidayChoice).
+type Holiday struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	CalendarID uint      `gorm:"not null;index:idx_holiday_calendar_date" json:"calendar_id"`
+	Date       time.Time `gorm:"type:date;not null;index:idx_holiday_calendar_date" json:"date"`
+	Name       string    `gorm:"size:150;not null" json:"name"`
+	Optional   bool      `json:"optional"`
+	UID        string    `gorm:"size:255" json:"-"` // iCalendar UID of imported holidays
+}
+
+// OptionalHolidayChoice is an optional holiday an employee takes off.
+type OptionalHolidayChoice struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	EmployeeID uint      `gorm:"not null;uniqueIndex:idx_employee_holiday;constraint:OnDelete:CASCADE" json:"employee_id"`
+	HolidayID  uint      `gorm:"not null;uniqueIndex:idx_employee_holiday;index" json:"holiday_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
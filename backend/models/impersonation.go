@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// ImpersonationSession is one "view as" grant: an HR actor acting as a subject
+// user until ExpiresAt or until it is ended.
+type ImpersonationSession struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	ActorID   uint       `gorm:"index;not null" json:"actor_id"`
+	SubjectID uint       `gorm:"index;not null" json:"subject_id"`
+	Reason    string     `gorm:"size:500;not null" json:"reason"`
+	IP        string     `gorm:"size:64" json:"ip"`
+	UserAgent string     `gorm:"size:255" json:"user_agent"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+	CreatedAt time.Time  `gorm:"index" json:"created_at"`
+}
+
+// ImpersonationRequest records every API request made with an impersonation
+// token, including the ones rejected because they would change data.
+type ImpersonationRequest struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	SessionID uint      `gorm:"index;not null" json:"session_id"`
+	ActorID   uint      `gorm:"index;not null" json:"actor_id"`
+	SubjectID uint      `gorm:"not null" json:"subject_id"`
+	Method    string    `gorm:"size:10;not null" json:"method"`
+	Path      string    `gorm:"size:255;not null" json:"path"`
+	Query     string    `gorm:"size:500" json:"query"`
+	Status    int       `json:"status"`
+	Blocked   bool      `gorm:"not null" json:"blocked"`
+	IP        string    `gorm:"size:64" json:"ip"`
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
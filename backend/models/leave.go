@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Leave struct {
 	ID         uint `gorm:"primaryKey"`
@@ -10,6 +14,8 @@ type Leave struct {
 	Type       string
 	Reason     string
 	Status     string `gorm:"default:pending"` // pending / approved / rejected
+	Days       int    // working days charged to the allocation
 	ApprovedBy *uint  // Nullable - set when approved/rejected
 	CreatedAt  time.Time
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
 }
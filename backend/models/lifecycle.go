@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// ChecklistTemplate is a configurable onboarding or offboarding checklist.
+// Starting a checklist for an employee copies its tasks into LifecycleTask rows.
+type ChecklistTemplate struct {
+	ID          uint                    `gorm:"primaryKey" json:"id"`
+	Name        string                  `gorm:"size:100;uniqueIndex;not null" json:"name"`
+	Kind        string                  `gorm:"size:20;not null;index" json:"kind"` // onboarding or offboarding
+	Description string                  `gorm:"size:500" json:"description"`
+	IsDefault   bool                    `gorm:"not null;default:false" json:"is_default"` // started automatically for its kind
+	Tasks       []ChecklistTemplateTask `gorm:"foreignKey:TemplateID;constraint:OnDelete:CASCADE" json:"tasks"`
+	CreatedAt   time.Time               `json:"created_at"`
+	UpdatedAt   time.Time               `json:"updated_at"`
+}
+
+// ChecklistTemplateTask is one task of a template. DueOffsetDays counts from
+// the start date (onboarding) or the last working day (offboarding).
+type ChecklistTemplateTask struct {
+	ID             uint   `gorm:"primaryKey" json:"id"`
+	TemplateID     uint   `gorm:"not null;index" json:"template_id"`
+	Position       int    `gorm:"not null;default:0" json:"position"`
+	Title          string `gorm:"size:200;not null" json:"title"`
+	Description    string `gorm:"size:1000" json:"description"`
+	Assignee       string `gorm:"size:20;not null" json:"assignee"` // hr, manager, it or employee
+	AssigneeUserID *uint  `json:"assignee_user_id"`                 // default person for hr and it tasks
+	DueOffsetDays  int    `gorm:"not null;default:0" json:"due_offset_days"`
+}
+
+// LifecycleTask is a checklist task for one employee's onboarding or offboarding.
+type LifecycleTask struct {
+	ID             uint       `gorm:"primaryKey" json:"id"`
+	EmployeeID     uint       `gorm:"not null;index;constraint:OnDelete:CASCADE" json:"employee_id"`
+	Kind           string     `gorm:"size:20;not null" json:"kind"` // onboarding or offboarding
+	TemplateID     *uint      `json:"template_id"`
+	OffboardingID  *uint      `gorm:"index" json:"offboarding_id"`
+	Title          string     `gorm:"size:200;not null" json:"title"`
+	Description    string     `gorm:"size:1000" json:"description"`
+	Assignee       string     `gorm:"size:20;not null" json:"assignee"`
+	AssigneeUserID *uint      `gorm:"index" json:"assignee_user_id"` // nil: open to anyone holding lifecycle.manage
+	DueDate        *time.Time `gorm:"type:date" json:"due_date"`
+	Status         string     `gorm:"size:20;not null;default:open;index" json:"status"` // open, done or skipped
+	Note           string     `gorm:"size:500" json:"note"`
+	CompletedAt    *time.Time `json:"completed_at"`
+	CompletedByID  *uint      `json:"completed_by_id"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// Offboarding is an employee's exit: notice is recorded as of NoticeDate and
+// the employee is terminated the day after LastWorkingDay, when their direct
+// reports move to SuccessorID (or their own manager) and their account is
+// deactivated.
+type Offboarding struct {
+	ID                uint       `gorm:"primaryKey" json:"id"`
+	EmployeeID        uint       `gorm:"not null;index;constraint:OnDelete:CASCADE" json:"employee_id"`
+	NoticeDate        time.Time  `gorm:"type:date;not null" json:"notice_date"`
+	LastWorkingDay    time.Time  `gorm:"type:date;not null" json:"last_working_day"`
+	ReasonCode        string     `gorm:"size:40" json:"reason_code"`
+	Note              string     `gorm:"size:500" json:"note"`
+	SuccessorID       *uint      `json:"successor_id"`                         // employee taking over direct reports
+	Status            string     `gorm:"size:20;not null;index" json:"status"` // pending, completed or cancelled
+	NoticeChangeID    *uint      `json:"notice_change_id"`                     // employment change to on_notice
+	ExitChangeID      *uint      `json:"exit_change_id"`                       // employment change to terminated
+	ReassignedReports int        `gorm:"not null;default:0" json:"reassigned_reports"`
+	CompletedAt       *time.Time `json:"completed_at"`
+	CreatedByID       *uint      `json:"created_by_id"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// LoginAttempt records every password/MFA login attempt for auditing and per-IP throttling.
+type LoginAttempt struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Email     string    `gorm:"size:255;index" json:"email"`
+	UserID    *uint     `gorm:"index" json:"user_id"`
+	IP        string    `gorm:"size:64;index" json:"ip"`
+	UserAgent string    `gorm:"size:255" json:"user_agent"`
+	Success   bool      `gorm:"not null" json:"success"`
+	Reason    string    `gorm:"size:40" json:"reason"` // ok | bad_credentials | bad_mfa_code | locked | ip_throttled | reset_requested
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// AccountLockout tracks consecutive failures per login identifier (normalized email).
+// It is keyed by email rather than user so unknown accounts behave exactly like real ones.
+type AccountLockout struct {
+	Email         string     `gorm:"primaryKey;size:255" json:"email"`
+	FailedCount   int        `gorm:"not null;default:0" json:"failed_count"`
+	LastFailureAt *time.Time `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// MFARecoveryCode is a single-use fallback for a lost authenticator. Only the
+// SHA-256 hash of the code is stored.
+type MFARecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"not null;index;constraint:OnDelete:CASCADE"`
+	CodeHash  string `gorm:"size:64;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
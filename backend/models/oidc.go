@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// UserIdentity links a user to an account at an external identity provider.
+// Once linked, logins match on (provider, subject) rather than on email.
+type UserIdentity struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"not null;index;constraint:OnDelete:CASCADE" json:"user_id"`
+	Provider    string     `gorm:"size:64;not null;uniqueIndex:idx_identity_provider_subject" json:"provider"`
+	Subject     string     `gorm:"size:255;not null;uniqueIndex:idx_identity_provider_subject" json:"subject"`
+	Email       string     `gorm:"size:255" json:"email"`
+	LastLoginAt *time.Time `json:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// OIDCLogin tracks one authorization-code login attempt. The state, nonce and
+// PKCE verifier are generated server-side; BindingHash ties the attempt to the
+// browser that started it (via a cookie). After a successful callback the row
+// carries a single-use handoff code the frontend trades for a session.
+type OIDCLogin struct {
+	ID           uint       `gorm:"primaryKey"`
+	StateHash    string     `gorm:"size:64;not null;uniqueIndex"`
+	Provider     string     `gorm:"size:64;not null"`
+	Nonce        string     `gorm:"size:128;not null"`
+	CodeVerifier string     `gorm:"size:128;not null"`
+	BindingHash  string     `gorm:"size:64;not null;default:''"`
+	LinkUserID   *uint      // set when a signed-in user links this provider to their account
+	ExpiresAt    time.Time  `gorm:"not null;index"`
+	CallbackAt   *time.Time // state consumed
+	UserID       *uint
+	HandoffHash  *string `gorm:"size:64;uniqueIndex"`
+	ExchangedAt  *time.Time
+	CreatedAt    time.Time
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// PasswordResetToken is a single-use, expiring token for the forgot-password flow.
+// Only the SHA-256 hash of the emailed token is stored.
+type PasswordResetToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"not null;index;constraint:OnDelete:CASCADE"`
+	TokenHash string    `gorm:"size:64;not null;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
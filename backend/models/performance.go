@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type Performance struct {
 	ID         uint `gorm:"primaryKey"`
@@ -10,5 +14,6 @@ type Performance struct {
 	Comments   string
 	ReviewerID uint
 	CreatedAt  time.Time
-	Score      float64 `json:"score"`
+	Score      float64        `json:"score"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
 }
@@ -1,6 +1,10 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type ReviewCycle struct {
 	ID          uint      `gorm:"primaryKey"`
@@ -34,6 +38,7 @@ type Goal struct {
     RatingValue *int                   // 5,4,3,2,1
 
     CreatedAt time.Time
+    DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 
@@ -44,6 +49,7 @@ type SelfAssessment struct {
 	Comments    string
 	Rating      *int
 	SubmittedAt time.Time
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
 }
 
 type ManagerReview struct {
@@ -55,4 +61,5 @@ type ManagerReview struct {
 	Comments   string
 	Status     string `gorm:"size:20;default:draft"`
 	ReviewedAt time.Time
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
 }
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// EmployeeProfile holds an employee's personal details, maintained by the
+// employee through profile change requests. The phone number stays on Employee.
+type EmployeeProfile struct {
+	EmployeeID               uint      `gorm:"primaryKey;autoIncrement:false;constraint:OnDelete:CASCADE" json:"employee_id"`
+	Address                  string    `gorm:"size:500" json:"address"`
+	EmergencyContactName     string    `gorm:"size:100" json:"emergency_contact_name"`
+	EmergencyContactPhone    string    `gorm:"size:30" json:"emergency_contact_phone"`
+	EmergencyContactRelation string    `gorm:"size:50" json:"emergency_contact_relation"`
+	BankAccountHolder        string    `gorm:"size:100" json:"bank_account_holder"`
+	BankAccountNumber        string    `gorm:"size:128" json:"bank_account_number"` // sealed, see utils.EncryptSecret
+	BankRoutingCode          string    `gorm:"size:128" json:"bank_routing_code"`   // sealed; IFSC, sort code, routing number, ...
+	UpdatedAt                time.Time `json:"updated_at"`
+}
+
+// ProfileChangeRequest is a change an employee made or asked for on their own
+// profile. Changes that need no approval are stored as applied right away;
+// the others wait for HR. Previous keeps the replaced values once applied, so
+// applied and approved requests form the profile's change history.
+type ProfileChangeRequest struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	EmployeeID    uint       `gorm:"not null;index;constraint:OnDelete:CASCADE" json:"employee_id"`
+	RequestedByID uint       `gorm:"not null" json:"requested_by_id"`
+	Changes       JSONText   `gorm:"type:text;not null" json:"changes"` // {"field": "new value"}, bank details sealed
+	Previous      JSONText   `gorm:"type:text" json:"previous"`
+	Reason        string     `gorm:"size:500" json:"reason"`
+	Status        string     `gorm:"size:20;not null;index" json:"status"` // pending, applied, approved, rejected, cancelled
+	ReviewedByID  *uint      `json:"reviewed_by_id"`
+	ReviewNote    string     `gorm:"size:500" json:"review_note"`
+	ReviewedAt    *time.Time `json:"reviewed_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
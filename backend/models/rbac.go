@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Permission is a single capability checked by the API, e.g. "leave.approve".
+type Permission struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	Key         string `gorm:"size:80;uniqueIndex;not null" json:"key"`
+	Description string `gorm:"size:255" json:"description"`
+}
+
+// Role bundles permissions. System roles (employee, manager, hr) are seeded at
+// startup and cannot be deleted, but their permissions may be edited.
+type Role struct {
+	ID          uint         `gorm:"primaryKey" json:"id"`
+	Name        string       `gorm:"size:50;uniqueIndex;not null" json:"name"`
+	Description string       `gorm:"size:255" json:"description"`
+	IsSystem    bool         `gorm:"not null;default:false" json:"is_system"`
+	Permissions []Permission `gorm:"many2many:role_permissions;constraint:OnDelete:CASCADE" json:"permissions"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+// UserRole grants an additional role to a user on top of users.role (the primary role).
+type UserRole struct {
+	UserID    uint      `gorm:"primaryKey;constraint:OnDelete:CASCADE" json:"user_id"`
+	RoleID    uint      `gorm:"primaryKey;constraint:OnDelete:CASCADE" json:"role_id"`
+	Role      Role      `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
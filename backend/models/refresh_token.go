@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// RefreshToken is a rotating, server-side refresh credential. Only the SHA-256
+// hash of the opaque token is stored. Every rotation keeps the same FamilyID so
+// a replayed (already rotated) token can revoke the whole chain.
+type RefreshToken struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	UserID       uint       `gorm:"not null;index;constraint:OnDelete:CASCADE" json:"user_id"`
+	TokenHash    string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	FamilyID     string     `gorm:"size:64;not null;index" json:"family_id"`
+	ExpiresAt    time.Time  `gorm:"not null" json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ReplacedByID *uint      `json:"replaced_by_id"`
+	UserAgent    string     `gorm:"size:255" json:"user_agent"`
+	IP           string     `gorm:"size:64" json:"ip"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// SCIMToken is a bearer credential for the SCIM provisioning endpoint. Only the
+// SHA-256 hash is stored; the raw token is shown once when it is created.
+type SCIMToken struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	Name        string     `gorm:"size:100;not null" json:"name"`
+	TokenHash   string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Provider    string     `gorm:"size:64" json:"provider"` // OIDC provider whose users this token provisions
+	CreatedByID uint       `json:"created_by_id"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// ServiceAccount is a non-human caller (payroll, badge system) that
+// authenticates with API keys instead of logging in.
+type ServiceAccount struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"size:100;uniqueIndex;not null" json:"name"`
+	Description string    `gorm:"size:255" json:"description"`
+	Active      bool      `gorm:"not null;default:true" json:"active"`
+	CreatedByID uint      `json:"created_by_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// APIKey is a scoped, expiring credential of a service account. Only the SHA-256
+// hash is stored; Prefix identifies the key in listings and logs.
+type APIKey struct {
+	ID               uint       `gorm:"primaryKey" json:"id"`
+	ServiceAccountID uint       `gorm:"index;not null" json:"service_account_id"`
+	Name             string     `gorm:"size:100" json:"name"`
+	Prefix           string     `gorm:"size:16;not null" json:"prefix"`
+	KeyHash          string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Scopes           string     `gorm:"size:255;not null" json:"scopes"` // space separated
+	ExpiresAt        time.Time  `gorm:"not null" json:"expires_at"`
+	LastUsedAt       *time.Time `json:"last_used_at"`
+	LastUsedIP       string     `gorm:"size:45" json:"last_used_ip"`
+	RotatedFromID    *uint      `json:"rotated_from_id"`
+	RevokedAt        *time.Time `json:"revoked_at"`
+	CreatedByID      uint       `json:"created_by_id"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// ScopeList splits Scopes.
+func (k APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// SigningKey is an asymmetric key used to sign access tokens. The private key is
+// stored PKCS#8 PEM encoded and encrypted; the public half is served in the JWKS
+// from ActivatesAt minus the pre-publish window until ExpiresAt.
+type SigningKey struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	KID         string    `gorm:"column:kid;size:64;not null;uniqueIndex" json:"kid"`
+	Algorithm   string    `gorm:"size:16;not null;index" json:"algorithm"`
+	PrivateKey  string    `gorm:"type:text;not null" json:"-"`
+	ActivatesAt time.Time `gorm:"not null" json:"activates_at"`
+	RetiresAt   time.Time `gorm:"not null" json:"retires_at"`
+	ExpiresAt   time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt   time.Time `json:"created_at"`
+}
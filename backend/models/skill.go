@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// Skill is an entry of the skills catalog (Kubernetes, negotiation, German, ...).
+type Skill struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"size:100;not null;uniqueIndex" json:"name"`
+	Category    string    `gorm:"size:60;index" json:"category"`
+	Description string    `gorm:"size:500" json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// EmployeeSkill records an employee's proficiency in a skill, from 1 (novice)
+// to 5 (expert). An endorsement by a manager or HR confirms the current level;
+// changing the level withdraws it.
+type EmployeeSkill struct {
+	ID           uint       `gorm:"primaryKey" json:"id"`
+	EmployeeID   uint       `gorm:"not null;uniqueIndex:idx_employee_skill;constraint:OnDelete:CASCADE" json:"employee_id"`
+	SkillID      uint       `gorm:"not null;uniqueIndex:idx_employee_skill;index" json:"skill_id"`
+	Level        int        `gorm:"not null" json:"level"`
+	Note         string     `gorm:"size:500" json:"note"`
+	EndorsedByID *uint      `json:"endorsed_by_id"` // user who endorsed the level
+	EndorsedAt   *time.Time `json:"endorsed_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// SkillRequirement is the minimum level a designation calls for in a skill;
+// team skill gaps are measured against it.
+type SkillRequirement struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	Designation string `gorm:"size:100;not null;uniqueIndex:idx_designation_skill" json:"designation"`
+	SkillID     uint   `gorm:"not null;uniqueIndex:idx_designation_skill;index" json:"skill_id"`
+	MinLevel    int    `gorm:"not null" json:"min_level"`
+}
@@ -1,13 +1,38 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type User struct {
-	ID           uint      `gorm:"primaryKey"`
-	Name         string    `gorm:"not null"`
-	Email        string    `gorm:"unique;not null"`
-	PasswordHash string    `gorm:"not null"`
-	Role         string    `gorm:"default:employee"`
+	ID           uint   `gorm:"primaryKey"`
+	Name         string `gorm:"not null"`
+	Email        string `gorm:"not null;uniqueIndex:idx_users_email_live,where:deleted_at IS NULL"` // unique among live accounts only
+	PasswordHash string `gorm:"not null"`
+	Role         string `gorm:"default:employee"`
 	DepartmentID uint
-	CreatedAt    time.Time
+	// Active is false for deactivated accounts (e.g. by SCIM); they cannot sign in.
+	Active bool `gorm:"not null;default:true"`
+	// ExternalID is the identifier assigned by the provisioning client (SCIM externalId).
+	ExternalID *string `gorm:"size:255;uniqueIndex"`
+	// ProvisionedBy is the identity provider that created the account (by JIT
+	// login or through its SCIM token). Only that provider's first login may
+	// claim the account by email; others must be linked by the user.
+	ProvisionedBy string `gorm:"size:64"`
+	// SessionVersion is embedded in every access token; bumping it
+	// invalidates all outstanding access tokens for the user.
+	SessionVersion int `gorm:"not null;default:0"`
+
+	// TOTP multi-factor authentication. Secrets are AES-GCM encrypted at rest.
+	MFAEnabled       bool   `gorm:"not null;default:false"`
+	MFASecret        string `gorm:"size:255" json:"-"`
+	MFAPendingSecret string `gorm:"size:255" json:"-"`           // set during enrollment until confirmed
+	MFALastStep      int64  `gorm:"not null;default:0" json:"-"` // last accepted TOTP step (replay guard)
+	MFAEnrolledAt    *time.Time
+
+	CreatedAt time.Time
+	// DeletedAt marks a soft-deleted account; gorm hides it from queries.
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 }
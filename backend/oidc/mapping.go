@@ -0,0 +1,159 @@
+package oidc
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// MappingRule maps a claim value to an application role or department.
+// The claim may hold a string or a list (e.g. "groups"); matching ignores case.
+type MappingRule struct {
+	Claim  string
+	Value  string
+	Target string
+}
+
+// Mapping decides how identities from a provider are provisioned.
+type Mapping struct {
+	// RoleRules are evaluated in order; the first match wins, so list the most
+	// privileged groups first. When rules exist the IdP owns the role: it is
+	// re-evaluated on every login and falls back to DefaultRole.
+	RoleRules   []MappingRule
+	DefaultRole string
+
+	// DepartmentRules work the same way; DepartmentClaim instead takes the
+	// department name straight from a claim. Targets are department names or ids.
+	DepartmentRules   []MappingRule
+	DepartmentClaim   string
+	DefaultDepartment string
+
+	// JIT creates accounts for unknown users on first login. When disabled only
+	// pre-provisioned users (e.g. via SCIM or HR) can sign in.
+	JIT bool
+}
+
+// loadMapping reads OIDC_<ID>_ROLE_RULES, _DEFAULT_ROLE, _DEPARTMENT_RULES,
+// _DEPARTMENT_CLAIM, _DEFAULT_DEPARTMENT and _JIT_PROVISIONING (default true).
+// Rules are "claim=value:target" separated by ";", e.g. "groups=hr-team:hr;groups=leads:manager".
+func loadMapping(id string) (Mapping, error) {
+	env := func(name string) string {
+		return strings.TrimSpace(os.Getenv("OIDC_" + strings.ToUpper(id) + "_" + name))
+	}
+	m := Mapping{
+		DefaultRole:       env("DEFAULT_ROLE"),
+		DepartmentClaim:   env("DEPARTMENT_CLAIM"),
+		DefaultDepartment: env("DEFAULT_DEPARTMENT"),
+		JIT:               env("JIT_PROVISIONING") != "false",
+	}
+	if m.DefaultRole == "" {
+		m.DefaultRole = "employee"
+	}
+
+	var err error
+	if m.RoleRules, err = parseRules(env("ROLE_RULES")); err != nil {
+		return m, fmt.Errorf("oidc provider %q: ROLE_RULES: %w", id, err)
+	}
+	if m.DepartmentRules, err = parseRules(env("DEPARTMENT_RULES")); err != nil {
+		return m, fmt.Errorf("oidc provider %q: DEPARTMENT_RULES: %w", id, err)
+	}
+	return m, nil
+}
+
+func parseRules(s string) ([]MappingRule, error) {
+	var rules []MappingRule
+	for _, part := range strings.Split(s, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		// Split on the last ":" so claim names may be URLs (Auth0 namespaced claims)
+		colon := strings.LastIndex(part, ":")
+		eq := strings.LastIndex(part[:max(colon, 0)], "=")
+		if colon <= 0 || eq <= 0 {
+			return nil, fmt.Errorf("invalid rule %q, expected claim=value:target", part)
+		}
+		rules = append(rules, MappingRule{
+			Claim:  strings.TrimSpace(part[:eq]),
+			Value:  strings.TrimSpace(part[eq+1 : colon]),
+			Target: strings.TrimSpace(part[colon+1:]),
+		})
+	}
+	return rules, nil
+}
+
+// ManagesRole reports whether the IdP is the source of truth for roles.
+func (m Mapping) ManagesRole() bool { return len(m.RoleRules) > 0 }
+
+// ManagesDepartment reports whether the IdP is the source of truth for departments.
+func (m Mapping) ManagesDepartment() bool {
+	return len(m.DepartmentRules) > 0 || m.DepartmentClaim != ""
+}
+
+// RoleCandidates lists matching role targets in rule order followed by the default.
+// The caller picks the first one that exists as a role.
+func (m Mapping) RoleCandidates(claims jwt.MapClaims) []string {
+	return append(matchRules(m.RoleRules, claims), m.DefaultRole)
+}
+
+// DepartmentCandidates lists department names/ids to try in order.
+func (m Mapping) DepartmentCandidates(claims jwt.MapClaims) []string {
+	out := matchRules(m.DepartmentRules, claims)
+	if m.DepartmentClaim != "" {
+		out = append(out, claimValues(claims, m.DepartmentClaim)...)
+	}
+	if m.DefaultDepartment != "" {
+		out = append(out, m.DefaultDepartment)
+	}
+	return out
+}
+
+func matchRules(rules []MappingRule, claims jwt.MapClaims) []string {
+	var out []string
+	for _, r := range rules {
+		for _, v := range claimValues(claims, r.Claim) {
+			if strings.EqualFold(v, r.Value) {
+				out = append(out, r.Target)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// claimValues returns a claim as strings. The name is tried verbatim first,
+// then as a dotted path into nested objects (e.g. "realm_access.roles").
+func claimValues(claims jwt.MapClaims, name string) []string {
+	v, ok := claims[name]
+	if !ok {
+		var cur interface{} = map[string]interface{}(claims)
+		for _, part := range strings.Split(name, ".") {
+			obj, isObj := cur.(map[string]interface{})
+			if !isObj {
+				return nil
+			}
+			if cur, ok = obj[part]; !ok {
+				return nil
+			}
+		}
+		v = cur
+	}
+
+	switch t := v.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		out := make([]string, 0, len(t))
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	case []string:
+		return t
+	}
+	return nil
+}
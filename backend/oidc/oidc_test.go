@@ -0,0 +1,282 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testClientID = "peoplesoft"
+	testKID      = "k1"
+)
+
+// testIdP serves discovery, JWKS and a token endpoint that enforces PKCE, so
+// the whole code flow runs against it.
+type testIdP struct {
+	srv *httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]issuedCode
+}
+
+// issuedCode is an authorization code waiting to be redeemed.
+type issuedCode struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &testIdP{key: key, codes: map[string]issuedCode{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Discovery{
+			Issuer:                idp.srv.URL,
+			AuthorizationEndpoint: idp.srv.URL + "/authorize",
+			TokenEndpoint:         idp.srv.URL + "/token",
+			JWKSURI:               idp.srv.URL + "/jwks",
+			SigningAlgs:           []string{"RS256"},
+			CodeChallengeMethods:  []string{"S256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		b64 := base64.RawURLEncoding.EncodeToString
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA", "use": "sig", "alg": "RS256", "kid": testKID,
+			"n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", idp.token)
+	idp.srv = httptest.NewServer(mux)
+	t.Cleanup(idp.srv.Close)
+	return idp
+}
+
+func (idp *testIdP) token(w http.ResponseWriter, r *http.Request) {
+	fail := func(code string) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": code})
+	}
+	if r.ParseForm() != nil || r.PostForm.Get("grant_type") != "authorization_code" || r.PostForm.Get("client_id") != testClientID {
+		fail("invalid_request")
+		return
+	}
+	idp.mu.Lock()
+	issued, ok := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	idp.mu.Unlock()
+	if !ok || CodeChallenge(r.PostForm.Get("code_verifier")) != issued.challenge {
+		fail("invalid_grant")
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id_token": idp.sign(issued.claims)})
+}
+
+// authorize plays the user approving the request at authURL: it issues a code
+// for an ID token with claims and the request's nonce.
+func (idp *testIdP) authorize(t *testing.T, authURL string, claims jwt.MapClaims) string {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	claims["nonce"] = q.Get("nonce")
+	code, err := RandomString()
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp.mu.Lock()
+	idp.codes[code] = issuedCode{challenge: q.Get("code_challenge"), claims: claims}
+	idp.mu.Unlock()
+	return code
+}
+
+// claims returns valid ID token claims for a verified user.
+func (idp *testIdP) claims() jwt.MapClaims {
+	now := time.Now()
+	return jwt.MapClaims{
+		"iss":            idp.srv.URL,
+		"aud":            testClientID,
+		"sub":            "user-1",
+		"email":          "Jane.Doe@Example.com",
+		"email_verified": true,
+		"name":           "Jane Doe",
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+	}
+}
+
+func (idp *testIdP) sign(claims jwt.MapClaims) string {
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	tok.Header["kid"] = testKID
+	raw, err := tok.SignedString(idp.key)
+	if err != nil {
+		panic(err)
+	}
+	return raw
+}
+
+func (idp *testIdP) provider() *Provider {
+	return &Provider{
+		ID:          "test",
+		Issuer:      idp.srv.URL,
+		ClientID:    testClientID,
+		RedirectURL: "http://localhost:8080/api/auth/oidc/test/callback",
+		Scopes:      []string{"openid", "email", "profile"},
+	}
+}
+
+func TestCodeFlow(t *testing.T) {
+	idp := newTestIdP(t)
+	p := idp.provider()
+	ctx := context.Background()
+
+	authURL, err := p.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(authURL)
+	q := u.Query()
+	for k, want := range map[string]string{
+		"response_type":         "code",
+		"client_id":             testClientID,
+		"redirect_uri":          p.RedirectURL,
+		"state":                 "state-1",
+		"nonce":                 "nonce-1",
+		"code_challenge":        CodeChallenge("verifier-1"),
+		"code_challenge_method": "S256",
+	} {
+		if got := q.Get(k); got != want {
+			t.Errorf("%s = %q, want %q", k, got, want)
+		}
+	}
+	if !strings.HasPrefix(authURL, idp.srv.URL+"/authorize?") {
+		t.Errorf("authorization request goes to %s", authURL)
+	}
+
+	code := idp.authorize(t, authURL, idp.claims())
+	raw, err := p.Exchange(ctx, code, "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := p.VerifyIDToken(ctx, raw, "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Provider != "test" || id.Subject != "user-1" || id.Email != "jane.doe@example.com" || id.Name != "Jane Doe" {
+		t.Errorf("identity = %+v", id)
+	}
+
+	if _, err := p.Exchange(ctx, code, "verifier-1"); err == nil {
+		t.Error("code redeemed twice")
+	}
+}
+
+func TestExchangeRequiresPKCEVerifier(t *testing.T) {
+	idp := newTestIdP(t)
+	p := idp.provider()
+	ctx := context.Background()
+
+	authURL, err := p.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := idp.authorize(t, authURL, idp.claims())
+	if _, err := p.Exchange(ctx, code, "another-verifier"); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Errorf("wrong verifier: err = %v", err)
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	idp := newTestIdP(t)
+	tests := []struct {
+		name    string
+		nonce   string
+		edit    func(jwt.MapClaims)
+		setup   func(*Provider)
+		wantErr string // "" when the token must be accepted
+	}{
+		{"valid", "n", nil, nil, ""},
+		{"nonce skipped for SDK tokens", "", func(c jwt.MapClaims) { delete(c, "nonce") }, nil, ""},
+		{"nonce mismatch", "other", nil, nil, "nonce mismatch"},
+		{"nonce missing", "n", func(c jwt.MapClaims) { delete(c, "nonce") }, nil, "nonce mismatch"},
+		{"foreign audience", "n", func(c jwt.MapClaims) { c["aud"] = "someone-else" }, nil, "audience"},
+		{"extra audience", "n", func(c jwt.MapClaims) { c["aud"] = "spa" }, func(p *Provider) { p.ExtraAudiences = []string{"spa"} }, ""},
+		{"foreign azp", "n", func(c jwt.MapClaims) {
+			c["aud"] = []string{testClientID, "someone-else"}
+			c["azp"] = "someone-else"
+		}, nil, "azp"},
+		{"wrong issuer", "n", func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, nil, "invalid id token"},
+		{"expired", "n", func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, nil, "invalid id token"},
+		{"no expiry", "n", func(c jwt.MapClaims) { delete(c, "exp") }, nil, "invalid id token"},
+		{"no subject", "n", func(c jwt.MapClaims) { delete(c, "sub") }, nil, "sub missing"},
+		{"no email", "n", func(c jwt.MapClaims) { delete(c, "email") }, nil, "email claim not found"},
+		{"email_verified missing", "n", func(c jwt.MapClaims) { delete(c, "email_verified") }, nil, "not verified"},
+		{"email_verified false", "n", func(c jwt.MapClaims) { c["email_verified"] = false }, nil, "not verified"},
+		{"email_verified as string", "n", func(c jwt.MapClaims) { c["email_verified"] = "true" }, nil, "not verified"},
+		{"unverified email allowed", "n", func(c jwt.MapClaims) { delete(c, "email_verified") }, func(p *Provider) { p.AllowUnverifiedEmail = true }, ""},
+	}
+	for _, tt := range tests {
+		p := idp.provider()
+		if tt.setup != nil {
+			tt.setup(p)
+		}
+		claims := idp.claims()
+		claims["nonce"] = "n"
+		if tt.edit != nil {
+			tt.edit(claims)
+		}
+		_, err := p.VerifyIDToken(context.Background(), idp.sign(claims), tt.nonce)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+			t.Errorf("%s: err = %v, want %q", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestVerifyIDTokenRejectsSymmetricAndUnsigned(t *testing.T) {
+	idp := newTestIdP(t)
+	p := idp.provider()
+	claims := idp.claims()
+
+	hs := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	hs.Header["kid"] = testKID
+	hsRaw, _ := hs.SignedString([]byte("client-secret"))
+	none, _ := jwt.NewWithClaims(jwt.SigningMethodNone, claims).SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	for name, raw := range map[string]string{"HS256": hsRaw, "none": none} {
+		if _, err := p.VerifyIDToken(context.Background(), raw, ""); err == nil {
+			t.Errorf("%s token accepted", name)
+		}
+	}
+}
+
+func TestDiscoverRejectsIssuerMismatch(t *testing.T) {
+	idp := newTestIdP(t)
+	p := idp.provider()
+	p.Issuer = idp.srv.URL + "/"
+	if _, err := p.Discover(context.Background()); err == nil || !strings.Contains(err.Error(), "issuer mismatch") {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,305 @@
+// Package oidc implements OpenID Connect relying-party support for any number
+// of identity providers: discovery, JWKS-backed ID token verification and the
+// authorization-code flow with PKCE.
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/MicahParks/keyfunc/v2"
+)
+
+// Provider is one configured identity provider. Discovery runs lazily on first
+// use so an unreachable IdP does not stop the backend from starting.
+type Provider struct {
+	ID           string
+	DisplayName  string
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+	// AllowUnverifiedEmail accepts tokens whose email_verified claim is false
+	// or missing; otherwise it must be true.
+	AllowUnverifiedEmail bool
+	// Audiences accepted besides ClientID (e.g. a SPA client id for the same tenant).
+	ExtraAudiences []string
+	// Mapping turns claims into role, department and provisioning decisions.
+	Mapping Mapping
+
+	mu        sync.Mutex
+	discovery *Discovery
+	jwks      *keyfunc.JWKS
+}
+
+// Discovery is the subset of /.well-known/openid-configuration we use.
+type Discovery struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	SigningAlgs           []string `json:"id_token_signing_alg_values_supported"`
+	CodeChallengeMethods  []string `json:"code_challenge_methods_supported"`
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]*Provider{}
+
+	httpClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// Init registers the providers listed in OIDC_PROVIDERS (comma separated ids).
+// Each id reads OIDC_<ID>_ISSUER, _CLIENT_ID, _CLIENT_SECRET, _REDIRECT_URL,
+// _SCOPES, _DISPLAY_NAME, _AUDIENCES and _ALLOW_UNVERIFIED_EMAIL, plus the
+// claim mapping settings described on loadMapping.
+// A configured AUTH0_DOMAIN is registered as provider "auth0" for the SPA login.
+func Init() error {
+	providers := map[string]*Provider{}
+
+	for _, id := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		id = strings.ToLower(strings.TrimSpace(id))
+		if id == "" {
+			continue
+		}
+		p, err := providerFromEnv(id)
+		if err != nil {
+			return err
+		}
+		providers[id] = p
+	}
+
+	if domain := os.Getenv("AUTH0_DOMAIN"); domain != "" && providers["auth0"] == nil {
+		mapping, err := loadMapping("auth0")
+		if err != nil {
+			return err
+		}
+		providers["auth0"] = &Provider{
+			ID:          "auth0",
+			DisplayName: "Auth0",
+			Issuer:      "https://" + strings.TrimSuffix(domain, "/") + "/",
+			ClientID:    os.Getenv("AUTH0_CLIENT_ID"),
+			Scopes:      []string{"openid", "email", "profile"},
+			Mapping:     mapping,
+		}
+	}
+
+	registryMu.Lock()
+	registry = providers
+	registryMu.Unlock()
+	return nil
+}
+
+func providerFromEnv(id string) (*Provider, error) {
+	env := func(name string) string {
+		return strings.TrimSpace(os.Getenv("OIDC_" + strings.ToUpper(id) + "_" + name))
+	}
+	p := &Provider{
+		ID:                   id,
+		DisplayName:          env("DISPLAY_NAME"),
+		Issuer:               env("ISSUER"),
+		ClientID:             env("CLIENT_ID"),
+		ClientSecret:         env("CLIENT_SECRET"),
+		RedirectURL:          env("REDIRECT_URL"),
+		Scopes:               strings.Fields(env("SCOPES")),
+		AllowUnverifiedEmail: env("ALLOW_UNVERIFIED_EMAIL") == "true",
+		ExtraAudiences:       splitList(env("AUDIENCES")),
+	}
+	if p.Issuer == "" || p.ClientID == "" {
+		return nil, fmt.Errorf("oidc provider %q: ISSUER and CLIENT_ID are required", id)
+	}
+	if p.DisplayName == "" {
+		p.DisplayName = id
+	}
+	mapping, err := loadMapping(id)
+	if err != nil {
+		return nil, err
+	}
+	p.Mapping = mapping
+	if len(p.Scopes) == 0 {
+		p.Scopes = []string{"openid", "email", "profile"}
+	}
+	return p, nil
+}
+
+// Register adds or replaces a provider (used by Init and handy for local tooling).
+func Register(p *Provider) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	registry[p.ID] = p
+}
+
+// Get returns a configured provider.
+func Get(id string) (*Provider, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	p, ok := registry[id]
+	return p, ok
+}
+
+// List returns the configured providers sorted by id.
+func List() []*Provider {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	out := make([]*Provider, 0, len(registry))
+	for _, p := range registry {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// SupportsCodeFlow reports whether the provider can be used for server-side login.
+func (p *Provider) SupportsCodeFlow() bool {
+	return p.RedirectURL != ""
+}
+
+// Discover fetches and caches the discovery document and the JWKS.
+func (p *Provider) Discover(ctx context.Context) (*Discovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+
+	wellKnown := strings.TrimSuffix(p.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("oidc discovery: %s returned %d", wellKnown, resp.StatusCode)
+	}
+
+	var d Discovery
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&d); err != nil {
+		return nil, fmt.Errorf("oidc discovery: %w", err)
+	}
+	// The document must describe the issuer we were configured with (OIDC Discovery §4.3)
+	if d.Issuer != p.Issuer {
+		return nil, fmt.Errorf("oidc discovery: issuer mismatch %q != %q", d.Issuer, p.Issuer)
+	}
+	if d.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc discovery: jwks_uri missing")
+	}
+
+	jwks, err := keyfunc.Get(d.JWKSURI, keyfunc.Options{
+		Client: httpClient,
+		RefreshErrorHandler: func(err error) {
+			fmt.Printf("JWKS refresh failed for %s: %v\n", p.ID, err)
+		},
+		RefreshInterval:   time.Hour,
+		RefreshRateLimit:  time.Minute,
+		RefreshTimeout:    10 * time.Second,
+		RefreshUnknownKID: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("oidc jwks: %w", err)
+	}
+
+	p.discovery = &d
+	p.jwks = jwks
+	return p.discovery, nil
+}
+
+// AuthCodeURL builds the authorization request for the code flow with PKCE (S256).
+func (p *Provider) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	d, err := p.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", p.ClientID)
+	q.Set("redirect_uri", p.RedirectURL)
+	q.Set("scope", strings.Join(p.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", CodeChallenge(codeVerifier))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange redeems an authorization code and returns the raw ID token.
+func (p *Provider) Exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	d, err := p.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", p.RedirectURL)
+	form.Set("client_id", p.ClientID)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("token exchange: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&body); err != nil {
+		return "", fmt.Errorf("token exchange: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || body.Error != "" {
+		return "", fmt.Errorf("token exchange failed: %s %s", body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", fmt.Errorf("token exchange: no id_token in response")
+	}
+	return body.IDToken, nil
+}
+
+// CodeChallenge derives the S256 PKCE challenge for a verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
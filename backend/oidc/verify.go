@@ -0,0 +1,139 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// asymmetricAlgs are the only ID token algorithms we accept. HS* would let
+// anyone holding the client secret mint tokens and "none" is never allowed.
+var asymmetricAlgs = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}
+
+// Identity is what a verified ID token says about the user. Only values from
+// the signed token are used; request bodies are never trusted for identity.
+type Identity struct {
+	Provider string
+	Subject  string
+	Email    string
+	Name     string
+	Claims   jwt.MapClaims
+}
+
+// VerifyIDToken checks signature, algorithm, issuer, audience, expiry and nonce.
+// An empty nonce skips the nonce check; it is only passed empty for tokens
+// obtained by a browser SDK that already checked its own nonce.
+func (p *Provider) VerifyIDToken(ctx context.Context, raw, nonce string) (*Identity, error) {
+	d, err := p.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	algs := allowedAlgs(d.SigningAlgs)
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(raw, claims, p.jwks.Keyfunc,
+		jwt.WithValidMethods(algs),
+		jwt.WithIssuer(p.Issuer),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("invalid id token: %w", err)
+	}
+
+	aud, _ := claims.GetAudience()
+	if !p.audienceAllowed(aud) {
+		return nil, fmt.Errorf("invalid id token: audience %v not accepted", aud)
+	}
+	// With several audiences the authorized party must be us (OIDC Core §3.1.3.7)
+	if azp, ok := claims["azp"].(string); ok && azp != "" && len(aud) > 1 && !p.audienceAllowed([]string{azp}) {
+		return nil, fmt.Errorf("invalid id token: azp %q not accepted", azp)
+	}
+
+	if nonce != "" {
+		if got, _ := claims["nonce"].(string); got != nonce {
+			return nil, fmt.Errorf("invalid id token: nonce mismatch")
+		}
+	}
+
+	sub, _ := claims.GetSubject()
+	if sub == "" {
+		return nil, fmt.Errorf("invalid id token: sub missing")
+	}
+
+	email, _ := claims["email"].(string)
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return nil, fmt.Errorf("email claim not found in token")
+	}
+	// A missing email_verified claim counts as unverified
+	if verified, _ := claims["email_verified"].(bool); !verified && !p.AllowUnverifiedEmail {
+		return nil, fmt.Errorf("email %s is not verified by the identity provider", email)
+	}
+
+	return &Identity{
+		Provider: p.ID,
+		Subject:  sub,
+		Email:    email,
+		Name:     displayName(claims, email),
+		Claims:   claims,
+	}, nil
+}
+
+func (p *Provider) audienceAllowed(aud []string) bool {
+	for _, a := range aud {
+		if a == p.ClientID {
+			return true
+		}
+		for _, extra := range p.ExtraAudiences {
+			if a == extra {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// allowedAlgs intersects what the IdP advertises with our asymmetric allow-list.
+func allowedAlgs(advertised []string) []string {
+	if len(advertised) == 0 {
+		return []string{"RS256"} // the OIDC default
+	}
+	var out []string
+	for _, a := range advertised {
+		for _, ok := range asymmetricAlgs {
+			if a == ok {
+				out = append(out, a)
+			}
+		}
+	}
+	if len(out) == 0 {
+		return []string{"RS256"}
+	}
+	return out
+}
+
+func displayName(claims jwt.MapClaims, email string) string {
+	if name, _ := claims["name"].(string); strings.TrimSpace(name) != "" {
+		return strings.TrimSpace(name)
+	}
+	given, _ := claims["given_name"].(string)
+	family, _ := claims["family_name"].(string)
+	if full := strings.TrimSpace(given + " " + family); full != "" {
+		return full
+	}
+	return email
+}
+
+// RandomString returns a URL-safe random value for state, nonce and PKCE verifiers.
+func RandomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,58 @@
+package org
+
+import "gorm.io/gorm"
+
+// departmentTreeSQL returns a department and every department below it.
+const departmentTreeSQL = `
+	WITH RECURSIVE tree AS (
+		SELECT d.id, ARRAY[d.id] AS path FROM departments d WHERE d.id = @dept
+		UNION ALL
+		SELECT d.id, t.path || d.id
+		FROM departments d JOIN tree t ON d.parent_id = t.id
+		WHERE NOT d.id = ANY(t.path)
+	)
+	SELECT id FROM tree`
+
+// departmentAncestorsSQL returns a department and every department above it,
+// nearest first.
+const departmentAncestorsSQL = `
+	WITH RECURSIVE up AS (
+		SELECT d.id, d.parent_id, 0 AS level, ARRAY[d.id] AS path FROM departments d WHERE d.id = @dept
+		UNION ALL
+		SELECT p.id, p.parent_id, u.level + 1, u.path || p.id
+		FROM departments p JOIN up u ON p.id = u.parent_id
+		WHERE NOT p.id = ANY(u.path)
+	)
+	SELECT id FROM up ORDER BY level`
+
+// DepartmentSubtree lists the department and all of its sub-departments.
+func DepartmentSubtree(db *gorm.DB, departmentID uint) ([]uint, error) {
+	var ids []uint
+	err := db.Raw(departmentTreeSQL, map[string]interface{}{"dept": departmentID}).Scan(&ids).Error
+	return ids, err
+}
+
+// DepartmentAncestors lists the department and every parent above it, nearest first.
+func DepartmentAncestors(db *gorm.DB, departmentID uint) ([]uint, error) {
+	var ids []uint
+	err := db.Raw(departmentAncestorsSQL, map[string]interface{}{"dept": departmentID}).Scan(&ids).Error
+	return ids, err
+}
+
+// DepartmentWouldCycle reports whether making parentID the parent of
+// departmentID would put the department below itself.
+func DepartmentWouldCycle(db *gorm.DB, departmentID, parentID uint) (bool, error) {
+	if departmentID == parentID {
+		return true, nil
+	}
+	ids, err := DepartmentAncestors(db, parentID)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range ids {
+		if id == departmentID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
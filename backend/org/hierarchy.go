@@ -0,0 +1,175 @@
+// Package org walks the reporting hierarchy formed by employees.manager_id and
+// the department tree formed by departments.parent_id. Every recursive query
+// carries the path it has walked so far and stops when it meets an id twice,
+// so bad data with a cycle cannot loop forever.
+package org
+
+import (
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// MaxDepth bounds how many levels below a root a chart request may return.
+const MaxDepth = 15
+
+// subtreeSQL returns every employee below (and including) the given roots
+// with its depth under its root. It is not depth-limited so headcounts can be
+// rolled up over the whole subtree. Former (terminated) and deleted employees
+// are left out.
+const subtreeSQL = `
+	WITH RECURSIVE tree AS (
+		SELECT e.id, e.manager_id, 0 AS depth, ARRAY[e.id] AS path
+		FROM employees e WHERE e.id IN @roots AND e.deleted_at IS NULL
+		UNION ALL
+		SELECT e.id, e.manager_id, t.depth + 1, t.path || e.id
+		FROM employees e JOIN tree t ON e.manager_id = t.id
+		WHERE NOT e.id = ANY(t.path) AND e.status <> 'terminated' AND e.deleted_at IS NULL
+	)
+	SELECT t.id, t.manager_id, t.depth, e.user_id, u.name, u.email, e.designation, e.department_id
+	FROM tree t
+	JOIN employees e ON e.id = t.id
+	JOIN users u ON u.id = e.user_id`
+
+// rootsSQL selects current employees with no (existing) manager: the tops of the chart.
+const rootsSQL = `
+	SELECT e.id FROM employees e
+	LEFT JOIN employees m ON m.id = e.manager_id AND m.deleted_at IS NULL
+	WHERE (e.manager_id IS NULL OR m.id IS NULL) AND e.status <> 'terminated' AND e.deleted_at IS NULL`
+
+// chainSQL walks upwards from an employee to the top of its reporting line.
+// cycle is true on the last row when its manager was already visited.
+const chainSQL = `
+	WITH RECURSIVE chain AS (
+		SELECT e.id, e.manager_id, 0 AS level, ARRAY[e.id] AS path
+		FROM employees e WHERE e.id = @emp AND e.deleted_at IS NULL
+		UNION ALL
+		SELECT m.id, m.manager_id, c.level + 1, c.path || m.id
+		FROM employees m JOIN chain c ON m.id = c.manager_id
+		WHERE NOT m.id = ANY(c.path) AND m.deleted_at IS NULL
+	)
+	SELECT c.id, c.manager_id, c.level, e.user_id, u.name, u.email, e.designation, e.department_id,
+		(c.manager_id IS NOT NULL AND c.manager_id = ANY(c.path)) AS cycle
+	FROM chain c
+	JOIN employees e ON e.id = c.id
+	JOIN users u ON u.id = e.user_id
+	ORDER BY c.level`
+
+// Node is one employee in the chart. DirectReports and Headcount count the
+// whole subtree even when Children is cut off by the depth limit.
+type Node struct {
+	ID            uint    `json:"id"`
+	UserID        uint    `json:"user_id"`
+	Name          string  `json:"name"`
+	Email         string  `json:"email"`
+	Designation   string  `json:"designation"`
+	DepartmentID  uint    `json:"department_id"`
+	ManagerID     *uint   `json:"manager_id"`
+	Depth         int     `json:"depth"`
+	DirectReports int     `gorm:"-" json:"direct_reports"`
+	Headcount     int     `gorm:"-" json:"headcount"` // everyone below this node
+	Children      []*Node `gorm:"-" json:"children,omitempty"`
+}
+
+// Roots lists the employees at the top of the hierarchy.
+func Roots(db *gorm.DB) ([]uint, error) {
+	var ids []uint
+	err := db.Raw(rootsSQL).Scan(&ids).Error
+	return ids, err
+}
+
+// Tree builds the chart below each root, returning only maxDepth levels of
+// children (0 returns just the roots with their rollups).
+func Tree(db *gorm.DB, roots []uint, maxDepth int) ([]*Node, error) {
+	if len(roots) == 0 {
+		return []*Node{}, nil
+	}
+	var rows []Node
+	if err := db.Raw(subtreeSQL, map[string]interface{}{"roots": roots}).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	nodes := make(map[uint]*Node, len(rows))
+	for i := range rows {
+		nodes[rows[i].ID] = &rows[i]
+	}
+
+	// Roll headcounts up from the deepest level so every parent sees its
+	// children's totals.
+	order := make([]*Node, 0, len(rows))
+	for i := range rows {
+		order = append(order, &rows[i])
+	}
+	sort.Slice(order, func(i, j int) bool { return order[i].Depth > order[j].Depth })
+	for _, n := range order {
+		if n.Depth == 0 || n.ManagerID == nil {
+			continue
+		}
+		if p, ok := nodes[*n.ManagerID]; ok {
+			p.DirectReports++
+			p.Headcount += n.Headcount + 1
+		}
+	}
+
+	// Link children within the depth limit, sorted by name.
+	sort.Slice(order, func(i, j int) bool { return order[i].Name < order[j].Name })
+	for _, n := range order {
+		if n.Depth == 0 || n.Depth > maxDepth || n.ManagerID == nil {
+			continue
+		}
+		if p, ok := nodes[*n.ManagerID]; ok {
+			p.Children = append(p.Children, n)
+		}
+	}
+
+	out := make([]*Node, 0, len(roots))
+	for _, id := range roots {
+		if n, ok := nodes[id]; ok && n.Depth == 0 {
+			out = append(out, n)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out, nil
+}
+
+// Link is one step of a chain of command; Level 0 is the employee itself.
+type Link struct {
+	ID           uint   `json:"id"`
+	UserID       uint   `json:"user_id"`
+	Name         string `json:"name"`
+	Email        string `json:"email"`
+	Designation  string `json:"designation"`
+	DepartmentID uint   `json:"department_id"`
+	ManagerID    *uint  `json:"manager_id"`
+	Level        int    `json:"level"`
+	Cycle        bool   `json:"-"`
+}
+
+// Chain returns the employee followed by each manager above them, and whether
+// the walk stopped because the reporting line loops back on itself.
+func Chain(db *gorm.DB, employeeID uint) ([]Link, bool, error) {
+	var links []Link
+	if err := db.Raw(chainSQL, map[string]interface{}{"emp": employeeID}).Scan(&links).Error; err != nil {
+		return nil, false, err
+	}
+	cycle := len(links) > 0 && links[len(links)-1].Cycle
+	return links, cycle, nil
+}
+
+// WouldCreateCycle reports whether making managerID the manager of employeeID
+// would put the employee in their own chain of command.
+func WouldCreateCycle(db *gorm.DB, employeeID, managerID uint) (bool, error) {
+	if employeeID == managerID {
+		return true, nil
+	}
+	links, _, err := Chain(db, managerID)
+	if err != nil {
+		return false, err
+	}
+	for _, l := range links {
+		if l.ID == employeeID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
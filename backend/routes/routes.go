@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"peoplesoft/authz"
 	"peoplesoft/controllers"
 	"peoplesoft/middleware"
 
@@ -16,14 +17,163 @@ func SetupRoutes(r *gin.Engine) {
 api.GET("/employees/team", controllers.ListMyTeam)  // ⭐ NEW - Must be first!
 api.GET("/my-team", controllers.ListMyTeam)
 api.GET("/employees", controllers.ListEmployees)
+api.GET("/employees/export", middleware.RequirePermission(authz.EmployeeExport), controllers.ExportEmployees)
 api.GET("/employees/:id", controllers.GetEmployee)
-api.POST("/employees", controllers.CreateEmployee)
-api.PUT("/employees/:id", controllers.UpdateEmployee)
-api.DELETE("/employees/:id", controllers.DeleteEmployee)
+api.GET("/employees/:id/chain", controllers.GetChainOfCommand)
+api.GET("/employees/:id/history", controllers.ListEmploymentHistory)
+api.DELETE("/employees/:id/history/:changeId", middleware.RequirePermission(authz.EmployeeUpdate), controllers.CancelEmploymentChange)
+api.GET("/employment/change-types", controllers.ListEmploymentChangeTypes)
+api.GET("/org-chart", controllers.GetOrgChart)
+api.GET("/employees/:id/lifecycle", controllers.GetEmployeeLifecycle)
+api.POST("/employees/:id/status", middleware.RequirePermission(authz.LifecycleManage), controllers.ChangeEmployeeStatus)
+api.POST("/employees/:id/onboarding", middleware.RequirePermission(authz.LifecycleManage), controllers.StartOnboarding)
+api.POST("/employees/:id/offboarding", middleware.RequirePermission(authz.LifecycleManage), controllers.StartOffboarding)
+api.DELETE("/employees/:id/offboarding", middleware.RequirePermission(authz.LifecycleManage), controllers.CancelOffboarding)
+api.POST("/employees", middleware.RequirePermission(authz.EmployeeCreate), controllers.CreateEmployee)
+api.POST("/employees/import", middleware.RequirePermission(authz.EmployeeImport), controllers.ImportEmployees)
+api.PUT("/employees/:id", middleware.RequirePermission(authz.EmployeeUpdate), controllers.UpdateEmployee)
+api.PUT("/employees/:id/custom-fields", controllers.UpdateEmployeeCustomFields)
+api.GET("/employees/:id/documents", controllers.ListEmployeeDocuments)
+api.POST("/employees/:id/documents", controllers.UploadEmployeeDocument)
+api.GET("/employees/:id/skills", controllers.ListEmployeeSkills)
+api.PUT("/employees/:id/skills/:skillId", controllers.SetEmployeeSkill)
+api.DELETE("/employees/:id/skills/:skillId", controllers.RemoveEmployeeSkill)
+api.POST("/employees/:id/skills/:skillId/endorse", controllers.EndorseEmployeeSkill)
+api.GET("/employees/:id/profile", controllers.GetEmployeeProfile)
+api.GET("/employees/:id/profile-changes", controllers.ListEmployeeProfileChanges)
+api.POST("/employees/:id/profile-changes", controllers.SubmitProfileChange)
+api.DELETE("/employees/:id", middleware.RequirePermission(authz.EmployeeDelete), controllers.DeleteEmployee)
+api.GET("/employees/deleted", middleware.RequirePermission(authz.UserRestore), controllers.ListDeletedEmployees)
+api.POST("/employees/:id/restore", middleware.RequirePermission(authz.UserRestore), controllers.RestoreEmployee)
+api.DELETE("/employees/:id/purge", middleware.RequirePermission(authz.DataPurge), controllers.PurgeEmployee)
+
+		// ========== DEPARTMENTS ==========
+		api.GET("/departments", controllers.ListDepartments)
+		api.GET("/departments/:id", controllers.GetDepartment)
+		api.GET("/departments/:id/employees", controllers.ListDepartmentEmployees)
+		api.GET("/departments/:id/stats", controllers.GetDepartmentStats)
+		api.POST("/departments", middleware.RequirePermission(authz.DepartmentManage), controllers.CreateDepartment)
+		api.PUT("/departments/:id", middleware.RequirePermission(authz.DepartmentManage), controllers.UpdateDepartment)
+		api.DELETE("/departments/:id", middleware.RequirePermission(authz.DepartmentManage), controllers.DeleteDepartment)
+
+		// ========== CUSTOM PROFILE FIELDS ==========
+		api.GET("/custom-fields", controllers.ListCustomFields)
+		api.POST("/custom-fields", middleware.RequirePermission(authz.CustomFieldManage), controllers.CreateCustomField)
+		api.PUT("/custom-fields/:id", middleware.RequirePermission(authz.CustomFieldManage), controllers.UpdateCustomField)
+		api.DELETE("/custom-fields/:id", middleware.RequirePermission(authz.CustomFieldManage), controllers.DeleteCustomField)
+
+		// ========== DIRECTORY SEARCH ==========
+		api.GET("/search/employees", controllers.SearchEmployees)
+		api.GET("/search/suggest", controllers.SuggestEmployees)
+
+		// ========== PROFILE CHANGE REQUESTS ==========
+		api.GET("/profile-fields", controllers.ListProfileFields)
+		api.GET("/profile-changes", middleware.RequirePermission(authz.ProfileApprove), controllers.ListProfileChangeRequests)
+		api.POST("/profile-changes/:id/approve", middleware.RequirePermission(authz.ProfileApprove), controllers.ApproveProfileChange)
+		api.POST("/profile-changes/:id/reject", middleware.RequirePermission(authz.ProfileApprove), controllers.RejectProfileChange)
+		api.DELETE("/profile-changes/:id", controllers.CancelProfileChange)
+
+		// ========== HOLIDAY CALENDARS ==========
+		api.GET("/holiday-calendars", controllers.ListHolidayCalendars)
+		api.POST("/holiday-calendars", middleware.RequirePermission(authz.HolidayManage), controllers.CreateHolidayCalendar)
+		api.PUT("/holiday-calendars/:id", middleware.RequirePermission(authz.HolidayManage), controllers.UpdateHolidayCalendar)
+		api.DELETE("/holiday-calendars/:id", middleware.RequirePermission(authz.HolidayManage), controllers.DeleteHolidayCalendar)
+		api.GET("/holiday-calendars/:id/holidays", controllers.ListHolidays)
+		api.POST("/holiday-calendars/:id/holidays", middleware.RequirePermission(authz.HolidayManage), controllers.CreateHoliday)
+		api.POST("/holiday-calendars/:id/import", middleware.RequirePermission(authz.HolidayManage), controllers.ImportHolidays)
+		api.GET("/holidays/mine", controllers.ListMyHolidays)
+		api.PUT("/holidays/:id", middleware.RequirePermission(authz.HolidayManage), controllers.UpdateHoliday)
+		api.DELETE("/holidays/:id", middleware.RequirePermission(authz.HolidayManage), controllers.DeleteHoliday)
+		api.POST("/holidays/:id/choose", controllers.ChooseOptionalHoliday)
+		api.DELETE("/holidays/:id/choose", controllers.UnchooseOptionalHoliday)
+
+		// ========== SKILLS ==========
+		api.GET("/skills", controllers.ListSkills)
+		api.GET("/skills/levels", controllers.ListSkillLevels)
+		api.GET("/skills/gaps", controllers.ListSkillGaps)
+		api.GET("/skills/requirements", controllers.ListSkillRequirements)
+		api.PUT("/skills/requirements", middleware.RequirePermission(authz.SkillManage), controllers.SetSkillRequirements)
+		api.POST("/skills", middleware.RequirePermission(authz.SkillManage), controllers.CreateSkill)
+		api.PUT("/skills/:id", middleware.RequirePermission(authz.SkillManage), controllers.UpdateSkill)
+		api.DELETE("/skills/:id", middleware.RequirePermission(authz.SkillManage), controllers.DeleteSkill)
+
+		// ========== EMPLOYEE DOCUMENTS ==========
+		api.GET("/documents/categories", controllers.ListDocumentCategories)
+		api.GET("/documents/expiring", middleware.RequirePermission(authz.DocumentManage), controllers.ListExpiringDocuments)
+		api.GET("/documents/:id", controllers.GetDocument)
+		api.GET("/documents/:id/download", controllers.DownloadDocument)
+		api.PUT("/documents/:id", controllers.UpdateDocument)
+		api.DELETE("/documents/:id", controllers.DeleteDocument)
+
+		// ========== LIFECYCLE CHECKLISTS ==========
+		api.GET("/lifecycle/my-tasks", controllers.ListMyLifecycleTasks)
+		api.PUT("/lifecycle/tasks/:id", controllers.UpdateLifecycleTask)
+		api.GET("/lifecycle/tasks", middleware.RequirePermission(authz.LifecycleManage), controllers.ListLifecycleTasks)
+		api.GET("/lifecycle/templates", middleware.RequirePermission(authz.LifecycleManage), controllers.ListChecklistTemplates)
+		api.POST("/lifecycle/templates", middleware.RequirePermission(authz.LifecycleManage), controllers.CreateChecklistTemplate)
+		api.PUT("/lifecycle/templates/:id", middleware.RequirePermission(authz.LifecycleManage), controllers.UpdateChecklistTemplate)
+		api.DELETE("/lifecycle/templates/:id", middleware.RequirePermission(authz.LifecycleManage), controllers.DeleteChecklistTemplate)
 
 		// ========== USERS ==========
 		api.GET("/users/by-email/:email", controllers.GetUserByEmail)
-		api.DELETE("/users/:id", controllers.DeleteUser)
+		api.DELETE("/users/:id", middleware.RequirePermission(authz.UserDelete), controllers.DeleteUser)
+		api.GET("/users/deleted", middleware.RequirePermission(authz.UserRestore), controllers.ListDeletedUsers)
+		api.POST("/users/:id/restore", middleware.RequirePermission(authz.UserRestore), controllers.RestoreUser)
+		api.DELETE("/users/:id/purge", middleware.RequirePermission(authz.DataPurge), controllers.PurgeUser)
+		api.PUT("/users/:id/role", middleware.RequirePermission(authz.UserManageRoles), controllers.UpdateUserRole)
+		api.GET("/users/:id/roles", middleware.RequirePermission(authz.UserManageRoles), controllers.GetUserRoles)
+		api.PUT("/users/:id/roles", middleware.RequirePermission(authz.UserManageRoles), controllers.SetUserRoles)
+		api.GET("/users/:id/scopes", middleware.RequirePermission(authz.UserManageRoles), controllers.GetUserScopes)
+		api.PUT("/users/:id/scopes", middleware.RequirePermission(authz.UserManageRoles), controllers.SetUserScopes)
+		api.POST("/users/:id/revoke-sessions", middleware.RequirePermission(authz.UserSecurity), controllers.RevokeUserSessions)
+		api.POST("/users/:id/mfa/reset", middleware.RequirePermission(authz.UserSecurity), controllers.ResetUserMFA)
+		api.POST("/users/:id/unlock", middleware.RequirePermission(authz.UserSecurity), controllers.UnlockUser)
+
+		// ========== LOGIN SECURITY (HR) ==========
+		api.GET("/security/login-attempts", middleware.RequirePermission(authz.SecurityAudit), controllers.ListLoginAttempts)
+		api.GET("/security/lockouts", middleware.RequirePermission(authz.SecurityAudit), controllers.ListLockouts)
+		api.GET("/security/signing-keys", middleware.RequirePermission(authz.SigningKeys), controllers.ListSigningKeys)
+		api.POST("/security/signing-keys/rotate", middleware.RequirePermission(authz.SigningKeys), controllers.RotateSigningKey)
+
+		// ========== AUDIT LOG (HR) ==========
+		api.GET("/audit/events", middleware.RequirePermission(authz.AuditView), controllers.ListAuditEvents)
+		api.GET("/audit/verify", middleware.RequirePermission(authz.AuditView), controllers.VerifyAuditLog)
+
+		// ========== IMPERSONATION ("view as", HR) ==========
+		api.POST("/impersonation/start", middleware.RequirePermission(authz.Impersonate), controllers.StartImpersonation)
+		api.POST("/impersonation/end", controllers.EndImpersonation)
+		api.GET("/impersonation/sessions", middleware.RequirePermission(authz.SecurityAudit), controllers.ListImpersonationSessions)
+		api.GET("/impersonation/sessions/:id/requests", middleware.RequirePermission(authz.SecurityAudit), controllers.ListImpersonationRequests)
+
+		// ========== SERVICE ACCOUNTS & API KEYS (HR) ==========
+		api.GET("/service-accounts/scopes", middleware.RequirePermission(authz.ServiceAccounts), controllers.ListAPIScopes)
+		api.GET("/service-accounts", middleware.RequirePermission(authz.ServiceAccounts), controllers.ListServiceAccounts)
+		api.POST("/service-accounts", middleware.RequirePermission(authz.ServiceAccounts), controllers.CreateServiceAccount)
+		api.PUT("/service-accounts/:id", middleware.RequirePermission(authz.ServiceAccounts), controllers.UpdateServiceAccount)
+		api.DELETE("/service-accounts/:id", middleware.RequirePermission(authz.ServiceAccounts), controllers.DeleteServiceAccount)
+		api.GET("/service-accounts/:id/keys", middleware.RequirePermission(authz.ServiceAccounts), controllers.ListAPIKeys)
+		api.POST("/service-accounts/:id/keys", middleware.RequirePermission(authz.ServiceAccounts), controllers.CreateAPIKey)
+		api.POST("/service-accounts/:id/keys/:keyId/rotate", middleware.RequirePermission(authz.ServiceAccounts), controllers.RotateAPIKey)
+		api.DELETE("/service-accounts/:id/keys/:keyId", middleware.RequirePermission(authz.ServiceAccounts), controllers.RevokeAPIKey)
+
+		// ========== SCIM TOKENS (HR) ==========
+		api.GET("/scim/tokens", middleware.RequirePermission(authz.SCIMManage), controllers.ListSCIMTokens)
+		api.POST("/scim/tokens", middleware.RequirePermission(authz.SCIMManage), controllers.CreateSCIMToken)
+		api.DELETE("/scim/tokens/:id", middleware.RequirePermission(authz.SCIMManage), controllers.RevokeSCIMToken)
+
+		// ========== ROLES & PERMISSIONS ==========
+		api.GET("/rbac/permissions", middleware.RequirePermission(authz.RBACManage), controllers.ListPermissions)
+		api.GET("/rbac/roles", middleware.RequirePermission(authz.RBACManage), controllers.ListRoles)
+		api.POST("/rbac/roles", middleware.RequirePermission(authz.RBACManage), controllers.CreateRole)
+		api.PUT("/rbac/roles/:id", middleware.RequirePermission(authz.RBACManage), controllers.UpdateRole)
+		api.DELETE("/rbac/roles/:id", middleware.RequirePermission(authz.RBACManage), controllers.DeleteRole)
+
+		// ========== MFA (self-service) ==========
+		api.GET("/mfa/status", controllers.MFAStatus)
+		api.POST("/mfa/setup", controllers.MFASetup)
+		api.POST("/mfa/activate", controllers.MFAActivate)
+		api.POST("/mfa/disable", controllers.MFADisable)
+		api.POST("/mfa/recovery-codes", controllers.MFARegenerateRecoveryCodes)
 
 		// ========== MANAGER TEAM ==========
 		api.GET("/managers/:managerId/team", controllers.ListTeam)
@@ -44,6 +194,26 @@ api.DELETE("/employees/:id", controllers.DeleteEmployee)
 	// NOTE: PMS routes are now defined in main.go under /api/pms
 	// This avoids duplication and keeps all PMS logic centralized
 
+	// ========== SCIM 2.0 (IdP provisioning, bearer token from /api/scim/tokens) ==========
+	scimGroup := r.Group("/scim/v2")
+	scimGroup.Use(middleware.SCIMAuth())
+	{
+		scimGroup.GET("/ServiceProviderConfig", controllers.SCIMServiceProviderConfig)
+		scimGroup.GET("/ResourceTypes", controllers.SCIMResourceTypes)
+		scimGroup.GET("/Users", controllers.SCIMListUsers)
+		scimGroup.POST("/Users", controllers.SCIMCreateUser)
+		scimGroup.GET("/Users/:id", controllers.SCIMGetUser)
+		scimGroup.PUT("/Users/:id", controllers.SCIMReplaceUser)
+		scimGroup.PATCH("/Users/:id", controllers.SCIMPatchUser)
+		scimGroup.DELETE("/Users/:id", controllers.SCIMDeleteUser)
+		scimGroup.GET("/Groups", controllers.SCIMListGroups)
+		scimGroup.POST("/Groups", controllers.SCIMCreateGroup)
+		scimGroup.GET("/Groups/:id", controllers.SCIMGetGroup)
+		scimGroup.PUT("/Groups/:id", controllers.SCIMReplaceGroup)
+		scimGroup.PATCH("/Groups/:id", controllers.SCIMPatchGroup)
+		scimGroup.DELETE("/Groups/:id", controllers.SCIMDeleteGroup)
+	}
+
 	// Chatbot routes
 	chatbot := api.Group("/chatbot")
 	{
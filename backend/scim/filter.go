@@ -0,0 +1,363 @@
+// Package scim holds the protocol pieces of the SCIM 2.0 server (RFC 7643/7644)
+// that do not depend on the database: filter parsing and SQL translation,
+// PATCH operations and the error/list envelopes.
+package scim
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Filter is a parsed SCIM filter expression.
+type Filter interface{ isFilter() }
+
+// Compare is "attrPath op value"; Value is nil for "pr".
+type Compare struct {
+	Attr  string
+	Op    string
+	Value interface{}
+}
+
+// Logical is "left and|or right".
+type Logical struct {
+	Op          string
+	Left, Right Filter
+}
+
+// Not negates a grouped expression.
+type Not struct{ Inner Filter }
+
+func (Compare) isFilter() {}
+func (Logical) isFilter() {}
+func (Not) isFilter()     {}
+
+var compareOps = map[string]bool{
+	"eq": true, "ne": true, "co": true, "sw": true, "ew": true,
+	"gt": true, "ge": true, "lt": true, "le": true, "pr": true,
+}
+
+// ParseFilter parses a filter such as `userName eq "a@b.c" and active eq true`.
+func ParseFilter(s string) (Filter, error) {
+	toks, err := tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	f, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.toks) {
+		return nil, fmt.Errorf("unexpected %q", p.toks[p.pos].text)
+	}
+	return f, nil
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+func tokenize(s string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(s); {
+		r := rune(s[i])
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' || r == ')':
+			toks = append(toks, token{text: string(r)})
+			i++
+		case r == '"':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' && j+1 < len(s) {
+					j++
+				}
+				b.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			toks = append(toks, token{text: b.String(), quoted: true})
+			i = j + 1
+		default:
+			j := i
+			for j < len(s) && !unicode.IsSpace(rune(s[j])) && s[j] != '(' && s[j] != ')' {
+				j++
+			}
+			toks = append(toks, token{text: s[i:j]})
+			i = j
+		}
+	}
+	return toks, nil
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) peekWord(w string) bool {
+	return p.pos < len(p.toks) && !p.toks[p.pos].quoted && strings.EqualFold(p.toks[p.pos].text, w)
+}
+
+func (p *parser) or() (Filter, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekWord("or") {
+		p.pos++
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		left = Logical{Op: "or", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) and() (Filter, error) {
+	left, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	for p.peekWord("and") {
+		p.pos++
+		right, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		left = Logical{Op: "and", Left: left, Right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) factor() (Filter, error) {
+	if p.pos >= len(p.toks) {
+		return nil, fmt.Errorf("unexpected end of filter")
+	}
+	if p.peekWord("not") {
+		p.pos++
+		inner, err := p.group()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Inner: inner}, nil
+	}
+	if p.peekWord("(") {
+		return p.group()
+	}
+
+	attr := p.toks[p.pos]
+	if attr.quoted {
+		return nil, fmt.Errorf("expected attribute, got %q", attr.text)
+	}
+	p.pos++
+	if p.pos >= len(p.toks) {
+		return nil, fmt.Errorf("missing operator after %s", attr.text)
+	}
+	op := strings.ToLower(p.toks[p.pos].text)
+	if !compareOps[op] {
+		return nil, fmt.Errorf("unsupported operator %q", p.toks[p.pos].text)
+	}
+	p.pos++
+	if op == "pr" {
+		return Compare{Attr: attr.text, Op: op}, nil
+	}
+	if p.pos >= len(p.toks) {
+		return nil, fmt.Errorf("missing value for %s %s", attr.text, op)
+	}
+	v := p.toks[p.pos]
+	p.pos++
+	return Compare{Attr: attr.text, Op: op, Value: literal(v)}, nil
+}
+
+func (p *parser) group() (Filter, error) {
+	if !p.peekWord("(") {
+		return nil, fmt.Errorf("expected (")
+	}
+	p.pos++
+	f, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if !p.peekWord(")") {
+		return nil, fmt.Errorf("expected )")
+	}
+	p.pos++
+	return f, nil
+}
+
+func literal(t token) interface{} {
+	if t.quoted {
+		return t.text
+	}
+	switch strings.ToLower(t.text) {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+	if n, err := strconv.ParseFloat(t.text, 64); err == nil {
+		return n
+	}
+	return t.text
+}
+
+// ----------------------------
+// SQL TRANSLATION
+// ----------------------------
+
+// AttrType controls how values are compared.
+type AttrType int
+
+const (
+	String AttrType = iota // case-insensitive
+	Bool
+	Int
+	Time
+)
+
+// Attr maps a filterable SCIM attribute to SQL. Build, when set, replaces the
+// default column comparison (used for multi-valued attributes like members).
+type Attr struct {
+	Column string
+	Type   AttrType
+	Build  func(op string, value interface{}) (string, []interface{}, error)
+}
+
+// ToSQL translates a filter into a WHERE clause. attrs is keyed by lower-case
+// attribute path; schema URN prefixes are stripped before lookup.
+func ToSQL(f Filter, attrs map[string]Attr) (string, []interface{}, error) {
+	switch t := f.(type) {
+	case Logical:
+		l, la, err := ToSQL(t.Left, attrs)
+		if err != nil {
+			return "", nil, err
+		}
+		r, ra, err := ToSQL(t.Right, attrs)
+		if err != nil {
+			return "", nil, err
+		}
+		return "(" + l + " " + strings.ToUpper(t.Op) + " " + r + ")", append(la, ra...), nil
+	case Not:
+		s, a, err := ToSQL(t.Inner, attrs)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT (" + s + ")", a, nil
+	case Compare:
+		a, ok := attrs[NormalizeAttr(t.Attr)]
+		if !ok {
+			return "", nil, fmt.Errorf("filtering on %q is not supported", t.Attr)
+		}
+		if a.Build != nil {
+			return a.Build(t.Op, t.Value)
+		}
+		return compareSQL(a, t.Op, t.Value)
+	}
+	return "", nil, fmt.Errorf("invalid filter")
+}
+
+// NormalizeAttr lower-cases an attribute path and strips core schema URNs.
+// Extension attributes keep their URN so they stay unambiguous.
+func NormalizeAttr(attr string) string {
+	a := strings.ToLower(attr)
+	for _, prefix := range []string{strings.ToLower(UserSchema) + ":", strings.ToLower(GroupSchema) + ":"} {
+		a = strings.TrimPrefix(a, prefix)
+	}
+	return a
+}
+
+func compareSQL(a Attr, op string, value interface{}) (string, []interface{}, error) {
+	col := a.Column
+	if op == "pr" {
+		if a.Type == String {
+			return fmt.Sprintf("(%s IS NOT NULL AND %s <> '')", col, col), nil, nil
+		}
+		return col + " IS NOT NULL", nil, nil
+	}
+	if value == nil {
+		switch op {
+		case "eq":
+			return col + " IS NULL", nil, nil
+		case "ne":
+			return col + " IS NOT NULL", nil, nil
+		}
+		return "", nil, fmt.Errorf("null only supports eq and ne")
+	}
+
+	v, err := coerce(a.Type, value)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if a.Type == String {
+		s := strings.ToLower(v.(string))
+		lower := "LOWER(" + col + ")"
+		switch op {
+		case "eq":
+			return lower + " = ?", []interface{}{s}, nil
+		case "ne":
+			return "(" + col + " IS NULL OR " + lower + " <> ?)", []interface{}{s}, nil
+		case "co":
+			return lower + ` LIKE ? ESCAPE '\'`, []interface{}{"%" + escapeLike(s) + "%"}, nil
+		case "sw":
+			return lower + ` LIKE ? ESCAPE '\'`, []interface{}{escapeLike(s) + "%"}, nil
+		case "ew":
+			return lower + ` LIKE ? ESCAPE '\'`, []interface{}{"%" + escapeLike(s)}, nil
+		}
+	}
+
+	sqlOps := map[string]string{"eq": "=", "ne": "<>", "gt": ">", "ge": ">=", "lt": "<", "le": "<="}
+	if sqlOp, ok := sqlOps[op]; ok && (a.Type != Bool || op == "eq" || op == "ne") {
+		return col + " " + sqlOp + " ?", []interface{}{v}, nil
+	}
+	return "", nil, fmt.Errorf("operator %s is not supported for this attribute", op)
+}
+
+func coerce(t AttrType, value interface{}) (interface{}, error) {
+	switch t {
+	case Bool:
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	case Int:
+		switch v := value.(type) {
+		case float64:
+			return int64(v), nil
+		case string:
+			if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return n, nil
+			}
+		}
+	case Time:
+		if s, ok := value.(string); ok {
+			if ts, err := time.Parse(time.RFC3339, s); err == nil {
+				return ts, nil
+			}
+		}
+	default:
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		}
+	}
+	return nil, fmt.Errorf("invalid value %v", value)
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
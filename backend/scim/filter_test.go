@@ -0,0 +1,127 @@
+package scim
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Filter
+	}{
+		{`userName eq "ada@example.com"`, Compare{Attr: "userName", Op: "eq", Value: "ada@example.com"}},
+		{`active EQ true`, Compare{Attr: "active", Op: "eq", Value: true}},
+		{`title pr`, Compare{Attr: "title", Op: "pr"}},
+		{`manager eq null`, Compare{Attr: "manager", Op: "eq", Value: nil}},
+		{`meta.version gt 3.5`, Compare{Attr: "meta.version", Op: "gt", Value: 3.5}},
+		{`displayName eq "say \"hi\""`, Compare{Attr: "displayName", Op: "eq", Value: `say "hi"`}},
+		{`displayName eq "and"`, Compare{Attr: "displayName", Op: "eq", Value: "and"}},
+		{
+			`a eq "1" or b eq "2" and c eq "3"`,
+			Logical{Op: "or", Left: Compare{Attr: "a", Op: "eq", Value: "1"},
+				Right: Logical{Op: "and", Left: Compare{Attr: "b", Op: "eq", Value: "2"}, Right: Compare{Attr: "c", Op: "eq", Value: "3"}}},
+		},
+		{
+			`(a eq "1" or b eq "2") and not (c pr)`,
+			Logical{Op: "and",
+				Left:  Logical{Op: "or", Left: Compare{Attr: "a", Op: "eq", Value: "1"}, Right: Compare{Attr: "b", Op: "eq", Value: "2"}},
+				Right: Not{Inner: Compare{Attr: "c", Op: "pr"}}},
+		},
+	}
+	for _, tt := range tests {
+		got, err := ParseFilter(tt.in)
+		if err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseFilterMalformed(t *testing.T) {
+	tests := []struct{ in, err string }{
+		{``, "unexpected end"},
+		{`   `, "unexpected end"},
+		{`userName`, "missing operator"},
+		{`userName eq`, "missing value"},
+		{`userName like "a"`, "unsupported operator"},
+		{`"userName" eq "a"`, "expected attribute"},
+		{`userName eq "a`, "unterminated string"},
+		{`userName eq "a\`, "unterminated string"},
+		{`(userName eq "a"`, "expected )"},
+		{`userName eq "a")`, `unexpected ")"`},
+		{`userName eq "a" and`, "unexpected end"},
+		{`userName eq "a" "b"`, `unexpected "b"`},
+		{`not userName eq "a"`, "expected ("},
+		{`()`, "missing operator"},
+	}
+	for _, tt := range tests {
+		_, err := ParseFilter(tt.in)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%q: got error %v, want %q", tt.in, err, tt.err)
+		}
+	}
+}
+
+var testAttrs = map[string]Attr{
+	"username":     {Column: "users.email"},
+	"active":       {Column: "users.active", Type: Bool},
+	"meta.created": {Column: "users.created_at", Type: Time},
+	"id":           {Column: "users.id", Type: Int},
+}
+
+func TestToSQL(t *testing.T) {
+	created := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		in   string
+		sql  string
+		args []interface{}
+	}{
+		{`userName eq "Ada@Example.com"`, "LOWER(users.email) = ?", []interface{}{"ada@example.com"}},
+		{UserSchema + `:userName sw "a_b%"`, `LOWER(users.email) LIKE ? ESCAPE '\'`, []interface{}{`a\_b\%%`}},
+		{`userName ne "x"`, "(users.email IS NULL OR LOWER(users.email) <> ?)", []interface{}{"x"}},
+		{`userName pr`, "(users.email IS NOT NULL AND users.email <> '')", nil},
+		{`active eq true and not (id ge "10")`, "(users.active = ? AND NOT (users.id >= ?))", []interface{}{true, int64(10)}},
+		{`meta.created gt "2026-01-02T03:04:05Z" or userName eq null`, "(users.created_at > ? OR users.email IS NULL)", []interface{}{created}},
+	}
+	for _, tt := range tests {
+		f, err := ParseFilter(tt.in)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.in, err)
+		}
+		sql, args, err := ToSQL(f, testAttrs)
+		if err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if sql != tt.sql || !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%s: got %s %v, want %s %v", tt.in, sql, args, tt.sql, tt.args)
+		}
+	}
+}
+
+func TestToSQLRejects(t *testing.T) {
+	for _, in := range []string{
+		`password eq "x"`,                // not filterable
+		`active gt true`,                 // ordering on a boolean
+		`active eq "yes"`,                // wrong type
+		`id eq "ten"`,                    // not a number
+		`meta.created gt "yesterday"`,    // not RFC 3339
+		`userName gt null`,               // null only with eq and ne
+		`userName eq "a" or secret pr`,   // one side unsupported
+		`not (urn:example:foo:bar eq 1)`, // unknown schema
+	} {
+		f, err := ParseFilter(in)
+		if err != nil {
+			t.Fatalf("%s: %v", in, err)
+		}
+		if sql, _, err := ToSQL(f, testAttrs); err == nil {
+			t.Errorf("%s: got %s, want an error", in, sql)
+		}
+	}
+}
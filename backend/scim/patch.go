@@ -0,0 +1,286 @@
+package scim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Operation is one entry of a PATCH request's "Operations".
+type Operation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// PatchRequest is the body of a SCIM PATCH.
+type PatchRequest struct {
+	Schemas    []string    `json:"schemas"`
+	Operations []Operation `json:"Operations"`
+}
+
+// ApplyPatch applies operations to a resource rendered as a JSON object. The
+// caller renders the current resource, patches it, then saves it with the
+// same code path as PUT.
+func ApplyPatch(doc map[string]interface{}, ops []Operation) error {
+	for _, op := range ops {
+		kind := strings.ToLower(op.Op)
+		if kind != "add" && kind != "replace" && kind != "remove" {
+			return fmt.Errorf("unsupported op %q", op.Op)
+		}
+		if err := applyOp(doc, kind, op.Path, op.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyOp(doc map[string]interface{}, kind, path string, value interface{}) error {
+	if path == "" {
+		// No path: the value is an object whose keys are paths (RFC 7644 §3.5.2.1/3)
+		obj, ok := value.(map[string]interface{})
+		if !ok || kind == "remove" {
+			return fmt.Errorf("a path is required for this operation")
+		}
+		for k, v := range obj {
+			if err := applyOp(doc, kind, k, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	container, rest, err := resolveContainer(doc, path)
+	if err != nil {
+		return err
+	}
+	if rest == "" {
+		// Path named an extension schema itself
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("value for %s must be an object", path)
+		}
+		for k, v := range obj {
+			if err := applyOp(container, kind, k, v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	attr, filter, sub, err := splitPath(rest)
+	if err != nil {
+		return err
+	}
+	key := findKey(container, attr)
+
+	if filter != nil {
+		return applyFiltered(container, key, filter, sub, kind, value)
+	}
+
+	if sub != "" {
+		child, _ := container[key].(map[string]interface{})
+		if child == nil {
+			if kind == "remove" {
+				return nil
+			}
+			child = map[string]interface{}{}
+			container[key] = child
+		}
+		return setValue(child, findKey(child, sub), kind, value)
+	}
+	return setValue(container, key, kind, value)
+}
+
+func setValue(m map[string]interface{}, key, kind string, value interface{}) error {
+	switch kind {
+	case "remove":
+		// "remove" with a value on a multi-valued attribute removes matching members
+		if list, ok := m[key].([]interface{}); ok {
+			if items, ok := value.([]interface{}); ok && len(items) > 0 {
+				m[key] = removeByValue(list, items)
+				return nil
+			}
+		}
+		delete(m, key)
+	case "add":
+		existing, isList := m[key].([]interface{})
+		if items, ok := value.([]interface{}); ok && isList {
+			m[key] = append(existing, items...)
+			return nil
+		}
+		if obj, ok := value.(map[string]interface{}); ok {
+			if cur, ok := m[key].(map[string]interface{}); ok {
+				for k, v := range obj {
+					cur[findKey(cur, k)] = v
+				}
+				return nil
+			}
+		}
+		m[key] = value
+	default:
+		m[key] = value
+	}
+	return nil
+}
+
+func applyFiltered(container map[string]interface{}, key string, filter Filter, sub, kind string, value interface{}) error {
+	list, _ := container[key].([]interface{})
+	var kept []interface{}
+	matched := false
+	for _, item := range list {
+		elem, ok := item.(map[string]interface{})
+		if !ok || !Matches(elem, filter) {
+			kept = append(kept, item)
+			continue
+		}
+		matched = true
+		switch {
+		case kind == "remove" && sub == "":
+			continue // drop the element
+		case kind == "remove":
+			delete(elem, findKey(elem, sub))
+		case sub != "":
+			elem[findKey(elem, sub)] = value
+		default:
+			if obj, ok := value.(map[string]interface{}); ok {
+				for k, v := range obj {
+					elem[findKey(elem, k)] = v
+				}
+			}
+		}
+		kept = append(kept, elem)
+	}
+
+	// emails[type eq "work"].value on a user without a work email creates it
+	if !matched && kind != "remove" {
+		elem := map[string]interface{}{}
+		if c, ok := filter.(Compare); ok && c.Op == "eq" {
+			elem[c.Attr] = c.Value
+		}
+		if sub != "" {
+			elem[sub] = value
+		} else if obj, ok := value.(map[string]interface{}); ok {
+			for k, v := range obj {
+				elem[k] = v
+			}
+		}
+		kept = append(kept, elem)
+	}
+	if kept == nil {
+		kept = []interface{}{}
+	}
+	container[key] = kept
+	return nil
+}
+
+// resolveContainer strips a schema URN from the path and returns the object the
+// remaining attribute lives in (the extension object for extension attributes).
+func resolveContainer(doc map[string]interface{}, path string) (map[string]interface{}, string, error) {
+	lower := strings.ToLower(path)
+	if !strings.HasPrefix(lower, "urn:") {
+		return doc, path, nil
+	}
+	for _, schema := range []string{UserSchema, GroupSchema} {
+		if strings.HasPrefix(lower, strings.ToLower(schema)+":") {
+			return doc, path[len(schema)+1:], nil
+		}
+	}
+	for _, schema := range []string{EnterpriseUserSchema} {
+		ls := strings.ToLower(schema)
+		if lower == ls || strings.HasPrefix(lower, ls+":") {
+			key := findKey(doc, schema)
+			ext, _ := doc[key].(map[string]interface{})
+			if ext == nil {
+				ext = map[string]interface{}{}
+				doc[key] = ext
+			}
+			if lower == ls {
+				return ext, "", nil
+			}
+			return ext, path[len(schema)+1:], nil
+		}
+	}
+	return nil, "", fmt.Errorf("unknown schema in path %q", path)
+}
+
+// splitPath splits `attr[filter].sub` into its parts.
+func splitPath(p string) (attr string, filter Filter, sub string, err error) {
+	if i := strings.Index(p, "["); i >= 0 {
+		j := strings.LastIndex(p, "]")
+		if j < i {
+			return "", nil, "", fmt.Errorf("invalid path %q", p)
+		}
+		if filter, err = ParseFilter(p[i+1 : j]); err != nil {
+			return "", nil, "", err
+		}
+		attr = p[:i]
+		sub = strings.TrimPrefix(p[j+1:], ".")
+		return attr, filter, sub, nil
+	}
+	if i := strings.Index(p, "."); i >= 0 {
+		return p[:i], nil, p[i+1:], nil
+	}
+	return p, nil, "", nil
+}
+
+// Matches evaluates a filter against one element of a multi-valued attribute.
+func Matches(elem map[string]interface{}, f Filter) bool {
+	switch t := f.(type) {
+	case Logical:
+		if t.Op == "and" {
+			return Matches(elem, t.Left) && Matches(elem, t.Right)
+		}
+		return Matches(elem, t.Left) || Matches(elem, t.Right)
+	case Not:
+		return !Matches(elem, t.Inner)
+	case Compare:
+		v, ok := elem[findKey(elem, t.Attr)]
+		if t.Op == "pr" {
+			return ok && v != nil && v != ""
+		}
+		a, b := strings.ToLower(fmt.Sprint(v)), strings.ToLower(fmt.Sprint(t.Value))
+		switch t.Op {
+		case "eq":
+			return ok && a == b
+		case "ne":
+			return !ok || a != b
+		case "co":
+			return ok && strings.Contains(a, b)
+		case "sw":
+			return ok && strings.HasPrefix(a, b)
+		case "ew":
+			return ok && strings.HasSuffix(a, b)
+		}
+	}
+	return false
+}
+
+func removeByValue(list, remove []interface{}) []interface{} {
+	drop := map[string]bool{}
+	for _, r := range remove {
+		if obj, ok := r.(map[string]interface{}); ok {
+			drop[fmt.Sprint(obj["value"])] = true
+		}
+	}
+	out := []interface{}{}
+	for _, item := range list {
+		if obj, ok := item.(map[string]interface{}); ok && drop[fmt.Sprint(obj["value"])] {
+			continue
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+// findKey returns the existing key matching name case-insensitively, or name.
+func findKey(m map[string]interface{}, name string) string {
+	if _, ok := m[name]; ok {
+		return name
+	}
+	for k := range m {
+		if strings.EqualFold(k, name) {
+			return k
+		}
+	}
+	return name
+}
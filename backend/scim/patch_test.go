@@ -0,0 +1,96 @@
+package scim
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func doc(t *testing.T, s string) map[string]interface{} {
+	t.Helper()
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestApplyPatch(t *testing.T) {
+	const user = `{"userName":"ada@example.com","active":true,"name":{"givenName":"Ada"},` +
+		`"emails":[{"type":"work","value":"ada@example.com"}],"members":[{"value":"1"},{"value":"2"}]}`
+	tests := []struct {
+		name string
+		ops  string
+		want string
+	}{
+		{
+			name: "replace simple attribute, case-insensitive path",
+			ops:  `[{"op":"Replace","path":"ACTIVE","value":false}]`,
+			want: `{"userName":"ada@example.com","active":false,"name":{"givenName":"Ada"},"emails":[{"type":"work","value":"ada@example.com"}],"members":[{"value":"1"},{"value":"2"}]}`,
+		},
+		{
+			name: "replace without path",
+			ops:  `[{"op":"replace","value":{"active":false,"name.familyName":"Lovelace"}}]`,
+			want: `{"userName":"ada@example.com","active":false,"name":{"givenName":"Ada","familyName":"Lovelace"},"emails":[{"type":"work","value":"ada@example.com"}],"members":[{"value":"1"},{"value":"2"}]}`,
+		},
+		{
+			name: "filtered sub-attribute",
+			ops:  `[{"op":"replace","path":"emails[type eq \"work\"].value","value":"ada@lovelace.dev"}]`,
+			want: `{"userName":"ada@example.com","active":true,"name":{"givenName":"Ada"},"emails":[{"type":"work","value":"ada@lovelace.dev"}],"members":[{"value":"1"},{"value":"2"}]}`,
+		},
+		{
+			name: "filtered path creates a missing element",
+			ops:  `[{"op":"add","path":"emails[type eq \"home\"].value","value":"ada@home.example"}]`,
+			want: `{"userName":"ada@example.com","active":true,"name":{"givenName":"Ada"},"emails":[{"type":"work","value":"ada@example.com"},{"type":"home","value":"ada@home.example"}],"members":[{"value":"1"},{"value":"2"}]}`,
+		},
+		{
+			name: "add and remove members",
+			ops:  `[{"op":"add","path":"members","value":[{"value":"3"}]},{"op":"remove","path":"members","value":[{"value":"1"}]}]`,
+			want: `{"userName":"ada@example.com","active":true,"name":{"givenName":"Ada"},"emails":[{"type":"work","value":"ada@example.com"}],"members":[{"value":"2"},{"value":"3"}]}`,
+		},
+		{
+			name: "remove by filter",
+			ops:  `[{"op":"remove","path":"members[value eq \"2\"]"}]`,
+			want: `{"userName":"ada@example.com","active":true,"name":{"givenName":"Ada"},"emails":[{"type":"work","value":"ada@example.com"}],"members":[{"value":"1"}]}`,
+		},
+		{
+			name: "enterprise extension",
+			ops:  `[{"op":"add","path":"` + EnterpriseUserSchema + `:department","value":"Engineering"},{"op":"remove","path":"urn:ietf:params:scim:schemas:core:2.0:User:name"}]`,
+			want: `{"userName":"ada@example.com","active":true,"emails":[{"type":"work","value":"ada@example.com"}],"members":[{"value":"1"},{"value":"2"}],"` + EnterpriseUserSchema + `":{"department":"Engineering"}}`,
+		},
+	}
+	for _, tt := range tests {
+		var ops []Operation
+		if err := json.Unmarshal([]byte(tt.ops), &ops); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got := doc(t, user)
+		if err := ApplyPatch(got, ops); err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if want := doc(t, tt.want); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, want)
+		}
+	}
+}
+
+func TestApplyPatchRejects(t *testing.T) {
+	for _, ops := range []string{
+		`[{"op":"move","path":"active","value":false}]`,
+		`[{"op":"remove"}]`,
+		`[{"op":"replace","value":"not an object"}]`,
+		`[{"op":"replace","path":"emails[type eq \"work\".value","value":"x"}]`,
+		`[{"op":"replace","path":"emails[type eq].value","value":"x"}]`,
+		`[{"op":"add","path":"urn:example:custom:1.0:foo","value":"x"}]`,
+		`[{"op":"add","path":"` + EnterpriseUserSchema + `","value":"x"}]`,
+	} {
+		var parsed []Operation
+		if err := json.Unmarshal([]byte(ops), &parsed); err != nil {
+			t.Fatalf("%s: %v", ops, err)
+		}
+		if err := ApplyPatch(doc(t, `{"active":true}`), parsed); err == nil {
+			t.Errorf("%s: expected an error", ops)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package scim
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Schema URNs used by the server.
+const (
+	UserSchema           = "urn:ietf:params:scim:schemas:core:2.0:User"
+	GroupSchema          = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	EnterpriseUserSchema = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+	ListResponseSchema   = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	PatchOpSchema        = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ErrorSchema          = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// ContentType is the SCIM media type.
+const ContentType = "application/scim+json"
+
+// MaxPageSize caps "count" on list requests.
+const MaxPageSize = 500
+
+// JSON writes a SCIM response.
+func JSON(c *gin.Context, status int, body interface{}) {
+	c.Header("Content-Type", ContentType)
+	c.JSON(status, body)
+}
+
+// Error writes a SCIM error; scimType may be empty (RFC 7644 §3.12).
+func Error(c *gin.Context, status int, scimType, detail string) {
+	body := gin.H{
+		"schemas": []string{ErrorSchema},
+		"status":  strconv.Itoa(status),
+		"detail":  detail,
+	}
+	if scimType != "" {
+		body["scimType"] = scimType
+	}
+	JSON(c, status, body)
+	c.Abort()
+}
+
+// Page reads startIndex (1-based) and count from the query string.
+func Page(c *gin.Context) (startIndex, count int) {
+	startIndex, _ = strconv.Atoi(c.DefaultQuery("startIndex", "1"))
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	count, err := strconv.Atoi(c.DefaultQuery("count", "100"))
+	if err != nil || count < 0 {
+		count = 100
+	}
+	if count > MaxPageSize {
+		count = MaxPageSize
+	}
+	return startIndex, count
+}
+
+// List writes a ListResponse.
+func List(c *gin.Context, total int64, startIndex int, resources []interface{}) {
+	if resources == nil {
+		resources = []interface{}{}
+	}
+	JSON(c, http.StatusOK, gin.H{
+		"schemas":      []string{ListResponseSchema},
+		"totalResults": total,
+		"startIndex":   startIndex,
+		"itemsPerPage": len(resources),
+		"Resources":    resources,
+	})
+}
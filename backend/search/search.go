@@ -0,0 +1,177 @@
+// Package search maintains the employee directory search index. Every employee
+// has a row in employee_search holding a weighted tsvector (name, then email
+// and designation, then department, location and skills, then custom field
+// values) and the plain words used for typo-tolerant trigram matching.
+// Triggers keep the rows current whatever code path changes an employee, user,
+// department, skill or custom field value.
+package search
+
+import (
+	"regexp"
+	"strings"
+
+	"peoplesoft/config"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// indexSQL creates the index table and the triggers that maintain it.
+// Weights: A name, B email and designation, C department, location and skills,
+// D custom field values (only searched by callers who may see all of them).
+const indexSQL = `
+	CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+	CREATE TABLE IF NOT EXISTS employee_search (
+		employee_id bigint PRIMARY KEY REFERENCES employees(id) ON DELETE CASCADE,
+		document tsvector NOT NULL,
+		words text NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_employee_search_document ON employee_search USING gin (document);
+	CREATE INDEX IF NOT EXISTS idx_employee_search_words ON employee_search USING gin (words gin_trgm_ops);
+
+	CREATE OR REPLACE FUNCTION employee_search_refresh(emp bigint) RETURNS void AS $$
+		INSERT INTO employee_search (employee_id, document, words)
+		SELECT e.id,
+			setweight(to_tsvector('simple', coalesce(u.name, '')), 'A') ||
+			setweight(to_tsvector('simple', regexp_replace(coalesce(u.email, ''), '[@._+-]', ' ', 'g')), 'B') ||
+			setweight(to_tsvector('simple', coalesce(e.designation, '')), 'B') ||
+			setweight(to_tsvector('simple', concat_ws(' ', d.name, e.location, sk.names)), 'C') ||
+			setweight(to_tsvector('simple', coalesce(
+				(SELECT string_agg(v.value, ' ') FROM employee_field_values v WHERE v.employee_id = e.id), '')), 'D'),
+			lower(concat_ws(' ', u.name, u.email, e.designation, d.name, e.location, sk.names))
+		FROM employees e
+		JOIN users u ON u.id = e.user_id
+		LEFT JOIN departments d ON d.id = e.department_id
+		LEFT JOIN LATERAL (
+			SELECT string_agg(s.name, ' ') AS names
+			FROM employee_skills es JOIN skills s ON s.id = es.skill_id
+			WHERE es.employee_id = e.id
+		) sk ON true
+		WHERE e.id = emp
+		ON CONFLICT (employee_id) DO UPDATE SET document = EXCLUDED.document, words = EXCLUDED.words;
+	$$ LANGUAGE sql;
+
+	CREATE OR REPLACE FUNCTION employee_search_on_employee() RETURNS trigger AS $$
+	BEGIN
+		PERFORM employee_search_refresh(NEW.id);
+		RETURN NULL;
+	END;
+	$$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION employee_search_on_user() RETURNS trigger AS $$
+	BEGIN
+		PERFORM employee_search_refresh(e.id) FROM employees e WHERE e.user_id = NEW.id;
+		RETURN NULL;
+	END;
+	$$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION employee_search_on_department() RETURNS trigger AS $$
+	BEGIN
+		PERFORM employee_search_refresh(e.id) FROM employees e WHERE e.department_id = NEW.id;
+		RETURN NULL;
+	END;
+	$$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION employee_search_on_skill() RETURNS trigger AS $$
+	BEGIN
+		PERFORM employee_search_refresh(es.employee_id) FROM employee_skills es WHERE es.skill_id = NEW.id;
+		RETURN NULL;
+	END;
+	$$ LANGUAGE plpgsql;
+
+	CREATE OR REPLACE FUNCTION employee_search_on_employee_data() RETURNS trigger AS $$
+	BEGIN
+		IF TG_OP = 'DELETE' THEN
+			PERFORM employee_search_refresh(OLD.employee_id);
+		ELSE
+			PERFORM employee_search_refresh(NEW.employee_id);
+		END IF;
+		RETURN NULL;
+	END;
+	$$ LANGUAGE plpgsql;
+
+	DROP TRIGGER IF EXISTS employee_search_employee ON employees;
+	CREATE TRIGGER employee_search_employee AFTER INSERT OR UPDATE ON employees
+		FOR EACH ROW EXECUTE FUNCTION employee_search_on_employee();
+
+	DROP TRIGGER IF EXISTS employee_search_user ON users;
+	CREATE TRIGGER employee_search_user AFTER UPDATE OF name, email ON users
+		FOR EACH ROW EXECUTE FUNCTION employee_search_on_user();
+
+	DROP TRIGGER IF EXISTS employee_search_department ON departments;
+	CREATE TRIGGER employee_search_department AFTER UPDATE OF name ON departments
+		FOR EACH ROW EXECUTE FUNCTION employee_search_on_department();
+
+	DROP TRIGGER IF EXISTS employee_search_field_value ON employee_field_values;
+	CREATE TRIGGER employee_search_field_value AFTER INSERT OR UPDATE OR DELETE ON employee_field_values
+		FOR EACH ROW EXECUTE FUNCTION employee_search_on_employee_data();
+
+	DROP TRIGGER IF EXISTS employee_search_employee_skill ON employee_skills;
+	CREATE TRIGGER employee_search_employee_skill AFTER INSERT OR UPDATE OR DELETE ON employee_skills
+		FOR EACH ROW EXECUTE FUNCTION employee_search_on_employee_data();
+
+	DROP TRIGGER IF EXISTS employee_search_skill ON skills;
+	CREATE TRIGGER employee_search_skill AFTER UPDATE OF name ON skills
+		FOR EACH ROW EXECUTE FUNCTION employee_search_on_skill();`
+
+// Init installs the index table and triggers and rebuilds every row, so
+// changes made while an older version was running are picked up. Run it after
+// AutoMigrate.
+func Init() error {
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(indexSQL).Error; err != nil {
+			return err
+		}
+		return tx.Exec("SELECT employee_search_refresh(id) FROM employees").Error
+	})
+}
+
+var termRE = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// Query is a parsed search string.
+type Query struct {
+	Text  string   // lower-cased input, for trigram matching
+	Terms []string // words, each matched as a prefix
+}
+
+// Parse splits q into terms. Punctuation separates terms, so "o'brien" and
+// "jane.doe@" search for their parts.
+func Parse(q string) Query {
+	q = strings.ToLower(strings.TrimSpace(q))
+	return Query{Text: q, Terms: termRE.FindAllString(q, 8)}
+}
+
+// Empty reports whether there is nothing to search for.
+func (q Query) Empty() bool { return len(q.Terms) == 0 }
+
+// tsquery builds a prefix query requiring every term. Without custom, terms
+// only match the A-C weights so custom field values stay hidden.
+func (q Query) tsquery(custom bool) string {
+	suffix := ":*ABC"
+	if custom {
+		suffix = ":*"
+	}
+	parts := make([]string, len(q.Terms))
+	for i, t := range q.Terms {
+		parts[i] = "'" + t + "'" + suffix
+	}
+	return strings.Join(parts, " & ")
+}
+
+// Match joins the index (as es) to db, whose employees are aliased e, and keeps
+// the employees matching every term as a word prefix or, for misspellings,
+// resembling the query closely enough. custom includes custom field values.
+func Match(db *gorm.DB, q Query, custom bool) *gorm.DB {
+	return db.Joins("JOIN employee_search es ON es.employee_id = e.id").
+		Where("(es.document @@ to_tsquery('simple', ?) OR ? <% es.words)", q.tsquery(custom), q.Text)
+}
+
+// OrderByRank sorts a Match result by relevance: full-text rank (name hits
+// weigh most) plus trigram similarity, then by name.
+func OrderByRank(db *gorm.DB, q Query, custom bool) *gorm.DB {
+	return db.Order(clause.OrderBy{Expression: clause.Expr{
+		SQL:  "ts_rank(es.document, to_tsquery('simple', ?)) + word_similarity(?, es.words) DESC, u.name",
+		Vars: []interface{}{q.tsquery(custom), q.Text},
+	}})
+}
@@ -0,0 +1,200 @@
+// Package signing manages the access token signing keys stored in the database:
+// it creates the first key, rotates keys on schedule and loads them into the
+// utils key ring used by GenerateToken/ValidateToken.
+package signing
+
+import (
+	"log"
+	"time"
+
+	"peoplesoft/config"
+	"peoplesoft/models"
+	"peoplesoft/utils"
+
+	"gorm.io/gorm"
+)
+
+// rotationLockID serialises rotation between backend instances (pg advisory lock).
+const rotationLockID = 7207001
+
+// RotationInterval is how long a key signs new tokens (JWT_KEY_ROTATION_INTERVAL, default 720h).
+func RotationInterval() time.Duration {
+	return utils.DurationFromEnv("JWT_KEY_ROTATION_INTERVAL", 30*24*time.Hour)
+}
+
+// PrepublishWindow is how long before activation a new key appears in the JWKS
+// (JWT_KEY_PREPUBLISH, default 24h), so verifiers have it cached before first use.
+func PrepublishWindow() time.Duration {
+	return utils.DurationFromEnv("JWT_KEY_PREPUBLISH", 24*time.Hour)
+}
+
+// verifyGrace keeps a retired key verifiable for the longest access token it
+// signed; impersonation tokens may outlive regular ones.
+func verifyGrace() time.Duration {
+	longest := utils.AccessTokenTTL()
+	if imp := utils.ImpersonationMaxTTL(); imp > longest {
+		longest = imp
+	}
+	return longest + time.Minute
+}
+
+// Init loads the key ring, creating the first key when none exists, and lets
+// token validation reload it on an unknown kid. It is a no-op for HS256.
+func Init() error {
+	if utils.JWTSigningAlg() == utils.AlgHS256 {
+		return nil
+	}
+	if err := ensureKeys(time.Now()); err != nil {
+		return err
+	}
+	utils.ReloadSigningKeys = Reload
+	return Reload()
+}
+
+// Start rotates and reloads keys in the background every check interval
+// (JWT_KEY_CHECK_INTERVAL, default 5m). Reloading also picks up keys created
+// by other instances; tokens signed with one before that reload the key ring
+// on demand.
+func Start() {
+	if utils.JWTSigningAlg() == utils.AlgHS256 {
+		return
+	}
+	interval := utils.DurationFromEnv("JWT_KEY_CHECK_INTERVAL", 5*time.Minute)
+	go func() {
+		for range time.Tick(interval) {
+			if err := ensureKeys(time.Now()); err != nil {
+				log.Printf("signing key rotation failed: %v", err)
+				continue
+			}
+			if err := Reload(); err != nil {
+				log.Printf("signing key reload failed: %v", err)
+			}
+		}
+	}()
+}
+
+// RotateNow retires the current key immediately and activates a fresh one,
+// e.g. after a suspected key compromise. Tokens signed by the old key keep
+// verifying until they expire.
+func RotateNow() (*models.SigningKey, error) {
+	var created *models.SigningKey
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", rotationLockID).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		alg := utils.JWTSigningAlg()
+
+		// Drop pending keys too: the new key takes over right away.
+		if err := tx.Where("algorithm = ? AND activates_at > ?", alg, now).
+			Delete(&models.SigningKey{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.SigningKey{}).
+			Where("algorithm = ? AND retires_at > ?", alg, now).
+			Updates(map[string]interface{}{"retires_at": now, "expires_at": now.Add(verifyGrace())}).Error; err != nil {
+			return err
+		}
+		k, err := createKey(tx, alg, now)
+		created = k
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return created, Reload()
+}
+
+// Reload reads every non-expired key of the configured algorithm into the key ring.
+func Reload() error {
+	alg := utils.JWTSigningAlg()
+	var rows []models.SigningKey
+	if err := config.DB.Where("algorithm = ? AND expires_at > ?", alg, time.Now()).
+		Order("activates_at").Find(&rows).Error; err != nil {
+		return err
+	}
+
+	keys := make([]utils.SigningKey, 0, len(rows))
+	for _, r := range rows {
+		pemStr, err := utils.DecryptSigningKey(r.PrivateKey)
+		if err != nil {
+			log.Printf("signing key %s: cannot decrypt: %v", r.KID, err)
+			continue
+		}
+		priv, err := utils.ParseSigningKeyPEM(r.Algorithm, pemStr)
+		if err != nil {
+			log.Printf("signing key %s: %v", r.KID, err)
+			continue
+		}
+		keys = append(keys, utils.SigningKey{
+			KID:         r.KID,
+			Alg:         r.Algorithm,
+			Private:     priv,
+			ActivatesAt: r.ActivatesAt,
+			RetiresAt:   r.RetiresAt,
+			ExpiresAt:   r.ExpiresAt,
+		})
+	}
+	utils.SetSigningKeys(keys)
+	return nil
+}
+
+// ensureKeys makes sure a key is signing now and, once the current key is
+// within the pre-publish window of retiring, that its successor exists.
+func ensureKeys(now time.Time) error {
+	alg := utils.JWTSigningAlg()
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", rotationLockID).Error; err != nil {
+			return err
+		}
+
+		var current models.SigningKey
+		err := tx.Where("algorithm = ? AND activates_at <= ? AND retires_at > ?", alg, now, now).
+			Order("retires_at desc").First(&current).Error
+		if err == gorm.ErrRecordNotFound {
+			_, err = createKey(tx, alg, now)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		if current.RetiresAt.Sub(now) > PrepublishWindow() {
+			return nil
+		}
+		var pending int64
+		tx.Model(&models.SigningKey{}).
+			Where("algorithm = ? AND activates_at >= ?", alg, current.RetiresAt).
+			Count(&pending)
+		if pending > 0 {
+			return nil
+		}
+		_, err = createKey(tx, alg, current.RetiresAt)
+		return err
+	})
+}
+
+func createKey(tx *gorm.DB, alg string, activatesAt time.Time) (*models.SigningKey, error) {
+	pemStr, kid, err := utils.NewSigningKeyPEM(alg)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := utils.EncryptSigningKey(pemStr)
+	if err != nil {
+		return nil, err
+	}
+	retires := activatesAt.Add(RotationInterval())
+	k := &models.SigningKey{
+		KID:         kid,
+		Algorithm:   alg,
+		PrivateKey:  sealed,
+		ActivatesAt: activatesAt,
+		RetiresAt:   retires,
+		ExpiresAt:   retires.Add(verifyGrace()),
+	}
+	if err := tx.Create(k).Error; err != nil {
+		return nil, err
+	}
+	log.Printf("signing key %s (%s) created, active from %s", kid, alg, activatesAt.Format(time.RFC3339))
+	return k, nil
+}
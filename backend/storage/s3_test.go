@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSecret = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+
+func TestSigningKey(t *testing.T) {
+	// "Examples of how to derive a signing key for Signature Version 4", AWS docs
+	got := hex.EncodeToString(signingKey(testSecret, "20120215", "us-east-1", "iam"))
+	if want := "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestS3Sign(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name, method, key, contentType, body string
+		pathStyle                            bool
+		url, signedHeaders, signature        string
+	}{
+		{
+			"path style put", http.MethodPut, "employees/12/offer letter.pdf", "application/pdf", "hello", true,
+			"https://s3.example.com/docs/employees/12/offer%20letter.pdf",
+			"content-type;host;x-amz-content-sha256;x-amz-date",
+			"f8a9b0147e37e2722e9c9d3cd16f3772d7c49b3ab89299cb606e874b38614e29",
+		},
+		{
+			"virtual host put", http.MethodPut, "employees/12/offer letter.pdf", "application/pdf", "hello", false,
+			"https://docs.s3.example.com/employees/12/offer%20letter.pdf",
+			"content-type;host;x-amz-content-sha256;x-amz-date",
+			"a99fbd1dfb096f362a791a1b08bd73d5868ba9203d49eaafd4a7d68a46c7a989",
+		},
+		{
+			"get with non-ASCII key", http.MethodGet, "a/été.txt", "", "", true,
+			"https://s3.example.com/docs/a/%C3%A9t%C3%A9.txt",
+			"host;x-amz-content-sha256;x-amz-date",
+			"6f5c050d27c7c3308c7dac184390ee1aaa14e4f7fd538ec67c3444b1f7a2505a",
+		},
+	}
+	for _, tt := range tests {
+		s, err := NewS3Store("https://s3.example.com", "eu-west-1", "docs", "AKIDEXAMPLE", testSecret, tt.pathStyle)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := s.objectURL(tt.key)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if u.String() != tt.url {
+			t.Errorf("%s: url %s, want %s", tt.name, u, tt.url)
+		}
+		req, _ := http.NewRequest(tt.method, u.String(), nil)
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		s.sign(req, u.EscapedPath(), []byte(tt.body), now)
+		want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20260102/eu-west-1/s3/aws4_request, SignedHeaders=" +
+			tt.signedHeaders + ", Signature=" + tt.signature
+		if got := req.Header.Get("Authorization"); got != want {
+			t.Errorf("%s: authorization\n got %s\nwant %s", tt.name, got, want)
+		}
+		if req.Header.Get("X-Amz-Date") != "20260102T030405Z" || req.Header.Get("X-Amz-Content-Sha256") != sha256Hex([]byte(tt.body)) {
+			t.Errorf("%s: date or payload hash header missing", tt.name)
+		}
+	}
+}
+
+func TestS3StoreSettings(t *testing.T) {
+	for _, endpoint := range []string{"", "s3.example.com", "ftp://s3.example.com", "https://"} {
+		if _, err := NewS3Store(endpoint, "", "docs", "ak", "sk", true); err == nil {
+			t.Errorf("endpoint %q accepted", endpoint)
+		}
+	}
+	s, err := NewS3Store("http://localhost:9100/", "", "docs", "ak", "sk", true)
+	if err != nil || s.Region != "us-east-1" {
+		t.Fatalf("got %+v, %v", s, err)
+	}
+	for _, key := range []string{"", "/abs", "a//b", "a/../b", "a/./b", `a\b`, "a/"} {
+		if _, err := s.objectURL(key); err == nil {
+			t.Errorf("key %q accepted", key)
+		}
+	}
+	if got := uriEncode("a-Z_0.~ /+%é"); got != "a-Z_0.~%20%2F%2B%25%C3%A9" {
+		t.Errorf("uriEncode = %s", got)
+	}
+}
+
+func TestS3StoreRequests(t *testing.T) {
+	objects := map[string][]byte{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=ak/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-Amz-Content-Sha256") != sha256Hex(body) {
+			http.Error(w, "XAmzContentSHA256Mismatch", http.StatusBadRequest)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			objects[r.URL.Path] = body
+		case http.MethodGet:
+			data, ok := objects[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(data)
+		case http.MethodDelete:
+			if _, ok := objects[r.URL.Path]; !ok {
+				http.NotFound(w, r)
+				return
+			}
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	s, err := NewS3Store(srv.URL, "", "docs", "ak", "sk", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := s.Put(ctx, "employees/1/cv.pdf", []byte("%PDF"), "application/pdf"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := objects["/docs/employees/1/cv.pdf"]; !ok {
+		t.Errorf("stored at %v", objects)
+	}
+	if data, err := s.Get(ctx, "employees/1/cv.pdf"); err != nil || !bytes.Equal(data, []byte("%PDF")) {
+		t.Errorf("get: %q, %v", data, err)
+	}
+	if err := s.Delete(ctx, "employees/1/cv.pdf"); err != nil {
+		t.Errorf("delete: %v", err)
+	}
+	if err := s.Delete(ctx, "employees/1/cv.pdf"); err != nil {
+		t.Errorf("deleting a missing object: %v", err)
+	}
+	if _, err := s.Get(ctx, "employees/1/cv.pdf"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing object: got %v, want ErrNotFound", err)
+	}
+
+	s.AccessKey = "other"
+	if err := s.Put(ctx, "employees/1/cv.pdf", nil, ""); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("rejected request: got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+// Package storage keeps uploaded files (employee documents) outside the
+// database, on the local filesystem or in an S3-compatible object store.
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrNotFound is returned when an object does not exist.
+var ErrNotFound = errors.New("object not found")
+
+// Store saves and loads whole objects by key. Keys are slash-separated paths
+// chosen by the caller (e.g. "employees/12/3f9a...").
+type Store interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Documents is the store for employee documents, selected by Init.
+var Documents Store = &LocalStore{Root: "data/documents"}
+
+// Init picks the document store from DOCUMENT_STORAGE:
+//   - "local" (default): files under DOCUMENT_DIR (default data/documents)
+//   - "s3": bucket DOCUMENT_S3_BUCKET at DOCUMENT_S3_ENDPOINT, signed with
+//     DOCUMENT_S3_ACCESS_KEY/DOCUMENT_S3_SECRET_KEY in DOCUMENT_S3_REGION
+//     (default us-east-1); DOCUMENT_S3_PATH_STYLE=false uses bucket subdomains
+func Init() error {
+	switch strings.ToLower(os.Getenv("DOCUMENT_STORAGE")) {
+	case "", "local":
+		root := os.Getenv("DOCUMENT_DIR")
+		if root == "" {
+			root = "data/documents"
+		}
+		Documents = &LocalStore{Root: root}
+	case "s3":
+		s, err := NewS3Store(
+			os.Getenv("DOCUMENT_S3_ENDPOINT"),
+			os.Getenv("DOCUMENT_S3_REGION"),
+			os.Getenv("DOCUMENT_S3_BUCKET"),
+			os.Getenv("DOCUMENT_S3_ACCESS_KEY"),
+			os.Getenv("DOCUMENT_S3_SECRET_KEY"),
+			os.Getenv("DOCUMENT_S3_PATH_STYLE") != "false",
+		)
+		if err != nil {
+			return err
+		}
+		Documents = s
+	default:
+		return fmt.Errorf("unknown DOCUMENT_STORAGE %q", os.Getenv("DOCUMENT_STORAGE"))
+	}
+	return nil
+}
+
+// validKey rejects empty keys and keys that could escape the store's root.
+func validKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
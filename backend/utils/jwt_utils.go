@@ -5,129 +5,180 @@ import (
 	"os"
 	"time"
 
-	"github.com/MicahParks/keyfunc/v2"
 	"github.com/golang-jwt/jwt/v5"
 )
 
 // ----------------------------
-// 1) LOCAL JWT (HS256)
+// 1) LOCAL JWT (HS256 / RS256 / EdDSA)
 // ----------------------------
 
 type Claims struct {
-	Email string `json:"email"`
-	Role  string `json:"role"`
+	Email          string `json:"email"`
+	Role           string `json:"role"`
+	SessionVersion int    `json:"sv"`
+	// Set only on impersonation tokens: Email/Role/sv are the impersonated
+	// user's, Actor is the HR user acting as them (RFC 8693 "act").
+	Actor           *Actor `json:"act,omitempty"`
+	ImpersonationID uint   `json:"imp,omitempty"`
 	jwt.RegisteredClaims
 }
 
-// GenerateToken creates a JWT for your application
-func GenerateToken(email, role string) (string, error) {
-	exp := time.Now().Add(24 * time.Hour)
+// Actor identifies who is really behind an impersonation token.
+type Actor struct {
+	Subject string `json:"sub"`
+	UserID  uint   `json:"uid"`
+}
 
-	claims := &Claims{
-		Email: email,
-		Role:  role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(exp),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
+// AccessTokenTTL is the lifetime of access tokens (ACCESS_TOKEN_TTL, default 15m).
+// Clients renew them through /api/auth/refresh.
+func AccessTokenTTL() time.Duration {
+	return DurationFromEnv("ACCESS_TOKEN_TTL", 15*time.Minute)
+}
+
+// ImpersonationMaxTTL caps how long an impersonation token may live
+// (IMPERSONATION_MAX_TTL, default 1h).
+func ImpersonationMaxTTL() time.Duration {
+	return DurationFromEnv("IMPERSONATION_MAX_TTL", time.Hour)
+}
+
+// GenerateToken creates a short-lived access JWT for your application.
+// sessionVersion must match users.session_version for the token to be accepted.
+// With JWT_SIGNING_ALG=RS256/EdDSA it is signed by the current key of the ring
+// and carries its "kid"; otherwise it is HS256 with JWT_SECRET.
+func GenerateToken(email, role string, sessionVersion int) (string, error) {
+	now := time.Now()
+	return signAccessToken(&Claims{
+		Email:            email,
+		Role:             role,
+		SessionVersion:   sessionVersion,
+		RegisteredClaims: accessRegisteredClaims(email, now, now.Add(AccessTokenTTL())),
+	}, now)
+}
+
+// GenerateImpersonationToken creates an access JWT for the impersonated user that
+// also names the actor and the impersonation session. It expires at expiresAt and
+// has no refresh token.
+func GenerateImpersonationToken(email, role string, sessionVersion int, actor Actor, impersonationID uint, expiresAt time.Time) (string, error) {
+	now := time.Now()
+	return signAccessToken(&Claims{
+		Email:            email,
+		Role:             role,
+		SessionVersion:   sessionVersion,
+		Actor:            &actor,
+		ImpersonationID:  impersonationID,
+		RegisteredClaims: accessRegisteredClaims(email, now, expiresAt),
+	}, now)
+}
+
+func accessRegisteredClaims(subject string, now, exp time.Time) jwt.RegisteredClaims {
+	return jwt.RegisteredClaims{
+		Issuer:    JWTIssuer(),
+		Subject:   subject,
+		Audience:  jwt.ClaimStrings{JWTAudience()},
+		ExpiresAt: jwt.NewNumericDate(exp),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+	}
+}
+
+func signAccessToken(claims *Claims, now time.Time) (string, error) {
+	alg := JWTSigningAlg()
+	if alg == AlgHS256 {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+	key, err := currentSigningKey(now)
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(alg), claims)
+	token.Header["kid"] = key.KID
+	return token.SignedString(key.Private)
 }
 
-// ValidateToken validates your application's JWT
+// ValidateToken validates your application's JWT. Only the configured algorithm
+// is accepted, and issuer, audience and expiry are required.
 func ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	alg := JWTSigningAlg()
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if alg == AlgHS256 {
+			return []byte(os.Getenv("JWT_SECRET")), nil
 		}
-		return []byte(os.Getenv("JWT_SECRET")), nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, fmt.Errorf("missing kid")
+		}
+		key, err := verificationKey(kid, alg, time.Now())
+		if err != nil && reloadForUnknownKID() {
+			key, err = verificationKey(kid, alg, time.Now())
+		}
+		return key, err
+	},
+		jwt.WithValidMethods([]string{alg}),
+		jwt.WithIssuer(JWTIssuer()),
+		jwt.WithAudience(JWTAudience()),
+		jwt.WithExpirationRequired(),
+	)
 
 	if err != nil {
 		return nil, err
 	}
-
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	if !token.Valid || claims.Email == "" {
+		return nil, fmt.Errorf("invalid token")
 	}
-
-	return nil, fmt.Errorf("invalid token")
+	return claims, nil
 }
 
 // ----------------------------
-// 2) AUTH0 (RS256) VERIFICATION
+// 2) MFA CHALLENGE TOKENS (HS256)
 // ----------------------------
 
-// Auth0Claims represents claims from Auth0 token
-type Auth0Claims struct {
-	Sub   string `json:"sub"`
-	Email string `json:"email"`
-	Name  string `json:"name"`
-}
+// MFAChallengeTTL bounds the time between the password step and the TOTP step.
+const MFAChallengeTTL = 5 * time.Minute
 
-// Global JWKS cache
-var jwks *keyfunc.JWKS
-
-// InitAuth0 loads JWKS keys from Auth0
-func InitAuth0() error {
-	domain := os.Getenv("AUTH0_DOMAIN")
-	if domain == "" {
-		return fmt.Errorf("AUTH0_DOMAIN missing")
-	}
-
-	jwksURL := fmt.Sprintf("https://%s/.well-known/jwks.json", domain)
-
-	var err error
-	jwks, err = keyfunc.Get(jwksURL, keyfunc.Options{
-		RefreshErrorHandler: func(err error) {
-			fmt.Printf("JWKS refresh failed: %v\n", err)
-		},
-		RefreshInterval:   time.Hour,
-		RefreshRateLimit:  time.Minute,
-		RefreshTimeout:    10 * time.Second,
-		RefreshUnknownKID: true,
-	})
+// MFA challenge purposes
+const (
+	MFAPurposeVerify = "mfa_verify" // user is enrolled, must present a code
+	MFAPurposeEnroll = "mfa_enroll" // role requires MFA, user must enroll first
+)
 
-	return err
+// MFAChallengeClaims is issued after a correct password when a second factor is
+// needed. It carries no email/role, so AuthRequired never accepts it as an access token.
+type MFAChallengeClaims struct {
+	UserID  uint   `json:"uid"`
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
 }
 
-// ValidateAuth0Token verifies RS256 ID token from Auth0
-func ValidateAuth0Token(tokenString string) (*Auth0Claims, error) {
-	if jwks == nil {
-		return nil, fmt.Errorf("JWKS not initialized, call InitAuth0() first")
-	}
-
-	// Parse token with JWKS
-	token, err := jwt.Parse(tokenString, jwks.Keyfunc)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse token: %w", err)
-	}
-
-	// Extract claims
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok || !token.Valid {
-		return nil, fmt.Errorf("invalid auth0 token")
-	}
-
-	// Convert to Auth0Claims struct
-	auth0Claims := &Auth0Claims{}
-
-	if sub, ok := claims["sub"].(string); ok {
-		auth0Claims.Sub = sub
+// GenerateMFAChallenge issues a short-lived token binding the second step to the user.
+func GenerateMFAChallenge(userID uint, purpose string) (string, error) {
+	now := time.Now()
+	claims := &MFAChallengeClaims{
+		UserID:  userID,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   fmt.Sprint(userID),
+			Audience:  jwt.ClaimStrings{"mfa-challenge"},
+			ExpiresAt: jwt.NewNumericDate(now.Add(MFAChallengeTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
 	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(os.Getenv("JWT_SECRET")))
+}
 
-	if email, ok := claims["email"].(string); ok {
-		auth0Claims.Email = email
-	} else {
-		return nil, fmt.Errorf("email claim not found in token")
+// ValidateMFAChallenge checks a challenge token and its purpose.
+func ValidateMFAChallenge(tokenString, purpose string) (*MFAChallengeClaims, error) {
+	claims := &MFAChallengeClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	}, jwt.WithValidMethods([]string{"HS256"}), jwt.WithAudience("mfa-challenge"))
+	if err != nil || !token.Valid {
+		return nil, fmt.Errorf("invalid or expired mfa token")
 	}
-
-	if name, ok := claims["name"].(string); ok {
-		auth0Claims.Name = name
+	if claims.Purpose != purpose || claims.UserID == 0 {
+		return nil, fmt.Errorf("invalid or expired mfa token")
 	}
-
-	return auth0Claims, nil
+	return claims, nil
 }
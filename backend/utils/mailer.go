@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mailer delivers transactional email (password resets, notifications).
+type Mailer interface {
+	Send(to, subject, body string) error
+}
+
+// Mail is the process-wide mailer, selected by InitMailer.
+var Mail Mailer = LogMailer{}
+
+// InitMailer picks the mail sender from MAIL_DRIVER:
+//   - "log" (default): prints messages to the server log
+//   - "file": appends messages to MAIL_FILE (default mail.log)
+//   - "smtp": sends through SMTP_HOST/SMTP_PORT with SMTP_USER/SMTP_PASS, from MAIL_FROM
+func InitMailer() error {
+	switch strings.ToLower(os.Getenv("MAIL_DRIVER")) {
+	case "", "log":
+		Mail = LogMailer{}
+	case "file":
+		path := os.Getenv("MAIL_FILE")
+		if path == "" {
+			path = "mail.log"
+		}
+		Mail = &FileMailer{Path: path}
+	case "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return fmt.Errorf("SMTP_HOST missing")
+		}
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		Mail = &SMTPMailer{
+			Addr: host + ":" + port,
+			Host: host,
+			User: os.Getenv("SMTP_USER"),
+			Pass: os.Getenv("SMTP_PASS"),
+			From: os.Getenv("MAIL_FROM"),
+		}
+	default:
+		return fmt.Errorf("unknown MAIL_DRIVER %q", os.Getenv("MAIL_DRIVER"))
+	}
+	return nil
+}
+
+// LogMailer writes messages to the server log. Intended for local development.
+type LogMailer struct{}
+
+func (LogMailer) Send(to, subject, body string) error {
+	log.Printf("📧 mail to=%s subject=%q\n%s", to, subject, body)
+	return nil
+}
+
+// FileMailer appends messages to a file so they can be inspected locally.
+type FileMailer struct {
+	Path string
+	mu   sync.Mutex
+}
+
+func (m *FileMailer) Send(to, subject, body string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = fmt.Fprintf(f, "Date: %s\nTo: %s\nSubject: %s\n\n%s\n\n----\n",
+		time.Now().Format(time.RFC1123Z), to, subject, body)
+	return err
+}
+
+// SMTPMailer sends plain-text mail through an SMTP relay.
+type SMTPMailer struct {
+	Addr string
+	Host string
+	User string
+	Pass string
+	From string
+}
+
+func (m *SMTPMailer) Send(to, subject, body string) error {
+	var auth smtp.Auth
+	if m.User != "" {
+		auth = smtp.PlainAuth("", m.User, m.Pass, m.Host)
+	}
+	msg := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n%s",
+		m.From, to, subject, body)
+	return smtp.SendMail(m.Addr, auth, m.From, []string{to}, []byte(msg))
+}
@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// PasswordPolicy describes the rules enforced on local account passwords.
+type PasswordPolicy struct {
+	MinLength      int
+	RequireUpper   bool
+	RequireLower   bool
+	RequireDigit   bool
+	RequireSymbol  bool
+	BlocklistFile  string
+	blocklist      map[string]struct{}
+	blocklistError error
+}
+
+var (
+	policy     *PasswordPolicy
+	policyOnce sync.Once
+)
+
+// GetPasswordPolicy returns the policy configured through the environment:
+// PASSWORD_MIN_LENGTH (default 8), PASSWORD_REQUIRE_UPPER/LOWER/DIGIT (default true),
+// PASSWORD_REQUIRE_SYMBOL (default false) and PASSWORD_BLOCKLIST_FILE, a file with
+// one breached/common password per line.
+func GetPasswordPolicy() *PasswordPolicy {
+	policyOnce.Do(func() {
+		policy = &PasswordPolicy{
+			MinLength:     IntFromEnv("PASSWORD_MIN_LENGTH", 8),
+			RequireUpper:  boolFromEnv("PASSWORD_REQUIRE_UPPER", true),
+			RequireLower:  boolFromEnv("PASSWORD_REQUIRE_LOWER", true),
+			RequireDigit:  boolFromEnv("PASSWORD_REQUIRE_DIGIT", true),
+			RequireSymbol: boolFromEnv("PASSWORD_REQUIRE_SYMBOL", false),
+			BlocklistFile: os.Getenv("PASSWORD_BLOCKLIST_FILE"),
+		}
+		policy.blocklist, policy.blocklistError = loadBlocklist(policy.BlocklistFile)
+		if policy.blocklistError != nil {
+			fmt.Printf("password blocklist not loaded: %v\n", policy.blocklistError)
+		}
+	})
+	return policy
+}
+
+// Validate returns a user-facing error describing the first rule the password breaks.
+func (p *PasswordPolicy) Validate(password, email string) error {
+	if strings.TrimSpace(password) == "" {
+		return fmt.Errorf("password is required")
+	}
+	if len([]rune(password)) < p.MinLength {
+		return fmt.Errorf("password must be at least %d characters", p.MinLength)
+	}
+
+	var upper, lower, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		case unicode.IsPunct(r) || unicode.IsSymbol(r):
+			symbol = true
+		}
+	}
+	if p.RequireUpper && !upper {
+		return fmt.Errorf("password must contain an uppercase letter")
+	}
+	if p.RequireLower && !lower {
+		return fmt.Errorf("password must contain a lowercase letter")
+	}
+	if p.RequireDigit && !digit {
+		return fmt.Errorf("password must contain a digit")
+	}
+	if p.RequireSymbol && !symbol {
+		return fmt.Errorf("password must contain a symbol")
+	}
+
+	lowered := strings.ToLower(password)
+	if local, _, ok := strings.Cut(strings.ToLower(email), "@"); ok && len(local) >= 3 && strings.Contains(lowered, local) {
+		return fmt.Errorf("password must not contain your email address")
+	}
+	if _, found := p.blocklist[lowered]; found {
+		return fmt.Errorf("password appears in a list of breached passwords, choose another")
+	}
+	return nil
+}
+
+func loadBlocklist(path string) (map[string]struct{}, error) {
+	list := map[string]struct{}{}
+	if path == "" {
+		return list, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return list, err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			list[strings.ToLower(line)] = struct{}{}
+		}
+	}
+	return list, sc.Err()
+}
+
+func boolFromEnv(key string, def bool) bool {
+	if v, err := strconv.ParseBool(os.Getenv(key)); err == nil {
+		return v
+	}
+	return def
+}
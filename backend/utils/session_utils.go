@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"log"
+	"os"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// RefreshTokenTTL is the lifetime of a refresh token (REFRESH_TOKEN_TTL, default 7 days).
+func RefreshTokenTTL() time.Duration {
+	return DurationFromEnv("REFRESH_TOKEN_TTL", 7*24*time.Hour)
+}
+
+// NewOpaqueToken returns a random URL-safe token and the hash to persist for it.
+func NewOpaqueToken() (raw, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err = rand.Read(b); err != nil {
+		return "", "", err
+	}
+	raw = base64.RawURLEncoding.EncodeToString(b)
+	return raw, HashToken(raw), nil
+}
+
+// HashToken returns the hex SHA-256 of an opaque token. Tokens are never stored in clear.
+func HashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+// DurationFromEnv parses a Go duration (e.g. "15m", "168h") from the environment.
+func DurationFromEnv(key string, def time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+		log.Printf("invalid %s=%q, using %s", key, v, def)
+	}
+	return def
+}
+
+// IntFromEnv parses a positive integer from the environment.
+func IntFromEnv(key string, def int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+		log.Printf("invalid %s=%q, using %d", key, v, def)
+	}
+	return def
+}
+
+// Truncate cuts s to at most n bytes without splitting a UTF-8 character, for
+// request data stored in size-limited columns.
+func Truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
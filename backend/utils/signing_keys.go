@@ -0,0 +1,249 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Supported access token algorithms (JWT_SIGNING_ALG).
+const (
+	AlgHS256 = "HS256" // shared JWT_SECRET, no JWKS (legacy default)
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// JWTSigningAlg returns the configured access token algorithm. Tokens signed
+// with any other algorithm are rejected, so switching it ends existing sessions
+// once their access tokens expire (refresh tokens keep working).
+func JWTSigningAlg() string {
+	switch strings.TrimSpace(os.Getenv("JWT_SIGNING_ALG")) {
+	case "RS256":
+		return AlgRS256
+	case "EdDSA", "EDDSA", "Ed25519":
+		return AlgEdDSA
+	default:
+		return AlgHS256
+	}
+}
+
+// JWTIssuer is the "iss" of access tokens (JWT_ISSUER, default "peoplesoft").
+func JWTIssuer() string {
+	if v := os.Getenv("JWT_ISSUER"); v != "" {
+		return v
+	}
+	return "peoplesoft"
+}
+
+// JWTAudience is the "aud" of access tokens (JWT_AUDIENCE, default "peoplesoft-api").
+func JWTAudience() string {
+	if v := os.Getenv("JWT_AUDIENCE"); v != "" {
+		return v
+	}
+	return "peoplesoft-api"
+}
+
+// SigningKey is an asymmetric key in the in-memory key ring.
+//
+//	ActivatesAt..RetiresAt  new tokens are signed with it
+//	until ExpiresAt         tokens signed with it still verify and it stays in the JWKS
+//
+// Keys are published before ActivatesAt so verifiers can cache them ahead of use.
+type SigningKey struct {
+	KID         string
+	Alg         string
+	Private     crypto.Signer
+	ActivatesAt time.Time
+	RetiresAt   time.Time
+	ExpiresAt   time.Time
+}
+
+var (
+	keyRingMu sync.RWMutex
+	keyRing   []SigningKey
+)
+
+// SetSigningKeys replaces the key ring used by GenerateToken and ValidateToken.
+func SetSigningKeys(keys []SigningKey) {
+	keyRingMu.Lock()
+	defer keyRingMu.Unlock()
+	keyRing = append([]SigningKey(nil), keys...)
+}
+
+// currentSigningKey picks the most recently activated key that is still signing.
+func currentSigningKey(now time.Time) (*SigningKey, error) {
+	keyRingMu.RLock()
+	defer keyRingMu.RUnlock()
+	var best *SigningKey
+	for i := range keyRing {
+		k := &keyRing[i]
+		if now.Before(k.ActivatesAt) || !now.Before(k.RetiresAt) {
+			continue
+		}
+		if best == nil || k.ActivatesAt.After(best.ActivatesAt) {
+			best = k
+		}
+	}
+	if best == nil {
+		return nil, fmt.Errorf("no active signing key")
+	}
+	return best, nil
+}
+
+// ReloadSigningKeys, when set, refreshes the key ring from storage (package
+// signing sets it). ValidateToken calls it when a token names a kid the ring
+// does not know, e.g. right after another instance rotated keys.
+var ReloadSigningKeys func() error
+
+var (
+	kidReloadMu   sync.Mutex
+	kidReloadLast time.Time
+)
+
+// reloadForUnknownKID reloads the key ring at most once per
+// JWT_KEY_MISS_RELOAD_INTERVAL (default 10s), so tokens with made-up kids
+// cannot hammer the database. It reports whether the ring was reloaded while
+// the caller waited, in which case the lookup is worth retrying.
+func reloadForUnknownKID() bool {
+	if ReloadSigningKeys == nil {
+		return false
+	}
+	start := time.Now()
+	kidReloadMu.Lock()
+	defer kidReloadMu.Unlock()
+	if !kidReloadLast.Before(start) {
+		return true // another request reloaded meanwhile
+	}
+	if time.Since(kidReloadLast) < DurationFromEnv("JWT_KEY_MISS_RELOAD_INTERVAL", 10*time.Second) {
+		return false
+	}
+	kidReloadLast = time.Now()
+	if err := ReloadSigningKeys(); err != nil {
+		log.Printf("signing key reload for unknown kid failed: %v", err)
+		return false
+	}
+	return true
+}
+
+// verificationKey returns the public key for a kid that has not expired yet.
+func verificationKey(kid, alg string, now time.Time) (crypto.PublicKey, error) {
+	keyRingMu.RLock()
+	defer keyRingMu.RUnlock()
+	for _, k := range keyRing {
+		if k.KID == kid && k.Alg == alg && now.Before(k.ExpiresAt) {
+			return k.Private.Public(), nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// JWK is the public part of a signing key as published in the JWKS.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// PublicJWKS lists every key that is upcoming, signing, or still verifying.
+func PublicJWKS() []JWK {
+	keyRingMu.RLock()
+	defer keyRingMu.RUnlock()
+	now := time.Now()
+	out := []JWK{}
+	for _, k := range keyRing {
+		if !now.Before(k.ExpiresAt) {
+			continue
+		}
+		jwk := JWK{Kid: k.KID, Use: "sig", Alg: k.Alg}
+		switch pub := k.Private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		out = append(out, jwk)
+	}
+	return out
+}
+
+// NewSigningKeyPEM generates a private key for alg and returns it PKCS#8 PEM
+// encoded together with its kid (a truncated SHA-256 of the public key).
+func NewSigningKeyPEM(alg string) (privPEM string, kid string, err error) {
+	var priv crypto.Signer
+	switch alg {
+	case AlgRS256:
+		priv, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgEdDSA:
+		_, priv, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return "", "", fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return "", "", err
+	}
+	kid, err = keyID(priv)
+	if err != nil {
+		return "", "", err
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})), kid, nil
+}
+
+// ParseSigningKeyPEM decodes a key produced by NewSigningKeyPEM and checks it matches alg.
+func ParseSigningKeyPEM(alg, privPEM string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(privPEM))
+	if block == nil {
+		return nil, fmt.Errorf("invalid key PEM")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if alg == AlgRS256 {
+			return k, nil
+		}
+	case ed25519.PrivateKey:
+		if alg == AlgEdDSA {
+			return k, nil
+		}
+	}
+	return nil, fmt.Errorf("key does not match algorithm %s", alg)
+}
+
+func keyID(priv crypto.Signer) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(priv.Public())
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:12]), nil
+}
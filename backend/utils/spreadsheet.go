@@ -0,0 +1,331 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// Spreadsheet formats accepted by ReadSpreadsheet and written by WriteSpreadsheet.
+const (
+	FormatCSV  = "csv"
+	FormatXLSX = "xlsx"
+)
+
+// SpreadsheetFormat picks the format from a file name, defaulting to CSV.
+func SpreadsheetFormat(filename string) string {
+	if strings.EqualFold(path.Ext(filename), ".xlsx") {
+		return FormatXLSX
+	}
+	return FormatCSV
+}
+
+// ReadSpreadsheet returns the rows of a CSV file or of the first worksheet of
+// an XLSX workbook. Cells are returned as text; empty trailing rows are dropped.
+// Worksheets addressing a row at or beyond maxRows or a column at or beyond
+// maxCols are rejected, as cell references would otherwise let a small file
+// allocate arbitrarily large tables.
+func ReadSpreadsheet(format string, data []byte, maxRows, maxCols int) ([][]string, error) {
+	var rows [][]string
+	var err error
+	if format == FormatXLSX {
+		rows, err = readXLSX(data, maxRows, maxCols)
+	} else {
+		r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+		r.FieldsPerRecord = -1
+		r.TrimLeadingSpace = true
+		rows, err = r.ReadAll()
+	}
+	if err != nil {
+		return nil, err
+	}
+	for len(rows) > 0 && blankRow(rows[len(rows)-1]) {
+		rows = rows[:len(rows)-1]
+	}
+	return rows, nil
+}
+
+// WriteSpreadsheet writes rows (header first) as CSV or as a single-sheet XLSX workbook.
+func WriteSpreadsheet(w io.Writer, format string, rows [][]string) error {
+	if format == FormatXLSX {
+		return writeXLSX(w, rows)
+	}
+	cw := csv.NewWriter(w)
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func blankRow(row []string) bool {
+	for _, v := range row {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// ---- XLSX reading (first worksheet, shared and inline strings) ----
+
+// xlsxMaxEntryBytes caps the decompressed size of each workbook part read.
+const xlsxMaxEntryBytes = 64 << 20
+
+var errXLSXTooLarge = fmt.Errorf("xlsx file too large (a part exceeds %d MB uncompressed)", xlsxMaxEntryBytes>>20)
+
+type xlsxText struct {
+	T    string `xml:"t"`
+	Runs []struct {
+		T string `xml:"t"`
+	} `xml:"r"`
+}
+
+func (t xlsxText) String() string {
+	if len(t.Runs) == 0 {
+		return t.T
+	}
+	var b strings.Builder
+	for _, r := range t.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		R     int `xml:"r,attr"`
+		Cells []struct {
+			Ref    string   `xml:"r,attr"`
+			Type   string   `xml:"t,attr"`
+			Value  string   `xml:"v"`
+			Inline xlsxText `xml:"is"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+func readXLSX(data []byte, maxRows, maxCols int) ([][]string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, errors.New("not a valid xlsx file")
+	}
+	files := map[string]*zip.File{}
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	var shared []string
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		var sst struct {
+			Items []xlsxText `xml:"si"`
+		}
+		if err := decodeZipXML(f, &sst); err != nil {
+			return nil, err
+		}
+		for _, si := range sst.Items {
+			shared = append(shared, si.String())
+		}
+	}
+
+	f, ok := files[firstSheetPath(files)]
+	if !ok {
+		return nil, errors.New("xlsx file has no worksheet")
+	}
+	var sheet xlsxSheet
+	if err := decodeZipXML(f, &sheet); err != nil {
+		return nil, err
+	}
+
+	var rows [][]string
+	for i, r := range sheet.Rows {
+		idx := r.R - 1
+		if idx < 0 {
+			idx = i
+		}
+		if idx >= maxRows {
+			return nil, fmt.Errorf("too many rows (max %d)", maxRows)
+		}
+		for len(rows) <= idx {
+			rows = append(rows, nil)
+		}
+		var row []string
+		for j, cell := range r.Cells {
+			col := columnIndex(cell.Ref)
+			if col < 0 {
+				col = j
+			}
+			if col >= maxCols {
+				return nil, fmt.Errorf("cell %s: too many columns (max %d)", cell.Ref, maxCols)
+			}
+			for len(row) <= col {
+				row = append(row, "")
+			}
+			switch cell.Type {
+			case "s":
+				n, err := strconv.Atoi(cell.Value)
+				if err != nil || n < 0 || n >= len(shared) {
+					return nil, fmt.Errorf("cell %s: bad shared string index", cell.Ref)
+				}
+				row[col] = shared[n]
+			case "inlineStr":
+				row[col] = cell.Inline.String()
+			default:
+				row[col] = cell.Value
+			}
+		}
+		rows[idx] = row
+	}
+	return rows, nil
+}
+
+// firstSheetPath resolves the first <sheet> of the workbook through its
+// relationship, falling back to the conventional sheet1 path.
+func firstSheetPath(files map[string]*zip.File) string {
+	const fallback = "xl/worksheets/sheet1.xml"
+	var wb struct {
+		Sheets []struct {
+			RID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Items []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	wf, ok1 := files["xl/workbook.xml"]
+	rf, ok2 := files["xl/_rels/workbook.xml.rels"]
+	if !ok1 || !ok2 || decodeZipXML(wf, &wb) != nil || decodeZipXML(rf, &rels) != nil || len(wb.Sheets) == 0 {
+		return fallback
+	}
+	for _, r := range rels.Items {
+		if r.ID == wb.Sheets[0].RID {
+			if strings.HasPrefix(r.Target, "/") {
+				return strings.TrimPrefix(r.Target, "/")
+			}
+			return path.Join("xl", r.Target)
+		}
+	}
+	return fallback
+}
+
+func decodeZipXML(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	err = xml.NewDecoder(&cappedReader{r: rc, n: xlsxMaxEntryBytes}).Decode(v)
+	if errors.Is(err, errXLSXTooLarge) {
+		return errXLSXTooLarge
+	}
+	return err
+}
+
+// cappedReader fails with errXLSXTooLarge once more than n bytes were read,
+// so zip bombs are not inflated further.
+type cappedReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *cappedReader) Read(p []byte) (int, error) {
+	if c.n <= 0 {
+		return 0, errXLSXTooLarge
+	}
+	if int64(len(p)) > c.n {
+		p = p[:c.n]
+	}
+	n, err := c.r.Read(p)
+	c.n -= int64(n)
+	return n, err
+}
+
+// columnIndex turns the letters of a cell reference ("C7") into a 0-based column.
+func columnIndex(ref string) int {
+	n := 0
+	for _, ch := range ref {
+		if ch < 'A' || ch > 'Z' {
+			break
+		}
+		if n = n*26 + int(ch-'A'+1); n > 1<<20 { // far past XFD; stop before overflowing
+			break
+		}
+	}
+	return n - 1
+}
+
+// ---- XLSX writing (one sheet, inline strings) ----
+
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types"><Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/><Default Extension="xml" ContentType="application/xml"/><Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/><Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/><Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/></Types>`
+	xlsxRootRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/></Relationships>`
+	xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets><sheet name="Sheet1" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"><Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/><Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/></Relationships>`
+	xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts><fills count="1"><fill><patternFill patternType="none"/></fill></fills><borders count="1"><border/></borders><cellStyleXfs count="1"><xf/></cellStyleXfs><cellXfs count="1"><xf/></cellXfs></styleSheet>`
+)
+
+func writeXLSX(w io.Writer, rows [][]string) error {
+	zw := zip.NewWriter(w)
+	parts := []struct{ name, body string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRootRels},
+		{"xl/workbook.xml", xlsxWorkbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, p := range parts {
+		f, err := zw.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, p.body); err != nil {
+			return err
+		}
+	}
+
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n")
+	b.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	for i, row := range rows {
+		fmt.Fprintf(&b, `<row r="%d">`, i+1)
+		for j, v := range row {
+			fmt.Fprintf(&b, `<c r="%s%d" t="inlineStr"><is><t xml:space="preserve">`, columnName(j), i+1)
+			if err := xml.EscapeText(&b, []byte(v)); err != nil {
+				return err
+			}
+			b.WriteString(`</t></is></c>`)
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	if _, err := f.Write(b.Bytes()); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// columnName turns a 0-based column index into its letters (0 → A, 27 → AB).
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
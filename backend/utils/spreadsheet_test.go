@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// xlsxWith builds a minimal workbook whose first sheet is the given sheetData body.
+func xlsxWith(t *testing.T, sheetData string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` +
+		sheetData + `</sheetData></worksheet>`))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestSpreadsheetRoundTrip(t *testing.T) {
+	rows := [][]string{
+		{"name", "email", "location"},
+		{"Ada Lovelace", "ada@example.com", "London & <Pune>"},
+		{"Alan Turing", "", "Manchester"},
+	}
+	for _, format := range []string{FormatCSV, FormatXLSX} {
+		var buf bytes.Buffer
+		if err := WriteSpreadsheet(&buf, format, rows); err != nil {
+			t.Fatalf("%s: write: %v", format, err)
+		}
+		got, err := ReadSpreadsheet(format, buf.Bytes(), 10, 5)
+		if err != nil {
+			t.Fatalf("%s: read: %v", format, err)
+		}
+		if !reflect.DeepEqual(got, rows) {
+			t.Errorf("%s: got %q, want %q", format, got, rows)
+		}
+	}
+}
+
+func TestReadXLSXCells(t *testing.T) {
+	data := xlsxWith(t, `<row r="1"><c r="A1" t="inlineStr"><is><t>name</t></is></c><c r="C1" t="inlineStr"><is><r><t>lo</t></r><r><t>cation</t></r></is></c></row>`+
+		`<row r="3"><c r="B3"><v>42</v></c></row>`)
+	got, err := ReadSpreadsheet(FormatXLSX, data, 10, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"name", "", "location"}, nil, {"", "42"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestReadXLSXLimits(t *testing.T) {
+	tests := []struct {
+		name, sheet, err string
+	}{
+		{"row index", `<row r="2000000000"><c r="A2000000000"><v>1</v></c></row>`, "too many rows"},
+		{"row count", `<row><c><v>1</v></c></row><row><c><v>1</v></c></row><row><c><v>1</v></c></row><row><c><v>1</v></c></row>`, "too many rows"},
+		{"column", `<row r="1"><c r="XFD1"><v>1</v></c></row>`, "too many columns"},
+		{"overflowing column", `<row r="1"><c r="ZZZZZZZZZZZZZZZZ1"><v>1</v></c></row>`, "too many columns"},
+		{"bad shared string", `<row r="1"><c r="A1" t="s"><v>3</v></c></row>`, "bad shared string"},
+	}
+	for _, tt := range tests {
+		_, err := ReadSpreadsheet(FormatXLSX, xlsxWith(t, tt.sheet), 3, 5)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestReadXLSXDecompressedCap(t *testing.T) {
+	// Highly compressible padding inflating past the cap
+	pad := strings.Repeat(" ", xlsxMaxEntryBytes)
+	_, err := ReadSpreadsheet(FormatXLSX, xlsxWith(t, pad), 3, 5)
+	if err != errXLSXTooLarge {
+		t.Errorf("got %v, want %v", err, errXLSXTooLarge)
+	}
+}
+
+func TestColumnNames(t *testing.T) {
+	for i, name := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(i); got != name {
+			t.Errorf("columnName(%d) = %s, want %s", i, got, name)
+		}
+		if got := columnIndex(name + "12"); got != i {
+			t.Errorf("columnIndex(%s12) = %d, want %d", name, got, i)
+		}
+	}
+}
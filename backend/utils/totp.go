@@ -0,0 +1,220 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// ----------------------------
+// TOTP (RFC 6238, SHA-1, 6 digits, 30s step)
+// ----------------------------
+
+const (
+	totpDigits = 6
+	totpPeriod = 30
+	totpSkew   = 1 // accept one step before/after to tolerate clock drift
+)
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret returns a random 160-bit base32 secret.
+func GenerateTOTPSecret() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return b32.EncodeToString(b), nil
+}
+
+// TOTPProvisioningURI builds the otpauth:// URI rendered as a QR code by authenticator apps.
+func TOTPProvisioningURI(secret, account string) string {
+	issuer := os.Getenv("MFA_ISSUER")
+	if issuer == "" {
+		issuer = "PeopleSoft"
+	}
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(totpDigits))
+	v.Set("period", fmt.Sprint(totpPeriod))
+	return "otpauth://totp/" + url.PathEscape(issuer+":"+account) + "?" + v.Encode()
+}
+
+// TOTPCode computes the code for the given time step.
+func TOTPCode(secret string, step int64) (string, error) {
+	key, err := b32.DecodeString(strings.ToUpper(strings.TrimSpace(secret)))
+	if err != nil {
+		return "", fmt.Errorf("invalid secret: %w", err)
+	}
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, bin%1000000), nil
+}
+
+// ValidateTOTP checks a code against the secret at time t and returns the matched
+// time step. Callers must reject steps <= the last accepted one to prevent replay.
+func ValidateTOTP(secret, code string, t time.Time) (int64, bool) {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != totpDigits {
+		return 0, false
+	}
+	now := t.Unix() / totpPeriod
+	for i := -totpSkew; i <= totpSkew; i++ {
+		want, err := TOTPCode(secret, now+int64(i))
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(want), []byte(code)) == 1 {
+			return now + int64(i), true
+		}
+	}
+	return 0, false
+}
+
+// GenerateRecoveryCodes returns n human-friendly single-use codes (xxxxx-xxxxx).
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	const alphabet = "abcdefghjkmnpqrstuvwxyz23456789"
+	codes := make([]string, n)
+	buf := make([]byte, 10)
+	for i := range codes {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		var sb strings.Builder
+		for j, b := range buf {
+			if j == 5 {
+				sb.WriteByte('-')
+			}
+			sb.WriteByte(alphabet[int(b)%len(alphabet)])
+		}
+		codes[i] = sb.String()
+	}
+	return codes, nil
+}
+
+// NormalizeRecoveryCode lowercases and strips spaces so codes can be typed loosely.
+func NormalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), " ", ""))
+}
+
+// ----------------------------
+// SECRET ENCRYPTION AT REST (AES-256-GCM)
+// ----------------------------
+
+// mfaKey derives the encryption key from MFA_ENCRYPTION_KEY, falling back to JWT_SECRET.
+func mfaKey() []byte {
+	k := os.Getenv("MFA_ENCRYPTION_KEY")
+	if k == "" {
+		k = os.Getenv("JWT_SECRET")
+	}
+	sum := sha256.Sum256([]byte(k))
+	return sum[:]
+}
+
+// signingKeyKey derives the key sealing token signing keys from
+// SIGNING_KEY_ENCRYPTION_KEY. Unset, it is the MFA key, so one secret then
+// protects both TOTP seeds and signing keys; set it in production.
+func signingKeyKey() []byte {
+	k := os.Getenv("SIGNING_KEY_ENCRYPTION_KEY")
+	if k == "" {
+		return mfaKey()
+	}
+	sum := sha256.Sum256([]byte(k))
+	return sum[:]
+}
+
+// EncryptSecret seals a secret (TOTP seed, bank details) for storage.
+func EncryptSecret(plain string) (string, error) {
+	return seal(mfaKey(), plain)
+}
+
+// DecryptSecret opens a value produced by EncryptSecret.
+func DecryptSecret(sealed string) (string, error) {
+	return unseal(mfaKey(), sealed)
+}
+
+// EncryptSigningKey seals a token signing key for storage.
+func EncryptSigningKey(plain string) (string, error) {
+	return seal(signingKeyKey(), plain)
+}
+
+// DecryptSigningKey opens a value produced by EncryptSigningKey. Keys sealed
+// with the MFA key, before SIGNING_KEY_ENCRYPTION_KEY was set, still open.
+func DecryptSigningKey(sealed string) (string, error) {
+	plain, err := unseal(signingKeyKey(), sealed)
+	if err != nil && os.Getenv("SIGNING_KEY_ENCRYPTION_KEY") != "" {
+		if legacy, lerr := unseal(mfaKey(), sealed); lerr == nil {
+			return legacy, nil
+		}
+	}
+	return plain, err
+}
+
+func seal(key []byte, plain string) (string, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(gcm.Seal(nonce, nonce, []byte(plain), nil)), nil
+}
+
+func unseal(key []byte, sealed string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+	if len(raw) < gcm.NonceSize() {
+		return "", fmt.Errorf("ciphertext too short")
+	}
+	plain, err := gcm.Open(nil, raw[:gcm.NonceSize()], raw[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// MFARequiredForRole reports whether MFA_REQUIRED_ROLES (comma separated) lists the role.
+func MFARequiredForRole(role string) bool {
+	for _, r := range strings.Split(os.Getenv("MFA_REQUIRED_ROLES"), ",") {
+		if strings.EqualFold(strings.TrimSpace(r), role) && role != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 seed of RFC 6238 appendix B ("12345678901234567890").
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// The RFC lists 8-digit codes; 6-digit codes are their last six digits
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tt := range tests {
+		got, err := TOTPCode(rfc6238Secret, tt.unix/totpPeriod)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.code {
+			t.Errorf("T=%d: got %s, want %s", tt.unix, got, tt.code)
+		}
+		// Lower case and surrounding spaces in the secret are accepted
+		if got, _ := TOTPCode(" "+strings.ToLower(rfc6238Secret)+" ", tt.unix/totpPeriod); got != tt.code {
+			t.Errorf("T=%d: lower-case secret gave %s", tt.unix, got)
+		}
+	}
+	if _, err := TOTPCode("not base32!", 1); err == nil {
+		t.Error("invalid secret accepted")
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	at := time.Unix(1111111111, 0) // step 37037037, code 050471
+	tests := []struct {
+		name string
+		code string
+		t    time.Time
+		step int64
+		ok   bool
+	}{
+		{"current step", "050471", at, 37037037, true},
+		{"typed with spaces", " 050 471 ", at, 37037037, true},
+		{"one step late", "050471", at.Add(totpPeriod * time.Second), 37037037, true},
+		{"one step early", "050471", at.Add(-totpPeriod * time.Second), 37037037, true},
+		{"two steps late", "050471", at.Add(2 * totpPeriod * time.Second), 0, false},
+		{"wrong code", "050472", at, 0, false},
+		{"too short", "50471", at, 0, false},
+		{"eight digits", "14050471", at, 0, false},
+	}
+	for _, tt := range tests {
+		step, ok := ValidateTOTP(rfc6238Secret, tt.code, tt.t)
+		if ok != tt.ok || step != tt.step {
+			t.Errorf("%s: got (%d, %v), want (%d, %v)", tt.name, step, ok, tt.step, tt.ok)
+		}
+	}
+	if _, ok := ValidateTOTP("not base32!", "050471", at); ok {
+		t.Error("invalid secret validated")
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	a, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := GenerateTOTPSecret()
+	if len(a) != 32 || a == b {
+		t.Errorf("got %q and %q, want two distinct 160-bit secrets", a, b)
+	}
+	if _, err := TOTPCode(a, 1); err != nil {
+		t.Errorf("generated secret does not decode: %v", err)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, c := range codes {
+		if len(c) != 11 || c[5] != '-' || seen[c] {
+			t.Errorf("bad or repeated code %q", c)
+		}
+		seen[c] = true
+		if NormalizeRecoveryCode(" "+strings.ToUpper(c[:5])+" "+c[5:]) != c {
+			t.Errorf("%q does not normalize back", c)
+		}
+	}
+}
+
+func TestSecretEncryption(t *testing.T) {
+	t.Setenv("MFA_ENCRYPTION_KEY", "mfa-key")
+	t.Setenv("SIGNING_KEY_ENCRYPTION_KEY", "")
+
+	sealed, err := EncryptSecret(rfc6238Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := EncryptSecret(rfc6238Secret)
+	if sealed == again || strings.Contains(sealed, rfc6238Secret) {
+		t.Error("sealing should be randomized and hide the secret")
+	}
+	if plain, err := DecryptSecret(sealed); err != nil || plain != rfc6238Secret {
+		t.Errorf("got %q, %v", plain, err)
+	}
+
+	tampered := []byte(sealed)
+	tampered[len(tampered)/2] ^= 1
+	for _, bad := range []string{string(tampered), "", "AAAA", "not base64!"} {
+		if _, err := DecryptSecret(bad); err == nil {
+			t.Errorf("%q opened", bad)
+		}
+	}
+
+	// Signing keys sealed with the MFA key before a dedicated key was set
+	legacy, err := EncryptSigningKey("signing-key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SIGNING_KEY_ENCRYPTION_KEY", "signing-key-key")
+	if plain, err := DecryptSigningKey(legacy); err != nil || plain != "signing-key" {
+		t.Errorf("legacy signing key: got %q, %v", plain, err)
+	}
+	current, _ := EncryptSigningKey("signing-key")
+	if _, err := DecryptSecret(current); err == nil {
+		t.Error("signing key opened with the MFA key")
+	}
+	if plain, err := DecryptSigningKey(current); err != nil || plain != "signing-key" {
+		t.Errorf("signing key: got %q, %v", plain, err)
+	}
+	t.Setenv("MFA_ENCRYPTION_KEY", "rotated")
+	if _, err := DecryptSecret(sealed); err == nil {
+		t.Error("secret opened with another key")
+	}
+}
+
+func TestMFARequiredForRole(t *testing.T) {
+	t.Setenv("MFA_REQUIRED_ROLES", "hr, Manager,")
+	for role, want := range map[string]bool{"hr": true, "manager": true, "HR": true, "employee": false, "": false} {
+		if got := MFARequiredForRole(role); got != want {
+			t.Errorf("%q: got %v, want %v", role, got, want)
+		}
+	}
+}
@@ -9,8 +9,10 @@ import Performance from './pages/Performance'
 import Goals from './pages/Goals'
 import Onboarding from './pages/Onboarding'
 import AuthCallback from './pages/AuthCallback'
+import SSOCallback from './pages/SSOCallback'
 import Unauthorized from './pages/Unauthorized'
 import Chatbot from './components/Chatbot'
+import { revokeSession } from './api/client'
 
 
 // Note: SelfAssessment and PerfReports are now consolidated into Performance component
@@ -70,6 +72,7 @@ export default function App() {
   const userRole = localStorage.getItem("role");
 
   const logout = () => {
+    revokeSession();
     localStorage.clear();
     auth0Logout({
       logoutParams: {
@@ -79,7 +82,7 @@ export default function App() {
   };
 
   // Hide navigation on login, callback, unauthorized, and dashboard pages
-  const hideNavRoutes = ["/login", "/callback", "/unauthorized", "/", "/dashboard"];
+  const hideNavRoutes = ["/login", "/callback", "/sso/callback", "/unauthorized", "/", "/dashboard"];
   const showNav = !hideNavRoutes.includes(location.pathname) && !!localStorage.getItem("token");
 
   return (
@@ -246,6 +249,7 @@ export default function App() {
                   {/* Public routes */}
                   <Route path="/login" element={<Login />} />
                   <Route path="/callback" element={<AuthCallback />} />
+                  <Route path="/sso/callback" element={<SSOCallback />} />
                   <Route path="/unauthorized" element={<Unauthorized />} />
 
                   {/* Protected routes (any logged-in user) */}
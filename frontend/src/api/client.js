@@ -30,12 +30,45 @@ client.interceptors.request.use(
     }
 );
 
+// Single in-flight refresh shared by all requests that hit a 401
+let refreshing = null;
+
+const refreshAccessToken = () => {
+    if (!refreshing) {
+        const refreshToken = localStorage.getItem('refresh_token');
+        refreshing = (refreshToken
+            ? axios.post(`${import.meta.env.VITE_API_BASE_URL}/api/auth/refresh`, { refresh_token: refreshToken })
+            : Promise.reject(new Error('no refresh token'))
+        )
+            .then(({ data }) => {
+                localStorage.setItem('token', data.token);
+                localStorage.setItem('refresh_token', data.refresh_token);
+                return data.token;
+            })
+            .finally(() => {
+                refreshing = null;
+            });
+    }
+    return refreshing;
+};
+
 // ✅ HANDLE ERRORS - DON'T REDIRECT IN CYPRESS
 client.interceptors.response.use(
     (response) => response,
-    (error) => {
+    async (error) => {
         // Don't redirect to login if in Cypress
         if (!window.Cypress && error.response?.status === 401) {
+            const original = error.config;
+            if (!original._retried && !original.url?.startsWith('/api/auth/')) {
+                original._retried = true;
+                try {
+                    const token = await refreshAccessToken();
+                    original.headers.Authorization = `Bearer ${token}`;
+                    return client(original);
+                } catch (_) {
+                    // fall through to login redirect
+                }
+            }
             localStorage.clear();
             window.location.href = '/login';
         }
@@ -43,4 +76,11 @@ client.interceptors.response.use(
     }
 );
 
+// Revoke the server-side session; best effort, the caller clears local state anyway
+export const revokeSession = () => {
+    const refreshToken = localStorage.getItem('refresh_token');
+    if (!refreshToken) return Promise.resolve();
+    return client.post('/api/auth/logout', { refresh_token: refreshToken }).catch(() => {});
+};
+
 export default client
\ No newline at end of file
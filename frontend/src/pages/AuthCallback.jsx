@@ -91,6 +91,7 @@ export default function AuthCallback() {
 
                 // Store backend-issued JWT token
                 localStorage.setItem("token", response.data.token);
+                localStorage.setItem("refresh_token", response.data.refresh_token);
                 localStorage.setItem("role", response.data.role);
                 localStorage.setItem("email", response.data.email);
                 localStorage.setItem("userID", response.data.userID);
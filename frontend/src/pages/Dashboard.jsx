@@ -1,7 +1,7 @@
 import React, { useEffect, useState } from "react";
 import { Link } from "react-router-dom";
 import { useAuth0 } from '@auth0/auth0-react';
-import client from "../api/client";
+import client, { revokeSession } from "../api/client";
 import "./Dashboard.css";
 import { Doughnut } from 'react-chartjs-2';
 import { Chart as ChartJS, ArcElement, Tooltip, Legend } from 'chart.js';
@@ -164,6 +164,7 @@ export default function Dashboard() {
     };
 
     const handleLogout = () => {
+        revokeSession();
         localStorage.clear();
         auth0Logout({
             logoutParams: {
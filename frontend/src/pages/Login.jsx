@@ -1,4 +1,4 @@
-import React, { useState } from 'react'
+import React, { useEffect, useState } from 'react'
 import { useAuth0 } from '@auth0/auth0-react'
 import { Navigate, useNavigate } from 'react-router-dom'
 import client from '../api/client'
@@ -14,6 +14,15 @@ export default function Login() {
     const [isRegister, setIsRegister] = useState(false)
     const [error, setError] = useState('')
     const [loading, setLoading] = useState(false)
+    const [ssoProviders, setSsoProviders] = useState([])
+
+    useEffect(() => {
+        client.get('/api/auth/oidc/providers')
+            .then(({ data }) => setSsoProviders(data.data || []))
+            .catch(() => setSsoProviders([]))
+        const ssoError = new URLSearchParams(window.location.search).get('sso_error')
+        if (ssoError) setError(`Single sign-on failed (${ssoError})`)
+    }, [])
 
     if (isAuthenticated && localStorage.getItem('token')) {
         return <Navigate to="/" replace />
@@ -34,6 +43,7 @@ export default function Login() {
             const { data } = await client.post('/api/auth/login', { email, password })
 
             localStorage.setItem('token', data.token)
+            localStorage.setItem('refresh_token', data.refresh_token)
             localStorage.setItem('role', data.role)
             localStorage.setItem('email', data.email)
             localStorage.setItem('userID', data.userID)
@@ -176,6 +186,17 @@ export default function Login() {
                     </svg>
                     Sign in with Google
                 </button>
+
+                {/* Generic OIDC providers (server-side code flow) */}
+                {ssoProviders.map(p => (
+                    <a
+                        key={p.id}
+                        className="btn-gradient-secondary"
+                        style={{ width: '100%', display: 'flex', justifyContent: 'center', marginTop: '10px', textDecoration: 'none' }}
+                        href={`${import.meta.env.VITE_API_BASE_URL}${p.login_url}`}>
+                        Sign in with {p.name}
+                    </a>
+                ))}
             </div>
         </div>
     )
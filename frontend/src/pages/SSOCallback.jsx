@@ -0,0 +1,44 @@
+import { useEffect, useRef, useState } from "react";
+import { useNavigate, useSearchParams } from "react-router-dom";
+import client from "../api/client";
+
+// Landing page after a server-side OIDC login: trades the one-time code for tokens.
+export default function SSOCallback() {
+    const [params] = useSearchParams();
+    const navigate = useNavigate();
+    const [error, setError] = useState(null);
+    const started = useRef(false);
+
+    useEffect(() => {
+        if (started.current) return;
+        started.current = true;
+
+        const code = params.get("code");
+        if (!code) {
+            navigate("/login", { replace: true });
+            return;
+        }
+
+        client.post("/api/auth/oidc/exchange", { code })
+            .then(({ data }) => {
+                localStorage.setItem("token", data.token);
+                localStorage.setItem("refresh_token", data.refresh_token);
+                localStorage.setItem("role", data.role);
+                localStorage.setItem("email", data.email);
+                localStorage.setItem("userID", data.userID);
+                localStorage.setItem("name", data.name || data.email);
+                navigate("/", { replace: true });
+            })
+            .catch(err => {
+                setError(err.response?.data?.error || "Sign-in failed");
+                setTimeout(() => navigate("/login", { replace: true }), 3000);
+            });
+    }, [params, navigate]);
+
+    return (
+        <div style={{ textAlign: "center", marginTop: "100px" }}>
+            <h2>{error ? "Authentication Error" : "Completing sign-in..."}</h2>
+            {error && <p style={{ color: "#dc3545" }}>{error}</p>}
+        </div>
+    );
+}